@@ -0,0 +1,11 @@
+package main
+
+import (
+	"Learn_Go/webook/internal/job"
+	"github.com/gin-gonic/gin"
+)
+
+type App struct {
+	server    *gin.Engine
+	scheduler *job.Scheduler
+}
@@ -0,0 +1,8 @@
+package domain
+
+// AccountExport 用户导出的个人数据
+type AccountExport struct {
+	User     User
+	Bindings []OAuthIdentity
+	Articles []Article
+}
@@ -1,10 +1,39 @@
 package domain
 
+import "time"
+
 type Article struct {
 	Id      int64
 	Title   string
 	Content string
 	Author  Author
+	Status  ArticleStatus
+	Ctime   time.Time
+	Utime   time.Time
+}
+
+// ArticleStatus 取值和 dao 里面的一致
+type ArticleStatus uint8
+
+const (
+	ArticleStatusNormal ArticleStatus = iota
+	// ArticleStatusTakenDown 被版主下架
+	ArticleStatusTakenDown
+	// ArticleStatusWithdrawn 作者注销账号之后撤回
+	ArticleStatusWithdrawn
+)
+
+func (s ArticleStatus) String() string {
+	switch s {
+	case ArticleStatusNormal:
+		return "normal"
+	case ArticleStatusTakenDown:
+		return "taken_down"
+	case ArticleStatusWithdrawn:
+		return "withdrawn"
+	default:
+		return "unknown"
+	}
 }
 
 type Author struct {
@@ -0,0 +1,8 @@
+package domain
+
+// Captcha 图形验证码，答案只保存在服务端
+
+type Captcha struct {
+	Id    string
+	Image []byte // PNG 格式的图片
+}
@@ -0,0 +1,10 @@
+package domain
+
+// Image 上传之后的图片
+type Image struct {
+	URL string
+	// ThumbURL 缩略图，列表页和编辑器预览用
+	ThumbURL string
+	Width    int
+	Height   int
+}
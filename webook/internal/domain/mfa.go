@@ -0,0 +1,26 @@
+package domain
+
+// TOTP 用户绑定的验证器应用
+type TOTP struct {
+	Uid    int64
+	Secret string
+	// Enabled 用户确认之前只是保存了密钥，登录的时候不需要验证
+	Enabled bool
+	// LastCounter 最近一次验证通过的周期，同一个周期的验证码不能用两次
+	LastCounter int64
+}
+
+// TOTPEnrollment 绑定验证器应用的时候返回给用户的信息
+type TOTPEnrollment struct {
+	Secret string
+	// URI otpauth:// 开头，验证器应用扫码添加
+	URI string
+	// QRCode URI 的二维码，PNG 格式
+	QRCode []byte
+}
+
+// RecoveryCode 恢复码，只保存哈希
+type RecoveryCode struct {
+	Id   int64
+	Hash string
+}
@@ -0,0 +1,32 @@
+package domain
+
+// OAuthIdentity 第三方登录拿到的用户身份
+
+type OAuthIdentity struct {
+	// Provider 第三方的名字，比如 wechat、github，和路由 /oauth2/:provider 一致
+	Provider string
+	// Subject 用户在第三方的唯一标识，微信是 openid，OIDC 是 sub
+	Subject string
+	// UnionId 微信同一个开放平台下的多个应用共用的标识，其它第三方为空
+	UnionId string
+
+	// 下面这些只在第一次登录的时候用来填充用户资料
+	Email    string
+	Nickname string
+	Avatar   string
+}
+
+// OAuthState 跳转到第三方之前保存的信息，回调的时候凭 state 取回来，只能取一次
+type OAuthState struct {
+	Provider string
+	// Verifier PKCE 的 code_verifier，换 token 的时候要用
+	Verifier string
+	// Browser 同时写在浏览器 cookie 里面的随机值，回调的时候必须一致
+	// 防止攻击者把自己的 state 和授权码塞给别人，让别人登录到攻击者的账号上
+	Browser string
+	// BindUid 不为 0 的时候是已经登录的用户在绑定第三方账号，而不是第三方登录
+	BindUid int64
+	Merge   bool
+	// Redirect 登录成功之后前端跳转的地址，已经校验过
+	Redirect string
+}
@@ -0,0 +1,108 @@
+package domain
+
+import "time"
+
+// Visibility 个人信息谁可以看到，值越大越公开
+type Visibility uint8
+
+const (
+	// VisibilityDefault 零值，表示用户没有设置过，用 DefaultPrivacy 里面的
+	VisibilityDefault Visibility = iota
+	// VisibilitySelf 只有自己
+	VisibilitySelf
+	// VisibilityFollowers 自己和关注了自己的人
+	VisibilityFollowers
+	// VisibilityPublic 所有人，包括没有登录的
+	VisibilityPublic
+)
+
+func (v Visibility) String() string {
+	switch v {
+	case VisibilitySelf:
+		return "self"
+	case VisibilityFollowers:
+		return "followers"
+	case VisibilityPublic:
+		return "public"
+	default:
+		return "default"
+	}
+}
+
+// ParseVisibility 前端传过来的 self、followers 或者 public
+func ParseVisibility(s string) (Visibility, bool) {
+	switch s {
+	case "self":
+		return VisibilitySelf, true
+	case "followers":
+		return VisibilityFollowers, true
+	case "public":
+		return VisibilityPublic, true
+	default:
+		return VisibilityDefault, false
+	}
+}
+
+// PrivacySettings 主页上面各个字段的可见范围，昵称和头像总是公开的
+type PrivacySettings struct {
+	Email    Visibility
+	Phone    Visibility
+	Birthday Visibility
+	AboutMe  Visibility
+}
+
+// DefaultPrivacy 联系方式默认只有自己可以看到
+var DefaultPrivacy = PrivacySettings{
+	Email:    VisibilitySelf,
+	Phone:    VisibilitySelf,
+	Birthday: VisibilityFollowers,
+	AboutMe:  VisibilityPublic,
+}
+
+// WithDefaults 没有设置过的字段用 DefaultPrivacy 里面的
+func (p PrivacySettings) WithDefaults() PrivacySettings {
+	fill := func(v *Visibility, def Visibility) {
+		if *v == VisibilityDefault {
+			*v = def
+		}
+	}
+	fill(&p.Email, DefaultPrivacy.Email)
+	fill(&p.Phone, DefaultPrivacy.Phone)
+	fill(&p.Birthday, DefaultPrivacy.Birthday)
+	fill(&p.AboutMe, DefaultPrivacy.AboutMe)
+	return p
+}
+
+// Relation 看主页的人和主页主人的关系
+type Relation uint8
+
+const (
+	RelationStranger Relation = iota
+	RelationFollower
+	RelationSelf
+)
+
+// Visible v 是 WithDefaults 之后的可见范围
+func (r Relation) Visible(v Visibility) bool {
+	switch r {
+	case RelationSelf:
+		return true
+	case RelationFollower:
+		return v >= VisibilityFollowers
+	default:
+		return v == VisibilityPublic
+	}
+}
+
+// PublicProfile 别人看到的主页，看不到的字段是零值
+// 不是自己看的时候，手机号和邮箱是打码的
+type PublicProfile struct {
+	Id       int64
+	Nickname string
+	Avatar   string
+	Email    string
+	Phone    string
+	Birthday time.Time
+	AboutMe  string
+	Ctime    time.Time
+}
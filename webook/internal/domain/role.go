@@ -0,0 +1,23 @@
+package domain
+
+// 角色，路由按照角色授权
+const (
+	// RoleUser 所有用户都有，不需要保存
+	RoleUser = "user"
+	// RoleAuthor 作者
+	RoleAuthor = "author"
+	// RoleModerator 版主，可以查询用户、封禁用户、下架文章
+	RoleModerator = "moderator"
+	// RoleAdmin 管理员，在版主的基础上还可以分配角色
+	RoleAdmin = "admin"
+)
+
+// ValidRole 是不是可以分配的角色，RoleUser 所有人都有，不能分配也不能收回
+func ValidRole(role string) bool {
+	switch role {
+	case RoleAuthor, RoleModerator, RoleAdmin:
+		return true
+	default:
+		return false
+	}
+}
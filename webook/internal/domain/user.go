@@ -5,13 +5,49 @@ import (
 )
 
 type User struct {
-	Id         int64
-	Email      string
-	Password   string
-	NickName   string
-	BirthDay   time.Time
-	AboutMe    string
-	Phone      string
-	Ctime      time.Time
-	WechatInfo WechatInfo
+	Id       int64
+	Email    string
+	Password string
+	NickName string
+	// Avatar 头像的地址
+	Avatar   string
+	BirthDay time.Time
+	AboutMe  string
+	Phone    string
+	Ctime    time.Time
+	Status   UserStatus
+	// SuspendedUntil 封禁到什么时候，零值表示永久封禁
+	SuspendedUntil time.Time
+	// DeleteAt 申请注销之后，到了这个时间才真正注销，零值表示没有申请
+	DeleteAt time.Time
+	// Privacy 主页上面各个字段的可见范围，没有设置过的是 VisibilityDefault
+	Privacy PrivacySettings
 }
+
+// Suspended 是否处于封禁期，到期之后不需要改状态，直接当作正常用户
+func (u User) Suspended(now time.Time) bool {
+	if u.Status != UserStatusSuspended {
+		return false
+	}
+	return u.SuspendedUntil.IsZero() || u.SuspendedUntil.After(now)
+}
+
+// BindingType 可以绑定和解绑的登录方式，除了手机号和邮箱，其它的是第三方登录的名字，比如 wechat、github
+type BindingType string
+
+const (
+	BindingPhone BindingType = "phone"
+	BindingEmail BindingType = "email"
+)
+
+// UserStatus 账号状态
+type UserStatus uint8
+
+const (
+	// UserStatusActive 零值就是正常状态，已有的数据不需要迁移
+	UserStatusActive UserStatus = iota
+	// UserStatusSuspended 被封禁，可能是永久的，也可能是一段时间
+	UserStatusSuspended
+	// UserStatusDeleted 已经注销
+	UserStatusDeleted
+)
@@ -1,8 +0,0 @@
-package domain
-
-// 通过Code授权返回的信息
-
-type WechatInfo struct {
-	UnionId string
-	OpenId  string
-}
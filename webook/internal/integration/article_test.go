@@ -4,6 +4,7 @@ import (
 	"Learn_Go/webook/internal/integration/startup"
 	"Learn_Go/webook/internal/repository/dao"
 	ijwt "Learn_Go/webook/internal/web/jwt"
+	"Learn_Go/webook/pkg/ginx/authz"
 	"bytes"
 	"encoding/json"
 	"github.com/gin-gonic/gin"
@@ -21,7 +22,7 @@ func TestArticleHandler_Edit(t *testing.T) {
 	server.Use(func(ctx *gin.Context) {
 		ctx.Set("user", ijwt.UserClaims{Uid: 123})
 	})
-	artHdl.RegisterRouters(server)
+	artHdl.RegisterRouters(authz.NewRouter(server, authz.NewRegistry()))
 	db := startup.InitDB()
 	testCases := []struct {
 		name string
@@ -0,0 +1,19 @@
+package startup
+
+import (
+	"Learn_Go/webook/internal/repository"
+	"Learn_Go/webook/internal/service"
+	"Learn_Go/webook/internal/service/oauth2"
+	"Learn_Go/webook/internal/service/oauth2/wechat"
+	"Learn_Go/webook/pkg/logger"
+	"net/http"
+)
+
+func InitOAuth2Providers(l logger.LoggerV1) []oauth2.Provider {
+
+	return []oauth2.Provider{wechat.NewWechatService(wechat.Config{}, http.DefaultClient, l)}
+}
+
+func InitOAuthStateService(repo repository.OAuthStateRepository) service.OAuthStateService {
+	return service.NewOAuthStateService(repo, nil)
+}
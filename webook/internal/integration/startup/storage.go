@@ -0,0 +1,12 @@
+package startup
+
+import (
+	"Learn_Go/webook/internal/service/storage"
+	"Learn_Go/webook/internal/service/storage/localstorage"
+	"os"
+	"path/filepath"
+)
+
+func InitStorage() storage.Storage {
+	return localstorage.NewStorage(filepath.Join(os.TempDir(), "webook-files"), "/files")
+}
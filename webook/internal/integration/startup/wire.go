@@ -8,8 +8,8 @@ import (
 	"Learn_Go/webook/internal/repository/dao"
 	"Learn_Go/webook/internal/service"
 	"Learn_Go/webook/internal/web"
-	ijwt "Learn_Go/webook/internal/web/jwt"
 	"Learn_Go/webook/ioc"
+	"Learn_Go/webook/pkg/ginx/authz"
 	"github.com/gin-gonic/gin"
 	"github.com/google/wire"
 )
@@ -21,17 +21,24 @@ func InitWebServer() *gin.Engine {
 		// 第三方依赖
 		thirdParty,
 		// dao
-		dao.NewGORMUserDao, dao.NewArticleGORMDAO,
+		dao.NewGORMUserDao, dao.NewArticleGORMDAO, dao.NewGORMRoleDAO, dao.NewGORMMFADAO,
 		// cache
-		cache.NewRedisUserCache, cache.NewRedisCodeCache,
+		cache.NewRedisUserCache, cache.NewRedisCodeCache, cache.NewRedisCaptchaCache, cache.NewRedisRoleCache, cache.NewRedisOAuthStateCache,
 		// repository
-		repository.NewCodeRepository, repository.NewCachedUserRepository, repository.NewCachedArticleRepository,
+		repository.NewCodeRepository, repository.NewCaptchaRepository, repository.NewCachedUserRepository, repository.NewCachedArticleRepository,
+		repository.NewCachedRoleRepository, repository.NewMFARepository, repository.NewOAuthStateRepository,
 		// service
-		ioc.InitSmsService,
-		service.NewuserService, service.NewcodeService, service.NewArticleService, InitWechatService,
+		ioc.InitSmsService, ioc.InitEmailService, ioc.InitVoiceService,
+		service.NewuserService, service.NewcodeService, service.NewArticleService, service.NewCaptchaService, InitOAuth2Providers,
+		service.NewRoleService, service.NewMFAService, InitOAuthStateService, service.NewAccountService,
+		InitStorage, service.NewImageService, service.NewNoopFollowService, service.NewProfileService,
 		// handler
-		web.NewUserHandler, web.NewArticleHandler, web.NewOAuth2WechatHandler, ijwt.NewRedisJWTHandler,
+		web.NewUserHandler, web.NewArticleHandler, web.NewOAuth2Handler, web.NewCaptchaHandler, web.NewJWKSHandler,
+		web.NewAdminHandler, web.NewAccountHandler, web.NewUploadHandler,
+		web.NewProfileHandler,
+		ioc.InitJWTKeyManager, ioc.InitJWTHandler,
 
+		authz.NewRegistry,
 		ioc.InitGinMiddleWares,
 		ioc.InitWebServer,
 	)
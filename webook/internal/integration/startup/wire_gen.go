@@ -12,8 +12,8 @@ import (
 	"Learn_Go/webook/internal/repository/dao"
 	"Learn_Go/webook/internal/service"
 	"Learn_Go/webook/internal/web"
-	"Learn_Go/webook/internal/web/jwt"
 	"Learn_Go/webook/ioc"
+	"Learn_Go/webook/pkg/ginx/authz"
 	"github.com/gin-gonic/gin"
 	"github.com/google/wire"
 )
@@ -22,10 +22,16 @@ import (
 
 func InitWebServer() *gin.Engine {
 	cmdable := InitRedis()
-	handler := jwt.NewRedisJWTHandler(cmdable)
-	loggerV1 := InitLogger()
-	v := ioc.InitGinMiddleWares(cmdable, handler, loggerV1)
+	keyManager := ioc.InitJWTKeyManager()
 	db := InitDB()
+	roleDAO := dao.NewGORMRoleDAO(db)
+	roleCache := cache.NewRedisRoleCache(cmdable)
+	roleRepository := repository.NewCachedRoleRepository(roleDAO, roleCache)
+	roleService := service.NewRoleService(roleRepository)
+	handler := ioc.InitJWTHandler(cmdable, keyManager, roleService)
+	registry := authz.NewRegistry()
+	loggerV1 := InitLogger()
+	v := ioc.InitGinMiddleWares(cmdable, handler, registry, roleService, loggerV1)
 	userDao := dao.NewGORMUserDao(db)
 	userCache := cache.NewRedisUserCache(cmdable)
 	userRepository := repository.NewCachedUserRepository(userDao, userCache)
@@ -33,15 +39,37 @@ func InitWebServer() *gin.Engine {
 	codeCache := cache.NewRedisCodeCache(cmdable)
 	codeRepository := repository.NewCodeRepository(codeCache)
 	smsService := ioc.InitSmsService()
-	codeService := service.NewcodeService(codeRepository, smsService)
-	userHandler := web.NewUserHandler(userService, codeService, handler)
-	wechatService := InitWechatService(loggerV1)
-	oAuth2WechatHandler := web.NewOAuth2WechatHandler(wechatService, userService, handler)
+	emailService := ioc.InitEmailService()
+	voiceService := ioc.InitVoiceService()
+	codeService := service.NewcodeService(codeRepository, smsService, emailService, voiceService)
+	captchaCache := cache.NewRedisCaptchaCache(cmdable)
+	captchaRepository := repository.NewCaptchaRepository(captchaCache)
+	captchaService := service.NewCaptchaService(captchaRepository)
+	mfadao := dao.NewGORMMFADAO(db)
+	mfaRepository := repository.NewMFARepository(mfadao)
+	mfaService := service.NewMFAService(mfaRepository)
+	userHandler := web.NewUserHandler(userService, codeService, captchaService, mfaService, handler)
+	v2 := InitOAuth2Providers(loggerV1)
+	oAuthStateCache := cache.NewRedisOAuthStateCache(cmdable)
+	oAuthStateRepository := repository.NewOAuthStateRepository(oAuthStateCache)
+	oAuthStateService := InitOAuthStateService(oAuthStateRepository)
+	oAuth2Handler := web.NewOAuth2Handler(v2, userService, oAuthStateService, handler)
 	articleDAO := dao.NewArticleGORMDAO(db)
 	articleRepository := repository.NewCachedArticleRepository(articleDAO)
 	articleService := service.NewArticleService(articleRepository)
 	articleHandler := web.NewArticleHandler(articleService, loggerV1)
-	engine := ioc.InitWebServer(v, userHandler, oAuth2WechatHandler, articleHandler)
+	captchaHandler := web.NewCaptchaHandler(captchaService)
+	jwksHandler := web.NewJWKSHandler(keyManager)
+	adminHandler := web.NewAdminHandler(userService, roleService, articleService, handler, loggerV1)
+	accountService := service.NewAccountService(userRepository, articleRepository)
+	accountHandler := web.NewAccountHandler(accountService, handler, loggerV1)
+	storage := InitStorage()
+	imageService := service.NewImageService(storage, userRepository)
+	uploadHandler := web.NewUploadHandler(imageService, storage, loggerV1)
+	followService := service.NewNoopFollowService()
+	profileService := service.NewProfileService(userRepository, followService)
+	profileHandler := web.NewProfileHandler(profileService, loggerV1)
+	engine := ioc.InitWebServer(v, registry, userHandler, oAuth2Handler, articleHandler, captchaHandler, jwksHandler, adminHandler, accountHandler, uploadHandler, profileHandler)
 	return engine
 }
 
@@ -0,0 +1,92 @@
+package integration
+
+import (
+	"Learn_Go/webook/internal/repository"
+	"Learn_Go/webook/internal/repository/dao"
+	"Learn_Go/webook/internal/service"
+	"Learn_Go/webook/internal/web"
+	ijwt "Learn_Go/webook/internal/web/jwt"
+	"Learn_Go/webook/ioc"
+	"Learn_Go/webook/pkg/ginx/authz"
+	"Learn_Go/webook/pkg/logger"
+	"bytes"
+	"encoding/json"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// 修改资料之后马上查询，本实例和其它实例都要拿到新的数据
+// 用 SQLite 和 miniredis，不依赖外部的 MySQL 和 Redis
+func TestUserHandler_EditThenProfile(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "webook.db")), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, dao.InitTables(db))
+	mr := miniredis.RunT(t)
+	const uid = 123
+	require.NoError(t, db.Create(&dao.User{Id: uid, Nickname: "旧昵称", AboutMe: "旧的自我介绍"}).Error)
+
+	// 两个实例共用数据库和 Redis，各自有自己的本地缓存
+	newServer := func() *gin.Engine {
+		rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		repo := repository.NewCachedUserRepository(dao.NewGORMUserDao(db), ioc.InitUserCache(rdb, logger.NewNopLogger()))
+		hdl := web.NewUserHandler(service.NewuserService(repo), nil, nil, nil, nil)
+		server := gin.New()
+		server.Use(func(ctx *gin.Context) {
+			ctx.Set("user", ijwt.UserClaims{Uid: uid})
+		})
+		hdl.RegisterRouters(authz.NewRouter(server, authz.NewRegistry()))
+		return server
+	}
+	a, b := newServer(), newServer()
+
+	type Profile struct {
+		Nickname string `json:"nickname"`
+		AboutMe  string `json:"aboutMe"`
+		Birthday string `json:"birthday"`
+	}
+	profile := func(server *gin.Engine) Profile {
+		req := httptest.NewRequest(http.MethodGet, "/users/profile", nil)
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, req)
+		require.Equal(t, http.StatusOK, recorder.Code)
+		var p Profile
+		require.NoError(t, json.NewDecoder(recorder.Body).Decode(&p))
+		return p
+	}
+
+	// 先查一次，两个实例的本地缓存和 Redis 里面都是旧数据
+	assert.Equal(t, "旧昵称", profile(a).Nickname)
+	assert.Equal(t, "旧昵称", profile(b).Nickname)
+
+	req := httptest.NewRequest(http.MethodPost, "/users/edit",
+		bytes.NewReader([]byte(`{"nickname":"新昵称","birthday":"2000-01-02","aboutMe":"新的自我介绍"}`)))
+	req.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	a.ServeHTTP(recorder, req)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var res web.Result
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&res))
+	require.Equal(t, 0, res.Code)
+
+	want := Profile{
+		Nickname: "新昵称",
+		AboutMe:  "新的自我介绍",
+		Birthday: "2000-01-02",
+	}
+	// 修改的实例马上就能看到
+	assert.Equal(t, want, profile(a))
+	// 其它实例收到 pub/sub 的通知之后删掉本地缓存
+	assert.Eventually(t, func() bool {
+		return profile(b) == want
+	}, time.Second, time.Millisecond*20)
+}
@@ -0,0 +1,58 @@
+package job
+
+import (
+	"Learn_Go/webook/internal/service"
+	"Learn_Go/webook/pkg/logger"
+	"context"
+	"time"
+)
+
+// SessionRevoker 注销之后让用户的设备全部下线
+type SessionRevoker interface {
+	RevokeOtherSessions(ctx context.Context, uid int64, keepSsid string) error
+}
+
+// AccountDeletionJob 处理注销宽限期已经过了的用户
+type AccountDeletionJob struct {
+	svc      service.AccountService
+	sessions SessionRevoker
+	l        logger.LoggerV1
+	// batchSize 每次查询多少个用户
+	batchSize int
+	now       func() time.Time
+}
+
+func NewAccountDeletionJob(svc service.AccountService, sessions SessionRevoker, l logger.LoggerV1) *AccountDeletionJob {
+	return &AccountDeletionJob{
+		svc:       svc,
+		sessions:  sessions,
+		l:         l,
+		batchSize: 100,
+		now:       time.Now,
+	}
+}
+
+func (j *AccountDeletionJob) Name() string {
+	return "account_deletion"
+}
+
+func (j *AccountDeletionJob) Run(ctx context.Context) error {
+	now := j.now()
+	for {
+		uids, err := j.svc.PurgeDue(ctx, now, j.batchSize)
+		for _, uid := range uids {
+			// 申请注销的时候已经下线过一次了，这里是防止中间有漏网的
+			if er := j.sessions.RevokeOtherSessions(ctx, uid, ""); er != nil {
+				j.l.Error("注销之后下线设备失败", logger.Int64("uid", uid), logger.Error(er))
+			}
+			j.l.Info("账号已注销", logger.Int64("uid", uid))
+		}
+		if err != nil {
+			return err
+		}
+		// 处理过的用户不会再被查出来，所以不需要翻页
+		if len(uids) < j.batchSize {
+			return nil
+		}
+	}
+}
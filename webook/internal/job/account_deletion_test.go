@@ -0,0 +1,78 @@
+package job
+
+import (
+	"Learn_Go/webook/internal/service"
+	svcmocks "Learn_Go/webook/internal/service/mocks"
+	"Learn_Go/webook/pkg/logger"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"testing"
+	"time"
+)
+
+type fakeRevoker struct {
+	uids []int64
+}
+
+func (f *fakeRevoker) RevokeOtherSessions(ctx context.Context, uid int64, keepSsid string) error {
+	f.uids = append(f.uids, uid)
+	return nil
+}
+
+func TestAccountDeletionJob_Run(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) service.AccountService
+
+		wantRevoked []int64
+		wantErr     error
+	}{
+		{
+			name: "一批就处理完",
+			mock: func(ctrl *gomock.Controller) service.AccountService {
+				svc := svcmocks.NewMockAccountService(ctrl)
+				svc.EXPECT().PurgeDue(gomock.Any(), now, 2).Return([]int64{1}, nil)
+				return svc
+			},
+			wantRevoked: []int64{1},
+		},
+		{
+			name: "满了一批要接着处理",
+			mock: func(ctrl *gomock.Controller) service.AccountService {
+				svc := svcmocks.NewMockAccountService(ctrl)
+				gomock.InOrder(
+					svc.EXPECT().PurgeDue(gomock.Any(), now, 2).Return([]int64{1, 2}, nil),
+					svc.EXPECT().PurgeDue(gomock.Any(), now, 2).Return([]int64{}, nil),
+				)
+				return svc
+			},
+			wantRevoked: []int64{1, 2},
+		},
+		{
+			name: "出错的时候已经处理的也要下线",
+			mock: func(ctrl *gomock.Controller) service.AccountService {
+				svc := svcmocks.NewMockAccountService(ctrl)
+				svc.EXPECT().PurgeDue(gomock.Any(), now, 2).Return([]int64{1}, errors.New("db 错误"))
+				return svc
+			},
+			wantRevoked: []int64{1},
+			wantErr:     errors.New("db 错误"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			revoker := &fakeRevoker{}
+			j := NewAccountDeletionJob(tc.mock(ctrl), revoker, logger.NewNopLogger())
+			j.batchSize = 2
+			j.now = func() time.Time { return now }
+			err := j.Run(context.Background())
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantRevoked, revoker.uids)
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package job
+
+import (
+	"Learn_Go/webook/pkg/logger"
+	"context"
+	"sync"
+	"time"
+)
+
+// Scheduler 每个任务一个 goroutine，按照固定的间隔执行
+// 上一次还没有执行完的时候不会开始下一次
+type Scheduler struct {
+	l     logger.LoggerV1
+	tasks []task
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+type task struct {
+	job      Job
+	interval time.Duration
+	// timeout 单次执行的超时时间
+	timeout time.Duration
+}
+
+func NewScheduler(l logger.LoggerV1) *Scheduler {
+	return &Scheduler{
+		l: l,
+	}
+}
+
+// Add 要在 Start 之前调用
+func (s *Scheduler) Add(j Job, interval, timeout time.Duration) {
+	s.tasks = append(s.tasks, task{job: j, interval: interval, timeout: timeout})
+}
+
+func (s *Scheduler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	for _, t := range s.tasks {
+		s.wg.Add(1)
+		go func(t task) {
+			defer s.wg.Done()
+			s.loop(ctx, t)
+		}(t)
+	}
+}
+
+// Stop 等待正在执行的任务结束
+func (s *Scheduler) Stop() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, t task) {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.run(ctx, t)
+		}
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context, t task) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	start := time.Now()
+	err := t.job.Run(ctx)
+	if err != nil {
+		s.l.Error("定时任务执行失败", logger.String("job", t.job.Name()), logger.Error(err))
+		return
+	}
+	s.l.Debug("定时任务执行完毕", logger.String("job", t.job.Name()),
+		logger.Int64("cost_ms", time.Since(start).Milliseconds()))
+}
@@ -0,0 +1,10 @@
+// Package job 定时任务
+package job
+
+import "context"
+
+// Job 定时执行的任务，Run 要能重复执行，多个实例同时执行也不能出错
+type Job interface {
+	Name() string
+	Run(ctx context.Context) error
+}
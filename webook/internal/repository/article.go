@@ -5,14 +5,20 @@ import (
 	"Learn_Go/webook/internal/repository/dao"
 	"context"
 	"gorm.io/gorm"
+	"time"
 )
 
 type ArticleRepository interface {
 	Create(ctx context.Context, art domain.Article) (int64, error)
 	Update(ctx context.Context, art domain.Article) error
 	Sync(ctx context.Context, art domain.Article) (int64, error)
+	TakeDown(ctx context.Context, id int64) error
+	// ListByAuthor 游标分页，maxId 是上一页最后一篇文章的 id，第一页传 0
+	ListByAuthor(ctx context.Context, authorId int64, maxId int64, limit int) ([]domain.Article, error)
 }
 
+var ErrArticleNotFound = dao.ErrRecordNotFound
+
 type CachedArticleRepository struct {
 	dao       dao.ArticleDAO
 	readerDAO dao.ArticleReaderDAO
@@ -98,6 +104,22 @@ func NewCachedArticleRepositoryV2(authorDAO dao.ArticleAuthorDAO, readerDAO dao.
 	}
 }
 
+func (c *CachedArticleRepository) TakeDown(ctx context.Context, id int64) error {
+	return c.dao.TakeDown(ctx, id)
+}
+
+func (c *CachedArticleRepository) ListByAuthor(ctx context.Context, authorId int64, maxId int64, limit int) ([]domain.Article, error) {
+	arts, err := c.dao.ListByAuthor(ctx, authorId, maxId, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.Article, 0, len(arts))
+	for _, art := range arts {
+		res = append(res, c.toDomain(art))
+	}
+	return res, nil
+}
+
 func (c *CachedArticleRepository) Update(ctx context.Context, art domain.Article) error {
 	return c.dao.UpdateById(ctx, c.toEntity(art))
 }
@@ -115,3 +137,17 @@ func (c *CachedArticleRepository) toEntity(art domain.Article) dao.Article {
 	}
 
 }
+
+func (c *CachedArticleRepository) toDomain(art dao.Article) domain.Article {
+	return domain.Article{
+		Id:      art.Id,
+		Title:   art.Title,
+		Content: art.Content,
+		Author: domain.Author{
+			Id: art.AuthorId,
+		},
+		Status: domain.ArticleStatus(art.Status),
+		Ctime:  time.UnixMilli(art.Ctime),
+		Utime:  time.UnixMilli(art.Utime),
+	}
+}
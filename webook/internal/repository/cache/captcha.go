@@ -0,0 +1,116 @@
+package cache
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+var (
+	//go:embed lua/verify_captcha.lua
+	luaVerifyCaptcha string
+	//go:embed lua/incr_attempt.lua
+	luaIncrAttempt string
+
+	ErrCaptchaNotFound = errors.New("图形验证码不存在或已过期")
+)
+
+type CaptchaCache interface {
+	// SetAnswer 保存图形验证码的答案
+	SetAnswer(ctx context.Context, id, answer string) error
+	// VerifyAnswer 校验答案，无论对错，答案都只能校验一次
+	VerifyAnswer(ctx context.Context, id, answer string) (bool, error)
+	// SetTicket 保存通过图形验证码之后颁发的票据
+	SetTicket(ctx context.Context, biz, ticket string) error
+	// ConsumeTicket 使用票据，票据只能使用一次
+	ConsumeTicket(ctx context.Context, biz, ticket string) (bool, error)
+	// IncrAttempt 在统计窗口内增加一次尝试次数，返回增加后的次数
+	IncrAttempt(ctx context.Context, biz, key string) (int64, error)
+	// Attempts 返回统计窗口内的尝试次数
+	Attempts(ctx context.Context, biz, key string) (int64, error)
+	// ResetAttempt 清空尝试次数
+	ResetAttempt(ctx context.Context, biz, key string) error
+}
+
+type RedisCaptchaCache struct {
+	cmd redis.Cmdable
+	// 答案的过期时间
+	answerExpiration time.Duration
+	// 票据的过期时间
+	ticketExpiration time.Duration
+	// 统计尝试次数的窗口
+	attemptWindow time.Duration
+}
+
+func NewRedisCaptchaCache(cmd redis.Cmdable) CaptchaCache {
+	return &RedisCaptchaCache{
+		cmd:              cmd,
+		answerExpiration: time.Minute * 5,
+		ticketExpiration: time.Minute * 5,
+		attemptWindow:    time.Minute * 15,
+	}
+}
+
+func (c *RedisCaptchaCache) SetAnswer(ctx context.Context, id, answer string) error {
+	return c.cmd.Set(ctx, c.answerKey(id), answer, c.answerExpiration).Err()
+}
+
+func (c *RedisCaptchaCache) VerifyAnswer(ctx context.Context, id, answer string) (bool, error) {
+	res, err := c.cmd.Eval(ctx, luaVerifyCaptcha, []string{c.answerKey(id)}, answer).Int()
+	if err != nil {
+		return false, err
+	}
+	switch res {
+	case -1:
+		return false, ErrCaptchaNotFound
+	case -2:
+		return false, nil
+	default:
+		return true, nil
+	}
+}
+
+func (c *RedisCaptchaCache) SetTicket(ctx context.Context, biz, ticket string) error {
+	return c.cmd.Set(ctx, c.ticketKey(biz, ticket), "", c.ticketExpiration).Err()
+}
+
+func (c *RedisCaptchaCache) ConsumeTicket(ctx context.Context, biz, ticket string) (bool, error) {
+	// DEL 本身是原子的，删除成功就代表这个票据是第一次使用
+	cnt, err := c.cmd.Del(ctx, c.ticketKey(biz, ticket)).Result()
+	if err != nil {
+		return false, err
+	}
+	return cnt > 0, nil
+}
+
+func (c *RedisCaptchaCache) IncrAttempt(ctx context.Context, biz, key string) (int64, error) {
+	return c.cmd.Eval(ctx, luaIncrAttempt, []string{c.attemptKey(biz, key)},
+		int64(c.attemptWindow.Seconds())).Int64()
+}
+
+func (c *RedisCaptchaCache) Attempts(ctx context.Context, biz, key string) (int64, error) {
+	cnt, err := c.cmd.Get(ctx, c.attemptKey(biz, key)).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return cnt, err
+}
+
+func (c *RedisCaptchaCache) ResetAttempt(ctx context.Context, biz, key string) error {
+	return c.cmd.Del(ctx, c.attemptKey(biz, key)).Err()
+}
+
+func (c *RedisCaptchaCache) answerKey(id string) string {
+	return fmt.Sprintf("captcha:answer:%s", id)
+}
+
+func (c *RedisCaptchaCache) ticketKey(biz, ticket string) string {
+	return fmt.Sprintf("captcha:ticket:%s:%s", biz, ticket)
+}
+
+func (c *RedisCaptchaCache) attemptKey(biz, key string) string {
+	return fmt.Sprintf("captcha:attempt:%s:%s", biz, key)
+}
@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// 同一套用例跑所有的 CodeCache 实现，保证本地实现和 Redis 实现的语义一致
+
+// codeCacheFactory 返回被测的 CodeCache，以及一个让时间往前走的方法
+type codeCacheFactory func(t *testing.T) (CodeCache, func(d time.Duration))
+
+func TestCodeCache_Conformance(t *testing.T) {
+	factories := map[string]codeCacheFactory{
+		"redis": func(t *testing.T) (CodeCache, func(d time.Duration)) {
+			mr := miniredis.RunT(t)
+			rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			return NewRedisCodeCache(rdb), mr.FastForward
+		},
+		"local": func(t *testing.T) (CodeCache, func(d time.Duration)) {
+			now := time.Now()
+			c := NewLocalCodeCache(100).(*LocalCodeCache)
+			c.now = func() time.Time {
+				return now
+			}
+			return c, func(d time.Duration) {
+				now = now.Add(d)
+			}
+		},
+	}
+	for name, factory := range factories {
+		t.Run(name, func(t *testing.T) {
+			testCodeCacheConformance(t, factory)
+		})
+	}
+}
+
+func testCodeCacheConformance(t *testing.T, factory codeCacheFactory) {
+	const (
+		biz   = "login"
+		phone = "15012345678"
+	)
+	ctx := context.Background()
+
+	t.Run("验证成功，验证码只能用一次", func(t *testing.T) {
+		c, _ := factory(t)
+		require.NoError(t, c.Set(ctx, biz, phone, "123456"))
+		ok, err := c.Verify(ctx, biz, phone, "123456")
+		require.NoError(t, err)
+		assert.True(t, ok)
+		_, err = c.Verify(ctx, biz, phone, "123456")
+		assert.Equal(t, ErrCodeVerifyTooMany, err)
+	})
+
+	t.Run("一分钟内不能重发", func(t *testing.T) {
+		c, forward := factory(t)
+		require.NoError(t, c.Set(ctx, biz, phone, "123456"))
+		forward(time.Second * 30)
+		assert.Equal(t, ErrCodeSendTooMany, c.Set(ctx, biz, phone, "654321"))
+		forward(time.Second * 31)
+		require.NoError(t, c.Set(ctx, biz, phone, "654321"))
+		// 重发之后，旧的验证码失效
+		ok, err := c.Verify(ctx, biz, phone, "123456")
+		require.NoError(t, err)
+		assert.False(t, ok)
+		ok, err = c.Verify(ctx, biz, phone, "654321")
+		require.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("最多验证三次", func(t *testing.T) {
+		c, _ := factory(t)
+		require.NoError(t, c.Set(ctx, biz, phone, "123456"))
+		for i := 0; i < 3; i++ {
+			ok, err := c.Verify(ctx, biz, phone, "000000")
+			require.NoError(t, err)
+			assert.False(t, ok)
+		}
+		// 次数耗尽之后，输对了也不行
+		_, err := c.Verify(ctx, biz, phone, "123456")
+		assert.Equal(t, ErrCodeVerifyTooMany, err)
+	})
+
+	t.Run("十分钟后过期", func(t *testing.T) {
+		c, forward := factory(t)
+		require.NoError(t, c.Set(ctx, biz, phone, "123456"))
+		forward(time.Minute*10 + time.Second)
+		_, err := c.Verify(ctx, biz, phone, "123456")
+		assert.Equal(t, ErrCodeVerifyTooMany, err)
+		// 过期之后可以重新发送
+		assert.NoError(t, c.Set(ctx, biz, phone, "654321"))
+	})
+
+	t.Run("不同业务互不影响", func(t *testing.T) {
+		c, _ := factory(t)
+		require.NoError(t, c.Set(ctx, biz, phone, "123456"))
+		require.NoError(t, c.Set(ctx, "reset_password", phone, "654321"))
+		ok, err := c.Verify(ctx, "reset_password", phone, "123456")
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+}
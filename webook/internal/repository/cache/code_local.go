@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"github.com/hashicorp/golang-lru/simplelru"
+	"sync"
+	"time"
+)
+
+// LocalCodeCache 本地缓存实现，语义和 set_code.lua、verify_code.lua 保持一致
+// 适合本地开发或者单机部署，多实例部署的时候验证码不能共享，要用 RedisCodeCache
+
+type LocalCodeCache struct {
+	// simplelru 本身不是线程安全的，而且 Set 和 Verify 都是先读后写，需要整体加锁
+	lock  sync.Mutex
+	cache *simplelru.LRU
+	// 验证码的有效期
+	expiration time.Duration
+	// 重发间隔，发送之后这段时间内不能再发
+	resendInterval time.Duration
+	// 可以验证的次数
+	maxVerifyCnt int
+	// 方便测试的时候控制时间
+	now func() time.Time
+}
+
+type codeItem struct {
+	code     string
+	cnt      int
+	expireAt time.Time
+}
+
+// NewLocalCodeCache capacity 是最多保存多少个验证码，超过之后淘汰最久没有使用的
+func NewLocalCodeCache(capacity int) CodeCache {
+	c, err := simplelru.NewLRU(capacity, nil)
+	if err != nil {
+		panic(err)
+	}
+	return &LocalCodeCache{
+		cache:          c,
+		expiration:     time.Minute * 10,
+		resendInterval: time.Minute,
+		maxVerifyCnt:   3,
+		now:            time.Now,
+	}
+}
+
+func (c *LocalCodeCache) Set(ctx context.Context, biz, phone, code string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	key := c.key(biz, phone)
+	now := c.now()
+	val, ok := c.cache.Get(key)
+	if ok {
+		item := val.(codeItem)
+		// 剩余有效期不少于 expiration - resendInterval，说明离上一次发送不到 resendInterval
+		if item.expireAt.After(now) && item.expireAt.Sub(now) >= c.expiration-c.resendInterval {
+			return ErrCodeSendTooMany
+		}
+	}
+	c.cache.Add(key, codeItem{
+		code:     code,
+		cnt:      c.maxVerifyCnt,
+		expireAt: now.Add(c.expiration),
+	})
+	return nil
+}
+
+func (c *LocalCodeCache) Verify(ctx context.Context, biz, phone, code string) (bool, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	key := c.key(biz, phone)
+	val, ok := c.cache.Get(key)
+	if !ok {
+		// 和 lua 脚本一样，没有验证码的时候按照验证次数耗尽处理
+		return false, ErrCodeVerifyTooMany
+	}
+	item := val.(codeItem)
+	if !item.expireAt.After(c.now()) {
+		c.cache.Remove(key)
+		return false, ErrCodeVerifyTooMany
+	}
+	if item.cnt <= 0 {
+		return false, ErrCodeVerifyTooMany
+	}
+	if item.code == code {
+		// 验证成功之后就不能再用了
+		item.cnt = 0
+		c.cache.Add(key, item)
+		return true, nil
+	}
+	item.cnt--
+	c.cache.Add(key, item)
+	return false, nil
+}
+
+func (c *LocalCodeCache) key(biz, phone string) string {
+	return fmt.Sprintf("phone_code:%s:%s", biz, phone)
+}
@@ -0,0 +1,9 @@
+local key = KEYS[1]  -- 尝试次数的 Key
+local window = tonumber(ARGV[1])  -- 统计窗口，单位秒
+
+local cnt = redis.call("incr", key)
+if cnt == 1 then
+    -- 第一次尝试，开始计时，窗口结束后自动清零
+    redis.call("expire", key, window)
+end
+return cnt
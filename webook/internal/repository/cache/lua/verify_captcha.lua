@@ -0,0 +1,17 @@
+local key = KEYS[1]  -- 图形验证码的 Key
+local expectedAnswer = ARGV[1]  -- 用户输入的答案
+
+local answer = redis.call("get", key)
+if answer == false then
+    -- 验证码不存在或者已经过期
+    return -1
+end
+
+-- 无论对错，图形验证码都只能用一次，防止被暴力枚举
+redis.call("del", key)
+
+if answer == expectedAnswer then
+    return 0
+else
+    return -2
+end
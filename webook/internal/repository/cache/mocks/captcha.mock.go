@@ -0,0 +1,142 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./webook/internal/repository/cache/captcha.go
+//
+// Generated by this command:
+//
+//	mockgen -source=./webook/internal/repository/cache/captcha.go -package=cachemocks -destination=./webook/internal/repository/cache/mocks/captcha.mock.go
+//
+
+// Package cachemocks is a generated GoMock package.
+package cachemocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockCaptchaCache is a mock of CaptchaCache interface.
+type MockCaptchaCache struct {
+	ctrl     *gomock.Controller
+	recorder *MockCaptchaCacheMockRecorder
+}
+
+// MockCaptchaCacheMockRecorder is the mock recorder for MockCaptchaCache.
+type MockCaptchaCacheMockRecorder struct {
+	mock *MockCaptchaCache
+}
+
+// NewMockCaptchaCache creates a new mock instance.
+func NewMockCaptchaCache(ctrl *gomock.Controller) *MockCaptchaCache {
+	mock := &MockCaptchaCache{ctrl: ctrl}
+	mock.recorder = &MockCaptchaCacheMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCaptchaCache) EXPECT() *MockCaptchaCacheMockRecorder {
+	return m.recorder
+}
+
+// Attempts mocks base method.
+func (m *MockCaptchaCache) Attempts(ctx context.Context, biz, key string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Attempts", ctx, biz, key)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Attempts indicates an expected call of Attempts.
+func (mr *MockCaptchaCacheMockRecorder) Attempts(ctx, biz, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Attempts", reflect.TypeOf((*MockCaptchaCache)(nil).Attempts), ctx, biz, key)
+}
+
+// ConsumeTicket mocks base method.
+func (m *MockCaptchaCache) ConsumeTicket(ctx context.Context, biz, ticket string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConsumeTicket", ctx, biz, ticket)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConsumeTicket indicates an expected call of ConsumeTicket.
+func (mr *MockCaptchaCacheMockRecorder) ConsumeTicket(ctx, biz, ticket any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConsumeTicket", reflect.TypeOf((*MockCaptchaCache)(nil).ConsumeTicket), ctx, biz, ticket)
+}
+
+// IncrAttempt mocks base method.
+func (m *MockCaptchaCache) IncrAttempt(ctx context.Context, biz, key string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IncrAttempt", ctx, biz, key)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IncrAttempt indicates an expected call of IncrAttempt.
+func (mr *MockCaptchaCacheMockRecorder) IncrAttempt(ctx, biz, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrAttempt", reflect.TypeOf((*MockCaptchaCache)(nil).IncrAttempt), ctx, biz, key)
+}
+
+// ResetAttempt mocks base method.
+func (m *MockCaptchaCache) ResetAttempt(ctx context.Context, biz, key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetAttempt", ctx, biz, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResetAttempt indicates an expected call of ResetAttempt.
+func (mr *MockCaptchaCacheMockRecorder) ResetAttempt(ctx, biz, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetAttempt", reflect.TypeOf((*MockCaptchaCache)(nil).ResetAttempt), ctx, biz, key)
+}
+
+// SetAnswer mocks base method.
+func (m *MockCaptchaCache) SetAnswer(ctx context.Context, id, answer string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetAnswer", ctx, id, answer)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetAnswer indicates an expected call of SetAnswer.
+func (mr *MockCaptchaCacheMockRecorder) SetAnswer(ctx, id, answer any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetAnswer", reflect.TypeOf((*MockCaptchaCache)(nil).SetAnswer), ctx, id, answer)
+}
+
+// SetTicket mocks base method.
+func (m *MockCaptchaCache) SetTicket(ctx context.Context, biz, ticket string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetTicket", ctx, biz, ticket)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetTicket indicates an expected call of SetTicket.
+func (mr *MockCaptchaCacheMockRecorder) SetTicket(ctx, biz, ticket any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTicket", reflect.TypeOf((*MockCaptchaCache)(nil).SetTicket), ctx, biz, ticket)
+}
+
+// VerifyAnswer mocks base method.
+func (m *MockCaptchaCache) VerifyAnswer(ctx context.Context, id, answer string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyAnswer", ctx, id, answer)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VerifyAnswer indicates an expected call of VerifyAnswer.
+func (mr *MockCaptchaCacheMockRecorder) VerifyAnswer(ctx, id, answer any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyAnswer", reflect.TypeOf((*MockCaptchaCache)(nil).VerifyAnswer), ctx, id, answer)
+}
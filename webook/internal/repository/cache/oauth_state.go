@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"Learn_Go/webook/internal/domain"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+var ErrOAuthStateNotFound = errors.New("state 不存在或已过期")
+
+// OAuthStateCache 第三方登录的 state 保存在服务端，浏览器只拿到 state 本身
+type OAuthStateCache interface {
+	Set(ctx context.Context, state string, st domain.OAuthState) error
+	// GetDel 取出来的同时删掉，同一个 state 只能回调一次
+	GetDel(ctx context.Context, state string) (domain.OAuthState, error)
+}
+
+type RedisOAuthStateCache struct {
+	cmd redis.Cmdable
+	// 用户在第三方的授权页面停留的最长时间
+	expiration time.Duration
+}
+
+func NewRedisOAuthStateCache(cmd redis.Cmdable) OAuthStateCache {
+	return &RedisOAuthStateCache{
+		cmd:        cmd,
+		expiration: time.Minute * 10,
+	}
+}
+
+func (c *RedisOAuthStateCache) key(state string) string {
+	return fmt.Sprintf("oauth2:state:%s", state)
+}
+
+func (c *RedisOAuthStateCache) Set(ctx context.Context, state string, st domain.OAuthState) error {
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	return c.cmd.Set(ctx, c.key(state), data, c.expiration).Err()
+}
+
+func (c *RedisOAuthStateCache) GetDel(ctx context.Context, state string) (domain.OAuthState, error) {
+	data, err := c.cmd.GetDel(ctx, c.key(state)).Bytes()
+	if err == redis.Nil {
+		return domain.OAuthState{}, ErrOAuthStateNotFound
+	}
+	if err != nil {
+		return domain.OAuthState{}, err
+	}
+	var st domain.OAuthState
+	err = json.Unmarshal(data, &st)
+	return st, err
+}
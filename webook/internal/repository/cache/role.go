@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// RoleCache 每次签发短 token、访问需要角色的路由都要查角色，所以缓存起来
+type RoleCache interface {
+	Get(ctx context.Context, uid int64) ([]string, error)
+	Set(ctx context.Context, uid int64, roles []string) error
+	Del(ctx context.Context, uid int64) error
+}
+
+type RedisRoleCache struct {
+	cmd        redis.Cmdable
+	expiration time.Duration
+}
+
+func NewRedisRoleCache(cmd redis.Cmdable) RoleCache {
+	return &RedisRoleCache{
+		cmd:        cmd,
+		expiration: time.Minute * 15,
+	}
+}
+
+func (c *RedisRoleCache) key(uid int64) string {
+	return fmt.Sprintf("user:roles:%d", uid)
+}
+
+func (c *RedisRoleCache) Get(ctx context.Context, uid int64) ([]string, error) {
+	data, err := c.cmd.Get(ctx, c.key(uid)).Bytes()
+	if err != nil {
+		return nil, err
+	}
+	var roles []string
+	err = json.Unmarshal(data, &roles)
+	return roles, err
+}
+
+// Set 没有角色的用户也要缓存一个空的列表，大部分用户都没有额外的角色
+func (c *RedisRoleCache) Set(ctx context.Context, uid int64, roles []string) error {
+	if roles == nil {
+		roles = []string{}
+	}
+	data, err := json.Marshal(roles)
+	if err != nil {
+		return err
+	}
+	return c.cmd.Set(ctx, c.key(uid), data, c.expiration).Err()
+}
+
+func (c *RedisRoleCache) Del(ctx context.Context, uid int64) error {
+	return c.cmd.Del(ctx, c.key(uid)).Err()
+}
@@ -2,36 +2,50 @@ package cache
 
 import (
 	"Learn_Go/webook/internal/domain"
+	"Learn_Go/webook/pkg/codec"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/redis/go-redis/v9"
+	"math/rand"
 	"time"
 )
 
 var ErrKeyNotExist = redis.Nil
 
+// ErrUserNotExistCached 缓存了"这个用户不存在"，不需要再查数据库
+var ErrUserNotExistCached = errors.New("缓存的用户不存在")
+
 type UserCache interface {
+	// Get 没有缓存的时候返回 ErrKeyNotExist，缓存了不存在的时候返回 ErrUserNotExistCached
 	Get(ctx context.Context, uid int64) (domain.User, error)
 	Set(ctx context.Context, du domain.User) error
+	// SetNotFound 缓存一个不存在的 uid，防止有人用不存在的 uid 一直打到数据库上（缓存穿透）
+	SetNotFound(ctx context.Context, uid int64) error
+	Del(ctx context.Context, uid int64) error
 }
 
 type RedisUserCache struct {
 	cmd        redis.Cmdable // 操作Redis的应用，为什么不适用client，因为client是具体的实现，而Cmdable是面向接口编程，扩展性更好
 	expiration time.Duration // 过期时间
+	// jitter 过期时间再加上 [0, jitter) 的随机值，避免同一时间写入的大量 key 同时过期（缓存雪崩）
+	jitter time.Duration
+	// notFoundExpiration 不存在的 uid 缓存多久，新注册的用户最多要等这么久才能查到，所以要短
+	notFoundExpiration time.Duration
+	// codec 写的时候用的序列化方式，读的时候按照数据里面记录的来
+	codec codec.Codec
 }
 
 func (c *RedisUserCache) Get(ctx context.Context, uid int64) (domain.User, error) {
 	key := c.Key(uid)
-	// 假定这个地方使用 JSON 序列化
-	data, err := c.cmd.Get(ctx, key).Result()
+	data, err := c.cmd.Get(ctx, key).Bytes()
 	if err != nil {
 		return domain.User{}, err
 	}
-	var u domain.User
-	// 反序列化
-	err = json.Unmarshal([]byte(data), &u)
-	return u, err
+	if len(data) == 0 {
+		return domain.User{}, ErrUserNotExistCached
+	}
+	return decodeUser(data)
 }
 
 func (c *RedisUserCache) Key(uid int64) string {
@@ -40,20 +54,45 @@ func (c *RedisUserCache) Key(uid int64) string {
 
 func (c *RedisUserCache) Set(ctx context.Context, du domain.User) error {
 	key := c.Key(du.Id)
-	// 序列化
-	data, err := json.Marshal(du)
+	// 序列化，密码不会写进去
+	data, err := encodeUser(c.codec, du)
 	if err != nil {
 		return err
 	}
 
-	err = c.cmd.Set(ctx, key, data, c.expiration).Err()
+	err = c.cmd.Set(ctx, key, data, c.ttl(c.expiration)).Err()
 	return err
 
 }
 
+// SetNotFound 用空字符串表示不存在，正常的用户序列化之后不会是空的
+func (c *RedisUserCache) SetNotFound(ctx context.Context, uid int64) error {
+	return c.cmd.Set(ctx, c.Key(uid), "", c.notFoundExpiration).Err()
+}
+
+func (c *RedisUserCache) ttl(base time.Duration) time.Duration {
+	if c.jitter <= 0 {
+		return base
+	}
+	return base + time.Duration(rand.Int63n(int64(c.jitter)))
+}
+
+func (c *RedisUserCache) Del(ctx context.Context, uid int64) error {
+	return c.cmd.Del(ctx, c.Key(uid)).Err()
+}
+
+// NewRedisUserCache 用 JSON 序列化
 func NewRedisUserCache(cmd redis.Cmdable) UserCache {
+	return NewRedisUserCacheWithCodec(cmd, codec.JSON{})
+}
+
+func NewRedisUserCacheWithCodec(cmd redis.Cmdable, c codec.Codec) UserCache {
 	return &RedisUserCache{
 		cmd:        cmd,              // 从外面传，不要自己去初始化需要的东西
 		expiration: time.Minute * 15, // 过期时间可以直接写死
+		jitter:     time.Minute * 3,
+		// 不存在的 uid 只缓存很短的时间
+		notFoundExpiration: time.Minute,
+		codec:              c,
 	}
 }
@@ -0,0 +1,250 @@
+package cache
+
+import (
+	"Learn_Go/webook/internal/domain"
+	"Learn_Go/webook/pkg/codec"
+	"errors"
+	"google.golang.org/protobuf/encoding/protowire"
+	"time"
+)
+
+// userSchemaVersion CachedUser 有不兼容的修改（比如删字段、改字段类型）的时候加一
+// 版本不一样的缓存当作没有缓存，重新从数据库加载，不会因为解析失败报错
+const userSchemaVersion byte = 1
+
+// 缓存内容的格式是 [版本][codec.Id][序列化之后的 CachedUser]
+const userHeaderLen = 2
+
+var errInvalidUserProto = errors.New("用户缓存的 protobuf 格式错误")
+
+// CachedUser 放到缓存里面的用户，只有展示和校验状态需要的字段
+// 密码之类的敏感信息不放进缓存，需要的时候直接查数据库
+//
+// 对应的 protobuf 定义：
+//
+//	message CachedUser {
+//	  int64 id = 1;
+//	  string email = 2;
+//	  string phone = 3;
+//	  string nickname = 4;
+//	  string about_me = 5;
+//	  string avatar = 6;
+//	  sint64 birthday = 7;
+//	  sint64 ctime = 8;
+//	  uint32 status = 9;
+//	  sint64 suspended_until = 10;
+//	  sint64 delete_at = 11;
+//	  uint32 privacy_email = 12;
+//	  uint32 privacy_phone = 13;
+//	  uint32 privacy_birthday = 14;
+//	  uint32 privacy_about_me = 15;
+//	}
+type CachedUser struct {
+	Id       int64  `json:"id" msgpack:"id"`
+	Email    string `json:"email,omitempty" msgpack:"email,omitempty"`
+	Phone    string `json:"phone,omitempty" msgpack:"phone,omitempty"`
+	Nickname string `json:"nickname,omitempty" msgpack:"nickname,omitempty"`
+	AboutMe  string `json:"aboutMe,omitempty" msgpack:"aboutMe,omitempty"`
+	Avatar   string `json:"avatar,omitempty" msgpack:"avatar,omitempty"`
+	// 时间都是毫秒，生日可能在 1970 年之前，所以 protobuf 里面用 sint64
+	Birthday       int64 `json:"birthday,omitempty" msgpack:"birthday,omitempty"`
+	Ctime          int64 `json:"ctime,omitempty" msgpack:"ctime,omitempty"`
+	Status         uint8 `json:"status,omitempty" msgpack:"status,omitempty"`
+	SuspendedUntil int64 `json:"suspendedUntil,omitempty" msgpack:"suspendedUntil,omitempty"`
+	DeleteAt       int64 `json:"deleteAt,omitempty" msgpack:"deleteAt,omitempty"`
+
+	PrivacyEmail    uint8 `json:"privacyEmail,omitempty" msgpack:"privacyEmail,omitempty"`
+	PrivacyPhone    uint8 `json:"privacyPhone,omitempty" msgpack:"privacyPhone,omitempty"`
+	PrivacyBirthday uint8 `json:"privacyBirthday,omitempty" msgpack:"privacyBirthday,omitempty"`
+	PrivacyAboutMe  uint8 `json:"privacyAboutMe,omitempty" msgpack:"privacyAboutMe,omitempty"`
+}
+
+// newCachedUser 时间的转换和 repository 从数据库转换过来的一致
+// 生日和创建时间 0 就是 1970 年，封禁和注销时间 0 表示没有
+func newCachedUser(u domain.User) CachedUser {
+	return CachedUser{
+		Id:              u.Id,
+		Email:           u.Email,
+		Phone:           u.Phone,
+		Nickname:        u.NickName,
+		AboutMe:         u.AboutMe,
+		Avatar:          u.Avatar,
+		Birthday:        u.BirthDay.UnixMilli(),
+		Ctime:           u.Ctime.UnixMilli(),
+		Status:          uint8(u.Status),
+		SuspendedUntil:  toMilli(u.SuspendedUntil),
+		DeleteAt:        toMilli(u.DeleteAt),
+		PrivacyEmail:    uint8(u.Privacy.Email),
+		PrivacyPhone:    uint8(u.Privacy.Phone),
+		PrivacyBirthday: uint8(u.Privacy.Birthday),
+		PrivacyAboutMe:  uint8(u.Privacy.AboutMe),
+	}
+}
+
+func (u CachedUser) toDomain() domain.User {
+	return domain.User{
+		Id:             u.Id,
+		Email:          u.Email,
+		Phone:          u.Phone,
+		NickName:       u.Nickname,
+		AboutMe:        u.AboutMe,
+		Avatar:         u.Avatar,
+		BirthDay:       time.UnixMilli(u.Birthday),
+		Ctime:          time.UnixMilli(u.Ctime),
+		Status:         domain.UserStatus(u.Status),
+		SuspendedUntil: toTime(u.SuspendedUntil),
+		DeleteAt:       toTime(u.DeleteAt),
+		Privacy: domain.PrivacySettings{
+			Email:    domain.Visibility(u.PrivacyEmail),
+			Phone:    domain.Visibility(u.PrivacyPhone),
+			Birthday: domain.Visibility(u.PrivacyBirthday),
+			AboutMe:  domain.Visibility(u.PrivacyAboutMe),
+		},
+	}
+}
+
+func toMilli(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixMilli()
+}
+
+func toTime(ms int64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
+
+// AppendProto 和 proto3 一样，零值的字段不写
+func (u *CachedUser) AppendProto(b []byte) []byte {
+	b = appendVarint(b, 1, uint64(u.Id))
+	b = appendString(b, 2, u.Email)
+	b = appendString(b, 3, u.Phone)
+	b = appendString(b, 4, u.Nickname)
+	b = appendString(b, 5, u.AboutMe)
+	b = appendString(b, 6, u.Avatar)
+	b = appendVarint(b, 7, protowire.EncodeZigZag(u.Birthday))
+	b = appendVarint(b, 8, protowire.EncodeZigZag(u.Ctime))
+	b = appendVarint(b, 9, uint64(u.Status))
+	b = appendVarint(b, 10, protowire.EncodeZigZag(u.SuspendedUntil))
+	b = appendVarint(b, 11, protowire.EncodeZigZag(u.DeleteAt))
+	b = appendVarint(b, 12, uint64(u.PrivacyEmail))
+	b = appendVarint(b, 13, uint64(u.PrivacyPhone))
+	b = appendVarint(b, 14, uint64(u.PrivacyBirthday))
+	b = appendVarint(b, 15, uint64(u.PrivacyAboutMe))
+	return b
+}
+
+func (u *CachedUser) UnmarshalProto(data []byte) error {
+	*u = CachedUser{}
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return errInvalidUserProto
+		}
+		data = data[n:]
+		switch {
+		case typ == protowire.BytesType && num >= 2 && num <= 6:
+			v, n := protowire.ConsumeString(data)
+			if n < 0 {
+				return errInvalidUserProto
+			}
+			data = data[n:]
+			switch num {
+			case 2:
+				u.Email = v
+			case 3:
+				u.Phone = v
+			case 4:
+				u.Nickname = v
+			case 5:
+				u.AboutMe = v
+			case 6:
+				u.Avatar = v
+			}
+		case typ == protowire.VarintType && (num == 1 || (num >= 7 && num <= 15)):
+			v, n := protowire.ConsumeVarint(data)
+			if n < 0 {
+				return errInvalidUserProto
+			}
+			data = data[n:]
+			switch num {
+			case 1:
+				u.Id = int64(v)
+			case 7:
+				u.Birthday = protowire.DecodeZigZag(v)
+			case 8:
+				u.Ctime = protowire.DecodeZigZag(v)
+			case 9:
+				u.Status = uint8(v)
+			case 10:
+				u.SuspendedUntil = protowire.DecodeZigZag(v)
+			case 11:
+				u.DeleteAt = protowire.DecodeZigZag(v)
+			case 12:
+				u.PrivacyEmail = uint8(v)
+			case 13:
+				u.PrivacyPhone = uint8(v)
+			case 14:
+				u.PrivacyBirthday = uint8(v)
+			case 15:
+				u.PrivacyAboutMe = uint8(v)
+			}
+		default:
+			// 新版本加的字段，或者类型对不上的字段，跳过
+			n := protowire.ConsumeFieldValue(num, typ, data)
+			if n < 0 {
+				return errInvalidUserProto
+			}
+			data = data[n:]
+		}
+	}
+	return nil
+}
+
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func appendString(b []byte, num protowire.Number, v string) []byte {
+	if v == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v)
+}
+
+// encodeUser 在前面加上版本和 codec.Id
+func encodeUser(c codec.Codec, u domain.User) ([]byte, error) {
+	cu := newCachedUser(u)
+	data, err := c.Marshal(&cu)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]byte, 0, userHeaderLen+len(data))
+	res = append(res, userSchemaVersion, c.Id())
+	return append(res, data...), nil
+}
+
+// decodeUser 按照数据里面的 codec.Id 解析，所以切换 codec 之后旧的缓存还能用
+// 版本不对、没有头部（改成这种格式之前直接存的 JSON）或者不认识的 codec 返回 ErrKeyNotExist
+func decodeUser(data []byte) (domain.User, error) {
+	if len(data) < userHeaderLen || data[0] != userSchemaVersion {
+		return domain.User{}, ErrKeyNotExist
+	}
+	c, ok := codec.ById(data[1])
+	if !ok {
+		return domain.User{}, ErrKeyNotExist
+	}
+	var cu CachedUser
+	if err := c.Unmarshal(data[userHeaderLen:], &cu); err != nil {
+		return domain.User{}, err
+	}
+	return cu.toDomain(), nil
+}
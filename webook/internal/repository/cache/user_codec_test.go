@@ -0,0 +1,146 @@
+package cache
+
+import (
+	"Learn_Go/webook/internal/domain"
+	"Learn_Go/webook/pkg/codec"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+	"testing"
+	"time"
+)
+
+var codecs = []codec.Codec{codec.JSON{}, codec.Msgpack{}, codec.Protobuf{}}
+
+// testUser 和从数据库加载出来的一样，时间都是毫秒精度
+func testUser() domain.User {
+	return domain.User{
+		Id:       123,
+		Email:    "123@qq.com",
+		Phone:    "15012345678",
+		Password: "$2a$10$hash",
+		NickName: "lip",
+		AboutMe:  "自我介绍",
+		Avatar:   "/files/avatars/ab/abcdef_256.png",
+		// 1970 年之前的生日是负数
+		BirthDay:       time.UnixMilli(-86400000),
+		Ctime:          time.UnixMilli(1700000000000),
+		Status:         domain.UserStatusSuspended,
+		SuspendedUntil: time.UnixMilli(1800000000000),
+		Privacy: domain.PrivacySettings{
+			Email:   domain.VisibilityPublic,
+			AboutMe: domain.VisibilityFollowers,
+		},
+	}
+}
+
+func TestUserCodec_RoundTrip(t *testing.T) {
+	for _, c := range codecs {
+		t.Run(c.Name(), func(t *testing.T) {
+			u := testUser()
+			data, err := encodeUser(c, u)
+			require.NoError(t, err)
+			assert.Equal(t, []byte{userSchemaVersion, c.Id()}, data[:userHeaderLen])
+			assert.NotContains(t, string(data), u.Password)
+
+			got, err := decodeUser(data)
+			require.NoError(t, err)
+			// 密码不进缓存
+			u.Password = ""
+			assert.Equal(t, u, got)
+			assert.True(t, got.DeleteAt.IsZero())
+		})
+	}
+}
+
+func TestUserCodec_Decode(t *testing.T) {
+	legacy, err := json.Marshal(testUser())
+	require.NoError(t, err)
+	protoData, err := encodeUser(codec.Protobuf{}, testUser())
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name    string
+		data    []byte
+		wantId  int64
+		wantErr error
+	}{
+		{
+			// 改成带版本的格式之前，直接存的 domain.User 的 JSON
+			name:    "旧格式当作没有缓存",
+			data:    legacy,
+			wantErr: ErrKeyNotExist,
+		},
+		{
+			name:    "版本不对当作没有缓存",
+			data:    append([]byte{userSchemaVersion + 1}, protoData[1:]...),
+			wantErr: ErrKeyNotExist,
+		},
+		{
+			name:    "不认识的 codec 当作没有缓存",
+			data:    append([]byte{userSchemaVersion, 100}, protoData[2:]...),
+			wantErr: ErrKeyNotExist,
+		},
+		{
+			name:    "太短",
+			data:    []byte{userSchemaVersion},
+			wantErr: ErrKeyNotExist,
+		},
+		{
+			name: "跳过新版本加的字段",
+			data: protowire.AppendString(protowire.AppendTag(append([]byte{}, protoData...), 100,
+				protowire.BytesType), "新字段"),
+			wantId: 123,
+		},
+		{
+			name:    "protobuf 格式错误",
+			data:    append(append([]byte{}, protoData...), 0xff),
+			wantErr: errInvalidUserProto,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			u, err := decodeUser(tc.data)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantId, u.Id)
+		})
+	}
+}
+
+// 比较各种序列化方式的体积和速度，json-legacy 是以前直接序列化 domain.User 的做法
+// go test -bench=BenchmarkUserCodec -benchmem ./internal/repository/cache/
+func BenchmarkUserCodec(b *testing.B) {
+	u := testUser()
+	b.Run("json-legacy", func(b *testing.B) {
+		data, _ := json.Marshal(u)
+		b.Run("marshal", func(b *testing.B) {
+			b.ReportMetric(float64(len(data)), "bytes")
+			for i := 0; i < b.N; i++ {
+				_, _ = json.Marshal(u)
+			}
+		})
+		b.Run("unmarshal", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				var du domain.User
+				_ = json.Unmarshal(data, &du)
+			}
+		})
+	})
+	for _, c := range codecs {
+		b.Run(c.Name(), func(b *testing.B) {
+			data, _ := encodeUser(c, u)
+			b.Run("marshal", func(b *testing.B) {
+				b.ReportMetric(float64(len(data)), "bytes")
+				for i := 0; i < b.N; i++ {
+					_, _ = encodeUser(c, u)
+				}
+			})
+			b.Run("unmarshal", func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					_, _ = decodeUser(data)
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package cache
+
+import (
+	"Learn_Go/webook/internal/domain"
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// userCacheFactory 返回被测的 UserCache，以及一个让时间往前走的方法
+type userCacheFactory func(t *testing.T) (UserCache, func(d time.Duration))
+
+func TestUserCache_Conformance(t *testing.T) {
+	// 本地缓存的过期时间和 Redis 的一样，这样同一套用例都能用
+	newLocal := func() (*LocalUserCache, func(d time.Duration)) {
+		now := time.Now()
+		c := NewLocalUserCache(100, time.Minute*15)
+		c.notFoundExpiration = time.Minute
+		c.now = func() time.Time {
+			return now
+		}
+		return c, func(d time.Duration) {
+			now = now.Add(d)
+		}
+	}
+	factories := map[string]userCacheFactory{
+		"local": func(t *testing.T) (UserCache, func(d time.Duration)) {
+			return newLocal()
+		},
+		"multilevel": func(t *testing.T) (UserCache, func(d time.Duration)) {
+			mr := miniredis.RunT(t)
+			rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			local, forward := newLocal()
+			return NewMultiLevelUserCache(local, NewRedisUserCache(rdb), nil, nil), func(d time.Duration) {
+				forward(d)
+				mr.FastForward(d)
+			}
+		},
+	}
+	for _, c := range codecs {
+		c := c
+		factories["redis-"+c.Name()] = func(t *testing.T) (UserCache, func(d time.Duration)) {
+			mr := miniredis.RunT(t)
+			rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			return NewRedisUserCacheWithCodec(rdb, c), mr.FastForward
+		}
+	}
+	for name, factory := range factories {
+		t.Run(name, func(t *testing.T) {
+			testUserCacheConformance(t, factory)
+		})
+	}
+}
+
+func testUserCacheConformance(t *testing.T, factory userCacheFactory) {
+	ctx := context.Background()
+	u := domain.User{
+		Id:       123,
+		Email:    "123@qq.com",
+		Password: "$2a$10$hash",
+		NickName: "lip",
+		Ctime:    time.UnixMilli(101),
+	}
+
+	t.Run("没有缓存", func(t *testing.T) {
+		c, _ := factory(t)
+		_, err := c.Get(ctx, 123)
+		assert.Equal(t, ErrKeyNotExist, err)
+	})
+
+	t.Run("缓存命中", func(t *testing.T) {
+		c, _ := factory(t)
+		require.NoError(t, c.Set(ctx, u))
+		got, err := c.Get(ctx, 123)
+		require.NoError(t, err)
+		assert.Equal(t, u.Id, got.Id)
+		assert.Equal(t, u.NickName, got.NickName)
+		assert.True(t, u.Ctime.Equal(got.Ctime))
+		// 哪一级缓存都不保存密码
+		assert.Empty(t, got.Password)
+	})
+
+	t.Run("删除之后没有缓存", func(t *testing.T) {
+		c, _ := factory(t)
+		require.NoError(t, c.Set(ctx, u))
+		require.NoError(t, c.Del(ctx, 123))
+		_, err := c.Get(ctx, 123)
+		assert.Equal(t, ErrKeyNotExist, err)
+	})
+
+	t.Run("过期", func(t *testing.T) {
+		c, forward := factory(t)
+		require.NoError(t, c.Set(ctx, u))
+		// 15 分钟加上最多 3 分钟的随机值
+		forward(time.Minute * 18)
+		_, err := c.Get(ctx, 123)
+		assert.Equal(t, ErrKeyNotExist, err)
+	})
+
+	t.Run("缓存不存在", func(t *testing.T) {
+		c, forward := factory(t)
+		require.NoError(t, c.SetNotFound(ctx, 123))
+		_, err := c.Get(ctx, 123)
+		assert.Equal(t, ErrUserNotExistCached, err)
+
+		// 不存在的只缓存一分钟
+		forward(time.Minute)
+		_, err = c.Get(ctx, 123)
+		assert.Equal(t, ErrKeyNotExist, err)
+	})
+
+	t.Run("新用户覆盖不存在", func(t *testing.T) {
+		c, _ := factory(t)
+		require.NoError(t, c.SetNotFound(ctx, 123))
+		require.NoError(t, c.Set(ctx, u))
+		got, err := c.Get(ctx, 123)
+		require.NoError(t, err)
+		assert.Equal(t, u.Id, got.Id)
+	})
+}
+
+func TestMultiLevelUserCache_Get(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	remote := NewRedisUserCache(rdb)
+	local := NewLocalUserCache(100, time.Minute)
+	requests := NewUserCacheCounter()
+	c := NewMultiLevelUserCache(local, remote, nil, requests)
+
+	// Redis 里面有，本地没有，查完之后回填本地
+	require.NoError(t, remote.Set(ctx, domain.User{Id: 1, NickName: "lip"}))
+	u, err := c.Get(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "lip", u.NickName)
+	u, err = local.Get(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "lip", u.NickName)
+
+	// 第二次直接命中本地，Redis 里面删掉也不影响
+	mr.FlushAll()
+	_, err = c.Get(ctx, 1)
+	require.NoError(t, err)
+
+	// 不存在也回填到本地
+	require.NoError(t, remote.SetNotFound(ctx, 2))
+	_, err = c.Get(ctx, 2)
+	assert.Equal(t, ErrUserNotExistCached, err)
+	_, err = local.Get(ctx, 2)
+	assert.Equal(t, ErrUserNotExistCached, err)
+
+	// 两级都没有
+	_, err = c.Get(ctx, 3)
+	assert.Equal(t, ErrKeyNotExist, err)
+
+	// Redis 出错的时候返回错误，让上层决定要不要查数据库
+	mr.Close()
+	_, err = c.Get(ctx, 4)
+	assert.Error(t, err)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(requests.WithLabelValues(levelLocal, resultHit)))
+	assert.Equal(t, 4.0, testutil.ToFloat64(requests.WithLabelValues(levelLocal, resultMiss)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(requests.WithLabelValues(levelRedis, resultHit)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(requests.WithLabelValues(levelRedis, resultNotFound)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(requests.WithLabelValues(levelRedis, resultMiss)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(requests.WithLabelValues(levelRedis, resultError)))
+}
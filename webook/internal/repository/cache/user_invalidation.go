@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"Learn_Go/webook/pkg/logger"
+	"context"
+	"github.com/redis/go-redis/v9"
+	"strconv"
+)
+
+// userInvalidationChannel 用户缓存失效的广播频道，消息内容是 uid
+const userInvalidationChannel = "user:cache:invalidate"
+
+// UserCacheInvalidation 一个实例修改了用户之后，通过 Redis 的 pub/sub 通知所有实例删掉本地缓存
+// pub/sub 不保证送达，断线期间的消息会丢失，这时候只能等本地缓存自己过期
+type UserCacheInvalidation struct {
+	client redis.UniversalClient
+	// local 收到消息之后删除的本地缓存
+	local UserCache
+	l     logger.LoggerV1
+}
+
+func NewUserCacheInvalidation(client redis.UniversalClient, local UserCache, l logger.LoggerV1) *UserCacheInvalidation {
+	return &UserCacheInvalidation{
+		client: client,
+		local:  local,
+		l:      l,
+	}
+}
+
+// Publish 自己也会收到这条消息，再删一次本地缓存没有影响
+func (i *UserCacheInvalidation) Publish(ctx context.Context, uid int64) error {
+	return i.client.Publish(ctx, userInvalidationChannel, strconv.FormatInt(uid, 10)).Err()
+}
+
+// Subscribe 返回的时候已经订阅成功，之后在后台处理消息，直到 ctx 被取消
+// 断线之后 go-redis 会自动重新订阅
+func (i *UserCacheInvalidation) Subscribe(ctx context.Context) error {
+	ps := i.client.Subscribe(ctx, userInvalidationChannel)
+	// 等到订阅确认，不然在这之前发布的消息收不到
+	if _, err := ps.Receive(ctx); err != nil {
+		_ = ps.Close()
+		return err
+	}
+	go func() {
+		defer ps.Close()
+		ch := ps.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+				i.handle(ctx, msg.Payload)
+			}
+		}
+	}()
+	return nil
+}
+
+func (i *UserCacheInvalidation) handle(ctx context.Context, payload string) {
+	uid, err := strconv.ParseInt(payload, 10, 64)
+	if err != nil {
+		i.l.Warn("用户缓存失效消息格式错误", logger.String("payload", payload))
+		return
+	}
+	_ = i.local.Del(ctx, uid)
+}
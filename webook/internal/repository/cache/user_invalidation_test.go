@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"Learn_Go/webook/internal/domain"
+	"Learn_Go/webook/pkg/logger"
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// 一个实例删除之后，其它实例的本地缓存也被删掉
+func TestUserCacheInvalidation(t *testing.T) {
+	mr := miniredis.RunT(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// 两个实例共用一个 Redis，各自有自己的本地缓存
+	newInstance := func() (UserCache, *LocalUserCache) {
+		rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		local := NewLocalUserCache(100, time.Minute)
+		invalidation := NewUserCacheInvalidation(rdb, local, logger.NewNopLogger())
+		require.NoError(t, invalidation.Subscribe(ctx))
+		return NewMultiLevelUserCache(local, NewRedisUserCache(rdb), invalidation, nil), local
+	}
+	a, localA := newInstance()
+	b, localB := newInstance()
+
+	u := domain.User{Id: 123, NickName: "lip"}
+	require.NoError(t, a.Set(ctx, u))
+	// b 从 Redis 读到之后回填本地
+	_, err := b.Get(ctx, 123)
+	require.NoError(t, err)
+	_, err = localB.Get(ctx, 123)
+	require.NoError(t, err)
+
+	require.NoError(t, a.Del(ctx, 123))
+	_, err = localA.Get(ctx, 123)
+	assert.Equal(t, ErrKeyNotExist, err)
+	assert.Eventually(t, func() bool {
+		_, err := localB.Get(ctx, 123)
+		return err == ErrKeyNotExist
+	}, time.Second, time.Millisecond*10)
+
+	// 格式错误的消息直接忽略
+	require.NoError(t, localB.Set(ctx, u))
+	mr.Publish(userInvalidationChannel, "abc")
+	mr.Publish(userInvalidationChannel, "456")
+	time.Sleep(time.Millisecond * 50)
+	_, err = localB.Get(ctx, 123)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"Learn_Go/webook/internal/domain"
+	"context"
+	"github.com/hashicorp/golang-lru/simplelru"
+	"sync"
+	"time"
+)
+
+// LocalUserCache 进程内的用户缓存，作为 Redis 前面的一级缓存
+// 其它实例修改了用户之后这里的数据会过时，所以过期时间要很短
+type LocalUserCache struct {
+	// simplelru 不是线程安全的，Get 也会调整顺序，所以读也要加锁
+	lock  sync.Mutex
+	cache *simplelru.LRU
+	// expiration 过期时间
+	expiration time.Duration
+	// notFoundExpiration 不存在的 uid 缓存多久
+	notFoundExpiration time.Duration
+	now                func() time.Time
+}
+
+type userItem struct {
+	// notFound 为 true 的时候 u 是零值
+	u        domain.User
+	notFound bool
+	expireAt time.Time
+}
+
+// NewLocalUserCache capacity 是最多缓存多少个用户，超过之后淘汰最久没有使用的
+func NewLocalUserCache(capacity int, expiration time.Duration) *LocalUserCache {
+	c, err := simplelru.NewLRU(capacity, nil)
+	if err != nil {
+		panic(err)
+	}
+	return &LocalUserCache{
+		cache:              c,
+		expiration:         expiration,
+		notFoundExpiration: expiration,
+		now:                time.Now,
+	}
+}
+
+func (c *LocalUserCache) Get(ctx context.Context, uid int64) (domain.User, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	val, ok := c.cache.Get(uid)
+	if !ok {
+		return domain.User{}, ErrKeyNotExist
+	}
+	item := val.(userItem)
+	if !item.expireAt.After(c.now()) {
+		c.cache.Remove(uid)
+		return domain.User{}, ErrKeyNotExist
+	}
+	if item.notFound {
+		return domain.User{}, ErrUserNotExistCached
+	}
+	return item.u, nil
+}
+
+// Set 和 Redis 一样不保存密码，不然从哪一级缓存读到的结果会不一样
+func (c *LocalUserCache) Set(ctx context.Context, du domain.User) error {
+	du.Password = ""
+	c.add(du.Id, userItem{u: du, expireAt: c.now().Add(c.expiration)})
+	return nil
+}
+
+func (c *LocalUserCache) SetNotFound(ctx context.Context, uid int64) error {
+	c.add(uid, userItem{notFound: true, expireAt: c.now().Add(c.notFoundExpiration)})
+	return nil
+}
+
+func (c *LocalUserCache) Del(ctx context.Context, uid int64) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.cache.Remove(uid)
+	return nil
+}
+
+func (c *LocalUserCache) add(uid int64, item userItem) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.cache.Add(uid, item)
+}
@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"Learn_Go/webook/internal/domain"
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// 监控的 label
+const (
+	levelLocal = "local"
+	levelRedis = "redis"
+
+	resultHit      = "hit"
+	resultMiss     = "miss"
+	resultNotFound = "not_found"
+	resultError    = "error"
+)
+
+// NewUserCacheCounter 每一级缓存的命中情况，label 是 level 和 result
+// result 是 hit、miss、not_found（命中了不存在的缓存）和 error
+func NewUserCacheCounter() *prometheus.CounterVec {
+	return prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "webook",
+		Subsystem: "cache",
+		Name:      "user_requests_total",
+		Help:      "用户缓存每一级的查询次数",
+	}, []string{"level", "result"})
+}
+
+// MultiLevelUserCache 先查本地缓存再查 Redis，Redis 命中之后回填本地缓存
+type MultiLevelUserCache struct {
+	local  UserCache
+	remote UserCache
+	// invalidation 删除的时候通知其它实例，为 nil 的时候其它实例只能等本地缓存过期
+	invalidation *UserCacheInvalidation
+	// requests 可以为 nil
+	requests *prometheus.CounterVec
+}
+
+func NewMultiLevelUserCache(local, remote UserCache, invalidation *UserCacheInvalidation,
+	requests *prometheus.CounterVec) UserCache {
+	return &MultiLevelUserCache{
+		local:        local,
+		remote:       remote,
+		invalidation: invalidation,
+		requests:     requests,
+	}
+}
+
+func (c *MultiLevelUserCache) Get(ctx context.Context, uid int64) (domain.User, error) {
+	u, err := c.local.Get(ctx, uid)
+	switch err {
+	case nil:
+		c.record(levelLocal, resultHit)
+		return u, nil
+	case ErrUserNotExistCached:
+		c.record(levelLocal, resultNotFound)
+		return u, err
+	}
+	c.record(levelLocal, resultMiss)
+
+	u, err = c.remote.Get(ctx, uid)
+	switch err {
+	case nil:
+		c.record(levelRedis, resultHit)
+		_ = c.local.Set(ctx, u)
+	case ErrUserNotExistCached:
+		c.record(levelRedis, resultNotFound)
+		_ = c.local.SetNotFound(ctx, uid)
+	case ErrKeyNotExist:
+		c.record(levelRedis, resultMiss)
+	default:
+		c.record(levelRedis, resultError)
+	}
+	return u, err
+}
+
+// Set Redis 写失败的时候本地缓存还是写进去，至少这个实例不用一直查数据库
+func (c *MultiLevelUserCache) Set(ctx context.Context, du domain.User) error {
+	_ = c.local.Set(ctx, du)
+	return c.remote.Set(ctx, du)
+}
+
+func (c *MultiLevelUserCache) SetNotFound(ctx context.Context, uid int64) error {
+	_ = c.local.SetNotFound(ctx, uid)
+	return c.remote.SetNotFound(ctx, uid)
+}
+
+// Del 先删 Redis 再删本地，不然其它请求可能又从 Redis 把旧数据回填到本地
+func (c *MultiLevelUserCache) Del(ctx context.Context, uid int64) error {
+	err := c.remote.Del(ctx, uid)
+	_ = c.local.Del(ctx, uid)
+	if c.invalidation == nil {
+		return err
+	}
+	if er := c.invalidation.Publish(ctx, uid); er != nil && err == nil {
+		err = er
+	}
+	return err
+}
+
+func (c *MultiLevelUserCache) record(level, result string) {
+	if c.requests != nil {
+		c.requests.WithLabelValues(level, result).Inc()
+	}
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"Learn_Go/webook/internal/repository/cache"
+	"context"
+)
+
+var ErrCaptchaNotFound = cache.ErrCaptchaNotFound
+
+type CaptchaRepository interface {
+	SetAnswer(ctx context.Context, id, answer string) error
+	VerifyAnswer(ctx context.Context, id, answer string) (bool, error)
+	SetTicket(ctx context.Context, biz, ticket string) error
+	ConsumeTicket(ctx context.Context, biz, ticket string) (bool, error)
+	IncrAttempt(ctx context.Context, biz, key string) (int64, error)
+	Attempts(ctx context.Context, biz, key string) (int64, error)
+	ResetAttempt(ctx context.Context, biz, key string) error
+}
+
+type CachedCaptchaRepository struct {
+	cache cache.CaptchaCache
+}
+
+func NewCaptchaRepository(c cache.CaptchaCache) CaptchaRepository {
+	return &CachedCaptchaRepository{
+		cache: c,
+	}
+}
+
+func (c *CachedCaptchaRepository) SetAnswer(ctx context.Context, id, answer string) error {
+	return c.cache.SetAnswer(ctx, id, answer)
+}
+
+func (c *CachedCaptchaRepository) VerifyAnswer(ctx context.Context, id, answer string) (bool, error) {
+	return c.cache.VerifyAnswer(ctx, id, answer)
+}
+
+func (c *CachedCaptchaRepository) SetTicket(ctx context.Context, biz, ticket string) error {
+	return c.cache.SetTicket(ctx, biz, ticket)
+}
+
+func (c *CachedCaptchaRepository) ConsumeTicket(ctx context.Context, biz, ticket string) (bool, error) {
+	return c.cache.ConsumeTicket(ctx, biz, ticket)
+}
+
+func (c *CachedCaptchaRepository) IncrAttempt(ctx context.Context, biz, key string) (int64, error) {
+	return c.cache.IncrAttempt(ctx, biz, key)
+}
+
+func (c *CachedCaptchaRepository) Attempts(ctx context.Context, biz, key string) (int64, error) {
+	return c.cache.Attempts(ctx, biz, key)
+}
+
+func (c *CachedCaptchaRepository) ResetAttempt(ctx context.Context, biz, key string) error {
+	return c.cache.ResetAttempt(ctx, biz, key)
+}
@@ -12,8 +12,20 @@ type ArticleDAO interface {
 	Create(ctx context.Context, art Article) (int64, error)
 	UpdateById(ctx context.Context, entity Article) error
 	Sync(ctx context.Context, entity Article) (int64, error)
+	// TakeDown 下架文章，线上库删掉，制作库标记为已下架
+	TakeDown(ctx context.Context, id int64) error
+	// ListByAuthor 按照 id 从小到大，查询 id 大于 maxId 的文章
+	ListByAuthor(ctx context.Context, authorId int64, maxId int64, limit int) ([]Article, error)
 }
 
+const (
+	ArticleStatusNormal uint8 = iota
+	// ArticleStatusTakenDown 被版主下架，作者不能再修改和发表
+	ArticleStatusTakenDown
+	// ArticleStatusWithdrawn 作者注销账号之后撤回
+	ArticleStatusWithdrawn
+)
+
 type ArticleGORMDAO struct {
 	db *gorm.DB
 }
@@ -114,7 +126,8 @@ func NewArticleGORMDAO(db *gorm.DB) ArticleDAO {
 
 func (a *ArticleGORMDAO) UpdateById(ctx context.Context, art Article) error {
 	now := time.Now().UnixMilli()
-	res := a.db.WithContext(ctx).Model(&art).Where("id = ? AND author_id = ?", art.Id, art.AuthorId).Updates(map[string]any{
+	res := a.db.WithContext(ctx).Model(&art).Where("id = ? AND author_id = ? AND status <> ?",
+		art.Id, art.AuthorId, ArticleStatusTakenDown).Updates(map[string]any{
 		"title":   art.Title,
 		"content": art.Content,
 		"utime":   now,
@@ -123,12 +136,60 @@ func (a *ArticleGORMDAO) UpdateById(ctx context.Context, art Article) error {
 		return res.Error
 	}
 	if res.RowsAffected == 0 {
-		// 这里不知道是 Id 不对还是 Author 不对，也不需要进行判定，普通用户进不来这里
+		// 这里不知道是 Id 不对、Author 不对还是已经被下架了，也不需要进行判定，普通用户进不来这里
 		return errors.New("更新失败，作者不对或者Id不对")
 	}
 	return nil
 }
 
+func (a *ArticleGORMDAO) TakeDown(ctx context.Context, id int64) error {
+	return a.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&Article{}).Where("id = ?", id).Updates(map[string]any{
+			"status": ArticleStatusTakenDown,
+			"utime":  time.Now().UnixMilli(),
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrRecordNotFound
+		}
+		// 读者看的是线上库，直接删掉就看不到了
+		return tx.Where("id = ?", id).Delete(&PublishedArticle{}).Error
+	})
+}
+
+// withdrawArticles 撤回作者的所有文章，线上库删掉，制作库标记为已撤回
+// 注销的时候和抹掉个人信息在同一个事务里面执行
+func withdrawArticles(tx *gorm.DB, authorId int64, now int64) error {
+	// 被下架的保持下架的状态
+	err := tx.Model(&Article{}).Where("author_id = ? AND status = ?", authorId, ArticleStatusNormal).
+		Updates(map[string]any{
+			"status": ArticleStatusWithdrawn,
+			"utime":  now,
+		}).Error
+	if err != nil {
+		return err
+	}
+	return tx.Where("author_id = ?", authorId).Delete(&PublishedArticle{}).Error
+}
+
+// mergeArticles 被合并账号的文章转移到目标账号，制作库和线上库都要改
+func mergeArticles(tx *gorm.DB, targetId, sourceId int64) error {
+	err := tx.Model(&Article{}).Where("author_id = ?", sourceId).Update("author_id", targetId).Error
+	if err != nil {
+		return err
+	}
+	return tx.Model(&PublishedArticle{}).Where("author_id = ?", sourceId).Update("author_id", targetId).Error
+}
+
+func (a *ArticleGORMDAO) ListByAuthor(ctx context.Context, authorId int64, maxId int64, limit int) ([]Article, error) {
+	var res []Article
+	err := a.db.WithContext(ctx).Where("author_id = ? AND id > ?", authorId, maxId).
+		Order("id").Limit(limit).Find(&res).Error
+	return res, err
+}
+
 func (a *ArticleGORMDAO) Create(ctx context.Context, art Article) (int64, error) {
 	now := time.Now().UnixMilli()
 
@@ -148,6 +209,7 @@ type Article struct {
 	Title    string `gorm:"type=varchar(4096)"`
 	Content  string `gorm:"type=BLOB"`
 	AuthorId int64  `gorm:"index"` // 这个索引是普通的索引
+	Status   uint8
 }
 
 // 同库不同表
@@ -4,5 +4,9 @@ import "gorm.io/gorm"
 
 func InitTables(db *gorm.DB) error {
 	// 严格来说，这不是优秀实践
-	return db.AutoMigrate(&User{}, &Article{})
+	err := db.AutoMigrate(&User{}, &Article{}, &PublishedArticle{}, &UserRole{}, &UserTOTP{}, &UserRecoveryCode{}, &UserOAuthBinding{})
+	if err != nil {
+		return err
+	}
+	return migrateWechatBindings(db)
 }
@@ -0,0 +1,165 @@
+package dao
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type MFADAO interface {
+	FindTOTP(ctx context.Context, uid int64) (UserTOTP, error)
+	// UpsertTOTP 重新绑定的时候覆盖之前没有确认的密钥
+	UpsertTOTP(ctx context.Context, t UserTOTP) error
+	EnableTOTP(ctx context.Context, uid int64, counter int64) error
+	// UpdateLastCounter 只有 counter 比记录的大才会更新，返回是否更新了，用来防止验证码被重复使用
+	UpdateLastCounter(ctx context.Context, uid int64, counter int64) (bool, error)
+	// DeleteTOTP 同时删掉恢复码
+	DeleteTOTP(ctx context.Context, uid int64) error
+	// ReplaceRecoveryCodes 删掉旧的恢复码，保存新的
+	ReplaceRecoveryCodes(ctx context.Context, uid int64, hashes []string) error
+	FindUnusedRecoveryCodes(ctx context.Context, uid int64) ([]UserRecoveryCode, error)
+	// UseRecoveryCode 返回是否是这一次用掉的，并发使用同一个恢复码的时候只有一个会成功
+	UseRecoveryCode(ctx context.Context, id int64) (bool, error)
+}
+
+type GORMMFADAO struct {
+	db *gorm.DB
+}
+
+func NewGORMMFADAO(db *gorm.DB) MFADAO {
+	return &GORMMFADAO{
+		db: db,
+	}
+}
+
+func (dao *GORMMFADAO) FindTOTP(ctx context.Context, uid int64) (UserTOTP, error) {
+	var res UserTOTP
+	err := dao.db.WithContext(ctx).Where("uid = ?", uid).First(&res).Error
+	return res, err
+}
+
+func (dao *GORMMFADAO) UpsertTOTP(ctx context.Context, t UserTOTP) error {
+	now := time.Now().UnixMilli()
+	t.Ctime = now
+	t.Utime = now
+	return dao.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "uid"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"secret":       t.Secret,
+			"enabled":      t.Enabled,
+			"last_counter": t.LastCounter,
+			"utime":        now,
+		}),
+	}).Create(&t).Error
+}
+
+func (dao *GORMMFADAO) EnableTOTP(ctx context.Context, uid int64, counter int64) error {
+	return dao.db.WithContext(ctx).Model(&UserTOTP{}).Where("uid = ?", uid).Updates(map[string]any{
+		"enabled":      true,
+		"last_counter": counter,
+		"utime":        time.Now().UnixMilli(),
+	}).Error
+}
+
+func (dao *GORMMFADAO) UpdateLastCounter(ctx context.Context, uid int64, counter int64) (bool, error) {
+	res := dao.db.WithContext(ctx).Model(&UserTOTP{}).
+		Where("uid = ? AND last_counter < ?", uid, counter).Updates(map[string]any{
+		"last_counter": counter,
+		"utime":        time.Now().UnixMilli(),
+	})
+	return res.RowsAffected > 0, res.Error
+}
+
+func (dao *GORMMFADAO) DeleteTOTP(ctx context.Context, uid int64) error {
+	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return deleteMFA(tx, uid)
+	})
+}
+
+func deleteMFA(tx *gorm.DB, uid int64) error {
+	if err := tx.Where("uid = ?", uid).Delete(&UserRecoveryCode{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("uid = ?", uid).Delete(&UserTOTP{}).Error
+}
+
+// mergeMFA 目标账号已经开启了两步验证的，用目标账号的，删掉被合并账号的
+// 否则把被合并账号的验证器应用连同恢复码一起转移过来，合并之后不会丢掉两步验证
+func mergeMFA(tx *gorm.DB, targetId, sourceId int64, now int64) error {
+	var enabled int64
+	err := tx.Model(&UserTOTP{}).Where("uid = ? AND enabled = ?", targetId, true).Count(&enabled).Error
+	if err != nil {
+		return err
+	}
+	if enabled > 0 {
+		return deleteMFA(tx, sourceId)
+	}
+	var cnt int64
+	if err = tx.Model(&UserTOTP{}).Where("uid = ?", sourceId).Count(&cnt).Error; err != nil {
+		return err
+	}
+	if cnt == 0 {
+		return nil
+	}
+	// 目标账号可能有一个还没有确认的，uid 有唯一索引，要先删掉
+	if err = deleteMFA(tx, targetId); err != nil {
+		return err
+	}
+	err = tx.Model(&UserRecoveryCode{}).Where("uid = ?", sourceId).Update("uid", targetId).Error
+	if err != nil {
+		return err
+	}
+	return tx.Model(&UserTOTP{}).Where("uid = ?", sourceId).Updates(map[string]any{
+		"uid":   targetId,
+		"utime": now,
+	}).Error
+}
+
+func (dao *GORMMFADAO) ReplaceRecoveryCodes(ctx context.Context, uid int64, hashes []string) error {
+	now := time.Now().UnixMilli()
+	codes := make([]UserRecoveryCode, 0, len(hashes))
+	for _, h := range hashes {
+		codes = append(codes, UserRecoveryCode{Uid: uid, Hash: h, Ctime: now})
+	}
+	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("uid = ?", uid).Delete(&UserRecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+func (dao *GORMMFADAO) FindUnusedRecoveryCodes(ctx context.Context, uid int64) ([]UserRecoveryCode, error) {
+	var res []UserRecoveryCode
+	err := dao.db.WithContext(ctx).Where("uid = ? AND used_at = 0", uid).Find(&res).Error
+	return res, err
+}
+
+func (dao *GORMMFADAO) UseRecoveryCode(ctx context.Context, id int64) (bool, error) {
+	res := dao.db.WithContext(ctx).Model(&UserRecoveryCode{}).
+		Where("id = ? AND used_at = 0", id).Update("used_at", time.Now().UnixMilli())
+	return res.RowsAffected > 0, res.Error
+}
+
+// UserTOTP 用户绑定的验证器应用，一个用户只能绑定一个
+type UserTOTP struct {
+	Id  int64 `gorm:"primaryKey,autoIncrement"`
+	Uid int64 `gorm:"unique"`
+	// base32 编码的密钥，验证的时候需要原文，所以不能像密码一样哈希
+	Secret      string `gorm:"type:varchar(64)"`
+	Enabled     bool
+	LastCounter int64
+	Ctime       int64
+	Utime       int64
+}
+
+// UserRecoveryCode 丢了手机之后用来登录的恢复码，每个只能用一次
+// 和密码一样只保存 bcrypt 的哈希
+type UserRecoveryCode struct {
+	Id     int64  `gorm:"primaryKey,autoIncrement"`
+	Uid    int64  `gorm:"index"`
+	Hash   string `gorm:"type:varchar(128)"`
+	UsedAt int64
+	Ctime  int64
+}
@@ -40,6 +40,34 @@ func (m *MockUserDao) EXPECT() *MockUserDaoMockRecorder {
 	return m.recorder
 }
 
+// Anonymize mocks base method.
+func (m *MockUserDao) Anonymize(ctx context.Context, uid, now int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Anonymize", ctx, uid, now)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Anonymize indicates an expected call of Anonymize.
+func (mr *MockUserDaoMockRecorder) Anonymize(ctx, uid, now any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Anonymize", reflect.TypeOf((*MockUserDao)(nil).Anonymize), ctx, uid, now)
+}
+
+// DeleteOAuthBinding mocks base method.
+func (m *MockUserDao) DeleteOAuthBinding(ctx context.Context, uid int64, provider string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteOAuthBinding", ctx, uid, provider)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteOAuthBinding indicates an expected call of DeleteOAuthBinding.
+func (mr *MockUserDaoMockRecorder) DeleteOAuthBinding(ctx, uid, provider any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteOAuthBinding", reflect.TypeOf((*MockUserDao)(nil).DeleteOAuthBinding), ctx, uid, provider)
+}
+
 // FindByEmail mocks base method.
 func (m *MockUserDao) FindByEmail(ctx context.Context, email string) (dao.User, error) {
 	m.ctrl.T.Helper()
@@ -70,6 +98,21 @@ func (mr *MockUserDaoMockRecorder) FindById(ctx, uid any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindById", reflect.TypeOf((*MockUserDao)(nil).FindById), ctx, uid)
 }
 
+// FindByOAuth mocks base method.
+func (m *MockUserDao) FindByOAuth(ctx context.Context, provider, subject string) (dao.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByOAuth", ctx, provider, subject)
+	ret0, _ := ret[0].(dao.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByOAuth indicates an expected call of FindByOAuth.
+func (mr *MockUserDaoMockRecorder) FindByOAuth(ctx, provider, subject any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByOAuth", reflect.TypeOf((*MockUserDao)(nil).FindByOAuth), ctx, provider, subject)
+}
+
 // FindByPhone mocks base method.
 func (m *MockUserDao) FindByPhone(ctx context.Context, phone string) (dao.User, error) {
 	m.ctrl.T.Helper()
@@ -85,27 +128,43 @@ func (mr *MockUserDaoMockRecorder) FindByPhone(ctx, phone any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByPhone", reflect.TypeOf((*MockUserDao)(nil).FindByPhone), ctx, phone)
 }
 
-// FindByWechat mocks base method.
-func (m *MockUserDao) FindByWechat(ctx context.Context, openId string) (dao.User, error) {
+// FindDueDeletions mocks base method.
+func (m *MockUserDao) FindDueDeletions(ctx context.Context, now int64, limit int) ([]dao.User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FindByWechat", ctx, openId)
-	ret0, _ := ret[0].(dao.User)
+	ret := m.ctrl.Call(m, "FindDueDeletions", ctx, now, limit)
+	ret0, _ := ret[0].([]dao.User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// FindByWechat indicates an expected call of FindByWechat.
-func (mr *MockUserDaoMockRecorder) FindByWechat(ctx, openId any) *gomock.Call {
+// FindDueDeletions indicates an expected call of FindDueDeletions.
+func (mr *MockUserDaoMockRecorder) FindDueDeletions(ctx, now, limit any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByWechat", reflect.TypeOf((*MockUserDao)(nil).FindByWechat), ctx, openId)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindDueDeletions", reflect.TypeOf((*MockUserDao)(nil).FindDueDeletions), ctx, now, limit)
+}
+
+// FindOAuthBindings mocks base method.
+func (m *MockUserDao) FindOAuthBindings(ctx context.Context, uid int64) ([]dao.UserOAuthBinding, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindOAuthBindings", ctx, uid)
+	ret0, _ := ret[0].([]dao.UserOAuthBinding)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindOAuthBindings indicates an expected call of FindOAuthBindings.
+func (mr *MockUserDaoMockRecorder) FindOAuthBindings(ctx, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOAuthBindings", reflect.TypeOf((*MockUserDao)(nil).FindOAuthBindings), ctx, uid)
 }
 
 // Insert mocks base method.
-func (m *MockUserDao) Insert(ctx context.Context, u dao.User) error {
+func (m *MockUserDao) Insert(ctx context.Context, u dao.User) (int64, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Insert", ctx, u)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // Insert indicates an expected call of Insert.
@@ -114,6 +173,63 @@ func (mr *MockUserDaoMockRecorder) Insert(ctx, u any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Insert", reflect.TypeOf((*MockUserDao)(nil).Insert), ctx, u)
 }
 
+// InsertWithOAuth mocks base method.
+func (m *MockUserDao) InsertWithOAuth(ctx context.Context, u dao.User, b dao.UserOAuthBinding) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertWithOAuth", ctx, u, b)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InsertWithOAuth indicates an expected call of InsertWithOAuth.
+func (mr *MockUserDaoMockRecorder) InsertWithOAuth(ctx, u, b any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertWithOAuth", reflect.TypeOf((*MockUserDao)(nil).InsertWithOAuth), ctx, u, b)
+}
+
+// Merge mocks base method.
+func (m *MockUserDao) Merge(ctx context.Context, target dao.User, sourceId int64, providers []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Merge", ctx, target, sourceId, providers)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Merge indicates an expected call of Merge.
+func (mr *MockUserDaoMockRecorder) Merge(ctx, target, sourceId, providers any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Merge", reflect.TypeOf((*MockUserDao)(nil).Merge), ctx, target, sourceId, providers)
+}
+
+// UpdateAvatar mocks base method.
+func (m *MockUserDao) UpdateAvatar(ctx context.Context, uid int64, avatar string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateAvatar", ctx, uid, avatar)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateAvatar indicates an expected call of UpdateAvatar.
+func (mr *MockUserDaoMockRecorder) UpdateAvatar(ctx, uid, avatar any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAvatar", reflect.TypeOf((*MockUserDao)(nil).UpdateAvatar), ctx, uid, avatar)
+}
+
+// UpdateBindings mocks base method.
+func (m *MockUserDao) UpdateBindings(ctx context.Context, entity dao.User) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateBindings", ctx, entity)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateBindings indicates an expected call of UpdateBindings.
+func (mr *MockUserDaoMockRecorder) UpdateBindings(ctx, entity any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateBindings", reflect.TypeOf((*MockUserDao)(nil).UpdateBindings), ctx, entity)
+}
+
 // UpdateById mocks base method.
 func (m *MockUserDao) UpdateById(ctx context.Context, entity dao.User) error {
 	m.ctrl.T.Helper()
@@ -127,3 +243,73 @@ func (mr *MockUserDaoMockRecorder) UpdateById(ctx, entity any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateById", reflect.TypeOf((*MockUserDao)(nil).UpdateById), ctx, entity)
 }
+
+// UpdateDeleteAt mocks base method.
+func (m *MockUserDao) UpdateDeleteAt(ctx context.Context, uid, deleteAt int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateDeleteAt", ctx, uid, deleteAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateDeleteAt indicates an expected call of UpdateDeleteAt.
+func (mr *MockUserDaoMockRecorder) UpdateDeleteAt(ctx, uid, deleteAt any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateDeleteAt", reflect.TypeOf((*MockUserDao)(nil).UpdateDeleteAt), ctx, uid, deleteAt)
+}
+
+// UpdatePassword mocks base method.
+func (m *MockUserDao) UpdatePassword(ctx context.Context, uid int64, password string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePassword", ctx, uid, password)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdatePassword indicates an expected call of UpdatePassword.
+func (mr *MockUserDaoMockRecorder) UpdatePassword(ctx, uid, password any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePassword", reflect.TypeOf((*MockUserDao)(nil).UpdatePassword), ctx, uid, password)
+}
+
+// UpdatePrivacy mocks base method.
+func (m *MockUserDao) UpdatePrivacy(ctx context.Context, entity dao.User) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePrivacy", ctx, entity)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdatePrivacy indicates an expected call of UpdatePrivacy.
+func (mr *MockUserDaoMockRecorder) UpdatePrivacy(ctx, entity any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePrivacy", reflect.TypeOf((*MockUserDao)(nil).UpdatePrivacy), ctx, entity)
+}
+
+// UpdateStatus mocks base method.
+func (m *MockUserDao) UpdateStatus(ctx context.Context, uid int64, status uint8, suspendedUntil int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateStatus", ctx, uid, status, suspendedUntil)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateStatus indicates an expected call of UpdateStatus.
+func (mr *MockUserDaoMockRecorder) UpdateStatus(ctx, uid, status, suspendedUntil any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateStatus", reflect.TypeOf((*MockUserDao)(nil).UpdateStatus), ctx, uid, status, suspendedUntil)
+}
+
+// UpsertOAuthBinding mocks base method.
+func (m *MockUserDao) UpsertOAuthBinding(ctx context.Context, b dao.UserOAuthBinding) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpsertOAuthBinding", ctx, b)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpsertOAuthBinding indicates an expected call of UpsertOAuthBinding.
+func (mr *MockUserDaoMockRecorder) UpsertOAuthBinding(ctx, b any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertOAuthBinding", reflect.TypeOf((*MockUserDao)(nil).UpsertOAuthBinding), ctx, b)
+}
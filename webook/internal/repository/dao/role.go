@@ -0,0 +1,68 @@
+package dao
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type RoleDAO interface {
+	FindByUid(ctx context.Context, uid int64) ([]UserRole, error)
+	// Insert 已经有这个角色的时候什么也不做
+	Insert(ctx context.Context, r UserRole) error
+	Delete(ctx context.Context, uid int64, role string) error
+}
+
+type GORMRoleDAO struct {
+	db *gorm.DB
+}
+
+func NewGORMRoleDAO(db *gorm.DB) RoleDAO {
+	return &GORMRoleDAO{
+		db: db,
+	}
+}
+
+func (dao *GORMRoleDAO) FindByUid(ctx context.Context, uid int64) ([]UserRole, error) {
+	var res []UserRole
+	err := dao.db.WithContext(ctx).Where("uid = ?", uid).Find(&res).Error
+	return res, err
+}
+
+func (dao *GORMRoleDAO) Insert(ctx context.Context, r UserRole) error {
+	r.Ctime = time.Now().UnixMilli()
+	return dao.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&r).Error
+}
+
+func (dao *GORMRoleDAO) Delete(ctx context.Context, uid int64, role string) error {
+	return dao.db.WithContext(ctx).Where("uid = ? AND role = ?", uid, role).Delete(&UserRole{}).Error
+}
+
+// mergeRoles 被合并账号的角色转移到目标账号，两边都有的角色只保留一个
+func mergeRoles(tx *gorm.DB, targetId, sourceId int64, now int64) error {
+	var roles []UserRole
+	if err := tx.Where("uid = ?", sourceId).Find(&roles).Error; err != nil {
+		return err
+	}
+	if len(roles) > 0 {
+		merged := make([]UserRole, 0, len(roles))
+		for _, r := range roles {
+			merged = append(merged, UserRole{Uid: targetId, Role: r.Role, Ctime: now})
+		}
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&merged).Error
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Where("uid = ?", sourceId).Delete(&UserRole{}).Error
+}
+
+// UserRole 用户拥有的角色，一个用户可以有多个角色
+// 大部分用户只有默认的 user 角色，所以不保存，这张表只记录额外分配的角色
+type UserRole struct {
+	Id    int64  `gorm:"primaryKey,autoIncrement"`
+	Uid   int64  `gorm:"uniqueIndex:uid_role"`
+	Role  string `gorm:"type:varchar(32);uniqueIndex:uid_role"`
+	Ctime int64
+}
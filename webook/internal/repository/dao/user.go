@@ -10,14 +10,52 @@ import (
 )
 
 type UserDao interface {
-	Insert(ctx context.Context, u User) error
+	// Insert 返回新用户的 id
+	Insert(ctx context.Context, u User) (int64, error)
 	FindByEmail(ctx context.Context, email string) (User, error)
 	UpdateById(ctx context.Context, entity User) error
 	FindById(ctx context.Context, uid int64) (User, error)
 	FindByPhone(ctx context.Context, phone string) (User, error)
-	FindByWechat(ctx context.Context, openId string) (User, error)
+	// FindByOAuth 通过第三方登录的身份找到用户
+	FindByOAuth(ctx context.Context, provider, subject string) (User, error)
+	// UpdateStatus suspendedUntil 只有封禁的时候有意义，0 表示永久封禁
+	UpdateStatus(ctx context.Context, uid int64, status uint8, suspendedUntil int64) error
+	// UpdatePassword password 是加密之后的密码
+	UpdatePassword(ctx context.Context, uid int64, password string) error
+	UpdateAvatar(ctx context.Context, uid int64, avatar string) error
+	// UpdatePrivacy 修改 entity 里面 Privacy 开头的字段
+	UpdatePrivacy(ctx context.Context, entity User) error
+	// UpdateBindings 修改绑定的手机号和邮箱，NULL 表示解绑
+	// 已经被其它用户绑定的时候返回 ErrDuplicateEmail
+	UpdateBindings(ctx context.Context, entity User) error
+	// Merge 把 sourceId 合并到 target 里面，target 的绑定和密码以传入的为准，sourceId 被标记为注销
+	// providers 是要从 sourceId 转移到 target 的第三方登录，target 原来的同名绑定会被替换，sourceId 剩下的绑定直接删掉
+	Merge(ctx context.Context, target User, sourceId int64, providers []string) error
+
+	// InsertWithOAuth 第三方登录的新用户，用户和绑定关系一起插入
+	InsertWithOAuth(ctx context.Context, u User, b UserOAuthBinding) (int64, error)
+	FindOAuthBindings(ctx context.Context, uid int64) ([]UserOAuthBinding, error)
+	// UpsertOAuthBinding 一个用户在同一个第三方只能绑定一个账号，已经绑定的会被替换
+	// 这个第三方账号已经被其它用户绑定的时候返回 ErrDuplicateEmail
+	UpsertOAuthBinding(ctx context.Context, b UserOAuthBinding) error
+	DeleteOAuthBinding(ctx context.Context, uid int64, provider string) error
+
+	// UpdateDeleteAt 申请注销的时候设置注销时间，撤销的时候 deleteAt 是 0
+	UpdateDeleteAt(ctx context.Context, uid int64, deleteAt int64) error
+	// FindDueDeletions 注销时间已经到了，但是还没有处理的用户
+	FindDueDeletions(ctx context.Context, now int64, limit int) ([]User, error)
+	// Anonymize 抹掉个人信息和所有绑定，撤回所有文章，标记为注销
+	// 注销时间还没到或者已经撤销了的时候返回 ErrRecordNotFound
+	Anonymize(ctx context.Context, uid int64, now int64) error
 }
 
+// 账号状态，取值和 domain.UserStatus 一致
+const (
+	UserStatusActive uint8 = iota
+	UserStatusSuspended
+	UserStatusDeleted
+)
+
 type GORMUserDao struct {
 	db *gorm.DB
 }
@@ -27,7 +65,7 @@ var (
 	ErrRecordNotFound = gorm.ErrRecordNotFound
 )
 
-func (dao *GORMUserDao) Insert(ctx context.Context, u User) error {
+func (dao *GORMUserDao) Insert(ctx context.Context, u User) (int64, error) {
 	now := time.Now().UnixMilli()
 	u.Ctime = now
 	u.Utime = now
@@ -38,10 +76,10 @@ func (dao *GORMUserDao) Insert(ctx context.Context, u User) error {
 		const duplicateErr uint16 = 1062
 		if me.Number == duplicateErr {
 			// 用户冲突，邮箱冲突
-			return ErrDuplicateEmail
+			return 0, ErrDuplicateEmail
 		}
 	}
-	return err
+	return u.Id, err // 自增主键会填回 u
 }
 
 func (dao *GORMUserDao) FindByEmail(ctx context.Context, email string) (User, error) {
@@ -71,10 +109,133 @@ func (dao *GORMUserDao) FindByPhone(ctx context.Context, phone string) (User, er
 	return u, err
 }
 
-func (dao *GORMUserDao) FindByWechat(ctx context.Context, openId string) (User, error) {
-	var u User
-	err := dao.db.WithContext(ctx).Where("wechat_open_id = ?", openId).First(&u).Error
-	return u, err
+func (dao *GORMUserDao) UpdateStatus(ctx context.Context, uid int64, status uint8, suspendedUntil int64) error {
+	res := dao.db.WithContext(ctx).Model(&User{}).Where("id = ?", uid).Updates(map[string]any{
+		"utime":           time.Now().UnixMilli(),
+		"status":          status,
+		"suspended_until": suspendedUntil,
+	})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (dao *GORMUserDao) UpdatePassword(ctx context.Context, uid int64, password string) error {
+	res := dao.db.WithContext(ctx).Model(&User{}).Where("id = ?", uid).Updates(map[string]any{
+		"utime":    time.Now().UnixMilli(),
+		"password": password,
+	})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (dao *GORMUserDao) UpdateAvatar(ctx context.Context, uid int64, avatar string) error {
+	res := dao.db.WithContext(ctx).Model(&User{}).Where("id = ?", uid).Updates(map[string]any{
+		"utime":  time.Now().UnixMilli(),
+		"avatar": avatar,
+	})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (dao *GORMUserDao) UpdatePrivacy(ctx context.Context, entity User) error {
+	res := dao.db.WithContext(ctx).Model(&User{}).Where("id = ?", entity.Id).Updates(map[string]any{
+		"utime":            time.Now().UnixMilli(),
+		"privacy_email":    entity.PrivacyEmail,
+		"privacy_phone":    entity.PrivacyPhone,
+		"privacy_birthday": entity.PrivacyBirthday,
+		"privacy_about_me": entity.PrivacyAboutMe,
+	})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (dao *GORMUserDao) UpdateBindings(ctx context.Context, entity User) error {
+	res := dao.db.WithContext(ctx).Model(&User{}).Where("id = ?", entity.Id).Updates(map[string]any{
+		"utime": time.Now().UnixMilli(),
+		"email": entity.Email,
+		"phone": entity.Phone,
+	})
+	if isDuplicateErr(res.Error) {
+		return ErrDuplicateEmail
+	}
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (dao *GORMUserDao) Merge(ctx context.Context, target User, sourceId int64, providers []string) error {
+	now := time.Now().UnixMilli()
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 先清空被合并账号的绑定，不然下面更新的时候会违反唯一索引
+		res := tx.Model(&User{}).Where("id = ? AND status <> ?", sourceId, UserStatusDeleted).Updates(map[string]any{
+			"utime":  now,
+			"email":  sql.NullString{},
+			"phone":  sql.NullString{},
+			"status": UserStatusDeleted,
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrRecordNotFound
+		}
+		res = tx.Model(&User{}).Where("id = ?", target.Id).Updates(map[string]any{
+			"utime":    now,
+			"email":    target.Email,
+			"phone":    target.Phone,
+			"password": target.Password,
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrRecordNotFound
+		}
+		if err := mergeOAuthBindings(tx, target.Id, sourceId, providers, now); err != nil {
+			return err
+		}
+		if err := mergeArticles(tx, target.Id, sourceId); err != nil {
+			return err
+		}
+		if err := mergeRoles(tx, target.Id, sourceId, now); err != nil {
+			return err
+		}
+		return mergeMFA(tx, target.Id, sourceId, now)
+	})
+	if isDuplicateErr(err) {
+		return ErrDuplicateEmail
+	}
+	return err
+}
+
+func isDuplicateErr(err error) bool {
+	const duplicateErr uint16 = 1062
+	me, ok := err.(*mysql.MySQLError)
+	return ok && me.Number == duplicateErr
 }
 
 func NewGORMUserDao(db *gorm.DB) UserDao {
@@ -97,11 +258,18 @@ type User struct {
 	Birthday int64
 	AboutMe  string         `gorm:"type=varchar(4096)"`
 	Nickname string         `gorm:"type=varchar(128)"`
+	Avatar   string         `gorm:"type:varchar(1024)"`
 	Phone    sql.NullString `gorm:"unique"`
-	// 这里的索引有两种方案
-	// 1.如果这里要同时使用OpenId和UnionId，那么这里要设置联合索引
-	// 2.如果这里只查询OpenId，那么这里就设置OpenId为唯一索引，或者<openId, unionId>联合索引
-	// 3.如果只查询unionId，那么就在unionid上设置唯一索引，或者<unionId, openId>联合索引
-	WechatOpenId  sql.NullString `gorm:"unique"`
-	WechatUnionId sql.NullString
+	// 微信等第三方登录的绑定关系在 UserOAuthBinding 里面
+	// 账号状态，0 是正常
+	Status uint8
+	// 封禁到什么时候，0 表示永久封禁
+	SuspendedUntil int64
+	// 申请注销之后，到了这个时间才真正注销，0 表示没有申请
+	DeleteAt int64 `gorm:"index"`
+	// 主页上面各个字段的可见范围，0 表示没有设置过，用默认的
+	PrivacyEmail    uint8
+	PrivacyPhone    uint8
+	PrivacyBirthday uint8
+	PrivacyAboutMe  uint8
 }
@@ -0,0 +1,68 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"gorm.io/gorm"
+	"time"
+)
+
+func (dao *GORMUserDao) UpdateDeleteAt(ctx context.Context, uid int64, deleteAt int64) error {
+	res := dao.db.WithContext(ctx).Model(&User{}).
+		Where("id = ? AND status <> ?", uid, UserStatusDeleted).Updates(map[string]any{
+		"delete_at": deleteAt,
+		"utime":     time.Now().UnixMilli(),
+	})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (dao *GORMUserDao) FindDueDeletions(ctx context.Context, now int64, limit int) ([]User, error) {
+	var res []User
+	err := dao.db.WithContext(ctx).
+		Where("delete_at > 0 AND delete_at <= ? AND status <> ?", now, UserStatusDeleted).
+		Order("delete_at").Limit(limit).Find(&res).Error
+	return res, err
+}
+
+func (dao *GORMUserDao) Anonymize(ctx context.Context, uid int64, now int64) error {
+	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 带上 delete_at 的条件，防止查出来之后用户刚好登录撤销了注销
+		res := tx.Model(&User{}).
+			Where("id = ? AND delete_at > 0 AND delete_at <= ? AND status <> ?", uid, now, UserStatusDeleted).
+			Updates(map[string]any{
+				"email":     sql.NullString{},
+				"phone":     sql.NullString{},
+				"password":  "",
+				"nickname":  "",
+				"avatar":    "",
+				"about_me":  "",
+				"birthday":  0,
+				"status":    UserStatusDeleted,
+				"delete_at": 0,
+				"utime":     now,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrRecordNotFound
+		}
+		// 确认真的要注销之后才撤回文章，用户刚好撤销了注销的时候文章不受影响
+		if err := withdrawArticles(tx, uid, now); err != nil {
+			return err
+		}
+		// 第三方账号、两步验证和角色都和这个人有关，一起删掉
+		for _, model := range []any{&UserOAuthBinding{}, &UserTOTP{}, &UserRecoveryCode{}, &UserRole{}} {
+			if err := tx.Where("uid = ?", uid).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,120 @@
+package dao
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"time"
+)
+
+func (dao *GORMUserDao) FindByOAuth(ctx context.Context, provider, subject string) (User, error) {
+	var u User
+	err := dao.db.WithContext(ctx).
+		Joins("JOIN user_oauth_bindings ON user_oauth_bindings.uid = users.id").
+		Where("user_oauth_bindings.provider = ? AND user_oauth_bindings.subject = ?", provider, subject).
+		First(&u).Error
+	return u, err
+}
+
+func (dao *GORMUserDao) InsertWithOAuth(ctx context.Context, u User, b UserOAuthBinding) (int64, error) {
+	now := time.Now().UnixMilli()
+	u.Ctime, u.Utime = now, now
+	b.Ctime, b.Utime = now, now
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&u).Error; err != nil {
+			return err
+		}
+		b.Uid = u.Id
+		return tx.Create(&b).Error
+	})
+	if isDuplicateErr(err) {
+		return 0, ErrDuplicateEmail
+	}
+	return u.Id, err
+}
+
+func (dao *GORMUserDao) FindOAuthBindings(ctx context.Context, uid int64) ([]UserOAuthBinding, error) {
+	var res []UserOAuthBinding
+	err := dao.db.WithContext(ctx).Where("uid = ?", uid).Order("id").Find(&res).Error
+	return res, err
+}
+
+func (dao *GORMUserDao) UpsertOAuthBinding(ctx context.Context, b UserOAuthBinding) error {
+	now := time.Now().UnixMilli()
+	b.Ctime, b.Utime = now, now
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("uid = ? AND provider = ?", b.Uid, b.Provider).Delete(&UserOAuthBinding{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(&b).Error
+	})
+	if isDuplicateErr(err) {
+		return ErrDuplicateEmail
+	}
+	return err
+}
+
+func (dao *GORMUserDao) DeleteOAuthBinding(ctx context.Context, uid int64, provider string) error {
+	return dao.db.WithContext(ctx).Where("uid = ? AND provider = ?", uid, provider).
+		Delete(&UserOAuthBinding{}).Error
+}
+
+// mergeOAuthBindings 在 Merge 的事务里面调用
+func mergeOAuthBindings(tx *gorm.DB, targetId, sourceId int64, providers []string, now int64) error {
+	if len(providers) > 0 {
+		err := tx.Where("uid = ? AND provider IN ?", targetId, providers).Delete(&UserOAuthBinding{}).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Model(&UserOAuthBinding{}).Where("uid = ? AND provider IN ?", sourceId, providers).
+			Updates(map[string]any{
+				"uid":   targetId,
+				"utime": now,
+			}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Where("uid = ?", sourceId).Delete(&UserOAuthBinding{}).Error
+}
+
+// migrateWechatBindings 以前微信的 openid 和 unionid 直接存在 users 表上，搬到 user_oauth_bindings 之后删掉这两列
+// 搬过的不会重复搬，中途失败的话重新启动就可以
+func migrateWechatBindings(db *gorm.DB) error {
+	m := db.Migrator()
+	if !m.HasColumn(&User{}, "wechat_open_id") {
+		return nil
+	}
+	err := db.Exec(`INSERT INTO user_oauth_bindings (uid, provider, subject, union_id, ctime, utime)
+SELECT id, 'wechat', wechat_open_id, COALESCE(wechat_union_id, ''), ctime, utime FROM users
+WHERE wechat_open_id IS NOT NULL AND NOT EXISTS (
+	SELECT 1 FROM user_oauth_bindings b WHERE b.provider = 'wechat' AND b.subject = users.wechat_open_id
+)`).Error
+	if err != nil {
+		return err
+	}
+	if err = m.DropColumn(&User{}, "wechat_open_id"); err != nil {
+		return err
+	}
+	if m.HasColumn(&User{}, "wechat_union_id") {
+		return m.DropColumn(&User{}, "wechat_union_id")
+	}
+	return nil
+}
+
+// UserOAuthBinding 用户和第三方账号的绑定关系
+// 一个用户在同一个第三方只能绑定一个账号，一个第三方账号也只能绑定一个用户
+type UserOAuthBinding struct {
+	Id       int64  `gorm:"primaryKey,autoIncrement"`
+	Uid      int64  `gorm:"uniqueIndex:uid_provider"`
+	Provider string `gorm:"type:varchar(32);uniqueIndex:uid_provider;uniqueIndex:provider_subject"`
+	Subject  string `gorm:"type:varchar(255);uniqueIndex:provider_subject"`
+	// UnionId 只有微信有
+	UnionId string `gorm:"type:varchar(255)"`
+	Ctime   int64
+	Utime   int64
+}
+
+func (UserOAuthBinding) TableName() string {
+	return "user_oauth_bindings"
+}
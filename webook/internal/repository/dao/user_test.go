@@ -7,8 +7,11 @@ import (
 	"github.com/DATA-DOG/go-sqlmock"
 	mysqlDriver "github.com/go-sql-driver/mysql"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"path/filepath"
 	"testing"
 )
 
@@ -18,6 +21,7 @@ func TestGORMUserDao_Insert(t *testing.T) {
 		mock    func(t *testing.T) *sql.DB
 		ctx     context.Context
 		user    User
+		wantId  int64
 		wantErr error
 	}{
 		{
@@ -35,6 +39,7 @@ func TestGORMUserDao_Insert(t *testing.T) {
 			user: User{
 				Nickname: "lip",
 			},
+			wantId:  123,
 			wantErr: nil,
 		},
 		{
@@ -81,8 +86,125 @@ func TestGORMUserDao_Insert(t *testing.T) {
 			})
 			assert.NoError(t, err)
 			dao := NewGORMUserDao(db)
-			err = dao.Insert(tc.ctx, tc.user)
+			id, err := dao.Insert(tc.ctx, tc.user)
 			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantId, id)
 		})
 	}
 }
+
+// 用 SQLite 跑真实的 SQL，确认被合并账号名下的数据都转移过来了
+func TestGORMUserDao_Merge(t *testing.T) {
+	db := newSQLiteDB(t)
+	const targetId, sourceId = 1, 2
+	require.NoError(t, db.Create(&[]User{
+		{Id: targetId, Email: sql.NullString{String: "1@qq.com", Valid: true}},
+		{Id: sourceId, Phone: sql.NullString{String: "15012345678", Valid: true}},
+	}).Error)
+	require.NoError(t, db.Create(&Article{Id: 11, AuthorId: sourceId, Title: "草稿"}).Error)
+	require.NoError(t, db.Create(&PublishedArticle{Id: 11, AuthorId: sourceId, Title: "草稿"}).Error)
+	require.NoError(t, db.Create(&[]UserRole{
+		{Uid: targetId, Role: "moderator"},
+		{Uid: sourceId, Role: "moderator"},
+		{Uid: sourceId, Role: "admin"},
+	}).Error)
+	// 目标账号只有一个没有确认的，用被合并账号已经开启的
+	require.NoError(t, db.Create(&[]UserTOTP{
+		{Uid: targetId, Secret: "target"},
+		{Uid: sourceId, Secret: "source", Enabled: true},
+	}).Error)
+	require.NoError(t, db.Create(&UserRecoveryCode{Uid: sourceId, Hash: "hash"}).Error)
+
+	dao := NewGORMUserDao(db)
+	err := dao.Merge(context.Background(), User{
+		Id:    targetId,
+		Email: sql.NullString{String: "1@qq.com", Valid: true},
+		Phone: sql.NullString{String: "15012345678", Valid: true},
+	}, sourceId, nil)
+	require.NoError(t, err)
+
+	var art Article
+	require.NoError(t, db.First(&art, 11).Error)
+	assert.Equal(t, int64(targetId), art.AuthorId)
+	var pub PublishedArticle
+	require.NoError(t, db.First(&pub, 11).Error)
+	assert.Equal(t, int64(targetId), pub.AuthorId)
+
+	var roles []string
+	require.NoError(t, db.Model(&UserRole{}).Where("uid = ?", targetId).Order("role").Pluck("role", &roles).Error)
+	assert.Equal(t, []string{"admin", "moderator"}, roles)
+
+	var totp UserTOTP
+	require.NoError(t, db.Where("uid = ?", targetId).First(&totp).Error)
+	assert.Equal(t, "source", totp.Secret)
+	assert.True(t, totp.Enabled)
+	var codes []UserRecoveryCode
+	require.NoError(t, db.Where("uid = ?", targetId).Find(&codes).Error)
+	assert.Len(t, codes, 1)
+
+	// 被合并账号名下什么都不剩
+	for _, m := range []any{&UserRole{}, &UserTOTP{}, &UserRecoveryCode{}} {
+		var cnt int64
+		require.NoError(t, db.Model(m).Where("uid = ?", sourceId).Count(&cnt).Error)
+		assert.Zero(t, cnt)
+	}
+	var source User
+	require.NoError(t, db.First(&source, sourceId).Error)
+	assert.Equal(t, uint8(UserStatusDeleted), source.Status)
+}
+
+func TestGORMUserDao_Anonymize(t *testing.T) {
+	const now = 1700000000000
+	testCases := []struct {
+		name     string
+		deleteAt int64
+
+		wantErr    error
+		wantStatus uint8
+		// 制作库里面文章的状态，线上库还有没有这篇文章
+		wantArtStatus uint8
+		wantPublished bool
+	}{
+		{
+			name:          "抹掉个人信息，撤回文章",
+			deleteAt:      now - 1,
+			wantStatus:    UserStatusDeleted,
+			wantArtStatus: ArticleStatusWithdrawn,
+		},
+		{
+			// 查出来之后用户登录撤销了注销，文章不能被撤回
+			name:          "已经撤销了注销",
+			wantErr:       ErrRecordNotFound,
+			wantArtStatus: ArticleStatusNormal,
+			wantPublished: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := newSQLiteDB(t)
+			require.NoError(t, db.Create(&User{Id: 1, Nickname: "lip", DeleteAt: tc.deleteAt}).Error)
+			require.NoError(t, db.Create(&Article{Id: 11, AuthorId: 1, Title: "标题"}).Error)
+			require.NoError(t, db.Create(&PublishedArticle{Id: 11, AuthorId: 1, Title: "标题"}).Error)
+
+			err := NewGORMUserDao(db).Anonymize(context.Background(), 1, now)
+			assert.Equal(t, tc.wantErr, err)
+
+			var u User
+			require.NoError(t, db.First(&u, 1).Error)
+			assert.Equal(t, tc.wantStatus, u.Status)
+			var art Article
+			require.NoError(t, db.First(&art, 11).Error)
+			assert.Equal(t, tc.wantArtStatus, art.Status)
+			var cnt int64
+			require.NoError(t, db.Model(&PublishedArticle{}).Where("id = ?", 11).Count(&cnt).Error)
+			assert.Equal(t, tc.wantPublished, cnt > 0)
+		})
+	}
+}
+
+func newSQLiteDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "webook.db")), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, InitTables(db))
+	return db
+}
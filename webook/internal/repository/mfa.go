@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"Learn_Go/webook/internal/domain"
+	"Learn_Go/webook/internal/repository/dao"
+	"context"
+)
+
+var ErrTOTPNotFound = dao.ErrRecordNotFound
+
+type MFARepository interface {
+	FindTOTP(ctx context.Context, uid int64) (domain.TOTP, error)
+	SaveTOTP(ctx context.Context, t domain.TOTP) error
+	EnableTOTP(ctx context.Context, uid int64, counter int64) error
+	UpdateLastCounter(ctx context.Context, uid int64, counter int64) (bool, error)
+	DeleteTOTP(ctx context.Context, uid int64) error
+	ReplaceRecoveryCodes(ctx context.Context, uid int64, hashes []string) error
+	FindUnusedRecoveryCodes(ctx context.Context, uid int64) ([]domain.RecoveryCode, error)
+	UseRecoveryCode(ctx context.Context, id int64) (bool, error)
+}
+
+// GORMMFARepository 登录的时候才会用到，量不大，所以没有缓存
+type GORMMFARepository struct {
+	dao dao.MFADAO
+}
+
+func NewMFARepository(d dao.MFADAO) MFARepository {
+	return &GORMMFARepository{
+		dao: d,
+	}
+}
+
+func (repo *GORMMFARepository) FindTOTP(ctx context.Context, uid int64) (domain.TOTP, error) {
+	t, err := repo.dao.FindTOTP(ctx, uid)
+	if err != nil {
+		return domain.TOTP{}, err
+	}
+	return domain.TOTP{
+		Uid:         t.Uid,
+		Secret:      t.Secret,
+		Enabled:     t.Enabled,
+		LastCounter: t.LastCounter,
+	}, nil
+}
+
+func (repo *GORMMFARepository) SaveTOTP(ctx context.Context, t domain.TOTP) error {
+	return repo.dao.UpsertTOTP(ctx, dao.UserTOTP{
+		Uid:         t.Uid,
+		Secret:      t.Secret,
+		Enabled:     t.Enabled,
+		LastCounter: t.LastCounter,
+	})
+}
+
+func (repo *GORMMFARepository) EnableTOTP(ctx context.Context, uid int64, counter int64) error {
+	return repo.dao.EnableTOTP(ctx, uid, counter)
+}
+
+func (repo *GORMMFARepository) UpdateLastCounter(ctx context.Context, uid int64, counter int64) (bool, error) {
+	return repo.dao.UpdateLastCounter(ctx, uid, counter)
+}
+
+func (repo *GORMMFARepository) DeleteTOTP(ctx context.Context, uid int64) error {
+	return repo.dao.DeleteTOTP(ctx, uid)
+}
+
+func (repo *GORMMFARepository) ReplaceRecoveryCodes(ctx context.Context, uid int64, hashes []string) error {
+	return repo.dao.ReplaceRecoveryCodes(ctx, uid, hashes)
+}
+
+func (repo *GORMMFARepository) FindUnusedRecoveryCodes(ctx context.Context, uid int64) ([]domain.RecoveryCode, error) {
+	codes, err := repo.dao.FindUnusedRecoveryCodes(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.RecoveryCode, 0, len(codes))
+	for _, c := range codes {
+		res = append(res, domain.RecoveryCode{Id: c.Id, Hash: c.Hash})
+	}
+	return res, nil
+}
+
+func (repo *GORMMFARepository) UseRecoveryCode(ctx context.Context, id int64) (bool, error) {
+	return repo.dao.UseRecoveryCode(ctx, id)
+}
@@ -0,0 +1,142 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./webook/internal/repository/captcha.go
+//
+// Generated by this command:
+//
+//	mockgen -source=./webook/internal/repository/captcha.go -package=repomocks -destination=./webook/internal/repository/mocks/captcha.mock.go
+//
+
+// Package repomocks is a generated GoMock package.
+package repomocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockCaptchaRepository is a mock of CaptchaRepository interface.
+type MockCaptchaRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockCaptchaRepositoryMockRecorder
+}
+
+// MockCaptchaRepositoryMockRecorder is the mock recorder for MockCaptchaRepository.
+type MockCaptchaRepositoryMockRecorder struct {
+	mock *MockCaptchaRepository
+}
+
+// NewMockCaptchaRepository creates a new mock instance.
+func NewMockCaptchaRepository(ctrl *gomock.Controller) *MockCaptchaRepository {
+	mock := &MockCaptchaRepository{ctrl: ctrl}
+	mock.recorder = &MockCaptchaRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCaptchaRepository) EXPECT() *MockCaptchaRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Attempts mocks base method.
+func (m *MockCaptchaRepository) Attempts(ctx context.Context, biz, key string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Attempts", ctx, biz, key)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Attempts indicates an expected call of Attempts.
+func (mr *MockCaptchaRepositoryMockRecorder) Attempts(ctx, biz, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Attempts", reflect.TypeOf((*MockCaptchaRepository)(nil).Attempts), ctx, biz, key)
+}
+
+// ConsumeTicket mocks base method.
+func (m *MockCaptchaRepository) ConsumeTicket(ctx context.Context, biz, ticket string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConsumeTicket", ctx, biz, ticket)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConsumeTicket indicates an expected call of ConsumeTicket.
+func (mr *MockCaptchaRepositoryMockRecorder) ConsumeTicket(ctx, biz, ticket any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConsumeTicket", reflect.TypeOf((*MockCaptchaRepository)(nil).ConsumeTicket), ctx, biz, ticket)
+}
+
+// IncrAttempt mocks base method.
+func (m *MockCaptchaRepository) IncrAttempt(ctx context.Context, biz, key string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IncrAttempt", ctx, biz, key)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IncrAttempt indicates an expected call of IncrAttempt.
+func (mr *MockCaptchaRepositoryMockRecorder) IncrAttempt(ctx, biz, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrAttempt", reflect.TypeOf((*MockCaptchaRepository)(nil).IncrAttempt), ctx, biz, key)
+}
+
+// ResetAttempt mocks base method.
+func (m *MockCaptchaRepository) ResetAttempt(ctx context.Context, biz, key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetAttempt", ctx, biz, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResetAttempt indicates an expected call of ResetAttempt.
+func (mr *MockCaptchaRepositoryMockRecorder) ResetAttempt(ctx, biz, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetAttempt", reflect.TypeOf((*MockCaptchaRepository)(nil).ResetAttempt), ctx, biz, key)
+}
+
+// SetAnswer mocks base method.
+func (m *MockCaptchaRepository) SetAnswer(ctx context.Context, id, answer string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetAnswer", ctx, id, answer)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetAnswer indicates an expected call of SetAnswer.
+func (mr *MockCaptchaRepositoryMockRecorder) SetAnswer(ctx, id, answer any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetAnswer", reflect.TypeOf((*MockCaptchaRepository)(nil).SetAnswer), ctx, id, answer)
+}
+
+// SetTicket mocks base method.
+func (m *MockCaptchaRepository) SetTicket(ctx context.Context, biz, ticket string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetTicket", ctx, biz, ticket)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetTicket indicates an expected call of SetTicket.
+func (mr *MockCaptchaRepositoryMockRecorder) SetTicket(ctx, biz, ticket any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTicket", reflect.TypeOf((*MockCaptchaRepository)(nil).SetTicket), ctx, biz, ticket)
+}
+
+// VerifyAnswer mocks base method.
+func (m *MockCaptchaRepository) VerifyAnswer(ctx context.Context, id, answer string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyAnswer", ctx, id, answer)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VerifyAnswer indicates an expected call of VerifyAnswer.
+func (mr *MockCaptchaRepositoryMockRecorder) VerifyAnswer(ctx, id, answer any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyAnswer", reflect.TypeOf((*MockCaptchaRepository)(nil).VerifyAnswer), ctx, id, answer)
+}
@@ -0,0 +1,70 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./webook/internal/repository/oauth_state.go
+//
+// Generated by this command:
+//
+//	mockgen -source=./webook/internal/repository/oauth_state.go -package=repomocks -destination=./webook/internal/repository/mocks/oauth_state.mock.go
+//
+
+// Package repomocks is a generated GoMock package.
+package repomocks
+
+import (
+	domain "Learn_Go/webook/internal/domain"
+	context "context"
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockOAuthStateRepository is a mock of OAuthStateRepository interface.
+type MockOAuthStateRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockOAuthStateRepositoryMockRecorder
+}
+
+// MockOAuthStateRepositoryMockRecorder is the mock recorder for MockOAuthStateRepository.
+type MockOAuthStateRepositoryMockRecorder struct {
+	mock *MockOAuthStateRepository
+}
+
+// NewMockOAuthStateRepository creates a new mock instance.
+func NewMockOAuthStateRepository(ctrl *gomock.Controller) *MockOAuthStateRepository {
+	mock := &MockOAuthStateRepository{ctrl: ctrl}
+	mock.recorder = &MockOAuthStateRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockOAuthStateRepository) EXPECT() *MockOAuthStateRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Consume mocks base method.
+func (m *MockOAuthStateRepository) Consume(ctx context.Context, state string) (domain.OAuthState, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Consume", ctx, state)
+	ret0, _ := ret[0].(domain.OAuthState)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Consume indicates an expected call of Consume.
+func (mr *MockOAuthStateRepositoryMockRecorder) Consume(ctx, state any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Consume", reflect.TypeOf((*MockOAuthStateRepository)(nil).Consume), ctx, state)
+}
+
+// Save mocks base method.
+func (m *MockOAuthStateRepository) Save(ctx context.Context, state string, st domain.OAuthState) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Save", ctx, state, st)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Save indicates an expected call of Save.
+func (mr *MockOAuthStateRepositoryMockRecorder) Save(ctx, state, st any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockOAuthStateRepository)(nil).Save), ctx, state, st)
+}
@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"Learn_Go/webook/internal/domain"
+	"Learn_Go/webook/internal/repository/cache"
+	"context"
+)
+
+var ErrOAuthStateNotFound = cache.ErrOAuthStateNotFound
+
+type OAuthStateRepository interface {
+	Save(ctx context.Context, state string, st domain.OAuthState) error
+	// Consume state 只能用一次
+	Consume(ctx context.Context, state string) (domain.OAuthState, error)
+}
+
+type CachedOAuthStateRepository struct {
+	cache cache.OAuthStateCache
+}
+
+func NewOAuthStateRepository(c cache.OAuthStateCache) OAuthStateRepository {
+	return &CachedOAuthStateRepository{
+		cache: c,
+	}
+}
+
+func (repo *CachedOAuthStateRepository) Save(ctx context.Context, state string, st domain.OAuthState) error {
+	return repo.cache.Set(ctx, state, st)
+}
+
+func (repo *CachedOAuthStateRepository) Consume(ctx context.Context, state string) (domain.OAuthState, error) {
+	return repo.cache.GetDel(ctx, state)
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"Learn_Go/webook/internal/repository/cache"
+	"Learn_Go/webook/internal/repository/dao"
+	"context"
+	"log"
+)
+
+type RoleRepository interface {
+	// FindByUid 用户额外分配的角色，不包括所有人都有的 user
+	FindByUid(ctx context.Context, uid int64) ([]string, error)
+	Add(ctx context.Context, uid int64, role string) error
+	Remove(ctx context.Context, uid int64, role string) error
+}
+
+type CachedRoleRepository struct {
+	dao   dao.RoleDAO
+	cache cache.RoleCache
+}
+
+func NewCachedRoleRepository(d dao.RoleDAO, c cache.RoleCache) RoleRepository {
+	return &CachedRoleRepository{
+		dao:   d,
+		cache: c,
+	}
+}
+
+func (repo *CachedRoleRepository) FindByUid(ctx context.Context, uid int64) ([]string, error) {
+	roles, err := repo.cache.Get(ctx, uid)
+	if err == nil {
+		return roles, nil
+	}
+	entities, err := repo.dao.FindByUid(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	roles = make([]string, 0, len(entities))
+	for _, e := range entities {
+		roles = append(roles, e.Role)
+	}
+	if err = repo.cache.Set(ctx, uid, roles); err != nil {
+		// 缓存写失败不影响，下次再查数据库
+		log.Println(err)
+	}
+	return roles, nil
+}
+
+func (repo *CachedRoleRepository) Add(ctx context.Context, uid int64, role string) error {
+	err := repo.dao.Insert(ctx, dao.UserRole{Uid: uid, Role: role})
+	if err != nil {
+		return err
+	}
+	return repo.cache.Del(ctx, uid)
+}
+
+// Remove 先改数据库再删缓存，删缓存失败的话返回错误，让调用方重试，不然收回的角色最多还能用一个缓存过期时间
+func (repo *CachedRoleRepository) Remove(ctx context.Context, uid int64, role string) error {
+	err := repo.dao.Delete(ctx, uid, role)
+	if err != nil {
+		return err
+	}
+	return repo.cache.Del(ctx, uid)
+}
@@ -7,7 +7,9 @@ import (
 	"context"
 	"database/sql"
 	"github.com/gin-gonic/gin"
+	"golang.org/x/sync/singleflight"
 	"log"
+	"strconv"
 	"time"
 )
 
@@ -20,25 +22,68 @@ type UserRepository interface {
 	Create(ctx context.Context, u domain.User) error
 	UpdateNonZeroFields(ctx context.Context, u domain.User) error
 	FindByPhone(ctx context.Context, phone string) (domain.User, error)
+	// FindById 优先查缓存，缓存里面没有密码，所以返回的 Password 可能是空的
 	FindById(ctx context.Context, uid int64) (domain.User, error)
+	// FindByIdWithPassword 不走缓存，要校验或者修改密码的时候用
+	FindByIdWithPassword(ctx context.Context, uid int64) (domain.User, error)
 	FindByEmail(ctx context.Context, email string) (domain.User, error)
-	FindByWechat(ctx context.Context, openId string) (domain.User, error)
+	FindByOAuth(ctx context.Context, provider, subject string) (domain.User, error)
+	// CreateWithOAuth 第三方登录的新用户
+	CreateWithOAuth(ctx context.Context, u domain.User, id domain.OAuthIdentity) error
+	// FindOAuthBindings 用户绑定的第三方账号
+	FindOAuthBindings(ctx context.Context, uid int64) ([]domain.OAuthIdentity, error)
+	// BindOAuth 同一个第三方已经绑定过的会被替换
+	BindOAuth(ctx context.Context, uid int64, id domain.OAuthIdentity) error
+	UnbindOAuth(ctx context.Context, uid int64, provider string) error
+	// UpdateStatus 修改 u.Status 和 u.SuspendedUntil
+	UpdateStatus(ctx context.Context, u domain.User) error
+	// UpdatePassword u.Password 是加密之后的密码
+	UpdatePassword(ctx context.Context, u domain.User) error
+	// UpdateAvatar avatar 是头像的 URL
+	UpdateAvatar(ctx context.Context, uid int64, avatar string) error
+	// UpdatePrivacy 修改 u.Privacy
+	UpdatePrivacy(ctx context.Context, u domain.User) error
+	// UpdateBindings 修改 u 绑定的手机号和邮箱，空字符串表示解绑
+	UpdateBindings(ctx context.Context, u domain.User) error
+	// Merge 把 sourceId 合并到 target，sourceId 被标记为注销
+	// providers 是从 sourceId 转移到 target 的第三方账号
+	Merge(ctx context.Context, target domain.User, sourceId int64, providers []string) error
+	// ScheduleDeletion 到了 at 的时候注销，at 为零值的时候是撤销注销
+	ScheduleDeletion(ctx context.Context, uid int64, at time.Time) error
+	// FindDueDeletions 到了注销时间还没有处理的用户
+	FindDueDeletions(ctx context.Context, now time.Time, limit int) ([]domain.User, error)
+	// Anonymize 抹掉个人信息、撤回文章，已经撤销注销的时候返回 ErrUserNotFound
+	Anonymize(ctx context.Context, uid int64, now time.Time) error
 }
 
+// delayedDeleteInterval 写完数据库之后隔多久再删一次缓存
+// 要比一次 FindById 从查数据库到回写缓存的时间长
+const delayedDeleteInterval = time.Second
+
 type CachedUserRepository struct {
 	dao   dao.UserDao
 	cache cache.UserCache
+	// group 合并同一个 uid 并发的缓存未命中
+	group singleflight.Group
+	// delayedDelete 为 0 的时候不做延迟双删
+	delayedDelete time.Duration
 }
 
 func NewCachedUserRepository(dao dao.UserDao, c cache.UserCache) UserRepository {
 	return &CachedUserRepository{
-		dao:   dao,
-		cache: c,
+		dao:           dao,
+		cache:         c,
+		delayedDelete: delayedDeleteInterval,
 	}
 }
 
 func (repo *CachedUserRepository) Create(ctx context.Context, u domain.User) error {
-	return repo.dao.Insert(ctx, repo.toEntity(u))
+	id, err := repo.dao.Insert(ctx, repo.toEntity(u))
+	if err != nil {
+		return err
+	}
+	repo.invalidateCreated(ctx, id)
+	return nil
 }
 
 func (repo *CachedUserRepository) FindByEmail(ctx context.Context, email string) (domain.User, error) {
@@ -52,20 +97,74 @@ func (repo *CachedUserRepository) FindByEmail(ctx context.Context, email string)
 
 func (repo *CachedUserRepository) toDomain(u dao.User) domain.User {
 	return domain.User{
-		Id:         u.Id,
-		Email:      u.Email.String,
-		Phone:      u.Phone.String,
-		Password:   u.Password,
-		AboutMe:    u.AboutMe,
-		BirthDay:   time.UnixMilli(u.Birthday),
-		NickName:   u.Nickname,
-		Ctime:      time.UnixMilli(u.Ctime),
-		WechatInfo: domain.WechatInfo{OpenId: u.WechatOpenId.String, UnionId: u.WechatUnionId.String},
+		Id:             u.Id,
+		Email:          u.Email.String,
+		Phone:          u.Phone.String,
+		Password:       u.Password,
+		AboutMe:        u.AboutMe,
+		BirthDay:       time.UnixMilli(u.Birthday),
+		NickName:       u.Nickname,
+		Avatar:         u.Avatar,
+		Ctime:          time.UnixMilli(u.Ctime),
+		Status:         domain.UserStatus(u.Status),
+		SuspendedUntil: repo.toTime(u.SuspendedUntil),
+		DeleteAt:       repo.toTime(u.DeleteAt),
+		Privacy: domain.PrivacySettings{
+			Email:    domain.Visibility(u.PrivacyEmail),
+			Phone:    domain.Visibility(u.PrivacyPhone),
+			Birthday: domain.Visibility(u.PrivacyBirthday),
+			AboutMe:  domain.Visibility(u.PrivacyAboutMe),
+		},
+	}
+}
+
+// toTime 数据库里面的 0 对应 time.Time 的零值
+func (repo *CachedUserRepository) toTime(ms int64) time.Time {
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
+
+// toMilli time.Time 的零值存成 0
+func (repo *CachedUserRepository) toMilli(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
 	}
+	return t.UnixMilli()
 }
 
 func (repo *CachedUserRepository) UpdateNonZeroFields(ctx context.Context, u domain.User) error {
-	return repo.dao.UpdateById(ctx, repo.toEntity(u))
+	err := repo.dao.UpdateById(ctx, repo.toEntity(u))
+	if err != nil {
+		return err
+	}
+	return repo.invalidate(ctx, u.Id)
+}
+
+// invalidate 写完数据库之后删缓存，过一会儿再删一次（延迟双删）
+// 写数据库之前开始的 FindById 可能读到旧数据，在第一次删除之后才回写缓存，第二次删除把它清掉
+// 数据库已经改成功了，所以第二次删除失败只记录日志
+func (repo *CachedUserRepository) invalidate(ctx context.Context, uids ...int64) error {
+	var err error
+	for _, uid := range uids {
+		if er := repo.cache.Del(ctx, uid); er != nil && err == nil {
+			err = er
+		}
+	}
+	if repo.delayedDelete <= 0 {
+		return err
+	}
+	// 请求结束之后 ctx 会被取消
+	ctx = context.WithoutCancel(ctx)
+	time.AfterFunc(repo.delayedDelete, func() {
+		for _, uid := range uids {
+			if er := repo.cache.Del(ctx, uid); er != nil {
+				log.Println(er)
+			}
+		}
+	})
+	return err
 }
 
 func (repo *CachedUserRepository) toEntity(u domain.User) dao.User {
@@ -79,19 +178,99 @@ func (repo *CachedUserRepository) toEntity(u domain.User) dao.User {
 			String: u.Phone,
 			Valid:  u.Phone != "",
 		},
-		Password: u.Password,
-		Birthday: u.BirthDay.UnixMilli(),
-		AboutMe:  u.AboutMe,
-		Nickname: u.NickName,
-		WechatOpenId: sql.NullString{
-			String: u.WechatInfo.OpenId,
-			Valid:  u.WechatInfo.OpenId != "",
-		},
-		WechatUnionId: sql.NullString{
-			String: u.WechatInfo.UnionId,
-			Valid:  u.WechatInfo.UnionId != "",
-		},
+		Password:       u.Password,
+		Birthday:       u.BirthDay.UnixMilli(),
+		AboutMe:        u.AboutMe,
+		Nickname:       u.NickName,
+		Avatar:         u.Avatar,
+		Status:         uint8(u.Status),
+		SuspendedUntil: repo.toMilli(u.SuspendedUntil),
+		DeleteAt:       repo.toMilli(u.DeleteAt),
+
+		PrivacyEmail:    uint8(u.Privacy.Email),
+		PrivacyPhone:    uint8(u.Privacy.Phone),
+		PrivacyBirthday: uint8(u.Privacy.Birthday),
+		PrivacyAboutMe:  uint8(u.Privacy.AboutMe),
+	}
+}
+
+// UpdateStatus 状态变了之后缓存里面的用户信息就不对了，所以要删掉缓存
+func (repo *CachedUserRepository) UpdateStatus(ctx context.Context, u domain.User) error {
+	err := repo.dao.UpdateStatus(ctx, u.Id, uint8(u.Status), repo.toMilli(u.SuspendedUntil))
+	if err != nil {
+		return err
+	}
+	return repo.invalidate(ctx, u.Id)
+}
+
+// UpdatePassword 缓存里面也有密码，同样要删掉
+func (repo *CachedUserRepository) UpdatePassword(ctx context.Context, u domain.User) error {
+	err := repo.dao.UpdatePassword(ctx, u.Id, u.Password)
+	if err != nil {
+		return err
+	}
+	return repo.invalidate(ctx, u.Id)
+}
+
+func (repo *CachedUserRepository) UpdateAvatar(ctx context.Context, uid int64, avatar string) error {
+	err := repo.dao.UpdateAvatar(ctx, uid, avatar)
+	if err != nil {
+		return err
+	}
+	return repo.invalidate(ctx, uid)
+}
+
+func (repo *CachedUserRepository) UpdatePrivacy(ctx context.Context, u domain.User) error {
+	err := repo.dao.UpdatePrivacy(ctx, repo.toEntity(u))
+	if err != nil {
+		return err
+	}
+	return repo.invalidate(ctx, u.Id)
+}
+
+func (repo *CachedUserRepository) UpdateBindings(ctx context.Context, u domain.User) error {
+	err := repo.dao.UpdateBindings(ctx, repo.toEntity(u))
+	if err != nil {
+		return err
+	}
+	return repo.invalidate(ctx, u.Id)
+}
+
+func (repo *CachedUserRepository) Merge(ctx context.Context, target domain.User, sourceId int64, providers []string) error {
+	err := repo.dao.Merge(ctx, repo.toEntity(target), sourceId, providers)
+	if err != nil {
+		return err
+	}
+	return repo.invalidate(ctx, target.Id, sourceId)
+}
+
+func (repo *CachedUserRepository) ScheduleDeletion(ctx context.Context, uid int64, at time.Time) error {
+	err := repo.dao.UpdateDeleteAt(ctx, uid, repo.toMilli(at))
+	if err != nil {
+		return err
+	}
+	return repo.invalidate(ctx, uid)
+}
+
+func (repo *CachedUserRepository) FindDueDeletions(ctx context.Context, now time.Time, limit int) ([]domain.User, error) {
+	us, err := repo.dao.FindDueDeletions(ctx, now.UnixMilli(), limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.User, 0, len(us))
+	for _, u := range us {
+		res = append(res, repo.toDomain(u))
+	}
+	return res, nil
+}
+
+// Anonymize 缓存里面有个人信息，一定要删掉
+func (repo *CachedUserRepository) Anonymize(ctx context.Context, uid int64, now time.Time) error {
+	err := repo.dao.Anonymize(ctx, uid, now.UnixMilli())
+	if err != nil {
+		return err
 	}
+	return repo.invalidate(ctx, uid)
 }
 
 func (repo *CachedUserRepository) FindById(ctx context.Context, uid int64) (domain.User, error) {
@@ -101,16 +280,52 @@ func (repo *CachedUserRepository) FindById(ctx context.Context, uid int64) (doma
 	if err == nil {
 		return du, err
 	}
+	// 缓存了不存在，不用再查数据库，防止有人拿不存在的 uid 刷接口把数据库打爆
+	if err == cache.ErrUserNotExistCached {
+		return domain.User{}, ErrUserNotFound
+	}
 	// err不为nil，就要查询数据库
 	// err有两种可能
 	// 1. key不存在，说明 redis 是正常的，uid可能正确也可能不正确
 	// 2. 访问 redis 有问题。可能是网路有问题，也可能是 redis 本身就崩溃了
 
+	// 同一个 uid 同时只有一个请求去查数据库，其它的等着用它的结果，热点用户的缓存过期的时候不会一下子打到数据库
+	ch := repo.group.DoChan(strconv.FormatInt(uid, 10), func() (any, error) {
+		// 结果是大家共享的，不能因为第一个请求被取消了就让其它请求都失败
+		return repo.load(context.WithoutCancel(ctx), uid)
+	})
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return domain.User{}, res.Err
+		}
+		return res.Val.(domain.User), nil
+	case <-ctx.Done():
+		return domain.User{}, ctx.Err()
+	}
+}
+
+func (repo *CachedUserRepository) FindByIdWithPassword(ctx context.Context, uid int64) (domain.User, error) {
+	u, err := repo.dao.FindById(ctx, uid)
+	if err != nil {
+		return domain.User{}, err
+	}
+	return repo.toDomain(u), nil
+}
+
+// load 查数据库并且回写缓存
+func (repo *CachedUserRepository) load(ctx context.Context, uid int64) (domain.User, error) {
 	u, err := repo.dao.FindById(ctx, uid)
+	if err == dao.ErrRecordNotFound {
+		if er := repo.cache.SetNotFound(ctx, uid); er != nil {
+			log.Println(er)
+		}
+		return domain.User{}, ErrUserNotFound
+	}
 	if err != nil {
 		return domain.User{}, err
 	}
-	du = repo.toDomain(u)
+	du := repo.toDomain(u)
 
 	err = repo.cache.Set(ctx, du)
 	if err != nil {
@@ -121,7 +336,6 @@ func (repo *CachedUserRepository) FindById(ctx context.Context, uid int64) (doma
 	}
 
 	return du, nil
-
 }
 
 // 查询的另一种写法，进一步判定err是何种错误
@@ -166,11 +380,60 @@ func (repo *CachedUserRepository) FindByPhone(ctx context.Context, phone string)
 
 }
 
-func (repo *CachedUserRepository) FindByWechat(ctx context.Context, openId string) (domain.User, error) {
-	u, err := repo.dao.FindByWechat(ctx, openId)
+func (repo *CachedUserRepository) FindByOAuth(ctx context.Context, provider, subject string) (domain.User, error) {
+	u, err := repo.dao.FindByOAuth(ctx, provider, subject)
 	if err != nil {
 		return domain.User{}, err
 	}
 	return repo.toDomain(u), nil
+}
+
+func (repo *CachedUserRepository) CreateWithOAuth(ctx context.Context, u domain.User, id domain.OAuthIdentity) error {
+	uid, err := repo.dao.InsertWithOAuth(ctx, repo.toEntity(u), repo.toBindingEntity(0, id))
+	if err != nil {
+		return err
+	}
+	repo.invalidateCreated(ctx, uid)
+	return nil
+}
 
+// invalidateCreated 注册之前可能有人查过这个 id，缓存里面有不存在的记录，要删掉
+// 用户已经创建成功了，删缓存失败不能让调用方以为注册失败，不然重试的时候会冲突
+func (repo *CachedUserRepository) invalidateCreated(ctx context.Context, uid int64) {
+	if err := repo.invalidate(ctx, uid); err != nil {
+		log.Println(err)
+	}
+}
+
+func (repo *CachedUserRepository) FindOAuthBindings(ctx context.Context, uid int64) ([]domain.OAuthIdentity, error) {
+	bs, err := repo.dao.FindOAuthBindings(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.OAuthIdentity, 0, len(bs))
+	for _, b := range bs {
+		res = append(res, domain.OAuthIdentity{
+			Provider: b.Provider,
+			Subject:  b.Subject,
+			UnionId:  b.UnionId,
+		})
+	}
+	return res, nil
+}
+
+func (repo *CachedUserRepository) BindOAuth(ctx context.Context, uid int64, id domain.OAuthIdentity) error {
+	return repo.dao.UpsertOAuthBinding(ctx, repo.toBindingEntity(uid, id))
+}
+
+func (repo *CachedUserRepository) UnbindOAuth(ctx context.Context, uid int64, provider string) error {
+	return repo.dao.DeleteOAuthBinding(ctx, uid, provider)
+}
+
+func (repo *CachedUserRepository) toBindingEntity(uid int64, id domain.OAuthIdentity) dao.UserOAuthBinding {
+	return dao.UserOAuthBinding{
+		Uid:      uid,
+		Provider: id.Provider,
+		Subject:  id.Subject,
+		UnionId:  id.UnionId,
+	}
 }
@@ -9,8 +9,12 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
+	"sync"
 	"testing"
 	"time"
 )
@@ -112,6 +116,7 @@ func TestCachedUserRepository_FindById(t *testing.T) {
 				c := cachemocks.NewMockUserCache(ctrl)
 				c.EXPECT().Get(gomock.Any(), uid).Return(domain.User{}, cache.ErrKeyNotExist)
 				d.EXPECT().FindById(gomock.Any(), uid).Return(dao.User{}, dao.ErrRecordNotFound)
+				c.EXPECT().SetNotFound(gomock.Any(), uid).Return(nil)
 				return d, c
 			},
 
@@ -120,6 +125,37 @@ func TestCachedUserRepository_FindById(t *testing.T) {
 			wantUser: domain.User{},
 			wantErr:  ErrUserNotFound,
 		},
+		{
+			name: "缓存了不存在，不查数据库",
+			mock: func(ctrl *gomock.Controller) (dao.UserDao, cache.UserCache) {
+				uid := int64(123)
+				d := daomocks.NewMockUserDao(ctrl)
+				c := cachemocks.NewMockUserCache(ctrl)
+				c.EXPECT().Get(gomock.Any(), uid).Return(domain.User{}, cache.ErrUserNotExistCached)
+				return d, c
+			},
+
+			ctx:      context.Background(),
+			uid:      123,
+			wantUser: domain.User{},
+			wantErr:  ErrUserNotFound,
+		},
+		{
+			name: "数据库错误",
+			mock: func(ctrl *gomock.Controller) (dao.UserDao, cache.UserCache) {
+				uid := int64(123)
+				d := daomocks.NewMockUserDao(ctrl)
+				c := cachemocks.NewMockUserCache(ctrl)
+				c.EXPECT().Get(gomock.Any(), uid).Return(domain.User{}, cache.ErrKeyNotExist)
+				d.EXPECT().FindById(gomock.Any(), uid).Return(dao.User{}, errors.New("数据库错误"))
+				return d, c
+			},
+
+			ctx:      context.Background(),
+			uid:      123,
+			wantUser: domain.User{},
+			wantErr:  errors.New("数据库错误"),
+		},
 		{
 			name: "回写缓存错误",
 			mock: func(ctrl *gomock.Controller) (dao.UserDao, cache.UserCache) {
@@ -181,3 +217,210 @@ func TestCachedUserRepository_FindById(t *testing.T) {
 	}
 
 }
+
+// 同一个 uid 并发的缓存未命中只查一次数据库
+func TestCachedUserRepository_FindById_Singleflight(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	const n = 10
+	uid := int64(123)
+	d := daomocks.NewMockUserDao(ctrl)
+	c := cachemocks.NewMockUserCache(ctrl)
+	// 所有请求都未命中之后才放行数据库查询
+	var misses sync.WaitGroup
+	misses.Add(n)
+	c.EXPECT().Get(gomock.Any(), uid).DoAndReturn(func(ctx context.Context, uid int64) (domain.User, error) {
+		misses.Done()
+		return domain.User{}, cache.ErrKeyNotExist
+	}).Times(n)
+	d.EXPECT().FindById(gomock.Any(), uid).DoAndReturn(func(ctx context.Context, uid int64) (dao.User, error) {
+		misses.Wait()
+		// 等其它请求都进到 singleflight 里面
+		time.Sleep(time.Millisecond * 50)
+		return dao.User{Id: uid, Nickname: "lip"}, nil
+	}).Times(1)
+	c.EXPECT().Set(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+	repo := NewCachedUserRepository(d, c)
+
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			u, err := repo.FindById(context.Background(), uid)
+			assert.NoError(t, err)
+			assert.Equal(t, "lip", u.NickName)
+		}()
+	}
+	wg.Wait()
+}
+
+// 等待的请求超时了直接返回，查数据库的那个请求不受影响
+func TestCachedUserRepository_FindById_Cancel(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	uid := int64(123)
+	d := daomocks.NewMockUserDao(ctrl)
+	c := cachemocks.NewMockUserCache(ctrl)
+	c.EXPECT().Get(gomock.Any(), uid).Return(domain.User{}, cache.ErrKeyNotExist)
+	done := make(chan struct{})
+	d.EXPECT().FindById(gomock.Any(), uid).DoAndReturn(func(ctx context.Context, uid int64) (dao.User, error) {
+		<-done
+		// 调用方取消了也不影响查询
+		assert.NoError(t, ctx.Err())
+		return dao.User{Id: uid}, nil
+	})
+	set := make(chan struct{})
+	c.EXPECT().Set(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, u domain.User) error {
+		close(set)
+		return nil
+	})
+	repo := NewCachedUserRepository(d, c)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := repo.FindById(ctx, uid)
+	assert.Equal(t, context.Canceled, err)
+	close(done)
+	<-set
+}
+
+func TestCachedUserRepository_UpdateNonZeroFields(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller, deleted chan struct{}) (dao.UserDao, cache.UserCache)
+
+		// wantDeletes 一共删几次缓存
+		wantDeletes int
+		wantErr     error
+	}{
+		{
+			name: "更新成功，删两次缓存",
+			mock: func(ctrl *gomock.Controller, deleted chan struct{}) (dao.UserDao, cache.UserCache) {
+				d := daomocks.NewMockUserDao(ctrl)
+				c := cachemocks.NewMockUserCache(ctrl)
+				d.EXPECT().UpdateById(gomock.Any(), dao.User{Id: 123, Nickname: "lip", Birthday: 100}).Return(nil)
+				c.EXPECT().Del(gomock.Any(), int64(123)).DoAndReturn(func(ctx context.Context, uid int64) error {
+					deleted <- struct{}{}
+					return nil
+				}).Times(2)
+				return d, c
+			},
+			wantDeletes: 2,
+		},
+		{
+			name: "删缓存失败，照样延迟再删一次",
+			mock: func(ctrl *gomock.Controller, deleted chan struct{}) (dao.UserDao, cache.UserCache) {
+				d := daomocks.NewMockUserDao(ctrl)
+				c := cachemocks.NewMockUserCache(ctrl)
+				d.EXPECT().UpdateById(gomock.Any(), gomock.Any()).Return(nil)
+				c.EXPECT().Del(gomock.Any(), int64(123)).DoAndReturn(func(ctx context.Context, uid int64) error {
+					deleted <- struct{}{}
+					return errors.New("redis错误")
+				}).Times(2)
+				return d, c
+			},
+			wantDeletes: 2,
+			wantErr:     errors.New("redis错误"),
+		},
+		{
+			name: "数据库错误，不删缓存",
+			mock: func(ctrl *gomock.Controller, deleted chan struct{}) (dao.UserDao, cache.UserCache) {
+				d := daomocks.NewMockUserDao(ctrl)
+				c := cachemocks.NewMockUserCache(ctrl)
+				d.EXPECT().UpdateById(gomock.Any(), gomock.Any()).Return(errors.New("数据库错误"))
+				return d, c
+			},
+			wantErr: errors.New("数据库错误"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			deleted := make(chan struct{}, 2)
+			userDao, userCache := tc.mock(ctrl, deleted)
+			repo := NewCachedUserRepository(userDao, userCache).(*CachedUserRepository)
+			repo.delayedDelete = time.Millisecond * 10
+			err := repo.UpdateNonZeroFields(context.Background(), domain.User{
+				Id:       123,
+				NickName: "lip",
+				BirthDay: time.UnixMilli(100),
+			})
+			assert.Equal(t, tc.wantErr, err)
+			for i := 0; i < tc.wantDeletes; i++ {
+				select {
+				case <-deleted:
+				case <-time.After(time.Second):
+					t.Fatal("没有删除缓存")
+				}
+			}
+		})
+	}
+}
+
+// 注册之前查过这个 id 的话，缓存里面有不存在的记录，注册之后要能马上查到
+func TestCachedUserRepository_Create(t *testing.T) {
+	testCases := []struct {
+		name   string
+		create func(repo UserRepository) error
+		mock   func(d *daomocks.MockUserDao)
+	}{
+		{
+			name: "注册",
+			create: func(repo UserRepository) error {
+				return repo.Create(context.Background(), domain.User{Phone: "15012345678"})
+			},
+			mock: func(d *daomocks.MockUserDao) {
+				d.EXPECT().Insert(gomock.Any(), gomock.Any()).Return(int64(123), nil)
+			},
+		},
+		{
+			name: "第三方登录注册",
+			create: func(repo UserRepository) error {
+				return repo.CreateWithOAuth(context.Background(), domain.User{},
+					domain.OAuthIdentity{Provider: "wechat", Subject: "open-id"})
+			},
+			mock: func(d *daomocks.MockUserDao) {
+				d.EXPECT().InsertWithOAuth(gomock.Any(), gomock.Any(), gomock.Any()).Return(int64(123), nil)
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mr := miniredis.RunT(t)
+			d := daomocks.NewMockUserDao(ctrl)
+			repo := NewCachedUserRepository(d, cache.NewRedisUserCache(redis.NewClient(&redis.Options{Addr: mr.Addr()})))
+			repo.(*CachedUserRepository).delayedDelete = 0
+
+			gomock.InOrder(
+				d.EXPECT().FindById(gomock.Any(), int64(123)).Return(dao.User{}, dao.ErrRecordNotFound),
+				d.EXPECT().FindById(gomock.Any(), int64(123)).Return(dao.User{Id: 123, Nickname: "lip"}, nil),
+			)
+			tc.mock(d)
+
+			_, err := repo.FindById(context.Background(), 123)
+			assert.Equal(t, ErrUserNotFound, err)
+			require.NoError(t, tc.create(repo))
+			u, err := repo.FindById(context.Background(), 123)
+			require.NoError(t, err)
+			assert.Equal(t, "lip", u.NickName)
+		})
+	}
+}
+
+// 缓存里面没有密码，要密码的时候直接查数据库
+func TestCachedUserRepository_FindByIdWithPassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	d := daomocks.NewMockUserDao(ctrl)
+	c := cachemocks.NewMockUserCache(ctrl)
+	d.EXPECT().FindById(gomock.Any(), int64(123)).Return(dao.User{Id: 123, Password: "hash"}, nil)
+	repo := NewCachedUserRepository(d, c)
+	u, err := repo.FindByIdWithPassword(context.Background(), 123)
+	assert.NoError(t, err)
+	assert.Equal(t, "hash", u.Password)
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"Learn_Go/webook/internal/domain"
+	"Learn_Go/webook/internal/repository"
+	"context"
+	"go.uber.org/zap"
+	"time"
+)
+
+// AccountService 注销账号和导出个人数据，会同时涉及用户和文章
+type AccountService interface {
+	// ScheduleDeletion 申请注销，宽限期过了才真正注销，宽限期内登录会撤销申请
+	// 返回真正注销的时间，调用方需要让用户的设备全部下线
+	ScheduleDeletion(ctx context.Context, uid int64) (time.Time, error)
+	// PurgeDue 处理到了注销时间的用户：撤回文章、抹掉个人信息
+	// 返回处理了的用户，调用方需要让这些用户的设备全部下线
+	PurgeDue(ctx context.Context, now time.Time, limit int) ([]int64, error)
+	// Export 导出个人数据
+	Export(ctx context.Context, uid int64) (domain.AccountExport, error)
+}
+
+type accountService struct {
+	userRepo repository.UserRepository
+	artRepo  repository.ArticleRepository
+	// gracePeriod 申请注销之后多久真正注销
+	gracePeriod time.Duration
+	now         func() time.Time
+}
+
+func NewAccountService(userRepo repository.UserRepository, artRepo repository.ArticleRepository) AccountService {
+	return &accountService{
+		userRepo:    userRepo,
+		artRepo:     artRepo,
+		gracePeriod: time.Hour * 24 * 14,
+		now:         time.Now,
+	}
+}
+
+func (svc *accountService) ScheduleDeletion(ctx context.Context, uid int64) (time.Time, error) {
+	u, err := svc.userRepo.FindById(ctx, uid)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !u.DeleteAt.IsZero() {
+		// 重复申请不会推迟注销时间
+		return u.DeleteAt, nil
+	}
+	at := svc.now().Add(svc.gracePeriod)
+	return at, svc.userRepo.ScheduleDeletion(ctx, uid, at)
+}
+
+func (svc *accountService) PurgeDue(ctx context.Context, now time.Time, limit int) ([]int64, error) {
+	us, err := svc.userRepo.FindDueDeletions(ctx, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]int64, 0, len(us))
+	for _, u := range us {
+		// 撤回文章和抹掉个人信息在同一个事务里面，失败的话这个用户下次还会被查出来
+		err = svc.userRepo.Anonymize(ctx, u.Id, now)
+		if err == repository.ErrUserNotFound {
+			// 查出来之后用户刚好登录撤销了注销，什么都没有改
+			zap.L().Warn("注销的时候用户撤销了注销", zap.Int64("uid", u.Id))
+			continue
+		}
+		if err != nil {
+			return res, err
+		}
+		res = append(res, u.Id)
+	}
+	return res, nil
+}
+
+// exportBatchSize 导出文章的时候每次查询的数量
+const exportBatchSize = 100
+
+func (svc *accountService) Export(ctx context.Context, uid int64) (domain.AccountExport, error) {
+	u, err := svc.userRepo.FindById(ctx, uid)
+	if err != nil {
+		return domain.AccountExport{}, err
+	}
+	// 密码虽然是加密的，也不应该出现在导出的数据里面
+	u.Password = ""
+	bindings, err := svc.userRepo.FindOAuthBindings(ctx, uid)
+	if err != nil {
+		return domain.AccountExport{}, err
+	}
+	var arts []domain.Article
+	var maxId int64
+	for {
+		batch, err := svc.artRepo.ListByAuthor(ctx, uid, maxId, exportBatchSize)
+		if err != nil {
+			return domain.AccountExport{}, err
+		}
+		arts = append(arts, batch...)
+		if len(batch) < exportBatchSize {
+			break
+		}
+		maxId = batch[len(batch)-1].Id
+	}
+	return domain.AccountExport{
+		User:     u,
+		Bindings: bindings,
+		Articles: arts,
+	}, nil
+}
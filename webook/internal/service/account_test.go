@@ -0,0 +1,149 @@
+package service
+
+import (
+	"Learn_Go/webook/internal/domain"
+	"Learn_Go/webook/internal/repository"
+	repomocks "Learn_Go/webook/internal/repository/mocks"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"testing"
+	"time"
+)
+
+func Test_accountService_ScheduleDeletion(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+	testCases := []struct {
+		name    string
+		mock    func(ctrl *gomock.Controller) repository.UserRepository
+		wantAt  time.Time
+		wantErr error
+	}{
+		{
+			name: "申请成功",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(1)).Return(domain.User{Id: 1}, nil)
+				repo.EXPECT().ScheduleDeletion(gomock.Any(), int64(1), now.Add(time.Hour*24*14)).Return(nil)
+				return repo
+			},
+			wantAt: now.Add(time.Hour * 24 * 14),
+		},
+		{
+			name: "重复申请不推迟",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(1)).Return(domain.User{
+					Id:       1,
+					DeleteAt: now.Add(time.Hour),
+				}, nil)
+				return repo
+			},
+			wantAt: now.Add(time.Hour),
+		},
+		{
+			name: "用户不存在",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(1)).Return(domain.User{}, repository.ErrUserNotFound)
+				return repo
+			},
+			wantErr: repository.ErrUserNotFound,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			svc := NewAccountService(tc.mock(ctrl), repomocks.NewMockArticleRepository(ctrl)).(*accountService)
+			svc.now = func() time.Time { return now }
+			at, err := svc.ScheduleDeletion(context.Background(), 1)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantAt, at)
+		})
+	}
+}
+
+func Test_accountService_PurgeDue(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+	testCases := []struct {
+		name     string
+		mock     func(ctrl *gomock.Controller) (repository.UserRepository, repository.ArticleRepository)
+		wantUids []int64
+		wantErr  error
+	}{
+		{
+			name: "注销成功",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, repository.ArticleRepository) {
+				userRepo := repomocks.NewMockUserRepository(ctrl)
+				userRepo.EXPECT().FindDueDeletions(gomock.Any(), now, 10).
+					Return([]domain.User{{Id: 1}, {Id: 2}}, nil)
+				userRepo.EXPECT().Anonymize(gomock.Any(), int64(1), now).Return(nil)
+				userRepo.EXPECT().Anonymize(gomock.Any(), int64(2), now).Return(nil)
+				return userRepo, repomocks.NewMockArticleRepository(ctrl)
+			},
+			wantUids: []int64{1, 2},
+		},
+		{
+			name: "查出来之后用户撤销了注销",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, repository.ArticleRepository) {
+				userRepo := repomocks.NewMockUserRepository(ctrl)
+				userRepo.EXPECT().FindDueDeletions(gomock.Any(), now, 10).
+					Return([]domain.User{{Id: 1}, {Id: 2}}, nil)
+				userRepo.EXPECT().Anonymize(gomock.Any(), int64(1), now).Return(repository.ErrUserNotFound)
+				userRepo.EXPECT().Anonymize(gomock.Any(), int64(2), now).Return(nil)
+				return userRepo, repomocks.NewMockArticleRepository(ctrl)
+			},
+			wantUids: []int64{2},
+		},
+		{
+			name: "注销失败，已经处理的用户也要返回",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, repository.ArticleRepository) {
+				userRepo := repomocks.NewMockUserRepository(ctrl)
+				userRepo.EXPECT().FindDueDeletions(gomock.Any(), now, 10).
+					Return([]domain.User{{Id: 1}, {Id: 2}}, nil)
+				userRepo.EXPECT().Anonymize(gomock.Any(), int64(1), now).Return(nil)
+				userRepo.EXPECT().Anonymize(gomock.Any(), int64(2), now).Return(errors.New("db 错误"))
+				return userRepo, repomocks.NewMockArticleRepository(ctrl)
+			},
+			wantUids: []int64{1},
+			wantErr:  errors.New("db 错误"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			svc := NewAccountService(tc.mock(ctrl))
+			uids, err := svc.PurgeDue(context.Background(), now, 10)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantUids, uids)
+		})
+	}
+}
+
+func Test_accountService_Export(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	userRepo := repomocks.NewMockUserRepository(ctrl)
+	artRepo := repomocks.NewMockArticleRepository(ctrl)
+	userRepo.EXPECT().FindById(gomock.Any(), int64(1)).
+		Return(domain.User{Id: 1, Email: "123@qq.com", Password: "hash"}, nil)
+	userRepo.EXPECT().FindOAuthBindings(gomock.Any(), int64(1)).
+		Return([]domain.OAuthIdentity{{Provider: "github", Subject: "42"}}, nil)
+	// 第一页是满的，要接着查
+	first := make([]domain.Article, exportBatchSize)
+	for i := range first {
+		first[i] = domain.Article{Id: int64(i + 1)}
+	}
+	artRepo.EXPECT().ListByAuthor(gomock.Any(), int64(1), int64(0), exportBatchSize).Return(first, nil)
+	artRepo.EXPECT().ListByAuthor(gomock.Any(), int64(1), int64(100), exportBatchSize).
+		Return([]domain.Article{{Id: 101}}, nil)
+
+	res, err := NewAccountService(userRepo, artRepo).Export(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.User{Id: 1, Email: "123@qq.com"}, res.User)
+	assert.Equal(t, []domain.OAuthIdentity{{Provider: "github", Subject: "42"}}, res.Bindings)
+	assert.Len(t, res.Articles, exportBatchSize+1)
+}
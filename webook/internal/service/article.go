@@ -11,8 +11,12 @@ import (
 type ArticleService interface {
 	Save(ctx context.Context, art domain.Article) (int64, error)
 	Publish(ctx context.Context, art domain.Article) (int64, error)
+	// TakeDown 版主下架文章，下架之后读者看不到，作者也不能再修改和发表
+	TakeDown(ctx context.Context, id int64) error
 }
 
+var ErrArticleNotFound = repository.ErrArticleNotFound
+
 type articleService struct {
 	repo repository.ArticleRepository
 
@@ -76,6 +80,10 @@ func (a *articleService) PublishV1(ctx context.Context, art domain.Article) (int
 	return id, errors.New("保存到线上库失败，重试次数耗尽")
 }
 
+func (a *articleService) TakeDown(ctx context.Context, id int64) error {
+	return a.repo.TakeDown(ctx, id)
+}
+
 func (a *articleService) Save(ctx context.Context, art domain.Article) (int64, error) {
 	if art.Id > 0 {
 		err := a.repo.Update(ctx, art)
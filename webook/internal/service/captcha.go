@@ -0,0 +1,121 @@
+package service
+
+import (
+	"Learn_Go/webook/internal/domain"
+	"Learn_Go/webook/internal/repository"
+	"Learn_Go/webook/pkg/captcha"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrCaptchaNotFound = repository.ErrCaptchaNotFound
+	ErrCaptchaWrong    = errors.New("图形验证码错误")
+)
+
+// CaptchaService 图形验证码
+// 在同一个维度（手机号、邮箱、IP）上失败次数过多之后，要求用户先通过图形验证码，拿到票据再继续操作
+
+type CaptchaService interface {
+	Generate(ctx context.Context) (domain.Captcha, error)
+	// Verify 校验图形验证码，通过之后返回一个只能在 biz 下使用一次的票据
+	Verify(ctx context.Context, biz, id, answer string) (string, error)
+	// Required keys 中任意一个维度的失败次数达到阈值，就需要图形验证码
+	Required(ctx context.Context, biz string, keys ...string) (bool, error)
+	// CheckTicket 校验并消费票据
+	CheckTicket(ctx context.Context, biz, ticket string) (bool, error)
+	// RecordFailure 在 keys 的每一个维度上记录一次失败
+	RecordFailure(ctx context.Context, biz string, keys ...string) error
+	// Reset 清空 keys 上的失败次数，一般是在登录成功之后
+	Reset(ctx context.Context, biz string, keys ...string) error
+}
+
+type captchaService struct {
+	repo repository.CaptchaRepository
+	// 失败多少次之后需要图形验证码
+	threshold int64
+	length    int
+	width     int
+	height    int
+}
+
+func NewCaptchaService(repo repository.CaptchaRepository) CaptchaService {
+	return &captchaService{
+		repo:      repo,
+		threshold: 3,
+		length:    4,
+		width:     120,
+		height:    40,
+	}
+}
+
+func (c *captchaService) Generate(ctx context.Context) (domain.Captcha, error) {
+	answer := captcha.RandomDigits(c.length)
+	img, err := captcha.GenerateImage(answer, c.width, c.height)
+	if err != nil {
+		return domain.Captcha{}, err
+	}
+	id := uuid.New().String()
+	err = c.repo.SetAnswer(ctx, id, answer)
+	if err != nil {
+		return domain.Captcha{}, err
+	}
+	return domain.Captcha{
+		Id:    id,
+		Image: img,
+	}, nil
+}
+
+func (c *captchaService) Verify(ctx context.Context, biz, id, answer string) (string, error) {
+	ok, err := c.repo.VerifyAnswer(ctx, id, answer)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", ErrCaptchaWrong
+	}
+	ticket := uuid.New().String()
+	err = c.repo.SetTicket(ctx, biz, ticket)
+	return ticket, err
+}
+
+func (c *captchaService) Required(ctx context.Context, biz string, keys ...string) (bool, error) {
+	for _, key := range keys {
+		cnt, err := c.repo.Attempts(ctx, biz, key)
+		if err != nil {
+			return false, err
+		}
+		if cnt >= c.threshold {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (c *captchaService) CheckTicket(ctx context.Context, biz, ticket string) (bool, error) {
+	if ticket == "" {
+		return false, nil
+	}
+	return c.repo.ConsumeTicket(ctx, biz, ticket)
+}
+
+func (c *captchaService) RecordFailure(ctx context.Context, biz string, keys ...string) error {
+	for _, key := range keys {
+		_, err := c.repo.IncrAttempt(ctx, biz, key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *captchaService) Reset(ctx context.Context, biz string, keys ...string) error {
+	for _, key := range keys {
+		err := c.repo.ResetAttempt(ctx, biz, key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"Learn_Go/webook/internal/repository"
+	repomocks "Learn_Go/webook/internal/repository/mocks"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"testing"
+)
+
+func Test_captchaService_Required(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) repository.CaptchaRepository
+		keys []string
+
+		wantRequired bool
+		wantErr      error
+	}{
+		{
+			name: "失败次数未达到阈值",
+			mock: func(ctrl *gomock.Controller) repository.CaptchaRepository {
+				repo := repomocks.NewMockCaptchaRepository(ctrl)
+				repo.EXPECT().Attempts(gomock.Any(), "login", "email:123@qq.com").Return(int64(2), nil)
+				repo.EXPECT().Attempts(gomock.Any(), "login", "ip:127.0.0.1").Return(int64(0), nil)
+				return repo
+			},
+			keys:         []string{"email:123@qq.com", "ip:127.0.0.1"},
+			wantRequired: false,
+		},
+		{
+			name: "某一个维度达到阈值",
+			mock: func(ctrl *gomock.Controller) repository.CaptchaRepository {
+				repo := repomocks.NewMockCaptchaRepository(ctrl)
+				repo.EXPECT().Attempts(gomock.Any(), "login", "email:123@qq.com").Return(int64(0), nil)
+				repo.EXPECT().Attempts(gomock.Any(), "login", "ip:127.0.0.1").Return(int64(3), nil)
+				return repo
+			},
+			keys:         []string{"email:123@qq.com", "ip:127.0.0.1"},
+			wantRequired: true,
+		},
+		{
+			name: "Redis 错误",
+			mock: func(ctrl *gomock.Controller) repository.CaptchaRepository {
+				repo := repomocks.NewMockCaptchaRepository(ctrl)
+				repo.EXPECT().Attempts(gomock.Any(), "login", "email:123@qq.com").Return(int64(0), errors.New("redis 错误"))
+				return repo
+			},
+			keys:    []string{"email:123@qq.com", "ip:127.0.0.1"},
+			wantErr: errors.New("redis 错误"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			svc := NewCaptchaService(tc.mock(ctrl))
+			required, err := svc.Required(context.Background(), "login", tc.keys...)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantRequired, required)
+		})
+	}
+}
+
+func Test_captchaService_Verify(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) repository.CaptchaRepository
+
+		wantTicket bool
+		wantErr    error
+	}{
+		{
+			name: "验证通过，颁发票据",
+			mock: func(ctrl *gomock.Controller) repository.CaptchaRepository {
+				repo := repomocks.NewMockCaptchaRepository(ctrl)
+				repo.EXPECT().VerifyAnswer(gomock.Any(), "abc", "1234").Return(true, nil)
+				repo.EXPECT().SetTicket(gomock.Any(), "login", gomock.Any()).Return(nil)
+				return repo
+			},
+			wantTicket: true,
+		},
+		{
+			name: "答案错误",
+			mock: func(ctrl *gomock.Controller) repository.CaptchaRepository {
+				repo := repomocks.NewMockCaptchaRepository(ctrl)
+				repo.EXPECT().VerifyAnswer(gomock.Any(), "abc", "1234").Return(false, nil)
+				return repo
+			},
+			wantErr: ErrCaptchaWrong,
+		},
+		{
+			name: "验证码已过期",
+			mock: func(ctrl *gomock.Controller) repository.CaptchaRepository {
+				repo := repomocks.NewMockCaptchaRepository(ctrl)
+				repo.EXPECT().VerifyAnswer(gomock.Any(), "abc", "1234").Return(false, repository.ErrCaptchaNotFound)
+				return repo
+			},
+			wantErr: ErrCaptchaNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			svc := NewCaptchaService(tc.mock(ctrl))
+			ticket, err := svc.Verify(context.Background(), "login", "abc", "1234")
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantTicket, ticket != "")
+		})
+	}
+}
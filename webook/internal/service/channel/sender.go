@@ -0,0 +1,58 @@
+package channel
+
+import (
+	"Learn_Go/webook/internal/service/email"
+	"Learn_Go/webook/internal/service/sms"
+	"Learn_Go/webook/internal/service/voice"
+	"context"
+	"fmt"
+)
+
+type SMSSender struct {
+	svc   sms.Service
+	tplId string
+}
+
+func NewSMSSender(svc sms.Service, tplId string) *SMSSender {
+	return &SMSSender{
+		svc:   svc,
+		tplId: tplId,
+	}
+}
+
+func (s *SMSSender) Send(ctx context.Context, target, code string) error {
+	return s.svc.Send(ctx, s.tplId, []string{code}, target)
+}
+
+type VoiceSender struct {
+	svc   voice.Service
+	tplId string
+}
+
+func NewVoiceSender(svc voice.Service, tplId string) *VoiceSender {
+	return &VoiceSender{
+		svc:   svc,
+		tplId: tplId,
+	}
+}
+
+func (s *VoiceSender) Send(ctx context.Context, target, code string) error {
+	return s.svc.Call(ctx, s.tplId, []string{code}, target)
+}
+
+type EmailSender struct {
+	svc     email.Service
+	subject string
+}
+
+func NewEmailSender(svc email.Service) *EmailSender {
+	return &EmailSender{
+		svc:     svc,
+		subject: "webook 验证码",
+	}
+}
+
+func (s *EmailSender) Send(ctx context.Context, target, code string) error {
+	body := fmt.Sprintf("你的验证码是 %s，10 分钟内有效。如果不是你本人操作，请忽略这封邮件。", code)
+	return s.svc.Send(ctx, target, s.subject, body)
+}
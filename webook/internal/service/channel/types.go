@@ -0,0 +1,64 @@
+package channel
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// Channel 验证码的发送渠道
+// 同一个验证码，存储是一样的，只是送达用户的方式不同
+
+type Channel string
+
+const (
+	SMS   Channel = "sms"
+	Email Channel = "email"
+	Voice Channel = "voice"
+)
+
+var ErrChannelNotSupported = errors.New("不支持的验证码发送渠道")
+
+// Sender 把验证码送达 target，target 可能是手机号，也可能是邮箱
+
+type Sender interface {
+	Send(ctx context.Context, target, code string) error
+}
+
+// Policy 决定最终使用哪个渠道
+// preferred 是用户自己选择的渠道，可能为空
+
+type Policy interface {
+	Choose(ctx context.Context, biz, target string, preferred Channel) (Channel, error)
+}
+
+// DefaultPolicy 邮箱只能走邮件；手机号默认走短信，用户收不到短信的时候可以自己选择语音
+
+type DefaultPolicy struct {
+}
+
+func NewDefaultPolicy() *DefaultPolicy {
+	return &DefaultPolicy{}
+}
+
+func (p *DefaultPolicy) Choose(ctx context.Context, biz, target string, preferred Channel) (Channel, error) {
+	if IsEmail(target) {
+		if preferred != "" && preferred != Email {
+			return "", ErrChannelNotSupported
+		}
+		return Email, nil
+	}
+	switch preferred {
+	case "":
+		return SMS, nil
+	case SMS, Voice:
+		return preferred, nil
+	default:
+		return "", ErrChannelNotSupported
+	}
+}
+
+// IsEmail 只是用来区分手机号和邮箱，邮箱格式的严格校验在 web 层
+func IsEmail(target string) bool {
+	return strings.Contains(target, "@")
+}
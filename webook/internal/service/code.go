@@ -2,43 +2,75 @@ package service
 
 import (
 	"Learn_Go/webook/internal/repository"
+	"Learn_Go/webook/internal/service/channel"
+	"Learn_Go/webook/internal/service/email"
 	"Learn_Go/webook/internal/service/sms"
+	"Learn_Go/webook/internal/service/voice"
 	"context"
 	"fmt"
 	"math/rand"
 )
 
-var ErrCodeSendTooMany = repository.ErrCodeSendTooMany
+var (
+	ErrCodeSendTooMany        = repository.ErrCodeSendTooMany
+	ErrCodeChannelUnsupported = channel.ErrChannelNotSupported
+)
 
 type CodeService interface {
+	// Send 由策略决定发送渠道，手机号默认走短信
 	Send(ctx context.Context, biz, phone string) error
+	// SendVia 指定发送渠道，target 可以是手机号或者邮箱
+	// ch 为空的时候和 Send 一样，由策略决定
+	SendVia(ctx context.Context, biz, target string, ch channel.Channel) error
 	Verify(ctx context.Context, biz, phone, inputCode string) (bool, error)
 }
 
 type codeService struct {
-	repo repository.CodeRepository
-	sms  sms.Service
+	repo    repository.CodeRepository
+	senders map[channel.Channel]channel.Sender
+	policy  channel.Policy
 }
 
-func NewcodeService(repo repository.CodeRepository, smsSvc sms.Service) CodeService {
+func NewcodeService(repo repository.CodeRepository, smsSvc sms.Service,
+	emailSvc email.Service, voiceSvc voice.Service) CodeService {
+	const (
+		codeTplId      = "1877556" // 可以将验证码模板设置为常量，很少改动
+		voiceCodeTplId = "1877557"
+	)
 	return &codeService{
 		repo: repo,
-		sms:  smsSvc,
+		senders: map[channel.Channel]channel.Sender{
+			channel.SMS:   channel.NewSMSSender(smsSvc, codeTplId),
+			channel.Email: channel.NewEmailSender(emailSvc),
+			channel.Voice: channel.NewVoiceSender(voiceSvc, voiceCodeTplId),
+		},
+		policy: channel.NewDefaultPolicy(),
 	}
 }
 
 func (c *codeService) Send(ctx context.Context, biz, phone string) error {
+	return c.SendVia(ctx, biz, phone, "")
+}
+
+func (c *codeService) SendVia(ctx context.Context, biz, target string, ch channel.Channel) error {
+	// 先确定渠道，渠道不对就不要占用发送频率了
+	ch, err := c.policy.Choose(ctx, biz, target, ch)
+	if err != nil {
+		return err
+	}
+	sender, ok := c.senders[ch]
+	if !ok {
+		return ErrCodeChannelUnsupported
+	}
 
 	code := c.generate()
-	err := c.repo.Set(ctx, biz, phone, code)
+	// 不管从哪个渠道发送，验证码都存在一起，60 秒内只能发一次，也就是说换渠道也不能绕过发送频率的限制
+	err = c.repo.Set(ctx, biz, target, code)
 	if err != nil {
 		return err
 	}
 	// 如果验证码保存成功，则开始发送验证码
-	const codeTplId = "1877556" // 可以将验证码模板设置为常量，很少改动
-	err = c.sms.Send(ctx, codeTplId, []string{code}, phone)
-
-	return err
+	return sender.Send(ctx, target, code)
 }
 
 func (c *codeService) Verify(ctx context.Context, biz, phone, inputCode string) (bool, error) {
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"Learn_Go/webook/internal/repository"
+	repomocks "Learn_Go/webook/internal/repository/mocks"
+	"Learn_Go/webook/internal/service/channel"
+	"Learn_Go/webook/internal/service/email"
+	emailmocks "Learn_Go/webook/internal/service/email/mocks"
+	"Learn_Go/webook/internal/service/sms"
+	smsmocks "Learn_Go/webook/internal/service/sms/mocks"
+	"Learn_Go/webook/internal/service/voice"
+	voicemocks "Learn_Go/webook/internal/service/voice/mocks"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"testing"
+)
+
+func Test_codeService_SendVia(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) (repository.CodeRepository, sms.Service, email.Service, voice.Service)
+
+		target string
+		ch     channel.Channel
+
+		wantErr error
+	}{
+		{
+			name: "手机号默认走短信",
+			mock: func(ctrl *gomock.Controller) (repository.CodeRepository, sms.Service, email.Service, voice.Service) {
+				repo := repomocks.NewMockCodeRepository(ctrl)
+				smsSvc := smsmocks.NewMockService(ctrl)
+				repo.EXPECT().Set(gomock.Any(), "login", "15012345678", gomock.Any()).Return(nil)
+				smsSvc.EXPECT().Send(gomock.Any(), gomock.Any(), gomock.Any(), "15012345678").Return(nil)
+				return repo, smsSvc, emailmocks.NewMockService(ctrl), voicemocks.NewMockService(ctrl)
+			},
+			target: "15012345678",
+		},
+		{
+			name: "用户选择语音",
+			mock: func(ctrl *gomock.Controller) (repository.CodeRepository, sms.Service, email.Service, voice.Service) {
+				repo := repomocks.NewMockCodeRepository(ctrl)
+				voiceSvc := voicemocks.NewMockService(ctrl)
+				repo.EXPECT().Set(gomock.Any(), "login", "15012345678", gomock.Any()).Return(nil)
+				voiceSvc.EXPECT().Call(gomock.Any(), gomock.Any(), gomock.Any(), "15012345678").Return(nil)
+				return repo, smsmocks.NewMockService(ctrl), emailmocks.NewMockService(ctrl), voiceSvc
+			},
+			target: "15012345678",
+			ch:     channel.Voice,
+		},
+		{
+			name: "邮箱走邮件",
+			mock: func(ctrl *gomock.Controller) (repository.CodeRepository, sms.Service, email.Service, voice.Service) {
+				repo := repomocks.NewMockCodeRepository(ctrl)
+				emailSvc := emailmocks.NewMockService(ctrl)
+				repo.EXPECT().Set(gomock.Any(), "login", "123@qq.com", gomock.Any()).Return(nil)
+				emailSvc.EXPECT().Send(gomock.Any(), "123@qq.com", gomock.Any(), gomock.Any()).Return(nil)
+				return repo, smsmocks.NewMockService(ctrl), emailSvc, voicemocks.NewMockService(ctrl)
+			},
+			target: "123@qq.com",
+		},
+		{
+			name: "邮箱不能走短信",
+			mock: func(ctrl *gomock.Controller) (repository.CodeRepository, sms.Service, email.Service, voice.Service) {
+				return repomocks.NewMockCodeRepository(ctrl), smsmocks.NewMockService(ctrl),
+					emailmocks.NewMockService(ctrl), voicemocks.NewMockService(ctrl)
+			},
+			target:  "123@qq.com",
+			ch:      channel.SMS,
+			wantErr: ErrCodeChannelUnsupported,
+		},
+		{
+			name: "换渠道也不能绕过发送频率限制",
+			mock: func(ctrl *gomock.Controller) (repository.CodeRepository, sms.Service, email.Service, voice.Service) {
+				repo := repomocks.NewMockCodeRepository(ctrl)
+				repo.EXPECT().Set(gomock.Any(), "login", "15012345678", gomock.Any()).Return(ErrCodeSendTooMany)
+				return repo, smsmocks.NewMockService(ctrl), emailmocks.NewMockService(ctrl), voicemocks.NewMockService(ctrl)
+			},
+			target:  "15012345678",
+			ch:      channel.Voice,
+			wantErr: ErrCodeSendTooMany,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo, smsSvc, emailSvc, voiceSvc := tc.mock(ctrl)
+			svc := NewcodeService(repo, smsSvc, emailSvc, voiceSvc)
+			err := svc.SendVia(context.Background(), "login", tc.target, tc.ch)
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package localemail
+
+import (
+	"context"
+	"log"
+)
+
+type Service struct {
+}
+
+func NewService() *Service {
+	return &Service{}
+}
+
+func (s *Service) Send(ctx context.Context, to, subject, body string) error {
+	log.Println("发送邮件给", to, subject, body)
+	return nil
+}
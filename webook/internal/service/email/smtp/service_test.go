@@ -0,0 +1,124 @@
+package smtp
+
+import (
+	"bufio"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net"
+	"strings"
+	"testing"
+)
+
+// fakeSMTPServer 只实现了发送一封邮件需要的最少命令，收到的邮件放到 mails 里面
+type fakeSMTPServer struct {
+	ln    net.Listener
+	mails chan fakeMail
+}
+
+type fakeMail struct {
+	from string
+	to   []string
+	data string
+}
+
+func newFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &fakeSMTPServer{
+		ln:    ln,
+		mails: make(chan fakeMail, 1),
+	}
+	go s.serve()
+	t.Cleanup(func() {
+		_ = ln.Close()
+	})
+	return s
+}
+
+func (s *fakeSMTPServer) port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+func (s *fakeSMTPServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeSMTPServer) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) {
+		_, _ = conn.Write([]byte(line + "\r\n"))
+	}
+	reply("220 localhost fake smtp")
+	var mail fakeMail
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			mail.from = strings.Trim(line[len("MAIL FROM:"):], "<> ")
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			mail.to = append(mail.to, strings.Trim(line[len("RCPT TO:"):], "<> "))
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 end with <CRLF>.<CRLF>")
+			var sb strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				sb.WriteString(l)
+			}
+			mail.data = sb.String()
+			s.mails <- mail
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestService_Send(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	svc := NewService("127.0.0.1", server.port(), "", "", "noreply@webook.com")
+
+	err := svc.Send(context.Background(), "123@qq.com", "webook 验证码", "你的验证码是 123456")
+	require.NoError(t, err)
+
+	mail := <-server.mails
+	assert.Equal(t, "noreply@webook.com", mail.from)
+	assert.Equal(t, []string{"123@qq.com"}, mail.to)
+	assert.Contains(t, mail.data, "To: 123@qq.com\r\n")
+	// 中文标题需要编码
+	assert.Contains(t, mail.data, "Subject: =?UTF-8?q?")
+	assert.Contains(t, mail.data, "你的验证码是 123456")
+}
+
+func TestService_Send_ContextCanceled(t *testing.T) {
+	svc := NewService("127.0.0.1", 1, "", "", "noreply@webook.com")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := svc.Send(ctx, "123@qq.com", "标题", "内容")
+	assert.Equal(t, context.Canceled, err)
+}
@@ -0,0 +1,10 @@
+package email
+
+import "context"
+
+// Service 是发送邮件的抽象
+// 和 sms.Service 一样，为了屏蔽不同邮件服务（SMTP、第三方邮件推送）之间的区别
+
+type Service interface {
+	Send(ctx context.Context, to, subject, body string) error
+}
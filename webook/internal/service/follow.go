@@ -0,0 +1,20 @@
+package service
+
+import "context"
+
+// FollowService 关注关系，目前只有主页的隐私设置用到
+// 关注功能还没有做，先用 NewNoopFollowService，所有人都当作没有关注
+type FollowService interface {
+	// IsFollowing follower 是否关注了 followee
+	IsFollowing(ctx context.Context, follower, followee int64) (bool, error)
+}
+
+type noopFollowService struct{}
+
+func NewNoopFollowService() FollowService {
+	return noopFollowService{}
+}
+
+func (noopFollowService) IsFollowing(ctx context.Context, follower, followee int64) (bool, error) {
+	return false, nil
+}
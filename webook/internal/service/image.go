@@ -0,0 +1,148 @@
+package service
+
+import (
+	"Learn_Go/webook/internal/domain"
+	"Learn_Go/webook/internal/repository"
+	"Learn_Go/webook/internal/service/storage"
+	"Learn_Go/webook/pkg/imagex"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrImageTooLarge = errors.New("图片太大")
+	// ErrInvalidImage 格式不支持、文件损坏或者尺寸太大
+	ErrInvalidImage = errors.New("不支持的图片")
+)
+
+// ImageService 上传头像和文章里面的图片
+// 文件名是内容的哈希，同样的图片只会存一份
+type ImageService interface {
+	// UploadAvatar 裁剪成正方形缩略图之后作为用户的头像，返回头像的 URL
+	UploadAvatar(ctx context.Context, uid int64, data []byte) (string, error)
+	UploadArticleImage(ctx context.Context, data []byte) (domain.Image, error)
+}
+
+type imageService struct {
+	store    storage.Storage
+	userRepo repository.UserRepository
+	// 各种图片的大小限制，单位字节
+	maxAvatarSize  int
+	maxArticleSize int
+	// avatarSize 头像的边长
+	avatarSize int
+	// thumbWidth 文章图片缩略图的最大宽高
+	thumbWidth int
+}
+
+func NewImageService(store storage.Storage, userRepo repository.UserRepository) ImageService {
+	return &imageService{
+		store:          store,
+		userRepo:       userRepo,
+		maxAvatarSize:  2 << 20,
+		maxArticleSize: 10 << 20,
+		avatarSize:     256,
+		thumbWidth:     480,
+	}
+}
+
+func (svc *imageService) UploadAvatar(ctx context.Context, uid int64, data []byte) (string, error) {
+	if len(data) > svc.maxAvatarSize {
+		return "", ErrImageTooLarge
+	}
+	info, err := inspectImage(data)
+	if err != nil {
+		return "", err
+	}
+	// 原图不保存，只保存裁剪之后的头像
+	key := fmt.Sprintf("avatars/%s_%d.%s", hashKey(data), svc.avatarSize, thumbExt(info))
+	err = svc.putIfAbsent(ctx, key, func() ([]byte, string, error) {
+		return thumbnail(data, svc.avatarSize, true)
+	})
+	if err != nil {
+		return "", err
+	}
+	url := svc.store.URL(key)
+	return url, svc.userRepo.UpdateAvatar(ctx, uid, url)
+}
+
+func (svc *imageService) UploadArticleImage(ctx context.Context, data []byte) (domain.Image, error) {
+	if len(data) > svc.maxArticleSize {
+		return domain.Image{}, ErrImageTooLarge
+	}
+	info, err := inspectImage(data)
+	if err != nil {
+		return domain.Image{}, err
+	}
+	hash := hashKey(data)
+	key := fmt.Sprintf("images/%s.%s", hash, info.Ext)
+	err = svc.putIfAbsent(ctx, key, func() ([]byte, string, error) {
+		return data, info.ContentType, nil
+	})
+	if err != nil {
+		return domain.Image{}, err
+	}
+	thumbKey := fmt.Sprintf("images/%s_%d.%s", hash, svc.thumbWidth, thumbExt(info))
+	err = svc.putIfAbsent(ctx, thumbKey, func() ([]byte, string, error) {
+		return thumbnail(data, svc.thumbWidth, false)
+	})
+	if err != nil {
+		return domain.Image{}, err
+	}
+	return domain.Image{
+		URL:      svc.store.URL(key),
+		ThumbURL: svc.store.URL(thumbKey),
+		Width:    info.Width,
+		Height:   info.Height,
+	}, nil
+}
+
+// putIfAbsent 已经有人上传过同样的图片就不用再处理一次了
+func (svc *imageService) putIfAbsent(ctx context.Context, key string,
+	build func() ([]byte, string, error)) error {
+	ok, err := svc.store.Exists(ctx, key)
+	if err != nil || ok {
+		return err
+	}
+	data, contentType, err := build()
+	if err != nil {
+		return err
+	}
+	return svc.store.Put(ctx, key, data, contentType)
+}
+
+// inspectImage 把 imagex 的各种错误统一成 ErrInvalidImage
+func inspectImage(data []byte) (imagex.Info, error) {
+	info, err := imagex.Inspect(data)
+	if err != nil {
+		return imagex.Info{}, ErrInvalidImage
+	}
+	return info, nil
+}
+
+// thumbnail 文件头没问题，但是数据损坏的时候到这里才能发现
+func thumbnail(data []byte, size int, square bool) ([]byte, string, error) {
+	thumb, info, err := imagex.Thumbnail(data, size, size, square)
+	if err != nil {
+		return nil, "", ErrInvalidImage
+	}
+	return thumb, info.ContentType, nil
+}
+
+// hashKey 前两个字符作为目录，避免一个目录下面的文件太多
+func hashKey(data []byte) string {
+	sum := sha256.Sum256(data)
+	h := hex.EncodeToString(sum[:])
+	return h[:2] + "/" + h
+}
+
+// thumbExt 和 imagex.Thumbnail 输出的格式保持一致
+func thumbExt(info imagex.Info) string {
+	if info.ContentType == "image/jpeg" {
+		return "jpg"
+	}
+	return "png"
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"Learn_Go/webook/internal/domain"
+	"Learn_Go/webook/internal/repository"
+	repomocks "Learn_Go/webook/internal/repository/mocks"
+	"Learn_Go/webook/internal/service/storage"
+	storagemocks "Learn_Go/webook/internal/service/storage/mocks"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"image"
+	"image/png"
+	"testing"
+)
+
+func pngBytes(t *testing.T, w, h int) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h))))
+	return buf.Bytes()
+}
+
+func sha(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func Test_imageService_UploadAvatar(t *testing.T) {
+	img := pngBytes(t, 600, 400)
+	h := sha(img)
+	key := "avatars/" + h[:2] + "/" + h + "_256.png"
+	testCases := []struct {
+		name string
+		data []byte
+		mock func(ctrl *gomock.Controller) (storage.Storage, repository.UserRepository)
+
+		wantURL string
+		wantErr error
+	}{
+		{
+			name: "上传成功",
+			data: img,
+			mock: func(ctrl *gomock.Controller) (storage.Storage, repository.UserRepository) {
+				store := storagemocks.NewMockStorage(ctrl)
+				repo := repomocks.NewMockUserRepository(ctrl)
+				store.EXPECT().Exists(gomock.Any(), key).Return(false, nil)
+				store.EXPECT().Put(gomock.Any(), key, gomock.Any(), "image/png").Return(nil)
+				store.EXPECT().URL(key).Return("/files/" + key)
+				repo.EXPECT().UpdateAvatar(gomock.Any(), int64(123), "/files/"+key).Return(nil)
+				return store, repo
+			},
+			wantURL: "/files/" + key,
+		},
+		{
+			name: "同样的图片不再上传",
+			data: img,
+			mock: func(ctrl *gomock.Controller) (storage.Storage, repository.UserRepository) {
+				store := storagemocks.NewMockStorage(ctrl)
+				repo := repomocks.NewMockUserRepository(ctrl)
+				store.EXPECT().Exists(gomock.Any(), key).Return(true, nil)
+				store.EXPECT().URL(key).Return("/files/" + key)
+				repo.EXPECT().UpdateAvatar(gomock.Any(), int64(123), "/files/"+key).Return(nil)
+				return store, repo
+			},
+			wantURL: "/files/" + key,
+		},
+		{
+			name: "不是图片",
+			data: []byte("<svg onload=alert(1)>"),
+			mock: func(ctrl *gomock.Controller) (storage.Storage, repository.UserRepository) {
+				return storagemocks.NewMockStorage(ctrl), repomocks.NewMockUserRepository(ctrl)
+			},
+			wantErr: ErrInvalidImage,
+		},
+		{
+			name: "图片太大",
+			data: make([]byte, 2<<20+1),
+			mock: func(ctrl *gomock.Controller) (storage.Storage, repository.UserRepository) {
+				return storagemocks.NewMockStorage(ctrl), repomocks.NewMockUserRepository(ctrl)
+			},
+			wantErr: ErrImageTooLarge,
+		},
+		{
+			name: "存储出错",
+			data: img,
+			mock: func(ctrl *gomock.Controller) (storage.Storage, repository.UserRepository) {
+				store := storagemocks.NewMockStorage(ctrl)
+				store.EXPECT().Exists(gomock.Any(), key).Return(false, nil)
+				store.EXPECT().Put(gomock.Any(), key, gomock.Any(), "image/png").Return(errors.New("网络错误"))
+				return store, repomocks.NewMockUserRepository(ctrl)
+			},
+			wantErr: errors.New("网络错误"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			svc := NewImageService(tc.mock(ctrl))
+			url, err := svc.UploadAvatar(context.Background(), 123, tc.data)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantURL, url)
+		})
+	}
+}
+
+func Test_imageService_UploadArticleImage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	img := pngBytes(t, 960, 100)
+	h := sha(img)
+	key := "images/" + h[:2] + "/" + h + ".png"
+	thumbKey := "images/" + h[:2] + "/" + h + "_480.png"
+
+	store := storagemocks.NewMockStorage(ctrl)
+	// 原图已经有人上传过了，缩略图还没有
+	store.EXPECT().Exists(gomock.Any(), key).Return(true, nil)
+	store.EXPECT().Exists(gomock.Any(), thumbKey).Return(false, nil)
+	store.EXPECT().Put(gomock.Any(), thumbKey, gomock.Any(), "image/png").
+		DoAndReturn(func(ctx context.Context, key string, data []byte, contentType string) error {
+			cfg, err := png.DecodeConfig(bytes.NewReader(data))
+			require.NoError(t, err)
+			assert.Equal(t, 480, cfg.Width)
+			assert.Equal(t, 50, cfg.Height)
+			return nil
+		})
+	store.EXPECT().URL(gomock.Any()).DoAndReturn(func(key string) string {
+		return "/files/" + key
+	}).Times(2)
+
+	res, err := NewImageService(store, repomocks.NewMockUserRepository(ctrl)).
+		UploadArticleImage(context.Background(), img)
+	require.NoError(t, err)
+	assert.Equal(t, domain.Image{
+		URL:      "/files/" + key,
+		ThumbURL: "/files/" + thumbKey,
+		Width:    960,
+		Height:   100,
+	}, res)
+}
@@ -0,0 +1,219 @@
+package service
+
+import (
+	"Learn_Go/webook/internal/domain"
+	"Learn_Go/webook/internal/repository"
+	"Learn_Go/webook/pkg/totp"
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"github.com/skip2/go-qrcode"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMFAAlreadyEnabled = errors.New("已经开启了两步验证")
+	ErrMFANotEnrolled    = errors.New("还没有绑定验证器应用")
+	ErrMFACodeWrong      = errors.New("两步验证的验证码错误")
+)
+
+// MFAService 基于 TOTP 的两步验证
+// 用户先绑定验证器应用，输入一次验证码确认之后才真正开启，同时拿到一组恢复码
+// 开启之后邮箱密码登录需要再输入验证器应用上的验证码，或者一个恢复码
+type MFAService interface {
+	// Enroll 生成新的密钥，account 是显示在验证器应用上的账号名
+	Enroll(ctx context.Context, uid int64, account string) (domain.TOTPEnrollment, error)
+	// Confirm 用验证器应用上的验证码确认绑定，返回恢复码的原文，只有这一次能看到
+	Confirm(ctx context.Context, uid int64, code string) ([]string, error)
+	// Disable 关闭两步验证，需要验证码或者恢复码
+	Disable(ctx context.Context, uid int64, code string) error
+	Enabled(ctx context.Context, uid int64) (bool, error)
+	// Verify 登录的时候校验验证码或者恢复码，错误的时候返回 ErrMFACodeWrong
+	Verify(ctx context.Context, uid int64, code string) error
+}
+
+type mfaService struct {
+	repo repository.MFARepository
+	// 验证器应用上显示的发行方
+	issuer string
+	// 容忍前后几个周期的时间误差
+	skew int
+	// 恢复码的数量
+	recoveryCodes int
+	qrSize        int
+	now           func() time.Time
+}
+
+func NewMFAService(repo repository.MFARepository) MFAService {
+	return &mfaService{
+		repo:          repo,
+		issuer:        "webook",
+		skew:          1,
+		recoveryCodes: 10,
+		qrSize:        256,
+		now:           time.Now,
+	}
+}
+
+func (svc *mfaService) Enroll(ctx context.Context, uid int64, account string) (domain.TOTPEnrollment, error) {
+	t, err := svc.repo.FindTOTP(ctx, uid)
+	switch {
+	case err == nil && t.Enabled:
+		return domain.TOTPEnrollment{}, ErrMFAAlreadyEnabled
+	case err != nil && err != repository.ErrTOTPNotFound:
+		return domain.TOTPEnrollment{}, err
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return domain.TOTPEnrollment{}, err
+	}
+	// 没有确认之前可以重复绑定，覆盖之前的密钥
+	err = svc.repo.SaveTOTP(ctx, domain.TOTP{
+		Uid:    uid,
+		Secret: secret,
+	})
+	if err != nil {
+		return domain.TOTPEnrollment{}, err
+	}
+	uri := totp.URI(svc.issuer, account, secret)
+	png, err := qrcode.Encode(uri, qrcode.Medium, svc.qrSize)
+	if err != nil {
+		return domain.TOTPEnrollment{}, err
+	}
+	return domain.TOTPEnrollment{
+		Secret: secret,
+		URI:    uri,
+		QRCode: png,
+	}, nil
+}
+
+func (svc *mfaService) Confirm(ctx context.Context, uid int64, code string) ([]string, error) {
+	t, err := svc.repo.FindTOTP(ctx, uid)
+	if err == repository.ErrTOTPNotFound {
+		return nil, ErrMFANotEnrolled
+	}
+	if err != nil {
+		return nil, err
+	}
+	if t.Enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	counter, ok := totp.Validate(t.Secret, code, svc.now(), svc.skew)
+	if !ok {
+		return nil, ErrMFACodeWrong
+	}
+	codes, hashes, err := svc.generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	// 先保存恢复码再开启，开启成功的时候用户一定有恢复码
+	if err = svc.repo.ReplaceRecoveryCodes(ctx, uid, hashes); err != nil {
+		return nil, err
+	}
+	if err = svc.repo.EnableTOTP(ctx, uid, counter); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (svc *mfaService) Disable(ctx context.Context, uid int64, code string) error {
+	if err := svc.Verify(ctx, uid, code); err != nil {
+		return err
+	}
+	return svc.repo.DeleteTOTP(ctx, uid)
+}
+
+func (svc *mfaService) Enabled(ctx context.Context, uid int64) (bool, error) {
+	t, err := svc.repo.FindTOTP(ctx, uid)
+	if err == repository.ErrTOTPNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return t.Enabled, nil
+}
+
+func (svc *mfaService) Verify(ctx context.Context, uid int64, code string) error {
+	t, err := svc.repo.FindTOTP(ctx, uid)
+	if err == repository.ErrTOTPNotFound {
+		return ErrMFANotEnrolled
+	}
+	if err != nil {
+		return err
+	}
+	if !t.Enabled {
+		return ErrMFANotEnrolled
+	}
+	code = strings.TrimSpace(code)
+	if len(code) == totp.Digits {
+		return svc.verifyTOTP(ctx, t, code)
+	}
+	return svc.verifyRecoveryCode(ctx, uid, code)
+}
+
+func (svc *mfaService) verifyTOTP(ctx context.Context, t domain.TOTP, code string) error {
+	counter, ok := totp.Validate(t.Secret, code, svc.now(), svc.skew)
+	if !ok || counter <= t.LastCounter {
+		return ErrMFACodeWrong
+	}
+	// 并发使用同一个验证码的时候，只有一个能更新成功
+	ok, err := svc.repo.UpdateLastCounter(ctx, t.Uid, counter)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrMFACodeWrong
+	}
+	return nil
+}
+
+func (svc *mfaService) verifyRecoveryCode(ctx context.Context, uid int64, code string) error {
+	codes, err := svc.repo.FindUnusedRecoveryCodes(ctx, uid)
+	if err != nil {
+		return err
+	}
+	code = normalizeRecoveryCode(code)
+	for _, c := range codes {
+		if bcrypt.CompareHashAndPassword([]byte(c.Hash), []byte(code)) != nil {
+			continue
+		}
+		ok, err := svc.repo.UseRecoveryCode(ctx, c.Id)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			// 被并发用掉了
+			return ErrMFACodeWrong
+		}
+		return nil
+	}
+	return ErrMFACodeWrong
+}
+
+// generateRecoveryCodes 恢复码是 xxxx-xxxx 的格式，用户输入的时候不区分大小写，横线可有可无
+func (svc *mfaService) generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, svc.recoveryCodes)
+	hashes := make([]string, 0, svc.recoveryCodes)
+	data := make([]byte, 5)
+	for i := 0; i < svc.recoveryCodes; i++ {
+		if _, err := rand.Read(data); err != nil {
+			return nil, nil, err
+		}
+		raw := strings.ToLower(base32.StdEncoding.EncodeToString(data))
+		hash, err := bcrypt.GenerateFromPassword([]byte(raw), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, nil, err
+		}
+		codes = append(codes, raw[:4]+"-"+raw[4:])
+		hashes = append(hashes, string(hash))
+	}
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(code, "-", ""))
+}
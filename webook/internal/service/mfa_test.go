@@ -0,0 +1,144 @@
+package service
+
+import (
+	"Learn_Go/webook/internal/domain"
+	"Learn_Go/webook/internal/repository"
+	repomocks "Learn_Go/webook/internal/repository/mocks"
+	"Learn_Go/webook/pkg/totp"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"golang.org/x/crypto/bcrypt"
+	"testing"
+	"time"
+)
+
+func Test_mfaService_Verify(t *testing.T) {
+	const secret = "JBSWY3DPEHPK3PXP"
+	now := time.Unix(1700000000, 0)
+	counter := totp.Counter(now)
+	code, err := totp.Code(secret, counter)
+	require.NoError(t, err)
+	hash, err := bcrypt.GenerateFromPassword([]byte("abcdefgh"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name    string
+		mock    func(ctrl *gomock.Controller) repository.MFARepository
+		code    string
+		wantErr error
+	}{
+		{
+			name: "验证码正确",
+			mock: func(ctrl *gomock.Controller) repository.MFARepository {
+				repo := repomocks.NewMockMFARepository(ctrl)
+				repo.EXPECT().FindTOTP(gomock.Any(), int64(123)).
+					Return(domain.TOTP{Uid: 123, Secret: secret, Enabled: true, LastCounter: counter - 5}, nil)
+				repo.EXPECT().UpdateLastCounter(gomock.Any(), int64(123), counter).Return(true, nil)
+				return repo
+			},
+			code: code,
+		},
+		{
+			name: "同一个周期的验证码已经用过了",
+			mock: func(ctrl *gomock.Controller) repository.MFARepository {
+				repo := repomocks.NewMockMFARepository(ctrl)
+				repo.EXPECT().FindTOTP(gomock.Any(), int64(123)).
+					Return(domain.TOTP{Uid: 123, Secret: secret, Enabled: true, LastCounter: counter}, nil)
+				return repo
+			},
+			code:    code,
+			wantErr: ErrMFACodeWrong,
+		},
+		{
+			name: "并发使用同一个验证码",
+			mock: func(ctrl *gomock.Controller) repository.MFARepository {
+				repo := repomocks.NewMockMFARepository(ctrl)
+				repo.EXPECT().FindTOTP(gomock.Any(), int64(123)).
+					Return(domain.TOTP{Uid: 123, Secret: secret, Enabled: true}, nil)
+				repo.EXPECT().UpdateLastCounter(gomock.Any(), int64(123), counter).Return(false, nil)
+				return repo
+			},
+			code:    code,
+			wantErr: ErrMFACodeWrong,
+		},
+		{
+			name: "没有确认绑定",
+			mock: func(ctrl *gomock.Controller) repository.MFARepository {
+				repo := repomocks.NewMockMFARepository(ctrl)
+				repo.EXPECT().FindTOTP(gomock.Any(), int64(123)).
+					Return(domain.TOTP{Uid: 123, Secret: secret}, nil)
+				return repo
+			},
+			code:    code,
+			wantErr: ErrMFANotEnrolled,
+		},
+		{
+			name: "恢复码正确",
+			mock: func(ctrl *gomock.Controller) repository.MFARepository {
+				repo := repomocks.NewMockMFARepository(ctrl)
+				repo.EXPECT().FindTOTP(gomock.Any(), int64(123)).
+					Return(domain.TOTP{Uid: 123, Secret: secret, Enabled: true}, nil)
+				repo.EXPECT().FindUnusedRecoveryCodes(gomock.Any(), int64(123)).
+					Return([]domain.RecoveryCode{{Id: 1, Hash: string(hash)}}, nil)
+				repo.EXPECT().UseRecoveryCode(gomock.Any(), int64(1)).Return(true, nil)
+				return repo
+			},
+			code: "ABCD-EFGH",
+		},
+		{
+			name: "恢复码错误",
+			mock: func(ctrl *gomock.Controller) repository.MFARepository {
+				repo := repomocks.NewMockMFARepository(ctrl)
+				repo.EXPECT().FindTOTP(gomock.Any(), int64(123)).
+					Return(domain.TOTP{Uid: 123, Secret: secret, Enabled: true}, nil)
+				repo.EXPECT().FindUnusedRecoveryCodes(gomock.Any(), int64(123)).
+					Return([]domain.RecoveryCode{{Id: 1, Hash: string(hash)}}, nil)
+				return repo
+			},
+			code:    "abcd-efgx",
+			wantErr: ErrMFACodeWrong,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			svc := NewMFAService(tc.mock(ctrl)).(*mfaService)
+			svc.now = func() time.Time { return now }
+			err := svc.Verify(context.Background(), 123, tc.code)
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func Test_mfaService_Confirm(t *testing.T) {
+	const secret = "JBSWY3DPEHPK3PXP"
+	now := time.Unix(1700000000, 0)
+	counter := totp.Counter(now)
+	code, err := totp.Code(secret, counter)
+	require.NoError(t, err)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := repomocks.NewMockMFARepository(ctrl)
+	repo.EXPECT().FindTOTP(gomock.Any(), int64(123)).Return(domain.TOTP{Uid: 123, Secret: secret}, nil)
+	var hashes []string
+	repo.EXPECT().ReplaceRecoveryCodes(gomock.Any(), int64(123), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, uid int64, hs []string) error {
+			hashes = hs
+			return nil
+		})
+	repo.EXPECT().EnableTOTP(gomock.Any(), int64(123), counter).Return(nil)
+
+	svc := NewMFAService(repo).(*mfaService)
+	svc.now = func() time.Time { return now }
+	codes, err := svc.Confirm(context.Background(), 123, code)
+	require.NoError(t, err)
+	require.Len(t, codes, 10)
+	require.Len(t, hashes, 10)
+	// 保存的是哈希，用户拿到的恢复码去掉横线之后可以对上
+	assert.Len(t, codes[0], 9)
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(hashes[0]), []byte(normalizeRecoveryCode(codes[0]))))
+}
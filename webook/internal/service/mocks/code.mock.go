@@ -10,6 +10,7 @@
 package svcmocks
 
 import (
+	channel "Learn_Go/webook/internal/service/channel"
 	context "context"
 	reflect "reflect"
 
@@ -53,6 +54,20 @@ func (mr *MockCodeServiceMockRecorder) Send(ctx, biz, phone any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*MockCodeService)(nil).Send), ctx, biz, phone)
 }
 
+// SendVia mocks base method.
+func (m *MockCodeService) SendVia(ctx context.Context, biz, target string, ch channel.Channel) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendVia", ctx, biz, target, ch)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendVia indicates an expected call of SendVia.
+func (mr *MockCodeServiceMockRecorder) SendVia(ctx, biz, target, ch any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendVia", reflect.TypeOf((*MockCodeService)(nil).SendVia), ctx, biz, target, ch)
+}
+
 // Verify mocks base method.
 func (m *MockCodeService) Verify(ctx context.Context, biz, phone, inputCode string) (bool, error) {
 	m.ctrl.T.Helper()
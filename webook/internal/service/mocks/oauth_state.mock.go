@@ -0,0 +1,72 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./webook/internal/service/oauth_state.go
+//
+// Generated by this command:
+//
+//	mockgen -source=./webook/internal/service/oauth_state.go -package=svcmocks -destination=./webook/internal/service/mocks/oauth_state.mock.go
+//
+
+// Package svcmocks is a generated GoMock package.
+package svcmocks
+
+import (
+	domain "Learn_Go/webook/internal/domain"
+	context "context"
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockOAuthStateService is a mock of OAuthStateService interface.
+type MockOAuthStateService struct {
+	ctrl     *gomock.Controller
+	recorder *MockOAuthStateServiceMockRecorder
+}
+
+// MockOAuthStateServiceMockRecorder is the mock recorder for MockOAuthStateService.
+type MockOAuthStateServiceMockRecorder struct {
+	mock *MockOAuthStateService
+}
+
+// NewMockOAuthStateService creates a new mock instance.
+func NewMockOAuthStateService(ctrl *gomock.Controller) *MockOAuthStateService {
+	mock := &MockOAuthStateService{ctrl: ctrl}
+	mock.recorder = &MockOAuthStateServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockOAuthStateService) EXPECT() *MockOAuthStateServiceMockRecorder {
+	return m.recorder
+}
+
+// Consume mocks base method.
+func (m *MockOAuthStateService) Consume(ctx context.Context, state, provider, browser string) (domain.OAuthState, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Consume", ctx, state, provider, browser)
+	ret0, _ := ret[0].(domain.OAuthState)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Consume indicates an expected call of Consume.
+func (mr *MockOAuthStateServiceMockRecorder) Consume(ctx, state, provider, browser any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Consume", reflect.TypeOf((*MockOAuthStateService)(nil).Consume), ctx, state, provider, browser)
+}
+
+// Create mocks base method.
+func (m *MockOAuthStateService) Create(ctx context.Context, st domain.OAuthState) (string, domain.OAuthState, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", ctx, st)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(domain.OAuthState)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockOAuthStateServiceMockRecorder) Create(ctx, st any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockOAuthStateService)(nil).Create), ctx, st)
+}
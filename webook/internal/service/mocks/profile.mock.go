@@ -0,0 +1,85 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./webook/internal/service/profile.go
+//
+// Generated by this command:
+//
+//	mockgen -source=./webook/internal/service/profile.go -package=svcmocks -destination=./webook/internal/service/mocks/profile.mock.go
+//
+
+// Package svcmocks is a generated GoMock package.
+package svcmocks
+
+import (
+	domain "Learn_Go/webook/internal/domain"
+	context "context"
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockProfileService is a mock of ProfileService interface.
+type MockProfileService struct {
+	ctrl     *gomock.Controller
+	recorder *MockProfileServiceMockRecorder
+}
+
+// MockProfileServiceMockRecorder is the mock recorder for MockProfileService.
+type MockProfileServiceMockRecorder struct {
+	mock *MockProfileService
+}
+
+// NewMockProfileService creates a new mock instance.
+func NewMockProfileService(ctrl *gomock.Controller) *MockProfileService {
+	mock := &MockProfileService{ctrl: ctrl}
+	mock.recorder = &MockProfileServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockProfileService) EXPECT() *MockProfileServiceMockRecorder {
+	return m.recorder
+}
+
+// Privacy mocks base method.
+func (m *MockProfileService) Privacy(ctx context.Context, uid int64) (domain.PrivacySettings, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Privacy", ctx, uid)
+	ret0, _ := ret[0].(domain.PrivacySettings)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Privacy indicates an expected call of Privacy.
+func (mr *MockProfileServiceMockRecorder) Privacy(ctx, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Privacy", reflect.TypeOf((*MockProfileService)(nil).Privacy), ctx, uid)
+}
+
+// Profile mocks base method.
+func (m *MockProfileService) Profile(ctx context.Context, viewer, uid int64) (domain.PublicProfile, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Profile", ctx, viewer, uid)
+	ret0, _ := ret[0].(domain.PublicProfile)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Profile indicates an expected call of Profile.
+func (mr *MockProfileServiceMockRecorder) Profile(ctx, viewer, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Profile", reflect.TypeOf((*MockProfileService)(nil).Profile), ctx, viewer, uid)
+}
+
+// UpdatePrivacy mocks base method.
+func (m *MockProfileService) UpdatePrivacy(ctx context.Context, uid int64, p domain.PrivacySettings) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePrivacy", ctx, uid, p)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdatePrivacy indicates an expected call of UpdatePrivacy.
+func (mr *MockProfileServiceMockRecorder) UpdatePrivacy(ctx, uid, p any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePrivacy", reflect.TypeOf((*MockProfileService)(nil).UpdatePrivacy), ctx, uid, p)
+}
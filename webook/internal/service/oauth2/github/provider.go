@@ -0,0 +1,142 @@
+package github
+
+import (
+	"Learn_Go/webook/internal/domain"
+	"Learn_Go/webook/internal/service/oauth2"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// 默认的接口地址，测试的时候可以换掉
+const (
+	authURL  = "https://github.com/login/oauth/authorize"
+	tokenURL = "https://github.com/login/oauth/access_token"
+	userURL  = "https://api.github.com/user"
+)
+
+type Config struct {
+	ClientId     string
+	ClientSecret string
+	RedirectURL  string
+	// 下面三个为空的时候用 github.com 的地址，GitHub Enterprise 需要配置
+	AuthURL  string
+	TokenURL string
+	UserURL  string
+}
+
+// Provider GitHub 不是 OIDC，没有 id_token，要用 access_token 调用 /user 拿到用户信息
+type Provider struct {
+	cfg    Config
+	client *http.Client
+}
+
+func NewProvider(cfg Config, client *http.Client) *Provider {
+	if cfg.AuthURL == "" {
+		cfg.AuthURL = authURL
+	}
+	if cfg.TokenURL == "" {
+		cfg.TokenURL = tokenURL
+	}
+	if cfg.UserURL == "" {
+		cfg.UserURL = userURL
+	}
+	return &Provider{
+		cfg:    cfg,
+		client: client,
+	}
+}
+
+func (p *Provider) Name() string {
+	return "github"
+}
+
+func (p *Provider) AuthURL(ctx context.Context, state, verifier string) (string, error) {
+	q := url.Values{}
+	q.Set("client_id", p.cfg.ClientId)
+	q.Set("redirect_uri", p.cfg.RedirectURL)
+	q.Set("scope", "read:user user:email")
+	q.Set("state", state)
+	q.Set("code_challenge", oauth2.Challenge(verifier))
+	q.Set("code_challenge_method", "S256")
+	return p.cfg.AuthURL + "?" + q.Encode(), nil
+}
+
+func (p *Provider) Exchange(ctx context.Context, code, verifier string) (oauth2.Token, error) {
+	form := url.Values{}
+	form.Set("client_id", p.cfg.ClientId)
+	form.Set("client_secret", p.cfg.ClientSecret)
+	form.Set("code", code)
+	form.Set("redirect_uri", p.cfg.RedirectURL)
+	form.Set("code_verifier", verifier)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return oauth2.Token{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// 不设置的话 GitHub 返回的是 form 格式
+	req.Header.Set("Accept", "application/json")
+	var res struct {
+		AccessToken      string `json:"access_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err = p.do(req, &res); err != nil {
+		return oauth2.Token{}, err
+	}
+	// 授权码错误的时候 GitHub 返回的也是 200，只能看 error 字段
+	if res.Error != "" || res.AccessToken == "" {
+		return oauth2.Token{}, fmt.Errorf("%w: %s %s", oauth2.ErrExchangeFailed, res.Error, res.ErrorDescription)
+	}
+	return oauth2.Token{AccessToken: res.AccessToken}, nil
+}
+
+func (p *Provider) Identity(ctx context.Context, token oauth2.Token) (domain.OAuthIdentity, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.cfg.UserURL, nil)
+	if err != nil {
+		return domain.OAuthIdentity{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	var res struct {
+		Id        int64  `json:"id"`
+		Login     string `json:"login"`
+		Name      string `json:"name"`
+		Email     string `json:"email"`
+		AvatarURL string `json:"avatar_url"`
+	}
+	if err = p.do(req, &res); err != nil {
+		return domain.OAuthIdentity{}, err
+	}
+	if res.Id == 0 {
+		return domain.OAuthIdentity{}, fmt.Errorf("GitHub 没有返回用户 id")
+	}
+	nickname := res.Name
+	if nickname == "" {
+		nickname = res.Login
+	}
+	return domain.OAuthIdentity{
+		Provider: p.Name(),
+		// login 可以改名，只有 id 是不变的
+		Subject:  strconv.FormatInt(res.Id, 10),
+		Email:    res.Email,
+		Nickname: nickname,
+		Avatar:   res.AvatarURL,
+	}, nil
+}
+
+func (p *Provider) do(req *http.Request, val any) error {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("请求 %s 失败 %d", req.URL.Path, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(val)
+}
@@ -0,0 +1,94 @@
+package github
+
+import (
+	"Learn_Go/webook/internal/domain"
+	"Learn_Go/webook/internal/service/oauth2"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProvider_Login(t *testing.T) {
+	testCases := []struct {
+		name    string
+		code    string
+		user    map[string]any
+		wantErr error
+		wantId  domain.OAuthIdentity
+	}{
+		{
+			name: "登录成功",
+			code: "code-123",
+			user: map[string]any{"id": 42, "login": "octocat", "name": "The Octocat", "avatar_url": "https://example.com/a.png"},
+			wantId: domain.OAuthIdentity{
+				Provider: "github",
+				Subject:  "42",
+				Nickname: "The Octocat",
+				Avatar:   "https://example.com/a.png",
+			},
+		},
+		{
+			name: "没有设置名字的时候用 login",
+			code: "code-123",
+			user: map[string]any{"id": 42, "login": "octocat", "email": "octocat@github.com"},
+			wantId: domain.OAuthIdentity{
+				Provider: "github",
+				Subject:  "42",
+				Email:    "octocat@github.com",
+				Nickname: "octocat",
+			},
+		},
+		{
+			name:    "授权码错误",
+			code:    "bad-code",
+			wantErr: oauth2.ErrExchangeFailed,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			verifier, err := oauth2.NewVerifier()
+			require.NoError(t, err)
+			mux := http.NewServeMux()
+			mux.HandleFunc("/login/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+				require.NoError(t, r.ParseForm())
+				w.Header().Set("Content-Type", "application/json")
+				// 授权码错误的时候 GitHub 返回的也是 200
+				if r.PostForm.Get("code") != "code-123" || r.PostForm.Get("code_verifier") != verifier ||
+					r.PostForm.Get("client_secret") != "client-secret" {
+					_ = json.NewEncoder(w).Encode(map[string]string{"error": "bad_verification_code"})
+					return
+				}
+				_ = json.NewEncoder(w).Encode(map[string]string{"access_token": "gho_123", "token_type": "bearer"})
+			})
+			mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != "Bearer gho_123" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				_ = json.NewEncoder(w).Encode(tc.user)
+			})
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			p := NewProvider(Config{
+				ClientId:     "client-id",
+				ClientSecret: "client-secret",
+				TokenURL:     server.URL + "/login/oauth/access_token",
+				UserURL:      server.URL + "/user",
+			}, server.Client())
+			ctx := context.Background()
+			token, err := p.Exchange(ctx, tc.code, verifier)
+			assert.ErrorIs(t, err, tc.wantErr)
+			if err != nil {
+				return
+			}
+			id, err := p.Identity(ctx, token)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantId, id)
+		})
+	}
+}
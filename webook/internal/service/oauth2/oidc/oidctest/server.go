@@ -0,0 +1,220 @@
+// Package oidctest 测试用的 OIDC IdP，支持授权码模式和 PKCE
+package oidctest
+
+import (
+	"Learn_Go/webook/internal/service/oauth2"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Server 授权的时候不需要用户确认，直接跳转回 redirect_uri
+type Server struct {
+	*httptest.Server
+	ClientId     string
+	ClientSecret string
+
+	lock sync.Mutex
+	// Subject、Email 和 Name 是下一次签发的 id_token 里面的用户信息
+	Subject string
+	Email   string
+	Name    string
+	// Audience 不为空的时候用它代替 ClientId，用来测试 aud 不对的 id_token
+	Audience string
+	// TTL id_token 的有效期，默认一小时
+	TTL time.Duration
+
+	kid   int
+	key   *rsa.PrivateKey
+	codes map[string]grant
+}
+
+type grant struct {
+	clientId    string
+	redirectURI string
+	challenge   string
+	nonce       string
+}
+
+func NewServer(clientId, clientSecret string) *Server {
+	s := &Server{
+		ClientId:     clientId,
+		ClientSecret: clientSecret,
+		Subject:      "user-1",
+		Email:        "user-1@example.com",
+		Name:         "User 1",
+		TTL:          time.Hour,
+		codes:        map[string]grant{},
+	}
+	s.RotateKey()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("/authorize", s.authorize)
+	mux.HandleFunc("/token", s.token)
+	mux.HandleFunc("/jwks", s.jwks)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// RotateKey 换一个新的签名密钥，kid 也会变
+func (s *Server) RotateKey() {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.kid++
+	s.key = key
+}
+
+// Authorize 模拟用户在 IdP 上同意授权，返回回调地址里面的 code
+func (s *Server) Authorize(authURL string) (code, state string, err error) {
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		return "", "", fmt.Errorf("授权失败 %d", resp.StatusCode)
+	}
+	loc, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return "", "", err
+	}
+	return loc.Query().Get("code"), loc.Query().Get("state"), nil
+}
+
+func (s *Server) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                 s.URL,
+		"authorization_endpoint": s.URL + "/authorize",
+		"token_endpoint":         s.URL + "/token",
+		"jwks_uri":               s.URL + "/jwks",
+	})
+}
+
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != s.ClientId || q.Get("response_type") != "code" ||
+		q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+	code := randString()
+	s.lock.Lock()
+	s.codes[code] = grant{
+		clientId:    q.Get("client_id"),
+		redirectURI: q.Get("redirect_uri"),
+		challenge:   q.Get("code_challenge"),
+		nonce:       q.Get("nonce"),
+	}
+	s.lock.Unlock()
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+	rq := redirect.Query()
+	rq.Set("code", code)
+	rq.Set("state", q.Get("state"))
+	redirect.RawQuery = rq.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	code := r.PostForm.Get("code")
+	g, ok := s.codes[code]
+	// 授权码只能用一次
+	delete(s.codes, code)
+	switch {
+	case r.PostForm.Get("client_id") != s.ClientId || r.PostForm.Get("client_secret") != s.ClientSecret:
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	case !ok || r.PostForm.Get("redirect_uri") != g.redirectURI ||
+		oauth2.Challenge(r.PostForm.Get("code_verifier")) != g.challenge:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	aud := s.Audience
+	if aud == "" {
+		aud = g.clientId
+	}
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   s.URL,
+		"sub":   s.Subject,
+		"aud":   aud,
+		"iat":   now.Unix(),
+		"exp":   now.Add(s.TTL).Unix(),
+		"nonce": g.nonce,
+		"email": s.Email,
+		"name":  s.Name,
+	})
+	token.Header["kid"] = s.kidString()
+	idToken, err := token.SignedString(s.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"access_token": randString(),
+		"token_type":   "Bearer",
+		"id_token":     idToken,
+	})
+}
+
+func (s *Server) jwks(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	pub := s.key.PublicKey
+	kid := s.kidString()
+	s.lock.Unlock()
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{
+			{
+				"kty": "RSA",
+				"kid": kid,
+				"use": "sig",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			},
+		},
+	})
+}
+
+func (s *Server) kidString() string {
+	return fmt.Sprintf("key-%d", s.kid)
+}
+
+func randString() string {
+	data := make([]byte, 16)
+	_, _ = rand.Read(data)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func writeJSON(w http.ResponseWriter, status int, val any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(val)
+}
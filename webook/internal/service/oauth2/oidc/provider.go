@@ -0,0 +1,266 @@
+package oidc
+
+import (
+	"Learn_Go/webook/internal/domain"
+	"Learn_Go/webook/internal/service/oauth2"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrIDTokenInvalid = errors.New("id_token 不合法")
+	ErrUnknownKid     = errors.New("未知的 kid")
+)
+
+// jwksRefreshInterval 遇到不认识的 kid 最多每隔这么久拉取一次 JWKS
+// 防止别人用随便编的 kid 让我们不停地请求 IdP
+const jwksRefreshInterval = time.Minute
+
+type Config struct {
+	// Name 路由 /oauth2/:provider 里面的名字，比如 google
+	Name string
+	// Issuer 从 Issuer + /.well-known/openid-configuration 拿到各个接口的地址
+	Issuer       string
+	ClientId     string
+	ClientSecret string
+	RedirectURL  string
+	// Scopes 为空的时候是 openid email profile
+	Scopes []string
+}
+
+// Provider 标准的 OpenID Connect，授权码模式加上 PKCE
+// 第一次用到的时候才去拉取 discovery 文档，避免 IdP 不可用的时候启动失败
+type Provider struct {
+	cfg    Config
+	client *http.Client
+
+	lock      sync.RWMutex
+	discovery *discovery
+	// kid => 公钥
+	keys map[string]any
+
+	// refreshLock 同一时间只有一个请求去拉取 JWKS，也保护 refreshedAt
+	refreshLock sync.Mutex
+	// refreshedAt 上一次拉取 JWKS 的时间，失败了也算
+	refreshedAt time.Time
+}
+
+func NewProvider(cfg Config, client *http.Client) *Provider {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+	return &Provider{
+		cfg:    cfg,
+		client: client,
+	}
+}
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+func (p *Provider) Name() string {
+	return p.cfg.Name
+}
+
+func (p *Provider) AuthURL(ctx context.Context, state, verifier string) (string, error) {
+	d, err := p.getDiscovery(ctx)
+	if err != nil {
+		return "", err
+	}
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", p.cfg.ClientId)
+	q.Set("redirect_uri", p.cfg.RedirectURL)
+	q.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce(verifier))
+	q.Set("code_challenge", oauth2.Challenge(verifier))
+	q.Set("code_challenge_method", "S256")
+	return d.AuthorizationEndpoint + "?" + q.Encode(), nil
+}
+
+// nonce 从 verifier 推导出来，不需要另外保存
+// verifier 只有我们自己知道，所以别人没办法把其它请求拿到的 id_token 塞到这次登录里面
+func nonce(verifier string) string {
+	sum := sha256.Sum256([]byte("nonce:" + verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func (p *Provider) Exchange(ctx context.Context, code, verifier string) (oauth2.Token, error) {
+	d, err := p.getDiscovery(ctx)
+	if err != nil {
+		return oauth2.Token{}, err
+	}
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.cfg.RedirectURL)
+	form.Set("client_id", p.cfg.ClientId)
+	form.Set("client_secret", p.cfg.ClientSecret)
+	form.Set("code_verifier", verifier)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return oauth2.Token{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	var res struct {
+		AccessToken      string `json:"access_token"`
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err = p.do(req, &res); err != nil {
+		return oauth2.Token{}, err
+	}
+	if res.Error != "" || res.IDToken == "" {
+		return oauth2.Token{}, fmt.Errorf("%w: %s %s", oauth2.ErrExchangeFailed, res.Error, res.ErrorDescription)
+	}
+	return oauth2.Token{
+		AccessToken: res.AccessToken,
+		IDToken:     res.IDToken,
+		Extra:       map[string]string{"nonce": nonce(verifier)},
+	}, nil
+}
+
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce   string `json:"nonce"`
+	Email   string `json:"email"`
+	Name    string `json:"name"`
+	Picture string `json:"picture"`
+}
+
+// Identity 用户身份直接从 id_token 里面拿，不需要再调用 userinfo 接口
+func (p *Provider) Identity(ctx context.Context, token oauth2.Token) (domain.OAuthIdentity, error) {
+	d, err := p.getDiscovery(ctx)
+	if err != nil {
+		return domain.OAuthIdentity{}, err
+	}
+	var claims idTokenClaims
+	_, err = jwt.ParseWithClaims(token.IDToken, &claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.publicKey(ctx, d, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "ES256", "EdDSA"}),
+		jwt.WithIssuer(d.Issuer),
+		jwt.WithAudience(p.cfg.ClientId),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute))
+	if err != nil {
+		return domain.OAuthIdentity{}, fmt.Errorf("%w: %w", ErrIDTokenInvalid, err)
+	}
+	if claims.Subject == "" || claims.Nonce != token.Extra["nonce"] {
+		return domain.OAuthIdentity{}, ErrIDTokenInvalid
+	}
+	return domain.OAuthIdentity{
+		Provider: p.cfg.Name,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+		Nickname: claims.Name,
+		Avatar:   claims.Picture,
+	}, nil
+}
+
+func (p *Provider) getDiscovery(ctx context.Context) (*discovery, error) {
+	p.lock.RLock()
+	d := p.discovery
+	p.lock.RUnlock()
+	if d != nil {
+		return d, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		strings.TrimSuffix(p.cfg.Issuer, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	var res discovery
+	if err = p.do(req, &res); err != nil {
+		return nil, err
+	}
+	// 防止 discovery 文档被篡改成别的 IdP
+	if strings.TrimSuffix(res.Issuer, "/") != strings.TrimSuffix(p.cfg.Issuer, "/") {
+		return nil, fmt.Errorf("issuer 不一致 %s", res.Issuer)
+	}
+	p.lock.Lock()
+	p.discovery = &res
+	p.lock.Unlock()
+	return &res, nil
+}
+
+// publicKey IdP 轮换密钥之后会出现不认识的 kid，这时候重新拉取一次 JWKS
+func (p *Provider) publicKey(ctx context.Context, d *discovery, kid string) (any, error) {
+	if key, ok := p.cachedKey(kid); ok {
+		return key, nil
+	}
+	p.refreshLock.Lock()
+	defer p.refreshLock.Unlock()
+	// 等锁的时候别的请求可能已经拉取过了
+	if key, ok := p.cachedKey(kid); ok {
+		return key, nil
+	}
+	// 刚拉取过还是不认识，就当作不存在，不再请求 IdP
+	if time.Since(p.refreshedAt) < jwksRefreshInterval {
+		return nil, ErrUnknownKid
+	}
+	p.refreshedAt = time.Now()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	var set jwks
+	if err = p.do(req, &set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]any, len(set.Keys))
+	for _, k := range set.Keys {
+		pub, err := k.publicKey()
+		if err != nil {
+			// 不认识的密钥类型直接跳过
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+	p.lock.Lock()
+	p.keys = keys
+	p.lock.Unlock()
+	key, ok := keys[kid]
+	if !ok {
+		return nil, ErrUnknownKid
+	}
+	return key, nil
+}
+
+func (p *Provider) cachedKey(kid string) (any, bool) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+func (p *Provider) do(req *http.Request, val any) error {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// token 接口出错的时候是 4xx，响应里面有 error 字段，交给调用方处理
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("请求 %s 失败 %d", req.URL.Path, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(val)
+}
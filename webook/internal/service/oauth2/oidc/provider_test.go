@@ -0,0 +1,205 @@
+package oidc
+
+import (
+	"Learn_Go/webook/internal/domain"
+	"Learn_Go/webook/internal/service/oauth2"
+	"Learn_Go/webook/internal/service/oauth2/oidc/oidctest"
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestProvider_Login(t *testing.T) {
+	testCases := []struct {
+		name string
+		// before 在授权之前修改 IdP 和 Provider 的状态
+		before func(idp *oidctest.Server, p *Provider)
+		// verifier 换 token 的时候用的 verifier，为空的时候用授权时候的
+		verifier string
+		// token 为 nil 的时候不修改 Exchange 拿到的 token
+		token func(token oauth2.Token) oauth2.Token
+
+		wantExchangeErr error
+		wantErr         error
+		wantId          domain.OAuthIdentity
+	}{
+		{
+			name: "登录成功",
+			wantId: domain.OAuthIdentity{
+				Provider: "google",
+				Subject:  "user-1",
+				Email:    "user-1@example.com",
+				Nickname: "User 1",
+			},
+		},
+		{
+			name: "IdP 轮换了密钥",
+			before: func(idp *oidctest.Server, p *Provider) {
+				idp.RotateKey()
+				// 上一次拉取 JWKS 已经是很久之前了
+				p.refreshedAt = time.Now().Add(-jwksRefreshInterval)
+			},
+			wantId: domain.OAuthIdentity{
+				Provider: "google",
+				Subject:  "user-1",
+				Email:    "user-1@example.com",
+				Nickname: "User 1",
+			},
+		},
+		{
+			// 刚拉取过 JWKS，要等到下一次能拉取的时候才认识新的 kid
+			name: "刚拉取过 JWKS 就轮换了密钥",
+			before: func(idp *oidctest.Server, p *Provider) {
+				idp.RotateKey()
+			},
+			wantErr: ErrIDTokenInvalid,
+		},
+		{
+			name:            "PKCE 的 verifier 不对",
+			verifier:        "wrong-verifier",
+			wantExchangeErr: oauth2.ErrExchangeFailed,
+		},
+		{
+			name: "nonce 不对",
+			token: func(token oauth2.Token) oauth2.Token {
+				token.Extra = map[string]string{"nonce": nonce("other-verifier")}
+				return token
+			},
+			wantErr: ErrIDTokenInvalid,
+		},
+		{
+			name: "id_token 不是签发给我们的",
+			before: func(idp *oidctest.Server, p *Provider) {
+				idp.Audience = "other-client"
+			},
+			wantErr: ErrIDTokenInvalid,
+		},
+		{
+			name: "id_token 过期",
+			before: func(idp *oidctest.Server, p *Provider) {
+				idp.TTL = -time.Hour
+			},
+			wantErr: ErrIDTokenInvalid,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			idp := oidctest.NewServer("client-id", "client-secret")
+			defer idp.Close()
+			p := NewProvider(Config{
+				Name:         "google",
+				Issuer:       idp.URL,
+				ClientId:     "client-id",
+				ClientSecret: "client-secret",
+				RedirectURL:  "http://localhost/oauth2/google/callback",
+			}, http.DefaultClient)
+			ctx := context.Background()
+			// 先用旧的密钥缓存一次 JWKS
+			_, err := p.AuthURL(ctx, "state", "verifier")
+			require.NoError(t, err)
+			d, err := p.getDiscovery(ctx)
+			require.NoError(t, err)
+			_, err = p.publicKey(ctx, d, "key-1")
+			require.NoError(t, err)
+
+			if tc.before != nil {
+				tc.before(idp, p)
+			}
+			verifier, err := oauth2.NewVerifier()
+			require.NoError(t, err)
+			authURL, err := p.AuthURL(ctx, "state-123", verifier)
+			require.NoError(t, err)
+			code, state, err := idp.Authorize(authURL)
+			require.NoError(t, err)
+			assert.Equal(t, "state-123", state)
+
+			if tc.verifier != "" {
+				verifier = tc.verifier
+			}
+			token, err := p.Exchange(ctx, code, verifier)
+			assert.ErrorIs(t, err, tc.wantExchangeErr)
+			if err != nil {
+				return
+			}
+			if tc.token != nil {
+				token = tc.token(token)
+			}
+			id, err := p.Identity(ctx, token)
+			assert.ErrorIs(t, err, tc.wantErr)
+			assert.Equal(t, tc.wantId, id)
+		})
+	}
+}
+
+// 随便编的 kid 不能让我们每次都去请求 IdP
+func TestProvider_UnknownKid(t *testing.T) {
+	idp := oidctest.NewServer("client-id", "client-secret")
+	defer idp.Close()
+	var fetches atomic.Int32
+	client := &http.Client{
+		Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			if req.URL.Path == "/jwks" {
+				fetches.Add(1)
+			}
+			return http.DefaultTransport.RoundTrip(req)
+		}),
+	}
+	p := NewProvider(Config{
+		Name:     "google",
+		Issuer:   idp.URL,
+		ClientId: "client-id",
+	}, client)
+	ctx := context.Background()
+	d, err := p.getDiscovery(ctx)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := p.publicKey(ctx, d, fmt.Sprintf("fake-%d", i))
+			assert.ErrorIs(t, err, ErrUnknownKid)
+		}(i)
+	}
+	wg.Wait()
+	// 认识的 kid 不受影响
+	_, err = p.publicKey(ctx, d, "key-1")
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), fetches.Load())
+
+	// 过了间隔之后才会再拉取一次
+	p.refreshedAt = time.Now().Add(-jwksRefreshInterval)
+	_, err = p.publicKey(ctx, d, "fake")
+	assert.ErrorIs(t, err, ErrUnknownKid)
+	assert.Equal(t, int32(2), fetches.Load())
+}
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestProvider_IssuerMismatch(t *testing.T) {
+	// discovery 文档里面的 issuer 是另外一个 IdP
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"issuer":"https://evil.example.com","authorization_endpoint":"https://evil.example.com/authorize"}`))
+	}))
+	defer server.Close()
+	p := NewProvider(Config{
+		Name:     "google",
+		Issuer:   server.URL,
+		ClientId: "client-id",
+	}, http.DefaultClient)
+	_, err := p.AuthURL(context.Background(), "state", "verifier")
+	assert.ErrorContains(t, err, "issuer 不一致")
+}
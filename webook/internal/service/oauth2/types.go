@@ -0,0 +1,56 @@
+package oauth2
+
+import (
+	"Learn_Go/webook/internal/domain"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+)
+
+var ErrExchangeFailed = errors.New("授权码换取 token 失败")
+
+// Provider 第三方登录，一次登录分成三步：
+// 1. AuthURL 构造跳转到第三方的授权页面
+// 2. Exchange 用回调拿到的授权码换取 token
+// 3. Identity 用 token 拿到用户在第三方的身份
+type Provider interface {
+	// Name 和路由 /oauth2/:provider 里面的 provider 一致
+	Name() string
+	// AuthURL state 用来防 CSRF；verifier 是 PKCE 的 code_verifier，不支持 PKCE 的第三方忽略它
+	AuthURL(ctx context.Context, state, verifier string) (string, error)
+	// Exchange verifier 和 AuthURL 的时候传入的一样
+	Exchange(ctx context.Context, code, verifier string) (Token, error)
+	Identity(ctx context.Context, token Token) (domain.OAuthIdentity, error)
+}
+
+// ProfileFetcher 有些第三方换 token 的时候只返回用户 id，昵称和头像要另外调用接口获取
+// 只在第一次登录创建用户的时候调用，不影响已有用户登录的速度
+type ProfileFetcher interface {
+	// Profile 在 id 的基础上补全昵称和头像
+	Profile(ctx context.Context, token Token, id domain.OAuthIdentity) (domain.OAuthIdentity, error)
+}
+
+type Token struct {
+	AccessToken string
+	// IDToken 只有 OIDC 才有
+	IDToken string
+	// Extra token 接口返回的其它字段，比如微信的 openid 和 unionid
+	Extra map[string]string
+}
+
+// NewVerifier 生成 PKCE 的 code_verifier，每次授权都要重新生成
+func NewVerifier() (string, error) {
+	data := make([]byte, 32)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// Challenge PKCE 的 code_challenge，只支持 S256
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -2,72 +2,159 @@ package wechat
 
 import (
 	"Learn_Go/webook/internal/domain"
+	"Learn_Go/webook/internal/service/oauth2"
 	"Learn_Go/webook/pkg/logger"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 )
 
-type Service interface {
-	AuthURL(ctx context.Context, state string) (string, error)
-	VerifyCode(ctx context.Context, code string) (domain.WechatInfo, error)
-}
+// 用哪个字段作为用户在微信的身份
+const (
+	// IdentityOpenId openid 每个应用都不一样，只有一个应用的时候用它就可以
+	IdentityOpenId = "openid"
+	// IdentityUnionId 同一个开放平台下面的多个应用（网站、App、公众号）拿到的 unionid 是一样的
+	// 从 openid 切换到 unionid 的时候，要把 user_oauth_bindings 里面微信的 subject 换成 union_id
+	IdentityUnionId = "unionid"
+)
+
+// 默认的接口地址，测试的时候可以换掉
+const (
+	authURL = "https://open.weixin.qq.com/connect/qrconnect"
+	apiURL  = "https://api.weixin.qq.com"
+)
+
+var ErrUnionIdMissing = errors.New("微信没有返回 unionid，应用可能没有绑定到开放平台")
 
-var redirectURL = url.PathEscape("https://meoying.com/oauth2/wechat/callback") // 这里需要对这个URL进行转义
+type Config struct {
+	AppId       string
+	AppSecret   string
+	RedirectURL string
+	// IdentityKey openid 或者 unionid，为空的时候是 openid
+	IdentityKey string
+	// 下面两个为空的时候用微信的地址
+	AuthURL string
+	APIURL  string
+}
 
 type service struct {
-	appId     string // 一般来说是固定的
-	appSecret string
-	client    *http.Client
-	l         logger.LoggerV1
+	cfg    Config
+	client *http.Client
+	l      logger.LoggerV1
 }
 
-func NewWechatService(appId string, appSecret string, l logger.LoggerV1) Service {
+// NewWechatService 微信不支持 PKCE，verifier 都会被忽略
+func NewWechatService(cfg Config, client *http.Client, l logger.LoggerV1) oauth2.Provider {
+	if cfg.IdentityKey == "" {
+		cfg.IdentityKey = IdentityOpenId
+	}
+	if cfg.AuthURL == "" {
+		cfg.AuthURL = authURL
+	}
+	if cfg.APIURL == "" {
+		cfg.APIURL = apiURL
+	}
 	return &service{
-		appId:     appId,
-		client:    http.DefaultClient, // 这里使用这个，是因为很少会去改这个，如果后面有需求，可以做成依赖注入的形式
-		appSecret: appSecret,
-		l:         l,
+		cfg:    cfg,
+		client: client,
+		l:      l,
 	}
 }
 
-func (s *service) AuthURL(ctx context.Context, state string) (string, error) {
-	const AuthURLPattern = `https://open.weixin.qq.com/connect/qrconnect?appid=%s&redirect_uri=%s&response_type=code&scope=snsapi_login&state=%s#wechat_redirect`
-	return fmt.Sprintf(AuthURLPattern, s.appId, redirectURL, state), nil
+func (s *service) Name() string {
+	return "wechat"
 }
 
-func (s *service) VerifyCode(ctx context.Context, code string) (domain.WechatInfo, error) {
-	accessTokenUrl := fmt.Sprintf("https://api.weixin.qq.com/sns/oauth2/access_token?appid=%s&secret=%s&code=%s&grant_type=authorization_code", s.appId, s.appSecret, code)
-	// 构造一个请求
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, accessTokenUrl, nil)
-	if err != nil {
-		return domain.WechatInfo{}, err
-	}
-	httpRes, err := s.client.Do(req)
-	if err != nil {
-		return domain.WechatInfo{}, err
-	}
-	// 因为这里返回的是一个json的格式，而我们是定义了一个结构体来存放返回的信息，所以在这里反序列化它
+func (s *service) AuthURL(ctx context.Context, state, verifier string) (string, error) {
+	// 微信要求参数的顺序是固定的，而且最后要带上 #wechat_redirect，url.Values 会按照 key 排序，所以这里自己拼
+	const AuthURLPattern = `%s?appid=%s&redirect_uri=%s&response_type=code&scope=snsapi_login&state=%s#wechat_redirect`
+	return fmt.Sprintf(AuthURLPattern, s.cfg.AuthURL, url.QueryEscape(s.cfg.AppId),
+		url.QueryEscape(s.cfg.RedirectURL), url.QueryEscape(state)), nil
+}
+
+func (s *service) Exchange(ctx context.Context, code, verifier string) (oauth2.Token, error) {
+	q := url.Values{}
+	q.Set("appid", s.cfg.AppId)
+	q.Set("secret", s.cfg.AppSecret)
+	q.Set("code", code)
+	q.Set("grant_type", "authorization_code")
 	var res Result
-	err = json.NewDecoder(httpRes.Body).Decode(&res) // 这里反序列化使用NewDecoder，是因为响应的body是readCloser,而不是字符串，无法使用Unmarshal
-	if err != nil {
-		// 反序列化出错
-		return domain.WechatInfo{}, err
+	if err := s.get(ctx, "/sns/oauth2/access_token", q, &res); err != nil {
+		return oauth2.Token{}, err
 	}
 	// 返回的响应错误码，如果不为0，就是调用失败，具体错误码信息可以查看开发文档
 	if res.ErrCode != 0 {
-		return domain.WechatInfo{}, fmt.Errorf("调用微信接口失败 errcode %d, errmsg %s", res.ErrCode, res.ErrMsg)
+		return oauth2.Token{}, fmt.Errorf("%w: 调用微信接口失败 errcode %d, errmsg %s", oauth2.ErrExchangeFailed, res.ErrCode, res.ErrMsg)
 	}
 
-	return domain.WechatInfo{
-		UnionId: res.UnionId,
-		OpenId:  res.OpenId,
+	// 微信在换 token 的时候就返回了 openid 和 unionid
+	return oauth2.Token{
+		AccessToken: res.AccessToken,
+		Extra: map[string]string{
+			"openid":  res.OpenId,
+			"unionid": res.UnionId,
+		},
 	}, nil
 
 }
 
+func (s *service) Identity(ctx context.Context, token oauth2.Token) (domain.OAuthIdentity, error) {
+	id := domain.OAuthIdentity{
+		Provider: s.Name(),
+		Subject:  token.Extra["openid"],
+		UnionId:  token.Extra["unionid"],
+	}
+	if s.cfg.IdentityKey == IdentityUnionId {
+		if id.UnionId == "" {
+			return domain.OAuthIdentity{}, ErrUnionIdMissing
+		}
+		id.Subject = id.UnionId
+	}
+	return id, nil
+}
+
+// Profile 换 token 的时候没有昵称和头像，要调用 /sns/userinfo
+func (s *service) Profile(ctx context.Context, token oauth2.Token, id domain.OAuthIdentity) (domain.OAuthIdentity, error) {
+	q := url.Values{}
+	q.Set("access_token", token.AccessToken)
+	q.Set("openid", token.Extra["openid"])
+	var res UserInfo
+	if err := s.get(ctx, "/sns/userinfo", q, &res); err != nil {
+		return id, err
+	}
+	if res.ErrCode != 0 {
+		return id, fmt.Errorf("获取微信用户信息失败 errcode %d, errmsg %s", res.ErrCode, res.ErrMsg)
+	}
+	id.Nickname = res.Nickname
+	id.Avatar = res.HeadImgURL
+	if id.UnionId == "" {
+		id.UnionId = res.UnionId
+	}
+	return id, nil
+}
+
+// get 微信的接口出错的时候也是 200，错误码在响应里面，交给调用方判断
+func (s *service) get(ctx context.Context, path string, q url.Values, val any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.cfg.APIURL+path+"?"+q.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		s.l.Warn("调用微信接口失败", logger.String("path", path), logger.Int64("status", int64(resp.StatusCode)))
+		return fmt.Errorf("请求 %s 失败 %d", path, resp.StatusCode)
+	}
+	// 这里反序列化使用NewDecoder，是因为响应的body是readCloser,而不是字符串，无法使用Unmarshal
+	return json.NewDecoder(resp.Body).Decode(val)
+}
+
 type Result struct {
 	// 接口调用凭证
 	AccessToken string `json:"access_token"`
@@ -86,3 +173,15 @@ type Result struct {
 	ErrCode int    `json:"errcode"`
 	ErrMsg  string `json:"errmsg"`
 }
+
+// UserInfo /sns/userinfo 的响应
+type UserInfo struct {
+	OpenId   string `json:"openid"`
+	Nickname string `json:"nickname"`
+	// HeadImgURL 用户头像，最后一个数值代表正方形头像大小，用户没有头像时该项为空
+	HeadImgURL string `json:"headimgurl"`
+	UnionId    string `json:"unionid"`
+
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
@@ -0,0 +1,177 @@
+package wechat
+
+import (
+	"Learn_Go/webook/internal/domain"
+	"Learn_Go/webook/internal/service/oauth2"
+	"Learn_Go/webook/pkg/httpx"
+	"Learn_Go/webook/pkg/logger"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeWechat 模拟微信的 /sns/oauth2/access_token 和 /sns/userinfo
+type fakeWechat struct {
+	*httptest.Server
+	// unionid 为空的时候表示应用没有绑定到开放平台
+	unionid string
+	// failures 前几次请求返回 502
+	failures atomic.Int32
+}
+
+func newFakeWechat(unionid string) *fakeWechat {
+	f := &fakeWechat{unionid: unionid}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sns/oauth2/access_token", func(w http.ResponseWriter, r *http.Request) {
+		if f.failures.Add(-1) >= 0 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		q := r.URL.Query()
+		if q.Get("appid") != "app-id" || q.Get("secret") != "app-secret" ||
+			q.Get("grant_type") != "authorization_code" || q.Get("code") != "code-123" {
+			_ = json.NewEncoder(w).Encode(Result{ErrCode: 40029, ErrMsg: "invalid code"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(Result{
+			AccessToken: "access-token",
+			ExpiresIn:   7200,
+			OpenId:      "open-id",
+			UnionId:     f.unionid,
+		})
+	})
+	mux.HandleFunc("/sns/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("access_token") != "access-token" || q.Get("openid") != "open-id" {
+			_ = json.NewEncoder(w).Encode(UserInfo{ErrCode: 40003, ErrMsg: "invalid openid"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(UserInfo{
+			OpenId:     "open-id",
+			Nickname:   "微信用户",
+			HeadImgURL: "https://thirdwx.qlogo.cn/a/132",
+			UnionId:    f.unionid,
+		})
+	})
+	f.Server = httptest.NewServer(mux)
+	return f
+}
+
+func TestService_AuthURL(t *testing.T) {
+	svc := NewWechatService(Config{
+		AppId:       "app-id",
+		RedirectURL: "https://example.com/oauth2/wechat/callback",
+	}, http.DefaultClient, logger.NewNopLogger())
+	val, err := svc.AuthURL(context.Background(), "state-123", "")
+	require.NoError(t, err)
+	assert.Equal(t, "https://open.weixin.qq.com/connect/qrconnect?appid=app-id&redirect_uri="+
+		url.QueryEscape("https://example.com/oauth2/wechat/callback")+
+		"&response_type=code&scope=snsapi_login&state=state-123#wechat_redirect", val)
+}
+
+func TestService_Login(t *testing.T) {
+	testCases := []struct {
+		name        string
+		identityKey string
+		unionid     string
+		code        string
+		failures    int32
+
+		wantExchangeErr error
+		wantErr         error
+		wantId          domain.OAuthIdentity
+	}{
+		{
+			name: "默认用 openid",
+			code: "code-123",
+			// 有 unionid 也会保存下来，以后切换到 unionid 的时候用
+			unionid: "union-id",
+			wantId:  domain.OAuthIdentity{Provider: "wechat", Subject: "open-id", UnionId: "union-id"},
+		},
+		{
+			name:        "用 unionid",
+			identityKey: IdentityUnionId,
+			code:        "code-123",
+			unionid:     "union-id",
+			wantId:      domain.OAuthIdentity{Provider: "wechat", Subject: "union-id", UnionId: "union-id"},
+		},
+		{
+			name:        "用 unionid 但是微信没有返回",
+			identityKey: IdentityUnionId,
+			code:        "code-123",
+			wantErr:     ErrUnionIdMissing,
+		},
+		{
+			name:     "微信的接口偶尔出错，重试之后成功",
+			code:     "code-123",
+			failures: 1,
+			wantId:   domain.OAuthIdentity{Provider: "wechat", Subject: "open-id"},
+		},
+		{
+			name:            "授权码错误",
+			code:            "bad-code",
+			wantExchangeErr: oauth2.ErrExchangeFailed,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := newFakeWechat(tc.unionid)
+			defer server.Close()
+			server.failures.Store(tc.failures)
+			client := &http.Client{Transport: &httpx.RetryTransport{Retries: 1, Backoff: time.Millisecond}}
+			svc := NewWechatService(Config{
+				AppId:       "app-id",
+				AppSecret:   "app-secret",
+				IdentityKey: tc.identityKey,
+				APIURL:      server.URL,
+			}, client, logger.NewNopLogger())
+			ctx := context.Background()
+			token, err := svc.Exchange(ctx, tc.code, "")
+			assert.ErrorIs(t, err, tc.wantExchangeErr)
+			if err != nil {
+				return
+			}
+			id, err := svc.Identity(ctx, token)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantId, id)
+		})
+	}
+}
+
+func TestService_Profile(t *testing.T) {
+	server := newFakeWechat("union-id")
+	defer server.Close()
+	svc := NewWechatService(Config{
+		AppId:     "app-id",
+		AppSecret: "app-secret",
+		APIURL:    server.URL,
+	}, http.DefaultClient, logger.NewNopLogger())
+	pf, ok := svc.(oauth2.ProfileFetcher)
+	require.True(t, ok)
+
+	ctx := context.Background()
+	token, err := svc.Exchange(ctx, "code-123", "")
+	require.NoError(t, err)
+	id, err := svc.Identity(ctx, token)
+	require.NoError(t, err)
+	id, err = pf.Profile(ctx, token, id)
+	require.NoError(t, err)
+	assert.Equal(t, domain.OAuthIdentity{
+		Provider: "wechat",
+		Subject:  "open-id",
+		UnionId:  "union-id",
+		Nickname: "微信用户",
+		Avatar:   "https://thirdwx.qlogo.cn/a/132",
+	}, id)
+
+	// access_token 过期了
+	_, err = pf.Profile(ctx, oauth2.Token{AccessToken: "expired", Extra: token.Extra}, id)
+	assert.Error(t, err)
+}
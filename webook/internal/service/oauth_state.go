@@ -0,0 +1,104 @@
+package service
+
+import (
+	"Learn_Go/webook/internal/domain"
+	"Learn_Go/webook/internal/repository"
+	"Learn_Go/webook/internal/service/oauth2"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"github.com/google/uuid"
+	"net/url"
+	"strings"
+)
+
+var (
+	ErrOAuthStateNotFound = repository.ErrOAuthStateNotFound
+	ErrOAuthStateMismatch = errors.New("state 和浏览器或者第三方不匹配")
+	ErrRedirectNotAllowed = errors.New("不允许跳转到这个地址")
+)
+
+// OAuthStateService 第三方登录的 state，防 CSRF
+// state 保存在服务端，同时和浏览器的 cookie 绑定，回调的时候只能用一次
+type OAuthStateService interface {
+	// Create 校验 st.Redirect，生成 PKCE 的 verifier 和写到 cookie 里面的 Browser
+	// 返回 state 和补全之后的 st
+	Create(ctx context.Context, st domain.OAuthState) (string, domain.OAuthState, error)
+	// Consume 取出 state，无论是否匹配 state 都已经失效了
+	Consume(ctx context.Context, state, provider, browser string) (domain.OAuthState, error)
+}
+
+type oauthStateService struct {
+	repo repository.OAuthStateRepository
+	// allowlist 允许跳转的站点，比如 https://meoying.com，站内的相对路径总是允许的
+	allowlist []string
+}
+
+func NewOAuthStateService(repo repository.OAuthStateRepository, allowlist []string) OAuthStateService {
+	origins := make([]string, 0, len(allowlist))
+	for _, o := range allowlist {
+		origins = append(origins, strings.ToLower(strings.TrimSuffix(o, "/")))
+	}
+	return &oauthStateService{
+		repo:      repo,
+		allowlist: origins,
+	}
+}
+
+func (svc *oauthStateService) Create(ctx context.Context, st domain.OAuthState) (string, domain.OAuthState, error) {
+	if !svc.redirectAllowed(st.Redirect) {
+		return "", domain.OAuthState{}, ErrRedirectNotAllowed
+	}
+	verifier, err := oauth2.NewVerifier()
+	if err != nil {
+		return "", domain.OAuthState{}, err
+	}
+	st.Verifier = verifier
+	st.Browser = uuid.New().String()
+	state := uuid.New().String()
+	err = svc.repo.Save(ctx, state, st)
+	return state, st, err
+}
+
+func (svc *oauthStateService) Consume(ctx context.Context, state, provider, browser string) (domain.OAuthState, error) {
+	if state == "" || browser == "" {
+		return domain.OAuthState{}, ErrOAuthStateMismatch
+	}
+	st, err := svc.repo.Consume(ctx, state)
+	if err != nil {
+		return domain.OAuthState{}, err
+	}
+	if st.Provider != provider || subtle.ConstantTimeCompare([]byte(st.Browser), []byte(browser)) != 1 {
+		return domain.OAuthState{}, ErrOAuthStateMismatch
+	}
+	return st, nil
+}
+
+// redirectAllowed 防止开放重定向，登录之后被带到钓鱼网站
+func (svc *oauthStateService) redirectAllowed(redirect string) bool {
+	if redirect == "" {
+		return true
+	}
+	// 反斜杠在有些浏览器里面会被当成斜杠，/\evil.com 就变成了 //evil.com
+	if strings.ContainsAny(redirect, "\\\r\n\t") {
+		return false
+	}
+	u, err := url.Parse(redirect)
+	if err != nil {
+		return false
+	}
+	if u.Scheme == "" && u.Host == "" {
+		// //evil.com 这种没有 scheme 的地址浏览器会跳转到别的站点
+		return strings.HasPrefix(redirect, "/") && !strings.HasPrefix(redirect, "//")
+	}
+	if u.Scheme != "https" && u.Scheme != "http" || u.User != nil {
+		return false
+	}
+	origin := strings.ToLower(u.Scheme + "://" + u.Host)
+	for _, o := range svc.allowlist {
+		if o == origin {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"Learn_Go/webook/internal/domain"
+	"Learn_Go/webook/internal/repository"
+	repomocks "Learn_Go/webook/internal/repository/mocks"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"testing"
+)
+
+func Test_oauthStateService_redirectAllowed(t *testing.T) {
+	svc := NewOAuthStateService(nil, []string{"https://meoying.com/", "http://localhost:3000"}).(*oauthStateService)
+	testCases := []struct {
+		redirect string
+		want     bool
+	}{
+		{redirect: "", want: true},
+		{redirect: "/articles/1?tab=comment", want: true},
+		{redirect: "https://meoying.com/articles/1", want: true},
+		{redirect: "HTTPS://MEOYING.COM", want: true},
+		{redirect: "http://localhost:3000/", want: true},
+		// 端口不一样就是别的站点
+		{redirect: "http://localhost:3001/", want: false},
+		{redirect: "http://meoying.com/", want: false},
+		{redirect: "https://meoying.com.evil.com/", want: false},
+		{redirect: "https://meoying.com@evil.com/", want: false},
+		{redirect: "//evil.com", want: false},
+		{redirect: "/\\evil.com", want: false},
+		{redirect: "javascript:alert(1)", want: false},
+		{redirect: "articles/1", want: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.redirect, func(t *testing.T) {
+			assert.Equal(t, tc.want, svc.redirectAllowed(tc.redirect))
+		})
+	}
+}
+
+func Test_oauthStateService_Consume(t *testing.T) {
+	st := domain.OAuthState{Provider: "github", Verifier: "verifier", Browser: "browser-1"}
+	testCases := []struct {
+		name     string
+		mock     func(ctrl *gomock.Controller) repository.OAuthStateRepository
+		provider string
+		browser  string
+		wantSt   domain.OAuthState
+		wantErr  error
+	}{
+		{
+			name: "匹配",
+			mock: func(ctrl *gomock.Controller) repository.OAuthStateRepository {
+				repo := repomocks.NewMockOAuthStateRepository(ctrl)
+				repo.EXPECT().Consume(gomock.Any(), "state-1").Return(st, nil)
+				return repo
+			},
+			provider: "github",
+			browser:  "browser-1",
+			wantSt:   st,
+		},
+		{
+			name: "浏览器不一样",
+			mock: func(ctrl *gomock.Controller) repository.OAuthStateRepository {
+				repo := repomocks.NewMockOAuthStateRepository(ctrl)
+				repo.EXPECT().Consume(gomock.Any(), "state-1").Return(st, nil)
+				return repo
+			},
+			provider: "github",
+			browser:  "browser-2",
+			wantErr:  ErrOAuthStateMismatch,
+		},
+		{
+			name: "第三方不一样",
+			mock: func(ctrl *gomock.Controller) repository.OAuthStateRepository {
+				repo := repomocks.NewMockOAuthStateRepository(ctrl)
+				repo.EXPECT().Consume(gomock.Any(), "state-1").Return(st, nil)
+				return repo
+			},
+			provider: "wechat",
+			browser:  "browser-1",
+			wantErr:  ErrOAuthStateMismatch,
+		},
+		{
+			name: "没有 cookie 的时候不用查 Redis",
+			mock: func(ctrl *gomock.Controller) repository.OAuthStateRepository {
+				return repomocks.NewMockOAuthStateRepository(ctrl)
+			},
+			provider: "github",
+			wantErr:  ErrOAuthStateMismatch,
+		},
+		{
+			name: "过期或者用过了",
+			mock: func(ctrl *gomock.Controller) repository.OAuthStateRepository {
+				repo := repomocks.NewMockOAuthStateRepository(ctrl)
+				repo.EXPECT().Consume(gomock.Any(), "state-1").Return(domain.OAuthState{}, repository.ErrOAuthStateNotFound)
+				return repo
+			},
+			provider: "github",
+			browser:  "browser-1",
+			wantErr:  ErrOAuthStateNotFound,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			svc := NewOAuthStateService(tc.mock(ctrl), nil)
+			res, err := svc.Consume(context.Background(), "state-1", tc.provider, tc.browser)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantSt, res)
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"Learn_Go/webook/internal/domain"
+	"Learn_Go/webook/internal/repository"
+	"Learn_Go/webook/pkg/mask"
+	"context"
+	"go.uber.org/zap"
+)
+
+// ProfileService 用户主页和主页的隐私设置
+type ProfileService interface {
+	// Profile viewer 看 uid 的主页，viewer 为 0 表示没有登录
+	// 按照 uid 的隐私设置过滤字段，已经注销的用户返回 ErrUserNotFound
+	Profile(ctx context.Context, viewer, uid int64) (domain.PublicProfile, error)
+	// Privacy 返回的是 WithDefaults 之后的设置
+	Privacy(ctx context.Context, uid int64) (domain.PrivacySettings, error)
+	UpdatePrivacy(ctx context.Context, uid int64, p domain.PrivacySettings) error
+}
+
+type profileService struct {
+	repo   repository.UserRepository
+	follow FollowService
+}
+
+func NewProfileService(repo repository.UserRepository, follow FollowService) ProfileService {
+	return &profileService{
+		repo:   repo,
+		follow: follow,
+	}
+}
+
+func (svc *profileService) Profile(ctx context.Context, viewer, uid int64) (domain.PublicProfile, error) {
+	u, err := svc.repo.FindById(ctx, uid)
+	if err != nil {
+		return domain.PublicProfile{}, err
+	}
+	if u.Status == domain.UserStatusDeleted {
+		return domain.PublicProfile{}, ErrUserNotFound
+	}
+	p := u.Privacy.WithDefaults()
+	rel := svc.relation(ctx, viewer, uid, p)
+	res := domain.PublicProfile{
+		Id:       u.Id,
+		Nickname: u.NickName,
+		Avatar:   u.Avatar,
+		Ctime:    u.Ctime,
+	}
+	if rel.Visible(p.Email) {
+		res.Email = u.Email
+	}
+	if rel.Visible(p.Phone) {
+		res.Phone = u.Phone
+	}
+	if rel.Visible(p.Birthday) {
+		res.Birthday = u.BirthDay
+	}
+	if rel.Visible(p.AboutMe) {
+		res.AboutMe = u.AboutMe
+	}
+	// 就算设置了公开，别人看到的联系方式也要打码
+	if rel != domain.RelationSelf {
+		res.Email = mask.Email(res.Email)
+		res.Phone = mask.Phone(res.Phone)
+	}
+	return res, nil
+}
+
+// relation 只有设置了仅关注者可见的时候才需要查关注关系
+func (svc *profileService) relation(ctx context.Context, viewer, uid int64, p domain.PrivacySettings) domain.Relation {
+	if viewer == uid {
+		return domain.RelationSelf
+	}
+	if viewer == 0 {
+		return domain.RelationStranger
+	}
+	if p.Email != domain.VisibilityFollowers && p.Phone != domain.VisibilityFollowers &&
+		p.Birthday != domain.VisibilityFollowers && p.AboutMe != domain.VisibilityFollowers {
+		return domain.RelationStranger
+	}
+	ok, err := svc.follow.IsFollowing(ctx, viewer, uid)
+	if err != nil {
+		// 查不到关注关系就少展示一点，不影响主页
+		zap.L().Warn("查询关注关系失败", zap.Int64("viewer", viewer), zap.Int64("uid", uid), zap.Error(err))
+		return domain.RelationStranger
+	}
+	if ok {
+		return domain.RelationFollower
+	}
+	return domain.RelationStranger
+}
+
+func (svc *profileService) Privacy(ctx context.Context, uid int64) (domain.PrivacySettings, error) {
+	u, err := svc.repo.FindById(ctx, uid)
+	if err != nil {
+		return domain.PrivacySettings{}, err
+	}
+	return u.Privacy.WithDefaults(), nil
+}
+
+func (svc *profileService) UpdatePrivacy(ctx context.Context, uid int64, p domain.PrivacySettings) error {
+	return svc.repo.UpdatePrivacy(ctx, domain.User{
+		Id:      uid,
+		Privacy: p,
+	})
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"Learn_Go/webook/internal/domain"
+	"Learn_Go/webook/internal/repository"
+	repomocks "Learn_Go/webook/internal/repository/mocks"
+	svcmocks "Learn_Go/webook/internal/service/mocks"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"testing"
+	"time"
+)
+
+func Test_profileService_Profile(t *testing.T) {
+	birthday := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	ctime := time.UnixMilli(1700000000000)
+	user := func(p domain.PrivacySettings) domain.User {
+		return domain.User{
+			Id:       123,
+			Email:    "abcdef@qq.com",
+			Password: "hash",
+			NickName: "大明",
+			Avatar:   "/files/avatars/a.png",
+			BirthDay: birthday,
+			AboutMe:  "我是大明",
+			Phone:    "13812345678",
+			Ctime:    ctime,
+			Privacy:  p,
+		}
+	}
+	testCases := []struct {
+		name   string
+		mock   func(ctrl *gomock.Controller) (repository.UserRepository, FollowService)
+		viewer int64
+
+		want    domain.PublicProfile
+		wantErr error
+	}{
+		{
+			name: "没有登录，默认设置",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, FollowService) {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(123)).Return(user(domain.PrivacySettings{}), nil)
+				return repo, svcmocks.NewMockFollowService(ctrl)
+			},
+			want: domain.PublicProfile{
+				Id:       123,
+				Nickname: "大明",
+				Avatar:   "/files/avatars/a.png",
+				AboutMe:  "我是大明",
+				Ctime:    ctime,
+			},
+		},
+		{
+			name: "自己看，全部字段不打码",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, FollowService) {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(123)).Return(user(domain.PrivacySettings{}), nil)
+				return repo, svcmocks.NewMockFollowService(ctrl)
+			},
+			viewer: 123,
+			want: domain.PublicProfile{
+				Id:       123,
+				Nickname: "大明",
+				Avatar:   "/files/avatars/a.png",
+				Email:    "abcdef@qq.com",
+				Phone:    "13812345678",
+				Birthday: birthday,
+				AboutMe:  "我是大明",
+				Ctime:    ctime,
+			},
+		},
+		{
+			name: "公开的联系方式别人看到的是打码的",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, FollowService) {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(123)).Return(user(domain.PrivacySettings{
+					Email:    domain.VisibilityPublic,
+					Phone:    domain.VisibilityPublic,
+					Birthday: domain.VisibilityPublic,
+					AboutMe:  domain.VisibilitySelf,
+				}), nil)
+				return repo, svcmocks.NewMockFollowService(ctrl)
+			},
+			viewer: 456,
+			want: domain.PublicProfile{
+				Id:       123,
+				Nickname: "大明",
+				Avatar:   "/files/avatars/a.png",
+				Email:    "a***f@qq.com",
+				Phone:    "138****5678",
+				Birthday: birthday,
+				Ctime:    ctime,
+			},
+		},
+		{
+			name: "关注者可以看到生日",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, FollowService) {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				follow := svcmocks.NewMockFollowService(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(123)).Return(user(domain.PrivacySettings{}), nil)
+				follow.EXPECT().IsFollowing(gomock.Any(), int64(456), int64(123)).Return(true, nil)
+				return repo, follow
+			},
+			viewer: 456,
+			want: domain.PublicProfile{
+				Id:       123,
+				Nickname: "大明",
+				Avatar:   "/files/avatars/a.png",
+				Birthday: birthday,
+				AboutMe:  "我是大明",
+				Ctime:    ctime,
+			},
+		},
+		{
+			name: "查询关注关系失败，按照陌生人处理",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, FollowService) {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				follow := svcmocks.NewMockFollowService(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(123)).Return(user(domain.PrivacySettings{}), nil)
+				follow.EXPECT().IsFollowing(gomock.Any(), int64(456), int64(123)).Return(false, errors.New("超时"))
+				return repo, follow
+			},
+			viewer: 456,
+			want: domain.PublicProfile{
+				Id:       123,
+				Nickname: "大明",
+				Avatar:   "/files/avatars/a.png",
+				AboutMe:  "我是大明",
+				Ctime:    ctime,
+			},
+		},
+		{
+			name: "已经注销",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, FollowService) {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(123)).
+					Return(domain.User{Id: 123, Status: domain.UserStatusDeleted}, nil)
+				return repo, svcmocks.NewMockFollowService(ctrl)
+			},
+			wantErr: ErrUserNotFound,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			svc := NewProfileService(tc.mock(ctrl))
+			p, err := svc.Profile(context.Background(), tc.viewer, 123)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.want, p)
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"Learn_Go/webook/internal/domain"
+	"Learn_Go/webook/internal/repository"
+	"context"
+	"errors"
+)
+
+var ErrRoleInvalid = errors.New("角色不存在")
+
+// RoleService 用户的角色
+// 所有用户都有 user 角色，作者、版主、管理员需要管理员分配
+type RoleService interface {
+	// Roles 用户的全部角色，包括 user
+	Roles(ctx context.Context, uid int64) ([]string, error)
+	Grant(ctx context.Context, uid int64, role string) error
+	Revoke(ctx context.Context, uid int64, role string) error
+}
+
+type roleService struct {
+	repo repository.RoleRepository
+}
+
+func NewRoleService(repo repository.RoleRepository) RoleService {
+	return &roleService{
+		repo: repo,
+	}
+}
+
+func (svc *roleService) Roles(ctx context.Context, uid int64) ([]string, error) {
+	roles, err := svc.repo.FindByUid(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{domain.RoleUser}, roles...), nil
+}
+
+func (svc *roleService) Grant(ctx context.Context, uid int64, role string) error {
+	if !domain.ValidRole(role) {
+		return ErrRoleInvalid
+	}
+	return svc.repo.Add(ctx, uid, role)
+}
+
+func (svc *roleService) Revoke(ctx context.Context, uid int64, role string) error {
+	if !domain.ValidRole(role) {
+		return ErrRoleInvalid
+	}
+	return svc.repo.Remove(ctx, uid, role)
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"Learn_Go/webook/internal/domain"
+	"Learn_Go/webook/internal/repository"
+	repomocks "Learn_Go/webook/internal/repository/mocks"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"testing"
+)
+
+func Test_roleService_Roles(t *testing.T) {
+	testCases := []struct {
+		name      string
+		mock      func(ctrl *gomock.Controller) repository.RoleRepository
+		wantRoles []string
+		wantErr   error
+	}{
+		{
+			name: "没有额外的角色",
+			mock: func(ctrl *gomock.Controller) repository.RoleRepository {
+				repo := repomocks.NewMockRoleRepository(ctrl)
+				repo.EXPECT().FindByUid(gomock.Any(), int64(123)).Return([]string{}, nil)
+				return repo
+			},
+			wantRoles: []string{domain.RoleUser},
+		},
+		{
+			name: "版主",
+			mock: func(ctrl *gomock.Controller) repository.RoleRepository {
+				repo := repomocks.NewMockRoleRepository(ctrl)
+				repo.EXPECT().FindByUid(gomock.Any(), int64(123)).Return([]string{domain.RoleModerator}, nil)
+				return repo
+			},
+			wantRoles: []string{domain.RoleUser, domain.RoleModerator},
+		},
+		{
+			name: "查询失败",
+			mock: func(ctrl *gomock.Controller) repository.RoleRepository {
+				repo := repomocks.NewMockRoleRepository(ctrl)
+				repo.EXPECT().FindByUid(gomock.Any(), int64(123)).Return(nil, errors.New("模拟的错误"))
+				return repo
+			},
+			wantErr: errors.New("模拟的错误"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			svc := NewRoleService(tc.mock(ctrl))
+			roles, err := svc.Roles(context.Background(), 123)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantRoles, roles)
+		})
+	}
+}
+
+func Test_roleService_Grant(t *testing.T) {
+	testCases := []struct {
+		name    string
+		mock    func(ctrl *gomock.Controller) repository.RoleRepository
+		role    string
+		wantErr error
+	}{
+		{
+			name: "分配成功",
+			mock: func(ctrl *gomock.Controller) repository.RoleRepository {
+				repo := repomocks.NewMockRoleRepository(ctrl)
+				repo.EXPECT().Add(gomock.Any(), int64(123), domain.RoleAuthor).Return(nil)
+				return repo
+			},
+			role: domain.RoleAuthor,
+		},
+		{
+			name: "user 不能分配",
+			mock: func(ctrl *gomock.Controller) repository.RoleRepository {
+				return repomocks.NewMockRoleRepository(ctrl)
+			},
+			role:    domain.RoleUser,
+			wantErr: ErrRoleInvalid,
+		},
+		{
+			name: "角色不存在",
+			mock: func(ctrl *gomock.Controller) repository.RoleRepository {
+				return repomocks.NewMockRoleRepository(ctrl)
+			},
+			role:    "root",
+			wantErr: ErrRoleInvalid,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			svc := NewRoleService(tc.mock(ctrl))
+			err := svc.Grant(context.Background(), 123, tc.role)
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
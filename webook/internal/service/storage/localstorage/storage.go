@@ -0,0 +1,93 @@
+package localstorage
+
+import (
+	"Learn_Go/webook/internal/service/storage"
+	"context"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Storage 存到本地磁盘，只适合本地开发和单机部署
+// 文件也由我们自己提供下载，见 ServeHTTP
+type Storage struct {
+	dir string
+	// baseURL 访问文件的地址前缀，比如 /files 或者 https://cdn.meoying.com/files
+	baseURL string
+	files   http.Handler
+}
+
+func NewStorage(dir, baseURL string) *Storage {
+	return &Storage{
+		dir:     dir,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		files:   http.FileServer(http.Dir(dir)),
+	}
+}
+
+func (s *Storage) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	// 先写临时文件再改名，别人不会读到写了一半的文件
+	f, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err = f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(f.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), p)
+}
+
+func (s *Storage) Exists(ctx context.Context, key string) (bool, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(p)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *Storage) URL(key string) string {
+	return s.baseURL + "/" + key
+}
+
+// ServeHTTP 请求的路径要先去掉 baseURL 的前缀
+func (s *Storage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// 不要列出目录
+	if strings.HasSuffix(r.URL.Path, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// key 里面有内容的哈希，内容不会变
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	s.files.ServeHTTP(w, r)
+}
+
+// path 防止 key 里面的 .. 跑到 dir 外面去
+func (s *Storage) path(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") || path.Clean(key) != key ||
+		strings.HasPrefix(key, "../") || key == ".." {
+		return "", storage.ErrInvalidKey
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
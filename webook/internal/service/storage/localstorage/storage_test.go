@@ -0,0 +1,40 @@
+package localstorage
+
+import (
+	"Learn_Go/webook/internal/service/storage"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStorage(t *testing.T) {
+	s := NewStorage(t.TempDir(), "/files/")
+	ctx := context.Background()
+
+	ok, err := s.Exists(ctx, "images/ab/abc.png")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, s.Put(ctx, "images/ab/abc.png", []byte("hello"), "image/png"))
+	ok, err = s.Exists(ctx, "images/ab/abc.png")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "/files/images/ab/abc.png", s.URL("images/ab/abc.png"))
+
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/images/ab/abc.png", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "hello", recorder.Body.String())
+
+	// 不能列出目录
+	recorder = httptest.NewRecorder()
+	s.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/images/ab/", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+	for _, key := range []string{"", "../abc.png", "images/../../abc.png", "/etc/passwd", "images\\abc.png"} {
+		assert.Equal(t, storage.ErrInvalidKey, s.Put(ctx, key, []byte("hello"), "image/png"), key)
+	}
+}
@@ -0,0 +1,113 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type Config struct {
+	// Endpoint 比如 https://s3.us-east-1.amazonaws.com 或者 http://localhost:9000
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyId     string
+	SecretAccessKey string
+	// PublicURL 前端访问文件的地址前缀，一般是 CDN；为空的时候是 Endpoint/Bucket
+	// bucket 本身要允许匿名读，或者放在 CDN 后面
+	PublicURL string
+}
+
+// Storage 兼容 S3 的对象存储，用 path-style 的地址，MinIO 默认也是这种
+// 只用到了 PUT 和 HEAD，没必要引入整个 SDK
+type Storage struct {
+	cfg    Config
+	client *http.Client
+	signer signer
+	now    func() time.Time
+}
+
+func NewStorage(cfg Config, client *http.Client) *Storage {
+	cfg.Endpoint = strings.TrimSuffix(cfg.Endpoint, "/")
+	if cfg.Region == "" {
+		// MinIO 默认的 region
+		cfg.Region = "us-east-1"
+	}
+	if cfg.PublicURL == "" {
+		cfg.PublicURL = cfg.Endpoint + "/" + cfg.Bucket
+	}
+	cfg.PublicURL = strings.TrimSuffix(cfg.PublicURL, "/")
+	return &Storage{
+		cfg:    cfg,
+		client: client,
+		signer: signer{
+			accessKeyId:     cfg.AccessKeyId,
+			secretAccessKey: cfg.SecretAccessKey,
+			region:          cfg.Region,
+		},
+		now: time.Now,
+	}
+}
+
+func (s *Storage) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	req, err := s.newRequest(ctx, http.MethodPut, key, data)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	// key 里面有内容的哈希，内容不会变
+	req.Header.Set("Cache-Control", "public, max-age=31536000, immutable")
+	s.signer.sign(req, hashHex(data), s.now())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("上传 %s 失败 %d", key, resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *Storage) Exists(ctx context.Context, key string) (bool, error) {
+	req, err := s.newRequest(ctx, http.MethodHead, key, nil)
+	if err != nil {
+		return false, err
+	}
+	s.signer.sign(req, emptyPayloadHash, s.now())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, fmt.Errorf("查询 %s 失败 %d", key, resp.StatusCode)
+	}
+}
+
+func (s *Storage) URL(key string) string {
+	return s.cfg.PublicURL + "/" + escapePath(key)
+}
+
+func (s *Storage) newRequest(ctx context.Context, method, key string, data []byte) (*http.Request, error) {
+	p := "/" + s.cfg.Bucket + "/" + key
+	req, err := http.NewRequestWithContext(ctx, method, s.cfg.Endpoint+escapePath(p), bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	// 保证发出去的路径和签名用的是同一种编码
+	req.URL.RawPath = escapePath(req.URL.Path)
+	if data == nil {
+		req.Body = http.NoBody
+		req.ContentLength = 0
+	}
+	return req, nil
+}
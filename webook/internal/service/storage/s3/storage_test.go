@@ -0,0 +1,128 @@
+package s3
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 模拟 MinIO，校验签名，对象存在内存里面
+type fakeS3 struct {
+	signer signer
+	bucket string
+
+	lock    sync.Mutex
+	objects map[string]object
+}
+
+type object struct {
+	data        []byte
+	contentType string
+}
+
+func newFakeS3(accessKeyId, secret, bucket string) *fakeS3 {
+	return &fakeS3{
+		signer:  signer{accessKeyId: accessKeyId, secretAccessKey: secret, region: "us-east-1"},
+		bucket:  bucket,
+		objects: map[string]object{},
+	}
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if !f.verify(r, body) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	key, ok := strings.CutPrefix(r.URL.Path, "/"+f.bucket+"/")
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		f.objects[key] = object{data: body, contentType: r.Header.Get("Content-Type")}
+	case http.MethodHead:
+		if _, ok := f.objects[key]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// verify 只拿声明了签名的 header 重新签一次，对比签名
+func (f *fakeS3) verify(r *http.Request, body []byte) bool {
+	sum := sha256.Sum256(body)
+	if r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+		return false
+	}
+	auth := r.Header.Get("Authorization")
+	_, signed, ok := strings.Cut(auth, "SignedHeaders=")
+	if !ok {
+		return false
+	}
+	signed, _, _ = strings.Cut(signed, ",")
+	now, err := time.Parse("20060102T150405Z", r.Header.Get("X-Amz-Date"))
+	if err != nil {
+		return false
+	}
+	req := r.Clone(context.Background())
+	req.Header = http.Header{}
+	for _, h := range strings.Split(signed, ";") {
+		if h != "host" && h != "x-amz-date" && h != "x-amz-content-sha256" {
+			req.Header.Set(h, r.Header.Get(h))
+		}
+	}
+	f.signer.sign(req, r.Header.Get("X-Amz-Content-Sha256"), now)
+	return req.Header.Get("Authorization") == auth
+}
+
+func TestStorage(t *testing.T) {
+	fake := newFakeS3("minio", "minio123", "webook")
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	s := NewStorage(Config{
+		Endpoint:        server.URL,
+		Bucket:          "webook",
+		AccessKeyId:     "minio",
+		SecretAccessKey: "minio123",
+	}, server.Client())
+	ctx := context.Background()
+	key := "images/ab/ab cd.png"
+
+	ok, err := s.Exists(ctx, key)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, s.Put(ctx, key, []byte("hello"), "image/png"))
+	assert.Equal(t, object{data: []byte("hello"), contentType: "image/png"}, fake.objects[key])
+
+	ok, err = s.Exists(ctx, key)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, server.URL+"/webook/images/ab/ab%20cd.png", s.URL(key))
+
+	// 密钥不对
+	bad := NewStorage(Config{
+		Endpoint:        server.URL,
+		Bucket:          "webook",
+		AccessKeyId:     "minio",
+		SecretAccessKey: "wrong",
+		PublicURL:       "https://cdn.meoying.com/",
+	}, server.Client())
+	assert.Error(t, bad.Put(ctx, key, []byte("hello"), "image/png"))
+	assert.Equal(t, "https://cdn.meoying.com/images/ab/ab%20cd.png", bad.URL(key))
+}
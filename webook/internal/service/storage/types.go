@@ -0,0 +1,20 @@
+package storage
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrInvalidKey = errors.New("非法的文件 key")
+
+// Storage 是对象存储的抽象
+// 和 sms.Service 一样，为了屏蔽本地磁盘和各家对象存储（S3、MinIO、OSS、COS）之间的区别
+// key 是类似 images/ab/abcdef.jpg 这样的相对路径，由调用方保证唯一
+
+type Storage interface {
+	// Put 同一个 key 重复写入会覆盖
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Exists(ctx context.Context, key string) (bool, error)
+	// URL 前端访问文件的地址
+	URL(key string) string
+}
@@ -4,8 +4,10 @@ import (
 	"Learn_Go/webook/internal/domain"
 	"Learn_Go/webook/internal/repository"
 	"context"
+	"errors"
 	"go.uber.org/zap"
 	"golang.org/x/crypto/bcrypt"
+	"time"
 )
 
 type UserService interface {
@@ -13,8 +15,33 @@ type UserService interface {
 	Login(ctx context.Context, email string, password string) (domain.User, error)
 	UpdateNonSensitiveInfo(ctx context.Context, u domain.User) error
 	FindOrCreate(ctx context.Context, phone string) (domain.User, error)
+	// LoginByEmail 邮箱验证码登录，调用方要先校验验证码，只有已经注册的邮箱能登录
+	LoginByEmail(ctx context.Context, email string) (domain.User, error)
 	FindById(ctx context.Context, uid int64) (domain.User, error)
-	FindOrCreateByWechat(ctx context.Context, info domain.WechatInfo) (domain.User, error)
+	FindByEmail(ctx context.Context, email string) (domain.User, error)
+	// FindOrCreateByOAuth 第三方登录，第一次登录的时候创建用户
+	// profile 不为 nil 的时候，创建用户之前用它补全昵称和头像
+	FindOrCreateByOAuth(ctx context.Context, id domain.OAuthIdentity,
+		profile func(ctx context.Context) (domain.OAuthIdentity, error)) (domain.User, error)
+	FindByPhone(ctx context.Context, phone string) (domain.User, error)
+	// Suspend 封禁用户，until 为零值的时候永久封禁
+	// 封禁之后不能再登录，已经登录的设备需要调用方让它们下线
+	Suspend(ctx context.Context, uid int64, until time.Time) error
+	Unsuspend(ctx context.Context, uid int64) error
+	// ChangePassword 修改密码，需要先校验原密码
+	ChangePassword(ctx context.Context, uid int64, oldPassword, newPassword string) error
+	// ResetPassword 忘记密码的时候重置，调用方要先确认是用户本人，比如校验过验证码
+	ResetPassword(ctx context.Context, uid int64, newPassword string) error
+	// BindPhone 绑定手机号，调用方要先校验验证码
+	// 手机号已经属于其它账号的时候返回 ErrBindingConflict，merge 为 true 的时候把那个账号合并进来
+	// 返回被合并的账号 id，没有合并的时候是 0，调用方需要让那个账号的设备全部下线
+	BindPhone(ctx context.Context, uid int64, phone string, merge bool) (int64, error)
+	// BindEmail 绑定邮箱，和 BindPhone 一样
+	BindEmail(ctx context.Context, uid int64, email string, merge bool) (int64, error)
+	// BindOAuth 绑定第三方账号，和 BindPhone 一样
+	BindOAuth(ctx context.Context, uid int64, id domain.OAuthIdentity, merge bool) (int64, error)
+	// Unbind 解绑，至少要保留一种登录方式
+	Unbind(ctx context.Context, uid int64, typ domain.BindingType) error
 }
 
 type userService struct {
@@ -25,6 +52,13 @@ type userService struct {
 var (
 	ErrDuplicateUser         = repository.ErrDuplicateEmail // 这里Email和Phone都是唯一索引，都会造成用户冲突，所以可以使用通用的错误名字
 	ErrInvalidUserOrPassword = repository.ErrUserNotFound   // 账号或密码不正确，安全性更高
+	ErrUserNotFound          = repository.ErrUserNotFound
+	ErrUserSuspended         = errors.New("账号已被封禁")
+	ErrUserDeleted           = errors.New("账号已注销")
+	ErrOldPasswordWrong      = errors.New("原密码错误")
+	ErrBindingConflict       = errors.New("已经被其它账号绑定")
+	ErrLastBinding           = errors.New("至少要保留一种登录方式")
+	ErrBindingTypeInvalid    = errors.New("不支持的绑定方式")
 )
 
 func NewuserService(repo repository.UserRepository) UserService {
@@ -63,8 +97,18 @@ func (svc *userService) Login(ctx context.Context, email string, password string
 		return domain.User{}, ErrInvalidUserOrPassword // 当密码不正确时，也返回这个错误
 
 	}
-	return u, nil
+	// 密码正确之后再检查状态，不然别人不用知道密码就能试出来账号有没有被封禁
+	return svc.checkLogin(ctx, u)
+
+}
 
+func (svc *userService) LoginByEmail(ctx context.Context, email string) (domain.User, error) {
+	u, err := svc.repo.FindByEmail(ctx, email)
+	if err != nil {
+		return domain.User{}, err
+	}
+	// 和其它登录方式一样，检查账号状态，申请了注销的撤销注销
+	return svc.checkLogin(ctx, u)
 }
 
 func (svc *userService) UpdateNonSensitiveInfo(ctx context.Context, u domain.User) error {
@@ -82,14 +126,265 @@ func (svc *userService) FindById(ctx context.Context, uid int64) (domain.User, e
 	return u, nil
 }
 
+func (svc *userService) FindByEmail(ctx context.Context, email string) (domain.User, error) {
+	return svc.repo.FindByEmail(ctx, email)
+}
+
+func (svc *userService) FindByPhone(ctx context.Context, phone string) (domain.User, error) {
+	return svc.repo.FindByPhone(ctx, phone)
+}
+
+func (svc *userService) Suspend(ctx context.Context, uid int64, until time.Time) error {
+	return svc.repo.UpdateStatus(ctx, domain.User{
+		Id:             uid,
+		Status:         domain.UserStatusSuspended,
+		SuspendedUntil: until,
+	})
+}
+
+func (svc *userService) Unsuspend(ctx context.Context, uid int64) error {
+	return svc.repo.UpdateStatus(ctx, domain.User{
+		Id:     uid,
+		Status: domain.UserStatusActive,
+	})
+}
+
+func (svc *userService) ChangePassword(ctx context.Context, uid int64, oldPassword, newPassword string) error {
+	u, err := svc.repo.FindByIdWithPassword(ctx, uid)
+	if err != nil {
+		return err
+	}
+	// 手机号和微信注册的用户没有密码，只能走重置密码
+	if u.Password == "" || bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(oldPassword)) != nil {
+		return ErrOldPasswordWrong
+	}
+	return svc.ResetPassword(ctx, uid, newPassword)
+}
+
+func (svc *userService) ResetPassword(ctx context.Context, uid int64, newPassword string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	return svc.repo.UpdatePassword(ctx, domain.User{
+		Id:       uid,
+		Password: string(hash),
+	})
+}
+
+func (svc *userService) BindPhone(ctx context.Context, uid int64, phone string, merge bool) (int64, error) {
+	return svc.bind(ctx, uid, merge, binding{
+		owner: func() (domain.User, error) {
+			return svc.repo.FindByPhone(ctx, phone)
+		},
+		apply: func(u *domain.User) {
+			u.Phone = phone
+		},
+	})
+}
+
+func (svc *userService) BindEmail(ctx context.Context, uid int64, email string, merge bool) (int64, error) {
+	return svc.bind(ctx, uid, merge, binding{
+		owner: func() (domain.User, error) {
+			return svc.repo.FindByEmail(ctx, email)
+		},
+		apply: func(u *domain.User) {
+			u.Email = email
+		},
+	})
+}
+
+func (svc *userService) BindOAuth(ctx context.Context, uid int64, id domain.OAuthIdentity, merge bool) (int64, error) {
+	return svc.bind(ctx, uid, merge, binding{
+		owner: func() (domain.User, error) {
+			return svc.repo.FindByOAuth(ctx, id.Provider, id.Subject)
+		},
+		oauth: &id,
+	})
+}
+
+type binding struct {
+	// owner 查询这个手机号（邮箱、第三方账号）现在属于谁
+	owner func() (domain.User, error)
+	// apply 绑定手机号和邮箱的时候，把它设置到用户上
+	apply func(u *domain.User)
+	// oauth 绑定第三方账号的时候不为 nil
+	oauth *domain.OAuthIdentity
+}
+
+func (svc *userService) bind(ctx context.Context, uid int64, merge bool, b binding) (int64, error) {
+	source, err := b.owner()
+	switch {
+	case err == nil && source.Id == uid:
+		// 已经绑定过了
+		return 0, nil
+	case err == nil && !merge:
+		return 0, ErrBindingConflict
+	case err == nil:
+		if err = svc.merge(ctx, uid, source, b); err != nil {
+			return 0, err
+		}
+		return source.Id, nil
+	case err != repository.ErrUserNotFound:
+		return 0, err
+	}
+	if b.oauth != nil {
+		err = svc.repo.BindOAuth(ctx, uid, *b.oauth)
+	} else {
+		var u domain.User
+		u, err = svc.repo.FindById(ctx, uid)
+		if err != nil {
+			return 0, err
+		}
+		b.apply(&u)
+		err = svc.repo.UpdateBindings(ctx, u)
+	}
+	if err == repository.ErrDuplicateEmail {
+		// 并发的时候被别人抢先绑定了
+		return 0, ErrBindingConflict
+	}
+	return 0, err
+}
+
+// merge 调用方已经校验过验证码（或者第三方授权），说明 source 也是同一个人的账号
+// source 上有而 uid 上没有的绑定都转移过来，然后 source 被标记为注销
+func (svc *userService) merge(ctx context.Context, uid int64, source domain.User, b binding) error {
+	// 被封禁的账号不能通过合并来绕过封禁
+	if err := svc.checkStatus(source); err != nil {
+		return err
+	}
+	// 合并的时候会把密码写回去，不能用缓存里面没有密码的用户
+	target, err := svc.repo.FindByIdWithPassword(ctx, uid)
+	if err != nil {
+		return err
+	}
+	targetIds, err := svc.repo.FindOAuthBindings(ctx, uid)
+	if err != nil {
+		return err
+	}
+	sourceIds, err := svc.repo.FindOAuthBindings(ctx, source.Id)
+	if err != nil {
+		return err
+	}
+	if target.Phone == "" {
+		target.Phone = source.Phone
+	}
+	if target.Email == "" {
+		target.Email = source.Email
+		// 密码是和邮箱一起用的
+		if target.Password == "" {
+			target.Password = source.Password
+		}
+	}
+	if b.apply != nil {
+		b.apply(&target)
+	}
+	providers := make([]string, 0, len(sourceIds))
+	for _, id := range sourceIds {
+		// 正在绑定的第三方账号替换掉 uid 原来绑定的
+		if (b.oauth != nil && id.Provider == b.oauth.Provider) || !hasProvider(targetIds, id.Provider) {
+			providers = append(providers, id.Provider)
+		}
+	}
+	zap.L().Info("合并账号", zap.Int64("uid", uid), zap.Int64("source", source.Id))
+	return svc.repo.Merge(ctx, target, source.Id, providers)
+}
+
+func hasProvider(ids []domain.OAuthIdentity, provider string) bool {
+	for _, id := range ids {
+		if id.Provider == provider {
+			return true
+		}
+	}
+	return false
+}
+
+func (svc *userService) Unbind(ctx context.Context, uid int64, typ domain.BindingType) error {
+	if typ == "" {
+		return ErrBindingTypeInvalid
+	}
+	u, err := svc.repo.FindById(ctx, uid)
+	if err != nil {
+		return err
+	}
+	ids, err := svc.repo.FindOAuthBindings(ctx, uid)
+	if err != nil {
+		return err
+	}
+	total := len(ids)
+	if u.Phone != "" {
+		total++
+	}
+	if u.Email != "" {
+		total++
+	}
+	var bound bool
+	switch typ {
+	case domain.BindingPhone:
+		bound = u.Phone != ""
+	case domain.BindingEmail:
+		bound = u.Email != ""
+	default:
+		bound = hasProvider(ids, string(typ))
+	}
+	if !bound {
+		// 本来就没有绑定
+		return nil
+	}
+	if total <= 1 {
+		return ErrLastBinding
+	}
+	switch typ {
+	case domain.BindingPhone:
+		u.Phone = ""
+	case domain.BindingEmail:
+		u.Email = ""
+	default:
+		return svc.repo.UnbindOAuth(ctx, uid, string(typ))
+	}
+	return svc.repo.UpdateBindings(ctx, u)
+}
+
+// checkLogin 登录的时候检查账号状态，申请了注销的用户在宽限期内登录就撤销注销
+func (svc *userService) checkLogin(ctx context.Context, u domain.User) (domain.User, error) {
+	if err := svc.checkStatus(u); err != nil {
+		return domain.User{}, err
+	}
+	if u.DeleteAt.IsZero() {
+		return u, nil
+	}
+	// 撤销失败的话不能让用户登录，不然用户以为撤销了，账号还是会被注销
+	if err := svc.repo.ScheduleDeletion(ctx, u.Id, time.Time{}); err != nil {
+		return domain.User{}, err
+	}
+	zap.L().Info("登录撤销注销", zap.Int64("uid", u.Id))
+	u.DeleteAt = time.Time{}
+	return u, nil
+}
+
+// checkStatus 登录的时候检查账号状态，被封禁和已经注销的账号不能登录
+func (svc *userService) checkStatus(u domain.User) error {
+	switch {
+	case u.Status == domain.UserStatusDeleted:
+		return ErrUserDeleted
+	case u.Suspended(time.Now()):
+		return ErrUserSuspended
+	default:
+		return nil
+	}
+}
+
 func (svc *userService) FindOrCreate(ctx context.Context, phone string) (domain.User, error) {
 
 	// 先找一下，我们认为，大部分用户是已经存在的用户
 	u, err := svc.repo.FindByPhone(ctx, phone)
 
-	// 这里直接判断这个错误是否是用户未找到，若不是，则有两种情况 1. nil，直接返回用户信息 2.系统错误
+	// 这里直接判断这个错误是否是用户未找到，若不是，则有两种情况 1. nil，检查状态之后返回用户信息 2.系统错误
 	if err != repository.ErrUserNotFound {
-		return u, err
+		if err != nil {
+			return domain.User{}, err
+		}
+		return svc.checkLogin(ctx, u)
 	}
 	// 没有进去分支说明没找到用户，那么创建用户
 	err = svc.repo.Create(ctx, domain.User{
@@ -111,24 +406,37 @@ func (svc *userService) FindOrCreate(ctx context.Context, phone string) (domain.
 
 // 在service层进行密码加密（PBKDF2、BCrypt） ，同样的文本加密后的结果都不同
 
-func (svc *userService) FindOrCreateByWechat(ctx context.Context, wechatInfo domain.WechatInfo) (domain.User, error) {
-
-	// 这里因为我们开发的应用不存在多个应用，所以我们就直接使用OpenId
-	u, err := svc.repo.FindByWechat(ctx, wechatInfo.OpenId)
+func (svc *userService) FindOrCreateByOAuth(ctx context.Context, id domain.OAuthIdentity,
+	profile func(ctx context.Context) (domain.OAuthIdentity, error)) (domain.User, error) {
+	u, err := svc.repo.FindByOAuth(ctx, id.Provider, id.Subject)
 
-	// 这里直接判断这个错误是否是用户未找到，若不是，则有两种情况 1. nil，直接返回用户信息 2.系统错误
+	// 这里直接判断这个错误是否是用户未找到，若不是，则有两种情况 1. nil，检查状态之后返回用户信息 2.系统错误
 	if err != repository.ErrUserNotFound {
-		return u, err
+		if err != nil {
+			return domain.User{}, err
+		}
+		return svc.checkLogin(ctx, u)
 	}
 	// 没有进去分支说明没找到用户，那么创建用户
-	zap.L().Info("新用户", zap.Any("wechatInfo", wechatInfo)) // 可以记录一下新用户
-	err = svc.repo.Create(ctx, domain.User{
-		WechatInfo: wechatInfo,
-	})
+	zap.L().Info("新用户", zap.String("provider", id.Provider), zap.String("subject", id.Subject)) // 可以记录一下新用户
+	if profile != nil {
+		p, er := profile(ctx)
+		if er != nil {
+			// 拿不到昵称和头像不影响登录，用户之后可以自己改
+			zap.L().Warn("获取第三方用户信息失败", zap.String("provider", id.Provider), zap.Error(er))
+		} else {
+			id = p
+		}
+	}
+	// 第三方返回的邮箱不一定验证过，不作为登录邮箱，用户想用邮箱登录需要自己绑定
+	err = svc.repo.CreateWithOAuth(ctx, domain.User{
+		NickName: id.Nickname,
+		Avatar:   id.Avatar,
+	}, id)
 
 	if err != nil && err != ErrDuplicateUser {
 		return domain.User{}, err
 	}
 
-	return svc.repo.FindByWechat(ctx, wechatInfo.OpenId)
+	return svc.repo.FindByOAuth(ctx, id.Provider, id.Subject)
 }
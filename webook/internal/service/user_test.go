@@ -8,9 +8,11 @@ import (
 	"errors"
 	"fmt"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 	"golang.org/x/crypto/bcrypt"
 	"testing"
+	"time"
 )
 
 func TestEncrypt(t *testing.T) {
@@ -107,6 +109,45 @@ func Test_userService_Login(t *testing.T) {
 			wantUser: domain.User{},
 			wantErr:  ErrInvalidUserOrPassword,
 		},
+		{
+			name: "账号被封禁",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByEmail(gomock.Any(), "12345@qq.com").Return(domain.User{
+					Email:    "12345@qq.com",
+					Password: "$2a$10$Xtf2o6ErMJcGNsdVcAJln.5qcQN4GzHOX4DIhPAOzHB.DF3lEzaVu",
+					Status:   domain.UserStatusSuspended,
+				}, nil)
+				return repo
+			},
+			Email:    "12345@qq.com",
+			Password: "123456&lip",
+
+			wantUser: domain.User{},
+			wantErr:  ErrUserSuspended,
+		},
+		{
+			name: "封禁已经到期",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByEmail(gomock.Any(), "12345@qq.com").Return(domain.User{
+					Email:          "12345@qq.com",
+					Password:       "$2a$10$Xtf2o6ErMJcGNsdVcAJln.5qcQN4GzHOX4DIhPAOzHB.DF3lEzaVu",
+					Status:         domain.UserStatusSuspended,
+					SuspendedUntil: time.UnixMilli(1000),
+				}, nil)
+				return repo
+			},
+			Email:    "12345@qq.com",
+			Password: "123456&lip",
+
+			wantUser: domain.User{
+				Email:          "12345@qq.com",
+				Password:       "$2a$10$Xtf2o6ErMJcGNsdVcAJln.5qcQN4GzHOX4DIhPAOzHB.DF3lEzaVu",
+				Status:         domain.UserStatusSuspended,
+				SuspendedUntil: time.UnixMilli(1000),
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -123,3 +164,532 @@ func Test_userService_Login(t *testing.T) {
 		})
 	}
 }
+
+func Test_userService_FindOrCreate(t *testing.T) {
+	testCases := []struct {
+		name     string
+		mock     func(ctrl *gomock.Controller) repository.UserRepository
+		wantUser domain.User
+		wantErr  error
+	}{
+		{
+			name: "老用户",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByPhone(gomock.Any(), "15023113254").Return(domain.User{Id: 1, Phone: "15023113254"}, nil)
+				return repo
+			},
+			wantUser: domain.User{Id: 1, Phone: "15023113254"},
+		},
+		{
+			name: "账号被封禁",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByPhone(gomock.Any(), "15023113254").Return(domain.User{
+					Id:             1,
+					Phone:          "15023113254",
+					Status:         domain.UserStatusSuspended,
+					SuspendedUntil: time.Now().Add(time.Hour),
+				}, nil)
+				return repo
+			},
+			wantErr: ErrUserSuspended,
+		},
+		{
+			name: "申请了注销，登录撤销注销",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByPhone(gomock.Any(), "15023113254").Return(domain.User{
+					Id:       1,
+					Phone:    "15023113254",
+					DeleteAt: time.UnixMilli(1700000000000),
+				}, nil)
+				repo.EXPECT().ScheduleDeletion(gomock.Any(), int64(1), time.Time{}).Return(nil)
+				return repo
+			},
+			wantUser: domain.User{Id: 1, Phone: "15023113254"},
+		},
+		{
+			name: "撤销注销失败",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByPhone(gomock.Any(), "15023113254").Return(domain.User{
+					Id:       1,
+					Phone:    "15023113254",
+					DeleteAt: time.UnixMilli(1700000000000),
+				}, nil)
+				repo.EXPECT().ScheduleDeletion(gomock.Any(), int64(1), time.Time{}).Return(errors.New("db 错误"))
+				return repo
+			},
+			wantErr: errors.New("db 错误"),
+		},
+		{
+			name: "新用户",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByPhone(gomock.Any(), "15023113254").Return(domain.User{}, repository.ErrUserNotFound)
+				repo.EXPECT().Create(gomock.Any(), domain.User{Phone: "15023113254"}).Return(nil)
+				repo.EXPECT().FindByPhone(gomock.Any(), "15023113254").Return(domain.User{Id: 1, Phone: "15023113254"}, nil)
+				return repo
+			},
+			wantUser: domain.User{Id: 1, Phone: "15023113254"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			svc := NewuserService(tc.mock(ctrl))
+			u, err := svc.FindOrCreate(context.Background(), "15023113254")
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantUser, u)
+		})
+	}
+}
+
+// 申请了注销的用户，不管用哪种方式登录都会撤销注销
+func Test_userService_LoginCancelsDeletion(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("hello#world123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	pending := domain.User{
+		Id:       1,
+		Email:    "123@qq.com",
+		Password: string(hash),
+		Phone:    "15023113254",
+		DeleteAt: time.UnixMilli(1700000000000),
+	}
+	testCases := []struct {
+		name  string
+		mock  func(repo *repomocks.MockUserRepository)
+		login func(svc UserService) (domain.User, error)
+	}{
+		{
+			name: "密码登录",
+			mock: func(repo *repomocks.MockUserRepository) {
+				repo.EXPECT().FindByEmail(gomock.Any(), "123@qq.com").Return(pending, nil)
+			},
+			login: func(svc UserService) (domain.User, error) {
+				return svc.Login(context.Background(), "123@qq.com", "hello#world123")
+			},
+		},
+		{
+			name: "短信验证码登录",
+			mock: func(repo *repomocks.MockUserRepository) {
+				repo.EXPECT().FindByPhone(gomock.Any(), "15023113254").Return(pending, nil)
+			},
+			login: func(svc UserService) (domain.User, error) {
+				return svc.FindOrCreate(context.Background(), "15023113254")
+			},
+		},
+		{
+			name: "邮箱验证码登录",
+			mock: func(repo *repomocks.MockUserRepository) {
+				repo.EXPECT().FindByEmail(gomock.Any(), "123@qq.com").Return(pending, nil)
+			},
+			login: func(svc UserService) (domain.User, error) {
+				return svc.LoginByEmail(context.Background(), "123@qq.com")
+			},
+		},
+		{
+			name: "第三方登录",
+			mock: func(repo *repomocks.MockUserRepository) {
+				repo.EXPECT().FindByOAuth(gomock.Any(), "wechat", "open-id").Return(pending, nil)
+			},
+			login: func(svc UserService) (domain.User, error) {
+				return svc.FindOrCreateByOAuth(context.Background(),
+					domain.OAuthIdentity{Provider: "wechat", Subject: "open-id"}, nil)
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo := repomocks.NewMockUserRepository(ctrl)
+			tc.mock(repo)
+			repo.EXPECT().ScheduleDeletion(gomock.Any(), int64(1), time.Time{}).Return(nil)
+			u, err := tc.login(NewuserService(repo))
+			require.NoError(t, err)
+			assert.True(t, u.DeleteAt.IsZero())
+		})
+	}
+}
+
+func Test_userService_LoginByEmail(t *testing.T) {
+	testCases := []struct {
+		name     string
+		mock     func(ctrl *gomock.Controller) repository.UserRepository
+		wantUser domain.User
+		wantErr  error
+	}{
+		{
+			name: "登录成功",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByEmail(gomock.Any(), "123@qq.com").Return(domain.User{Id: 1, Email: "123@qq.com"}, nil)
+				return repo
+			},
+			wantUser: domain.User{Id: 1, Email: "123@qq.com"},
+		},
+		{
+			name: "账号已注销",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByEmail(gomock.Any(), "123@qq.com").
+					Return(domain.User{Id: 1, Email: "123@qq.com", Status: domain.UserStatusDeleted}, nil)
+				return repo
+			},
+			wantErr: ErrUserDeleted,
+		},
+		{
+			name: "用户不存在",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByEmail(gomock.Any(), "123@qq.com").Return(domain.User{}, repository.ErrUserNotFound)
+				return repo
+			},
+			wantErr: ErrUserNotFound,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			svc := NewuserService(tc.mock(ctrl))
+			u, err := svc.LoginByEmail(context.Background(), "123@qq.com")
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantUser, u)
+		})
+	}
+}
+
+func Test_userService_ChangePassword(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("hello#world123"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	testCases := []struct {
+		name        string
+		mock        func(ctrl *gomock.Controller) repository.UserRepository
+		oldPassword string
+		wantErr     error
+	}{
+		{
+			name: "修改成功",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByIdWithPassword(gomock.Any(), int64(1)).Return(domain.User{Id: 1, Password: string(hash)}, nil)
+				repo.EXPECT().UpdatePassword(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(ctx context.Context, u domain.User) error {
+						// 保存的是新密码加密之后的结果
+						assert.Equal(t, int64(1), u.Id)
+						assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(u.Password), []byte("new#world123")))
+						return nil
+					})
+				return repo
+			},
+			oldPassword: "hello#world123",
+		},
+		{
+			name: "原密码错误",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByIdWithPassword(gomock.Any(), int64(1)).Return(domain.User{Id: 1, Password: string(hash)}, nil)
+				return repo
+			},
+			oldPassword: "hello#world",
+			wantErr:     ErrOldPasswordWrong,
+		},
+		{
+			name: "手机号注册的用户没有密码",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByIdWithPassword(gomock.Any(), int64(1)).Return(domain.User{Id: 1, Phone: "15023113254"}, nil)
+				return repo
+			},
+			wantErr: ErrOldPasswordWrong,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			svc := NewuserService(tc.mock(ctrl))
+			err := svc.ChangePassword(context.Background(), 1, tc.oldPassword, "new#world123")
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func Test_userService_BindPhone(t *testing.T) {
+	testCases := []struct {
+		name       string
+		mock       func(ctrl *gomock.Controller) repository.UserRepository
+		merge      bool
+		wantMerged int64
+		wantErr    error
+	}{
+		{
+			name: "手机号没有被绑定过",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByPhone(gomock.Any(), "15023113254").Return(domain.User{}, repository.ErrUserNotFound)
+				repo.EXPECT().FindById(gomock.Any(), int64(1)).Return(domain.User{Id: 1, Email: "123@qq.com"}, nil)
+				repo.EXPECT().UpdateBindings(gomock.Any(), domain.User{Id: 1, Email: "123@qq.com", Phone: "15023113254"}).Return(nil)
+				return repo
+			},
+		},
+		{
+			name: "手机号属于其它账号",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByPhone(gomock.Any(), "15023113254").Return(domain.User{Id: 2, Phone: "15023113254"}, nil)
+				return repo
+			},
+			wantErr: ErrBindingConflict,
+		},
+		{
+			name: "合并其它账号",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByPhone(gomock.Any(), "15023113254").Return(domain.User{Id: 2, Phone: "15023113254"}, nil)
+				repo.EXPECT().FindByIdWithPassword(gomock.Any(), int64(1)).Return(domain.User{Id: 1, Email: "123@qq.com", Password: "hash"}, nil)
+				repo.EXPECT().FindOAuthBindings(gomock.Any(), int64(1)).
+					Return([]domain.OAuthIdentity{{Provider: "github", Subject: "1"}}, nil)
+				repo.EXPECT().FindOAuthBindings(gomock.Any(), int64(2)).
+					Return([]domain.OAuthIdentity{{Provider: "wechat", Subject: "open-id"}, {Provider: "github", Subject: "2"}}, nil)
+				// 对方的微信转移过来，GitHub 两边都有，保留自己的
+				repo.EXPECT().Merge(gomock.Any(), domain.User{
+					Id:       1,
+					Email:    "123@qq.com",
+					Password: "hash",
+					Phone:    "15023113254",
+				}, int64(2), []string{"wechat"}).Return(nil)
+				return repo
+			},
+			merge:      true,
+			wantMerged: 2,
+		},
+		{
+			name: "不能合并被封禁的账号",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByPhone(gomock.Any(), "15023113254").Return(domain.User{
+					Id:     2,
+					Phone:  "15023113254",
+					Status: domain.UserStatusSuspended,
+				}, nil)
+				return repo
+			},
+			merge:   true,
+			wantErr: ErrUserSuspended,
+		},
+		{
+			name: "已经绑定过了",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByPhone(gomock.Any(), "15023113254").Return(domain.User{Id: 1, Phone: "15023113254"}, nil)
+				return repo
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			svc := NewuserService(tc.mock(ctrl))
+			merged, err := svc.BindPhone(context.Background(), 1, "15023113254", tc.merge)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantMerged, merged)
+		})
+	}
+}
+
+func Test_userService_BindOAuth(t *testing.T) {
+	id := domain.OAuthIdentity{Provider: "github", Subject: "2"}
+	testCases := []struct {
+		name       string
+		mock       func(ctrl *gomock.Controller) repository.UserRepository
+		merge      bool
+		wantMerged int64
+		wantErr    error
+	}{
+		{
+			name: "第三方账号没有被绑定过",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByOAuth(gomock.Any(), "github", "2").Return(domain.User{}, repository.ErrUserNotFound)
+				repo.EXPECT().BindOAuth(gomock.Any(), int64(1), id).Return(nil)
+				return repo
+			},
+		},
+		{
+			name: "并发绑定被别人抢先了",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByOAuth(gomock.Any(), "github", "2").Return(domain.User{}, repository.ErrUserNotFound)
+				repo.EXPECT().BindOAuth(gomock.Any(), int64(1), id).Return(repository.ErrDuplicateEmail)
+				return repo
+			},
+			wantErr: ErrBindingConflict,
+		},
+		{
+			name: "合并的时候替换掉自己原来绑定的",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByOAuth(gomock.Any(), "github", "2").Return(domain.User{Id: 2}, nil)
+				repo.EXPECT().FindByIdWithPassword(gomock.Any(), int64(1)).Return(domain.User{Id: 1, Phone: "15023113254"}, nil)
+				repo.EXPECT().FindOAuthBindings(gomock.Any(), int64(1)).
+					Return([]domain.OAuthIdentity{{Provider: "github", Subject: "1"}}, nil)
+				repo.EXPECT().FindOAuthBindings(gomock.Any(), int64(2)).
+					Return([]domain.OAuthIdentity{id}, nil)
+				repo.EXPECT().Merge(gomock.Any(), domain.User{Id: 1, Phone: "15023113254"}, int64(2), []string{"github"}).Return(nil)
+				return repo
+			},
+			merge:      true,
+			wantMerged: 2,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			svc := NewuserService(tc.mock(ctrl))
+			merged, err := svc.BindOAuth(context.Background(), 1, id, tc.merge)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantMerged, merged)
+		})
+	}
+}
+
+func Test_userService_FindOrCreateByOAuth(t *testing.T) {
+	id := domain.OAuthIdentity{Provider: "wechat", Subject: "open-id"}
+	profile := func(ctx context.Context) (domain.OAuthIdentity, error) {
+		return domain.OAuthIdentity{Provider: "wechat", Subject: "open-id", Nickname: "微信用户", Avatar: "avatar.png"}, nil
+	}
+	testCases := []struct {
+		name    string
+		mock    func(ctrl *gomock.Controller) repository.UserRepository
+		profile func(ctx context.Context) (domain.OAuthIdentity, error)
+		wantErr error
+	}{
+		{
+			name: "老用户不需要获取昵称和头像",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByOAuth(gomock.Any(), "wechat", "open-id").Return(domain.User{Id: 1}, nil)
+				return repo
+			},
+			profile: func(ctx context.Context) (domain.OAuthIdentity, error) {
+				t.Fatal("老用户不应该调用 profile")
+				return domain.OAuthIdentity{}, nil
+			},
+		},
+		{
+			name: "新用户带上昵称和头像",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByOAuth(gomock.Any(), "wechat", "open-id").Return(domain.User{}, repository.ErrUserNotFound)
+				repo.EXPECT().CreateWithOAuth(gomock.Any(), domain.User{NickName: "微信用户", Avatar: "avatar.png"},
+					domain.OAuthIdentity{Provider: "wechat", Subject: "open-id", Nickname: "微信用户", Avatar: "avatar.png"}).Return(nil)
+				repo.EXPECT().FindByOAuth(gomock.Any(), "wechat", "open-id").Return(domain.User{Id: 1}, nil)
+				return repo
+			},
+			profile: profile,
+		},
+		{
+			name: "获取昵称失败也可以注册",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByOAuth(gomock.Any(), "wechat", "open-id").Return(domain.User{}, repository.ErrUserNotFound)
+				repo.EXPECT().CreateWithOAuth(gomock.Any(), domain.User{}, id).Return(nil)
+				repo.EXPECT().FindByOAuth(gomock.Any(), "wechat", "open-id").Return(domain.User{Id: 1}, nil)
+				return repo
+			},
+			profile: func(ctx context.Context) (domain.OAuthIdentity, error) {
+				return domain.OAuthIdentity{}, errors.New("access_token 过期")
+			},
+		},
+		{
+			name: "账号被封禁",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByOAuth(gomock.Any(), "wechat", "open-id").
+					Return(domain.User{Id: 1, Status: domain.UserStatusSuspended}, nil)
+				return repo
+			},
+			wantErr: ErrUserSuspended,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			svc := NewuserService(tc.mock(ctrl))
+			_, err := svc.FindOrCreateByOAuth(context.Background(), id, tc.profile)
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func Test_userService_Unbind(t *testing.T) {
+	testCases := []struct {
+		name    string
+		mock    func(ctrl *gomock.Controller) repository.UserRepository
+		typ     domain.BindingType
+		wantErr error
+	}{
+		{
+			name: "解绑成功",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(1)).Return(domain.User{Id: 1, Phone: "15023113254"}, nil)
+				repo.EXPECT().FindOAuthBindings(gomock.Any(), int64(1)).
+					Return([]domain.OAuthIdentity{{Provider: "wechat", Subject: "open-id"}}, nil)
+				repo.EXPECT().UnbindOAuth(gomock.Any(), int64(1), "wechat").Return(nil)
+				return repo
+			},
+			typ: "wechat",
+		},
+		{
+			name: "解绑手机号",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(1)).Return(domain.User{Id: 1, Phone: "15023113254"}, nil)
+				repo.EXPECT().FindOAuthBindings(gomock.Any(), int64(1)).
+					Return([]domain.OAuthIdentity{{Provider: "github", Subject: "1"}}, nil)
+				repo.EXPECT().UpdateBindings(gomock.Any(), domain.User{Id: 1}).Return(nil)
+				return repo
+			},
+			typ: domain.BindingPhone,
+		},
+		{
+			name: "不能解绑最后一种登录方式",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(1)).Return(domain.User{Id: 1, Phone: "15023113254"}, nil)
+				repo.EXPECT().FindOAuthBindings(gomock.Any(), int64(1)).Return(nil, nil)
+				return repo
+			},
+			typ:     domain.BindingPhone,
+			wantErr: ErrLastBinding,
+		},
+		{
+			name: "没有绑定",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(1)).Return(domain.User{Id: 1, Phone: "15023113254"}, nil)
+				repo.EXPECT().FindOAuthBindings(gomock.Any(), int64(1)).Return(nil, nil)
+				return repo
+			},
+			typ: domain.BindingEmail,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			svc := NewuserService(tc.mock(ctrl))
+			err := svc.Unbind(context.Background(), 1, tc.typ)
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
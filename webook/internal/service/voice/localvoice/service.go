@@ -0,0 +1,18 @@
+package localvoice
+
+import (
+	"context"
+	"log"
+)
+
+type Service struct {
+}
+
+func NewService() *Service {
+	return &Service{}
+}
+
+func (s *Service) Call(ctx context.Context, tplId string, args []string, number string) error {
+	log.Println("语音验证码是", args)
+	return nil
+}
@@ -0,0 +1,10 @@
+package voice
+
+import "context"
+
+// Service 是语音电话通知的抽象
+// 有些用户收不到短信，就通过语音电话把验证码念给用户
+
+type Service interface {
+	Call(ctx context.Context, tplId string, args []string, number string) error
+}
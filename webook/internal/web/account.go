@@ -0,0 +1,170 @@
+package web
+
+import (
+	"Learn_Go/webook/internal/domain"
+	"Learn_Go/webook/internal/service"
+	ijwt "Learn_Go/webook/internal/web/jwt"
+	"Learn_Go/webook/pkg/ginx/authz"
+	"Learn_Go/webook/pkg/logger"
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+// AccountHandler 注销账号和导出个人数据
+type AccountHandler struct {
+	svc service.AccountService
+	// 申请注销之后让用户所有的设备下线
+	sessions ijwt.Handler
+	l        logger.LoggerV1
+}
+
+func NewAccountHandler(svc service.AccountService, sessions ijwt.Handler, l logger.LoggerV1) *AccountHandler {
+	return &AccountHandler{
+		svc:      svc,
+		sessions: sessions,
+		l:        l,
+	}
+}
+
+func (h *AccountHandler) RegisterRouters(server *authz.Router) {
+	login := server.Group("/users").With(authz.Login())
+	// 申请注销，14 天之内重新登录就撤销
+	login.POST("/delete", h.Delete)
+	// 下载个人数据，是一个 zip 文件
+	login.GET("/export", h.Export)
+}
+
+func (h *AccountHandler) Delete(ctx *gin.Context) {
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	at, err := h.svc.ScheduleDeletion(ctx, uc.Uid)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		h.l.Error("申请注销失败", logger.Int64("uid", uc.Uid), logger.Error(err))
+		return
+	}
+	// 所有设备都下线，之后只要登录就会撤销注销
+	if err = h.sessions.RevokeOtherSessions(ctx, uc.Uid, ""); err != nil {
+		h.l.Error("申请注销之后下线设备失败", logger.Int64("uid", uc.Uid), logger.Error(err))
+	}
+	ctx.JSON(http.StatusOK, Result{
+		Msg:  fmt.Sprintf("账号将在 %s 注销，在这之前重新登录可以撤销", at.Format(time.DateOnly)),
+		Data: at.UnixMilli(),
+	})
+}
+
+// 导出的数据，字段名字对外，不要随便改
+type exportProfile struct {
+	Id       int64           `json:"id"`
+	Nickname string          `json:"nickname"`
+	Avatar   string          `json:"avatar"`
+	Email    string          `json:"email"`
+	Phone    string          `json:"phone"`
+	Birthday string          `json:"birthday"`
+	AboutMe  string          `json:"aboutMe"`
+	Ctime    string          `json:"ctime"`
+	Bindings []exportBinding `json:"bindings"`
+}
+
+type exportBinding struct {
+	Provider string `json:"provider"`
+	Subject  string `json:"subject"`
+}
+
+type exportArticle struct {
+	Id      int64  `json:"id"`
+	Title   string `json:"title"`
+	Content string `json:"content"`
+	Status  string `json:"status"`
+	Ctime   string `json:"ctime"`
+	Utime   string `json:"utime"`
+}
+
+func (h *AccountHandler) Export(ctx *gin.Context) {
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	data, err := h.svc.Export(ctx, uc.Uid)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		h.l.Error("导出个人数据失败", logger.Int64("uid", uc.Uid), logger.Error(err))
+		return
+	}
+	// 先写到内存里面，出错的时候还能返回 JSON
+	body, err := h.zip(data)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		h.l.Error("打包个人数据失败", logger.Int64("uid", uc.Uid), logger.Error(err))
+		return
+	}
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="webook-%d.zip"`, uc.Uid))
+	ctx.Data(http.StatusOK, "application/zip", body)
+}
+
+func (h *AccountHandler) zip(data domain.AccountExport) ([]byte, error) {
+	u := data.User
+	profile := exportProfile{
+		Id:       u.Id,
+		Nickname: u.NickName,
+		Avatar:   u.Avatar,
+		Email:    u.Email,
+		Phone:    u.Phone,
+		AboutMe:  u.AboutMe,
+		Ctime:    u.Ctime.Format(time.RFC3339),
+		Bindings: make([]exportBinding, 0, len(data.Bindings)),
+	}
+	// 没有设置过生日的时候数据库里面是 0
+	if !u.BirthDay.IsZero() && u.BirthDay.UnixMilli() != 0 {
+		profile.Birthday = u.BirthDay.Format(time.DateOnly)
+	}
+	for _, b := range data.Bindings {
+		profile.Bindings = append(profile.Bindings, exportBinding{Provider: b.Provider, Subject: b.Subject})
+	}
+	arts := make([]exportArticle, 0, len(data.Articles))
+	for _, art := range data.Articles {
+		arts = append(arts, exportArticle{
+			Id:      art.Id,
+			Title:   art.Title,
+			Content: art.Content,
+			Status:  art.Status.String(),
+			Ctime:   art.Ctime.Format(time.RFC3339),
+			Utime:   art.Utime.Format(time.RFC3339),
+		})
+	}
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	files := []struct {
+		name string
+		val  any
+	}{
+		{name: "profile.json", val: profile},
+		{name: "articles.json", val: arts},
+	}
+	for _, f := range files {
+		fw, err := w.Create(f.name)
+		if err != nil {
+			return nil, err
+		}
+		enc := json.NewEncoder(fw)
+		enc.SetIndent("", "  ")
+		if err = enc.Encode(f.val); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,157 @@
+package web
+
+import (
+	"Learn_Go/webook/internal/domain"
+	"Learn_Go/webook/internal/service"
+	svcmocks "Learn_Go/webook/internal/service/mocks"
+	ijwt "Learn_Go/webook/internal/web/jwt"
+	"Learn_Go/webook/pkg/ginx/authz"
+	"Learn_Go/webook/pkg/logger"
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newAccountServer(t *testing.T, svc service.AccountService, mr *miniredis.Miniredis) *gin.Engine {
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	h := NewAccountHandler(svc, ijwt.NewRedisJWTHandler(client, ijwt.NewKeyManager(0), 0), logger.NewNopLogger())
+	server := gin.New()
+	server.Use(func(ctx *gin.Context) {
+		ctx.Set("user", ijwt.UserClaims{Uid: 123})
+	})
+	h.RegisterRouters(authz.NewRouter(server, authz.NewRegistry()))
+	return server
+}
+
+func TestAccountHandler_Delete(t *testing.T) {
+	at := time.Date(2024, 1, 15, 10, 0, 0, 0, time.Local)
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) service.AccountService
+
+		wantRes Result
+		// 申请成功之后所有设备都要下线
+		wantRevoked bool
+	}{
+		{
+			name: "申请成功",
+			mock: func(ctrl *gomock.Controller) service.AccountService {
+				svc := svcmocks.NewMockAccountService(ctrl)
+				svc.EXPECT().ScheduleDeletion(gomock.Any(), int64(123)).Return(at, nil)
+				return svc
+			},
+			wantRes: Result{
+				Msg:  "账号将在 2024-01-15 注销，在这之前重新登录可以撤销",
+				Data: float64(at.UnixMilli()),
+			},
+			wantRevoked: true,
+		},
+		{
+			name: "系统错误",
+			mock: func(ctrl *gomock.Controller) service.AccountService {
+				svc := svcmocks.NewMockAccountService(ctrl)
+				svc.EXPECT().ScheduleDeletion(gomock.Any(), int64(123)).Return(time.Time{}, errors.New("db 错误"))
+				return svc
+			},
+			wantRes: Result{Code: 5, Msg: "系统错误"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mr := miniredis.RunT(t)
+			mr.ZAdd("users:sessions:123", 1, "ssid-123")
+			server := newAccountServer(t, tc.mock(ctrl), mr)
+
+			req, err := http.NewRequest(http.MethodPost, "/users/delete", nil)
+			require.NoError(t, err)
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			var res Result
+			require.NoError(t, json.NewDecoder(recorder.Body).Decode(&res))
+			assert.Equal(t, tc.wantRes, res)
+			assert.Equal(t, tc.wantRevoked, mr.Exists("users:ssid:ssid-123"))
+		})
+	}
+}
+
+func TestAccountHandler_Export(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	svc := svcmocks.NewMockAccountService(ctrl)
+	ctime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	svc.EXPECT().Export(gomock.Any(), int64(123)).Return(domain.AccountExport{
+		User: domain.User{
+			Id:       123,
+			Email:    "123@qq.com",
+			NickName: "大明",
+			Ctime:    ctime,
+		},
+		Bindings: []domain.OAuthIdentity{{Provider: "github", Subject: "42"}},
+		Articles: []domain.Article{{
+			Id:      1,
+			Title:   "我的标题",
+			Content: "我的内容",
+			Status:  domain.ArticleStatusNormal,
+			Ctime:   ctime,
+			Utime:   ctime,
+		}},
+	}, nil)
+	server := newAccountServer(t, svc, miniredis.RunT(t))
+
+	req, err := http.NewRequest(http.MethodGet, "/users/export", nil)
+	require.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/zip", recorder.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="webook-123.zip"`, recorder.Header().Get("Content-Disposition"))
+
+	body := recorder.Body.Bytes()
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	require.NoError(t, err)
+	files := make(map[string][]byte, len(zr.File))
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		files[f.Name], err = io.ReadAll(rc)
+		require.NoError(t, err)
+		rc.Close()
+	}
+
+	var profile map[string]any
+	require.NoError(t, json.Unmarshal(files["profile.json"], &profile))
+	assert.Equal(t, "123@qq.com", profile["email"])
+	assert.Equal(t, "2024-01-01T00:00:00Z", profile["ctime"])
+	// 没有设置过生日
+	assert.Equal(t, "", profile["birthday"])
+	assert.Equal(t, []any{map[string]any{"provider": "github", "subject": "42"}}, profile["bindings"])
+	assert.NotContains(t, profile, "password")
+
+	var arts []map[string]any
+	require.NoError(t, json.Unmarshal(files["articles.json"], &arts))
+	assert.Equal(t, []map[string]any{{
+		"id":      float64(1),
+		"title":   "我的标题",
+		"content": "我的内容",
+		"status":  "normal",
+		"ctime":   "2024-01-01T00:00:00Z",
+		"utime":   "2024-01-01T00:00:00Z",
+	}}, arts)
+}
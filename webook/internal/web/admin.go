@@ -0,0 +1,372 @@
+package web
+
+import (
+	"Learn_Go/webook/internal/domain"
+	"Learn_Go/webook/internal/service"
+	ijwt "Learn_Go/webook/internal/web/jwt"
+	"Learn_Go/webook/pkg/ginx/authz"
+	"Learn_Go/webook/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// AdminHandler 管理后台，版主和管理员使用
+type AdminHandler struct {
+	userSvc service.UserService
+	roleSvc service.RoleService
+	artSvc  service.ArticleService
+	// 封禁之后让用户所有的设备下线
+	sessions ijwt.Handler
+	l        logger.LoggerV1
+}
+
+func NewAdminHandler(userSvc service.UserService, roleSvc service.RoleService, artSvc service.ArticleService,
+	sessions ijwt.Handler, l logger.LoggerV1) *AdminHandler {
+	return &AdminHandler{
+		userSvc:  userSvc,
+		roleSvc:  roleSvc,
+		artSvc:   artSvc,
+		sessions: sessions,
+		l:        l,
+	}
+}
+
+func (h *AdminHandler) RegisterRouters(server *authz.Router) {
+	ag := server.Group("/admin")
+	// 版主和管理员都可以
+	mod := ag.With(authz.Roles(domain.RoleModerator, domain.RoleAdmin))
+	// 只有管理员可以
+	admin := ag.With(authz.Roles(domain.RoleAdmin))
+
+	// GET /admin/users?email=xxx 或者 ?phone=xxx
+	mod.GET("/users", h.SearchUser)
+	mod.GET("/users/:id", h.UserInfo)
+	mod.POST("/users/ban", h.Ban)
+	mod.POST("/users/unban", h.Unban)
+	mod.POST("/articles/takedown", h.TakeDownArticle)
+
+	admin.POST("/users/roles/grant", h.GrantRole)
+	admin.POST("/users/roles/revoke", h.RevokeRole)
+}
+
+// AdminUserVo 管理后台看到的用户信息
+type AdminUserVo struct {
+	Id       int64  `json:"id"`
+	Email    string `json:"email"`
+	Phone    string `json:"phone"`
+	Nickname string `json:"nickname"`
+	Status   uint8  `json:"status"`
+	// SuspendedUntil 封禁到什么时候，0 表示永久封禁
+	SuspendedUntil int64    `json:"suspendedUntil"`
+	Roles          []string `json:"roles"`
+	Ctime          int64    `json:"ctime"`
+}
+
+func (h *AdminHandler) UserInfo(ctx *gin.Context) {
+	uid, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "用户 id 不对",
+		})
+		return
+	}
+	u, err := h.userSvc.FindById(ctx, uid)
+	h.writeUser(ctx, u, err)
+}
+
+func (h *AdminHandler) SearchUser(ctx *gin.Context) {
+	var (
+		u   domain.User
+		err error
+	)
+	switch {
+	case ctx.Query("email") != "":
+		u, err = h.userSvc.FindByEmail(ctx, ctx.Query("email"))
+	case ctx.Query("phone") != "":
+		u, err = h.userSvc.FindByPhone(ctx, ctx.Query("phone"))
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "请输入邮箱或者手机号",
+		})
+		return
+	}
+	h.writeUser(ctx, u, err)
+}
+
+func (h *AdminHandler) writeUser(ctx *gin.Context, u domain.User, err error) {
+	switch err {
+	case nil:
+	case service.ErrUserNotFound:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "用户不存在",
+		})
+		return
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		h.l.Error("查询用户失败", logger.Error(err))
+		return
+	}
+	roles, err := h.roleSvc.Roles(ctx, u.Id)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		h.l.Error("查询用户角色失败", logger.Int64("uid", u.Id), logger.Error(err))
+		return
+	}
+	var suspendedUntil int64
+	if !u.SuspendedUntil.IsZero() {
+		suspendedUntil = u.SuspendedUntil.UnixMilli()
+	}
+	ctx.JSON(http.StatusOK, Result{
+		Data: AdminUserVo{
+			Id:             u.Id,
+			Email:          u.Email,
+			Phone:          u.Phone,
+			Nickname:       u.NickName,
+			Status:         uint8(u.Status),
+			SuspendedUntil: suspendedUntil,
+			Roles:          roles,
+			Ctime:          u.Ctime.UnixMilli(),
+		},
+	})
+}
+
+func (h *AdminHandler) Ban(ctx *gin.Context) {
+	type Req struct {
+		Uid    int64  `json:"uid"`
+		Reason string `json:"reason"`
+		// Until 封禁到什么时候，毫秒数，不传表示永久封禁
+		Until int64 `json:"until"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		return
+	}
+	var until time.Time
+	if req.Until > 0 {
+		until = time.UnixMilli(req.Until)
+		if !until.After(time.Now()) {
+			ctx.JSON(http.StatusOK, Result{
+				Code: 4,
+				Msg:  "封禁截止时间已经过去了",
+			})
+			return
+		}
+	}
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	if !h.canManage(ctx, uc, req.Uid) {
+		return
+	}
+	err := h.userSvc.Suspend(ctx, req.Uid, until)
+	if !h.writeUpdateResult(ctx, err) {
+		return
+	}
+	// 已经登录的设备全部下线，已经签发的 token 在下一次校验 ssid 的时候就不能用了
+	if err = h.sessions.RevokeOtherSessions(ctx, req.Uid, ""); err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		h.l.Error("封禁用户之后下线设备失败", logger.Int64("uid", req.Uid), logger.Error(err))
+		return
+	}
+	h.l.Info("封禁用户",
+		logger.Int64("operator", uc.Uid),
+		logger.Int64("uid", req.Uid),
+		logger.String("reason", req.Reason),
+		logger.Int64("until", req.Until))
+	ctx.JSON(http.StatusOK, Result{
+		Msg: "封禁成功",
+	})
+}
+
+func (h *AdminHandler) Unban(ctx *gin.Context) {
+	type Req struct {
+		Uid int64 `json:"uid"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		return
+	}
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	if !h.canManage(ctx, uc, req.Uid) {
+		return
+	}
+	err := h.userSvc.Unsuspend(ctx, req.Uid)
+	if !h.writeUpdateResult(ctx, err) {
+		return
+	}
+	h.l.Info("解封用户", logger.Int64("operator", uc.Uid), logger.Int64("uid", req.Uid))
+	ctx.JSON(http.StatusOK, Result{
+		Msg: "解封成功",
+	})
+}
+
+// canManage 不能封禁自己；版主不能封禁版主和管理员，只有管理员可以
+// 返回 false 的时候已经写好了响应
+func (h *AdminHandler) canManage(ctx *gin.Context, operator ijwt.UserClaims, uid int64) bool {
+	if uid <= 0 || uid == operator.Uid {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "不能操作这个用户",
+		})
+		return false
+	}
+	if authz.Roles(domain.RoleAdmin).Allow(operator.Roles) {
+		return true
+	}
+	roles, err := h.roleSvc.Roles(ctx, uid)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		h.l.Error("查询用户角色失败", logger.Int64("uid", uid), logger.Error(err))
+		return false
+	}
+	if authz.Roles(domain.RoleModerator, domain.RoleAdmin).Allow(roles) {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "没有权限操作版主和管理员",
+		})
+		return false
+	}
+	return true
+}
+
+// writeUpdateResult 出错的时候写好响应并返回 false
+func (h *AdminHandler) writeUpdateResult(ctx *gin.Context, err error) bool {
+	switch err {
+	case nil:
+		return true
+	case service.ErrUserNotFound:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "用户不存在",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		h.l.Error("修改用户状态失败", logger.Error(err))
+	}
+	return false
+}
+
+func (h *AdminHandler) TakeDownArticle(ctx *gin.Context) {
+	type Req struct {
+		Id     int64  `json:"id"`
+		Reason string `json:"reason"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		return
+	}
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	err := h.artSvc.TakeDown(ctx, req.Id)
+	switch err {
+	case nil:
+		h.l.Info("下架文章",
+			logger.Int64("operator", uc.Uid),
+			logger.Int64("art_id", req.Id),
+			logger.String("reason", req.Reason))
+		ctx.JSON(http.StatusOK, Result{
+			Msg: "下架成功",
+		})
+	case service.ErrArticleNotFound:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "文章不存在",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		h.l.Error("下架文章失败", logger.Int64("art_id", req.Id), logger.Error(err))
+	}
+}
+
+type roleReq struct {
+	Uid  int64  `json:"uid"`
+	Role string `json:"role"`
+}
+
+func (h *AdminHandler) GrantRole(ctx *gin.Context) {
+	var req roleReq
+	if err := ctx.Bind(&req); err != nil {
+		return
+	}
+	// 角色表没有外键，不检查的话可以给不存在或者已经注销的 uid 分配角色
+	u, err := h.userSvc.FindById(ctx, req.Uid)
+	switch {
+	case err == nil && u.Status != domain.UserStatusDeleted:
+	case err == nil || err == service.ErrUserNotFound:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "用户不存在",
+		})
+		return
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		h.l.Error("查询用户失败", logger.Int64("uid", req.Uid), logger.Error(err))
+		return
+	}
+	h.writeRoleResult(ctx, req, "分配角色", h.roleSvc.Grant(ctx, req.Uid, req.Role))
+}
+
+func (h *AdminHandler) RevokeRole(ctx *gin.Context) {
+	var req roleReq
+	if err := ctx.Bind(&req); err != nil {
+		return
+	}
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	if req.Uid == uc.Uid && req.Role == domain.RoleAdmin {
+		// 避免最后一个管理员把自己降级之后，没有人可以再分配角色
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "不能收回自己的管理员角色",
+		})
+		return
+	}
+	h.writeRoleResult(ctx, req, "收回角色", h.roleSvc.Revoke(ctx, req.Uid, req.Role))
+}
+
+func (h *AdminHandler) writeRoleResult(ctx *gin.Context, req roleReq, action string, err error) {
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	switch err {
+	case nil:
+		h.l.Info(action,
+			logger.Int64("operator", uc.Uid),
+			logger.Int64("uid", req.Uid),
+			logger.String("role", req.Role))
+		ctx.JSON(http.StatusOK, Result{
+			Msg: "OK",
+		})
+	case service.ErrRoleInvalid:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "角色不存在",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		h.l.Error(action+"失败", logger.Int64("uid", req.Uid), logger.Error(err))
+	}
+}
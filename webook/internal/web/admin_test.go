@@ -0,0 +1,241 @@
+package web
+
+import (
+	"Learn_Go/webook/internal/domain"
+	"Learn_Go/webook/internal/service"
+	svcmocks "Learn_Go/webook/internal/service/mocks"
+	ijwt "Learn_Go/webook/internal/web/jwt"
+	"Learn_Go/webook/pkg/ginx/authz"
+	"Learn_Go/webook/pkg/logger"
+	"bytes"
+	"encoding/json"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAdminHandler_Ban(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) (service.UserService, service.RoleService)
+		// 操作人的角色
+		roles   []string
+		reqBody string
+
+		wantRes Result
+		// 被封禁用户的 ssid 是不是已经作废了
+		wantRevoked bool
+	}{
+		{
+			name: "版主封禁普通用户",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.RoleService) {
+				userSvc := svcmocks.NewMockUserService(ctrl)
+				roleSvc := svcmocks.NewMockRoleService(ctrl)
+				roleSvc.EXPECT().Roles(gomock.Any(), int64(456)).Return([]string{domain.RoleUser}, nil)
+				userSvc.EXPECT().Suspend(gomock.Any(), int64(456), time.Time{}).Return(nil)
+				return userSvc, roleSvc
+			},
+			roles:       []string{domain.RoleUser, domain.RoleModerator},
+			reqBody:     `{"uid": 456, "reason": "发广告"}`,
+			wantRes:     Result{Msg: "封禁成功"},
+			wantRevoked: true,
+		},
+		{
+			name: "版主不能封禁管理员",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.RoleService) {
+				userSvc := svcmocks.NewMockUserService(ctrl)
+				roleSvc := svcmocks.NewMockRoleService(ctrl)
+				roleSvc.EXPECT().Roles(gomock.Any(), int64(456)).Return([]string{domain.RoleUser, domain.RoleAdmin}, nil)
+				return userSvc, roleSvc
+			},
+			roles:   []string{domain.RoleUser, domain.RoleModerator},
+			reqBody: `{"uid": 456}`,
+			wantRes: Result{Code: 4, Msg: "没有权限操作版主和管理员"},
+		},
+		{
+			name: "管理员可以封禁版主",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.RoleService) {
+				userSvc := svcmocks.NewMockUserService(ctrl)
+				roleSvc := svcmocks.NewMockRoleService(ctrl)
+				userSvc.EXPECT().Suspend(gomock.Any(), int64(456), time.Time{}).Return(nil)
+				return userSvc, roleSvc
+			},
+			roles:       []string{domain.RoleUser, domain.RoleAdmin},
+			reqBody:     `{"uid": 456}`,
+			wantRes:     Result{Msg: "封禁成功"},
+			wantRevoked: true,
+		},
+		{
+			name: "不能封禁自己",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.RoleService) {
+				return svcmocks.NewMockUserService(ctrl), svcmocks.NewMockRoleService(ctrl)
+			},
+			roles:   []string{domain.RoleUser, domain.RoleAdmin},
+			reqBody: `{"uid": 123}`,
+			wantRes: Result{Code: 4, Msg: "不能操作这个用户"},
+		},
+		{
+			name: "用户不存在",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.RoleService) {
+				userSvc := svcmocks.NewMockUserService(ctrl)
+				roleSvc := svcmocks.NewMockRoleService(ctrl)
+				userSvc.EXPECT().Suspend(gomock.Any(), int64(456), time.Time{}).Return(service.ErrUserNotFound)
+				return userSvc, roleSvc
+			},
+			roles:   []string{domain.RoleUser, domain.RoleAdmin},
+			reqBody: `{"uid": 456}`,
+			wantRes: Result{Code: 4, Msg: "用户不存在"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mr := miniredis.RunT(t)
+			client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			// 被封禁的用户已经在一个设备上登录了
+			mr.ZAdd("users:sessions:456", 1, "ssid-456")
+
+			userSvc, roleSvc := tc.mock(ctrl)
+			h := NewAdminHandler(userSvc, roleSvc, svcmocks.NewMockArticleService(ctrl),
+				ijwt.NewRedisJWTHandler(client, ijwt.NewKeyManager(0), 0), logger.NewNopLogger())
+			server := gin.New()
+			server.Use(func(ctx *gin.Context) {
+				ctx.Set("user", ijwt.UserClaims{Uid: 123, Roles: tc.roles})
+			})
+			h.RegisterRouters(authz.NewRouter(server, authz.NewRegistry()))
+
+			req, err := http.NewRequest(http.MethodPost, "/admin/users/ban", bytes.NewReader([]byte(tc.reqBody)))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			var res Result
+			require.NoError(t, json.NewDecoder(recorder.Body).Decode(&res))
+			assert.Equal(t, tc.wantRes, res)
+			assert.Equal(t, tc.wantRevoked, mr.Exists("users:ssid:ssid-456"))
+		})
+	}
+}
+
+func TestAdminHandler_TakeDownArticle(t *testing.T) {
+	testCases := []struct {
+		name    string
+		mock    func(ctrl *gomock.Controller) service.ArticleService
+		wantRes Result
+	}{
+		{
+			name: "下架成功",
+			mock: func(ctrl *gomock.Controller) service.ArticleService {
+				svc := svcmocks.NewMockArticleService(ctrl)
+				svc.EXPECT().TakeDown(gomock.Any(), int64(1)).Return(nil)
+				return svc
+			},
+			wantRes: Result{Msg: "下架成功"},
+		},
+		{
+			name: "文章不存在",
+			mock: func(ctrl *gomock.Controller) service.ArticleService {
+				svc := svcmocks.NewMockArticleService(ctrl)
+				svc.EXPECT().TakeDown(gomock.Any(), int64(1)).Return(service.ErrArticleNotFound)
+				return svc
+			},
+			wantRes: Result{Code: 4, Msg: "文章不存在"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			h := NewAdminHandler(svcmocks.NewMockUserService(ctrl), svcmocks.NewMockRoleService(ctrl), tc.mock(ctrl),
+				nil, logger.NewNopLogger())
+			server := gin.New()
+			server.Use(func(ctx *gin.Context) {
+				ctx.Set("user", ijwt.UserClaims{Uid: 123, Roles: []string{domain.RoleModerator}})
+			})
+			h.RegisterRouters(authz.NewRouter(server, authz.NewRegistry()))
+
+			req, err := http.NewRequest(http.MethodPost, "/admin/articles/takedown", bytes.NewReader([]byte(`{"id": 1}`)))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			var res Result
+			require.NoError(t, json.NewDecoder(recorder.Body).Decode(&res))
+			assert.Equal(t, tc.wantRes, res)
+		})
+	}
+}
+
+func TestAdminHandler_GrantRole(t *testing.T) {
+	testCases := []struct {
+		name    string
+		mock    func(ctrl *gomock.Controller) (service.UserService, service.RoleService)
+		wantRes Result
+	}{
+		{
+			name: "分配成功",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.RoleService) {
+				userSvc := svcmocks.NewMockUserService(ctrl)
+				roleSvc := svcmocks.NewMockRoleService(ctrl)
+				userSvc.EXPECT().FindById(gomock.Any(), int64(456)).Return(domain.User{Id: 456}, nil)
+				roleSvc.EXPECT().Grant(gomock.Any(), int64(456), domain.RoleModerator).Return(nil)
+				return userSvc, roleSvc
+			},
+			wantRes: Result{Msg: "OK"},
+		},
+		{
+			name: "用户不存在",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.RoleService) {
+				userSvc := svcmocks.NewMockUserService(ctrl)
+				userSvc.EXPECT().FindById(gomock.Any(), int64(456)).Return(domain.User{}, service.ErrUserNotFound)
+				return userSvc, svcmocks.NewMockRoleService(ctrl)
+			},
+			wantRes: Result{Code: 4, Msg: "用户不存在"},
+		},
+		{
+			name: "用户已经注销",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.RoleService) {
+				userSvc := svcmocks.NewMockUserService(ctrl)
+				userSvc.EXPECT().FindById(gomock.Any(), int64(456)).
+					Return(domain.User{Id: 456, Status: domain.UserStatusDeleted}, nil)
+				return userSvc, svcmocks.NewMockRoleService(ctrl)
+			},
+			wantRes: Result{Code: 4, Msg: "用户不存在"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			userSvc, roleSvc := tc.mock(ctrl)
+			h := NewAdminHandler(userSvc, roleSvc, svcmocks.NewMockArticleService(ctrl), nil, logger.NewNopLogger())
+			server := gin.New()
+			server.Use(func(ctx *gin.Context) {
+				ctx.Set("user", ijwt.UserClaims{Uid: 123, Roles: []string{domain.RoleAdmin}})
+			})
+			h.RegisterRouters(authz.NewRouter(server, authz.NewRegistry()))
+
+			req, err := http.NewRequest(http.MethodPost, "/admin/users/roles/grant",
+				bytes.NewReader([]byte(`{"uid": 456, "role": "moderator"}`)))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			var res Result
+			require.NoError(t, json.NewDecoder(recorder.Body).Decode(&res))
+			assert.Equal(t, tc.wantRes, res)
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"Learn_Go/webook/internal/domain"
 	"Learn_Go/webook/internal/service"
 	"Learn_Go/webook/internal/web/jwt"
+	"Learn_Go/webook/pkg/ginx/authz"
 	"Learn_Go/webook/pkg/logger"
 	"github.com/gin-gonic/gin"
 	"net/http"
@@ -21,9 +22,9 @@ func NewArticleHandler(svc service.ArticleService, l logger.LoggerV1) *ArticleHa
 	}
 }
 
-func (h *ArticleHandler) RegisterRouters(server *gin.Engine) {
+func (h *ArticleHandler) RegisterRouters(server *authz.Router) {
 
-	g := server.Group("/articles")
+	g := server.Group("/articles").With(authz.Login())
 	g.POST("/edit", h.Edit)
 	g.POST("/publish", h.Publish)
 
@@ -5,6 +5,7 @@ import (
 	"Learn_Go/webook/internal/service"
 	svcmocks "Learn_Go/webook/internal/service/mocks"
 	ijwt "Learn_Go/webook/internal/web/jwt"
+	"Learn_Go/webook/pkg/ginx/authz"
 	"Learn_Go/webook/pkg/logger"
 	"bytes"
 	"encoding/json"
@@ -139,7 +140,7 @@ func TestArticleHandler_Publish(t *testing.T) {
 					Uid: 123,
 				})
 			})
-			artHdl.RegisterRouters(server)
+			artHdl.RegisterRouters(authz.NewRouter(server, authz.NewRegistry()))
 
 			req, err := http.NewRequest(http.MethodPost,
 				"/articles/publish",
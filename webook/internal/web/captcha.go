@@ -0,0 +1,102 @@
+package web
+
+import (
+	"Learn_Go/webook/internal/service"
+	"Learn_Go/webook/pkg/ginx/authz"
+	"encoding/base64"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+// 需要图形验证码的业务
+const (
+	captchaBizLogin    = "login"
+	captchaBizLoginSMS = "login_sms"
+	// 忘记密码的时候发送验证码
+	captchaBizResetPassword = "reset_password"
+	// 登录之后修改密码，防止拿到登录态的人暴力试原密码
+	captchaBizChangePassword = "change_password"
+	// 告诉前端需要先完成图形验证码
+	captchaRequired = "captcha_required"
+)
+
+type CaptchaHandler struct {
+	svc service.CaptchaService
+}
+
+func NewCaptchaHandler(svc service.CaptchaService) *CaptchaHandler {
+	return &CaptchaHandler{
+		svc: svc,
+	}
+}
+
+func (h *CaptchaHandler) RegisterRouters(server *authz.Router) {
+	g := server.Group("/captcha").With(authz.Public())
+	g.GET("/generate", h.Generate)
+	g.POST("/verify", h.Verify)
+}
+
+func (h *CaptchaHandler) Generate(ctx *gin.Context) {
+	c, err := h.svc.Generate(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		zap.L().Error("生成图形验证码失败", zap.Error(err))
+		return
+	}
+	type Captcha struct {
+		Id    string `json:"id"`
+		Image string `json:"image"`
+	}
+	ctx.JSON(http.StatusOK, Result{
+		Data: Captcha{
+			Id: c.Id,
+			// 直接返回 data URI，前端可以放在 img 的 src 里面
+			Image: "data:image/png;base64," + base64.StdEncoding.EncodeToString(c.Image),
+		},
+	})
+}
+
+func (h *CaptchaHandler) Verify(ctx *gin.Context) {
+	type Req struct {
+		Id     string `json:"id"`
+		Answer string `json:"answer"`
+		Biz    string `json:"biz"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		return
+	}
+	switch req.Biz {
+	case captchaBizLogin, captchaBizLoginSMS, captchaBizResetPassword, captchaBizChangePassword:
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "非法请求",
+		})
+		return
+	}
+
+	ticket, err := h.svc.Verify(ctx, req.Biz, req.Id, req.Answer)
+	switch err {
+	case nil:
+		ctx.JSON(http.StatusOK, Result{
+			Msg:  "验证成功",
+			Data: ticket,
+		})
+	case service.ErrCaptchaWrong, service.ErrCaptchaNotFound:
+		// 答案只能校验一次，前端需要重新获取图形验证码
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "图形验证码错误，请刷新后重试",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+	}
+}
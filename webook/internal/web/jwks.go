@@ -0,0 +1,30 @@
+package web
+
+import (
+	ijwt "Learn_Go/webook/internal/web/jwt"
+	"Learn_Go/webook/pkg/ginx/authz"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// JWKSHandler 公开 JWT 的验证公钥，其它内部服务可以用来验证 webook 签发的 token
+type JWKSHandler struct {
+	keys *ijwt.KeyManager
+}
+
+func NewJWKSHandler(keys *ijwt.KeyManager) *JWKSHandler {
+	return &JWKSHandler{
+		keys: keys,
+	}
+}
+
+func (h *JWKSHandler) RegisterRouters(server *authz.Router) {
+	server.With(authz.Public()).GET("/.well-known/jwks.json", h.JWKS)
+}
+
+func (h *JWKSHandler) JWKS(ctx *gin.Context) {
+	// 密钥轮换之后，其它服务最多晚这么久拿到新的公钥，所以新的密钥要有生效延迟
+	ctx.Header("Cache-Control", "public, max-age=60")
+	// 这里是标准格式，不使用 Result 包装
+	ctx.JSON(http.StatusOK, h.keys.JWKS())
+}
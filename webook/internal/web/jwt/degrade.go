@@ -0,0 +1,81 @@
+package jwt
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"strings"
+)
+
+// 降级判定的结果，作为监控的 label
+const (
+	decisionAllow       = "allow"
+	decisionRevoked     = "deny_revoked"
+	decisionSensitive   = "deny_sensitive"
+	decisionFailClosed  = "deny_fail_closed"
+	degradedDecisionKey = "decision"
+)
+
+// SessionDegradePolicy Redis 不可用的时候，CheckSession 怎么处理
+type SessionDegradePolicy struct {
+	// FailOpen 为 true 的时候，只要 ssid 不在 Revoked 里面就放行
+	// 为 false 的时候和原来一样，Redis 出错就拒绝，所有用户都要重新登录
+	FailOpen bool
+	Revoked  *LocalRevokedSessions
+	// SensitivePaths 敏感操作一直是 fail-closed，可以写注册路由时候的路径，以 /** 结尾表示匹配前缀
+	SensitivePaths []string
+	// Degraded 降级判定的次数，可以为 nil
+	Degraded *prometheus.CounterVec
+}
+
+// NewDegradedCounter 降级判定的监控，label 是 decision
+func NewDegradedCounter() *prometheus.CounterVec {
+	return prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "webook",
+		Subsystem: "session",
+		Name:      "check_degraded_total",
+		Help:      "Redis 不可用的时候，校验 ssid 的降级判定次数",
+	}, []string{degradedDecisionKey})
+}
+
+func (p *SessionDegradePolicy) sensitive(ctx *gin.Context) bool {
+	for _, sp := range p.SensitivePaths {
+		if sp == ctx.FullPath() || sp == ctx.Request.URL.Path {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(sp, "/**"); ok &&
+			(ctx.Request.URL.Path == prefix || strings.HasPrefix(ctx.Request.URL.Path, prefix+"/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// Degrade 设置 Redis 不可用时候的降级策略
+func (h *RedisJWTHandler) Degrade(policy *SessionDegradePolicy) *RedisJWTHandler {
+	h.degrade = policy
+	return h
+}
+
+// checkSessionDegraded Redis 出错的时候判定 ssid 是否有效，err 是 Redis 返回的错误
+func (h *RedisJWTHandler) checkSessionDegraded(ctx *gin.Context, ssid string, err error) error {
+	p := h.degrade
+	if p == nil {
+		return err
+	}
+	decision := decisionFailClosed
+	switch {
+	case !p.FailOpen:
+	case p.sensitive(ctx):
+		decision = decisionSensitive
+	case p.Revoked != nil && p.Revoked.Contains(ssid):
+		decision = decisionRevoked
+		err = ErrSessionRevoked
+	default:
+		decision = decisionAllow
+		err = nil
+	}
+	if p.Degraded != nil {
+		p.Degraded.WithLabelValues(decision).Inc()
+	}
+	return err
+}
@@ -0,0 +1,69 @@
+package jwt
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRedisJWTHandler_CheckSessionDegraded(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	keys := NewKeyManager(0)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// 两个实例，a 上面作废的 ssid，通过 pub/sub 同步到 b
+	newHandler := func() *RedisJWTHandler {
+		revoked := NewLocalRevokedSessions(100, time.Hour)
+		revoked.Subscribe(ctx, client)
+		return NewRedisJWTHandler(client, keys, 0).Degrade(&SessionDegradePolicy{
+			FailOpen:       true,
+			Revoked:        revoked,
+			SensitivePaths: []string{"/users/edit", "/admin/**"},
+			Degraded:       NewDegradedCounter(),
+		})
+	}
+	a, b := newHandler(), newHandler()
+	// 等订阅生效
+	assert.Eventually(t, func() bool {
+		return mr.PubSubNumSub(revokedSsidChannel)[revokedSsidChannel] == 2
+	}, time.Second, time.Millisecond*10)
+	require.NoError(t, a.revokeSession(ctx, 123, "revoked"))
+	assert.Eventually(t, func() bool {
+		return b.degrade.Revoked.Contains("revoked")
+	}, time.Second, time.Millisecond*10)
+
+	check := func(h *RedisJWTHandler, path, ssid string) error {
+		gctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		gctx.Request = httptest.NewRequest(http.MethodPost, path, nil)
+		return h.CheckSession(gctx, ssid)
+	}
+	// Redis 正常的时候以 Redis 为准
+	assert.Equal(t, ErrSessionRevoked, check(b, "/users/profile", "revoked"))
+	assert.NoError(t, check(b, "/users/profile", "normal"))
+
+	// Redis 崩溃了
+	mr.Close()
+	assert.NoError(t, check(b, "/users/profile", "normal"))
+	assert.Equal(t, ErrSessionRevoked, check(b, "/users/profile", "revoked"))
+	assert.Error(t, check(b, "/users/edit", "normal"))
+	assert.Error(t, check(b, "/admin/users/1", "normal"))
+	counter := b.degrade.Degraded
+	assert.Equal(t, float64(1), testutil.ToFloat64(counter.WithLabelValues(decisionAllow)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(counter.WithLabelValues(decisionRevoked)))
+	assert.Equal(t, float64(2), testutil.ToFloat64(counter.WithLabelValues(decisionSensitive)))
+
+	// fail-closed 的时候全部拒绝
+	b.degrade.FailOpen = false
+	assert.Error(t, check(b, "/users/profile", "normal"))
+	assert.Equal(t, float64(1), testutil.ToFloat64(counter.WithLabelValues(decisionFailClosed)))
+}
@@ -0,0 +1,165 @@
+package jwt
+
+import (
+	"errors"
+	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// KeyRotator 加载密钥，并且定时轮换
+// 密钥有两个来源：配置里面写死的密钥，以及 dir 目录下面的 <kid>.pem 文件（文件的修改时间就是密钥的创建时间）
+// 多个实例挂载同一个目录（比如共享存储）就可以共享密钥，任何一个实例轮换之后，其它实例下一次加载就能拿到
+type KeyRotator struct {
+	manager *KeyManager
+	// 配置里面写死的密钥，不会被轮换，也不会被删除
+	static []*Key
+	dir    string
+	// 轮换时生成的新密钥使用的算法
+	alg string
+	// 多久生成一个新的密钥，0 表示不自动轮换
+	interval time.Duration
+	// 旧密钥被替换之后还要保留多久用来验证，至少是 token 的最长有效期
+	retention time.Duration
+}
+
+func NewKeyRotator(manager *KeyManager, static []*Key, dir string, alg string,
+	interval time.Duration, retention time.Duration) *KeyRotator {
+	return &KeyRotator{
+		manager:   manager,
+		static:    static,
+		dir:       dir,
+		alg:       alg,
+		interval:  interval,
+		retention: retention,
+	}
+}
+
+// Reload 重新加载密钥，需要的话生成新的密钥，并且删掉已经不再需要的旧密钥
+func (r *KeyRotator) Reload() error {
+	keys, err := r.loadDir()
+	if err != nil {
+		return err
+	}
+	now := r.manager.now()
+	if r.needRotate(keys, now) {
+		key, err := GenerateKey(r.alg, now)
+		if err != nil {
+			return err
+		}
+		if err = r.save(key); err != nil {
+			return err
+		}
+		zap.L().Info("生成了新的 JWT 签名密钥", zap.String("kid", key.Kid))
+		keys = append(keys, key)
+	}
+	keys = r.prune(keys, now)
+	return r.manager.SetKeys(append(keys, r.static...))
+}
+
+// Start 定时重新加载
+func (r *KeyRotator) Start(reloadInterval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(reloadInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := r.Reload(); err != nil {
+				zap.L().Error("加载 JWT 密钥失败", zap.Error(err))
+			}
+		}
+	}()
+}
+
+func (r *KeyRotator) needRotate(keys []*Key, now time.Time) bool {
+	if r.dir == "" {
+		return false
+	}
+	if len(keys) == 0 && len(r.static) == 0 {
+		// 第一次启动，目录是空的
+		return true
+	}
+	if r.interval <= 0 {
+		return false
+	}
+	for _, k := range keys {
+		if now.Sub(k.CreatedAt) < r.interval {
+			return false
+		}
+	}
+	return true
+}
+
+// prune 删掉已经被替换超过 retention 的密钥，这些密钥签名的 token 都已经过期了
+func (r *KeyRotator) prune(keys []*Key, now time.Time) []*Key {
+	if r.interval <= 0 {
+		// 没有开启自动轮换，目录里面的密钥由运维自己管理
+		return keys
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+	res := make([]*Key, 0, len(keys))
+	for i, k := range keys {
+		// 下一个密钥生效的时候，这个密钥就不再用来签名了
+		if i < len(keys)-1 && now.Sub(keys[i+1].CreatedAt.Add(r.manager.activationDelay)) >= r.retention {
+			err := os.Remove(filepath.Join(r.dir, k.Kid+".pem"))
+			if err == nil || errors.Is(err, os.ErrNotExist) {
+				zap.L().Info("删除了过期的 JWT 签名密钥", zap.String("kid", k.Kid))
+				continue
+			}
+			zap.L().Error("删除 JWT 签名密钥失败", zap.String("kid", k.Kid), zap.Error(err))
+		}
+		res = append(res, k)
+	}
+	return res
+}
+
+func (r *KeyRotator) loadDir() ([]*Key, error) {
+	if r.dir == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return nil, err
+	}
+	var res []*Key
+	for _, e := range entries {
+		kid, ok := strings.CutSuffix(e.Name(), ".pem")
+		if e.IsDir() || !ok {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(filepath.Join(r.dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		key, err := ParseKeyPEM(kid, data, info.ModTime())
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, key)
+	}
+	return res, nil
+}
+
+// save 先写临时文件再改名，避免别的实例读到写了一半的文件
+func (r *KeyRotator) save(key *Key) error {
+	data, err := key.MarshalPEM()
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(r.dir, "."+key.Kid+".tmp")
+	if err = os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if err = os.Chtimes(tmp, key.CreatedAt, key.CreatedAt); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(r.dir, key.Kid+".pem"))
+}
@@ -0,0 +1,277 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 支持的签名算法，都是非对称的，其它服务只需要公钥就可以验证 webook 的 token
+const (
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// token header 里面的 typ，避免一种 token 被当成另一种使用，比如长 token 被当成短 token
+const (
+	typAccess  = "at+jwt"
+	typRefresh = "refresh+jwt"
+	// 密码验证通过、还需要两步验证的时候签发
+	typMFA = "mfa+jwt"
+)
+
+var (
+	ErrNoSigningKey   = errors.New("没有可用的签名密钥")
+	ErrUnknownKid     = errors.New("未知的 kid")
+	ErrTokenTypeWrong = errors.New("token 类型不对")
+)
+
+// Key 一个签名密钥，kid 会放在 token 的 header 里面，验证的时候根据 kid 找到对应的公钥
+type Key struct {
+	Kid     string
+	Method  jwt.SigningMethod
+	Private crypto.Signer
+	// CreatedAt 新的密钥要过了生效延迟之后才会用来签名
+	CreatedAt time.Time
+}
+
+func NewKey(kid string, private crypto.Signer, createdAt time.Time) (*Key, error) {
+	if kid == "" {
+		return nil, errors.New("kid 不能为空")
+	}
+	var method jwt.SigningMethod
+	switch private.(type) {
+	case *rsa.PrivateKey:
+		method = jwt.SigningMethodRS256
+	case ed25519.PrivateKey:
+		method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("密钥 %s 的类型 %T 不支持", kid, private)
+	}
+	return &Key{
+		Kid:       kid,
+		Method:    method,
+		Private:   private,
+		CreatedAt: createdAt,
+	}, nil
+}
+
+// GenerateKey 生成一个新的密钥，kid 里面带上创建时间，方便排查问题
+func GenerateKey(alg string, now time.Time) (*Key, error) {
+	var (
+		private crypto.Signer
+		err     error
+	)
+	switch alg {
+	case AlgRS256:
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	case AlgEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("签名算法 %s 不支持", alg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	suffix := make([]byte, 4)
+	if _, err = rand.Read(suffix); err != nil {
+		return nil, err
+	}
+	kid := fmt.Sprintf("%s-%x", now.UTC().Format("20060102150405"), suffix)
+	return NewKey(kid, private, now)
+}
+
+// ParseKeyPEM 解析 PEM 格式的私钥，支持 PKCS8，RSA 也支持 PKCS1
+func ParseKeyPEM(kid string, data []byte, createdAt time.Time) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("密钥 %s 不是 PEM 格式", kid)
+	}
+	var (
+		private any
+		err     error
+	)
+	if block.Type == "RSA PRIVATE KEY" {
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	} else {
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析密钥 %s 失败 %w", kid, err)
+	}
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("密钥 %s 的类型 %T 不支持", kid, private)
+	}
+	return NewKey(kid, signer, createdAt)
+}
+
+// MarshalPEM 以 PKCS8 格式导出私钥
+func (k *Key) MarshalPEM() ([]byte, error) {
+	data, err := x509.MarshalPKCS8PrivateKey(k.Private)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: data}), nil
+}
+
+// JWK 公钥的 JSON Web Key 表示，见 RFC 7517、RFC 8037
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+func (k *Key) JWK() JWK {
+	res := JWK{
+		Kid: k.Kid,
+		Use: "sig",
+		Alg: k.Method.Alg(),
+	}
+	switch pub := k.Private.Public().(type) {
+	case *rsa.PublicKey:
+		res.Kty = "RSA"
+		res.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		res.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case ed25519.PublicKey:
+		res.Kty = "OKP"
+		res.Crv = "Ed25519"
+		res.X = base64.RawURLEncoding.EncodeToString(pub)
+	}
+	return res
+}
+
+// KeyManager 管理签名密钥
+// 用最新的、已经生效的密钥签名；所有的密钥都可以用来验证，这样轮换密钥之后，旧的 token 在过期之前还能用
+type KeyManager struct {
+	lock sync.RWMutex
+	// 按照创建时间排序，最新的在最后
+	keys []*Key
+	// 新的密钥要等所有实例都加载之后才能用来签名，否则别的实例验证不了
+	activationDelay time.Duration
+	now             func() time.Time
+}
+
+func NewKeyManager(activationDelay time.Duration) *KeyManager {
+	return &KeyManager{
+		activationDelay: activationDelay,
+		now:             time.Now,
+	}
+}
+
+// SetKeys 替换全部密钥
+func (m *KeyManager) SetKeys(keys []*Key) error {
+	if len(keys) == 0 {
+		return ErrNoSigningKey
+	}
+	res := make([]*Key, len(keys))
+	copy(res, keys)
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].CreatedAt.Equal(res[j].CreatedAt) {
+			return res[i].Kid < res[j].Kid
+		}
+		return res[i].CreatedAt.Before(res[j].CreatedAt)
+	})
+	m.lock.Lock()
+	m.keys = res
+	m.lock.Unlock()
+	return nil
+}
+
+func (m *KeyManager) Keys() []*Key {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.keys
+}
+
+// signingKey 最新的已经生效的密钥，如果都还没有生效，用最老的那个
+func (m *KeyManager) signingKey() (*Key, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	if len(m.keys) == 0 {
+		return nil, ErrNoSigningKey
+	}
+	now := m.now()
+	for i := len(m.keys) - 1; i >= 0; i-- {
+		if !m.keys[i].CreatedAt.Add(m.activationDelay).After(now) {
+			return m.keys[i], nil
+		}
+	}
+	return m.keys[0], nil
+}
+
+func (m *KeyManager) sign(claims jwt.Claims, typ string) (string, error) {
+	key, err := m.signingKey()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.Kid
+	token.Header["typ"] = typ
+	return token.SignedString(key.Private)
+}
+
+func (m *KeyManager) parse(tokenStr string, typ string, claims jwt.Claims) error {
+	token, err := jwt.ParseWithClaims(tokenStr, claims, m.keyFunc,
+		jwt.WithValidMethods([]string{AlgRS256, AlgEdDSA}))
+	if err != nil {
+		return err
+	}
+	if !token.Valid {
+		return jwt.ErrTokenInvalidClaims
+	}
+	if t, _ := token.Header["typ"].(string); t != typ {
+		return ErrTokenTypeWrong
+	}
+	return nil
+}
+
+func (m *KeyManager) keyFunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	for _, k := range m.keys {
+		if k.Kid != kid {
+			continue
+		}
+		// 防止用别的算法伪造
+		if k.Method.Alg() != token.Method.Alg() {
+			return nil, fmt.Errorf("kid %s 的算法是 %s", kid, k.Method.Alg())
+		}
+		return k.Private.Public(), nil
+	}
+	return nil, ErrUnknownKid
+}
+
+// JWKS 所有可以用来验证的公钥
+func (m *KeyManager) JWKS() JWKS {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	res := JWKS{Keys: make([]JWK, 0, len(m.keys))}
+	for _, k := range m.keys {
+		res.Keys = append(res.Keys, k.JWK())
+	}
+	return res
+}
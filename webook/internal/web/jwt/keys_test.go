@@ -0,0 +1,124 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestKeyManager_SignAndParse(t *testing.T) {
+	for _, alg := range []string{AlgRS256, AlgEdDSA} {
+		t.Run(alg, func(t *testing.T) {
+			key, err := GenerateKey(alg, time.Now())
+			require.NoError(t, err)
+			m := NewKeyManager(0)
+			require.NoError(t, m.SetKeys([]*Key{key}))
+
+			uc := UserClaims{
+				Uid: 123,
+				RegisteredClaims: jwt.RegisteredClaims{
+					ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+				},
+			}
+			tokenStr, err := m.sign(uc, typAccess)
+			require.NoError(t, err)
+
+			var res UserClaims
+			require.NoError(t, m.parse(tokenStr, typAccess, &res))
+			assert.Equal(t, int64(123), res.Uid)
+
+			// 短 token 不能当成长 token 使用
+			var rc RefreshClaims
+			assert.Equal(t, ErrTokenTypeWrong, m.parse(tokenStr, typRefresh, &rc))
+
+			// 别的密钥签名的 token 验证不了
+			other, err := GenerateKey(alg, time.Now())
+			require.NoError(t, err)
+			m2 := NewKeyManager(0)
+			require.NoError(t, m2.SetKeys([]*Key{other}))
+			assert.ErrorIs(t, m2.parse(tokenStr, typAccess, &res), ErrUnknownKid)
+		})
+	}
+}
+
+func TestKeyManager_Rotation(t *testing.T) {
+	now := time.Now()
+	m := NewKeyManager(time.Minute)
+	m.now = func() time.Time {
+		return now
+	}
+	oldKey, err := GenerateKey(AlgEdDSA, now.Add(-time.Hour))
+	require.NoError(t, err)
+	require.NoError(t, m.SetKeys([]*Key{oldKey}))
+	oldToken, err := m.sign(RefreshClaims{Uid: 1}, typRefresh)
+	require.NoError(t, err)
+
+	// 新的密钥还没有生效，继续用旧的密钥签名
+	newKey, err := GenerateKey(AlgRS256, now)
+	require.NoError(t, err)
+	require.NoError(t, m.SetKeys([]*Key{oldKey, newKey}))
+	key, err := m.signingKey()
+	require.NoError(t, err)
+	assert.Equal(t, oldKey.Kid, key.Kid)
+
+	// 生效之后用新的密钥签名，旧的 token 还能验证
+	now = now.Add(time.Minute)
+	key, err = m.signingKey()
+	require.NoError(t, err)
+	assert.Equal(t, newKey.Kid, key.Kid)
+	var rc RefreshClaims
+	assert.NoError(t, m.parse(oldToken, typRefresh, &rc))
+
+	// JWKS 里面两个公钥都有
+	jwks := m.JWKS()
+	require.Len(t, jwks.Keys, 2)
+	assert.Equal(t, "OKP", jwks.Keys[0].Kty)
+	x, err := base64.RawURLEncoding.DecodeString(jwks.Keys[0].X)
+	require.NoError(t, err)
+	assert.Equal(t, oldKey.Private.Public(), ed25519.PublicKey(x))
+	assert.Equal(t, "RSA", jwks.Keys[1].Kty)
+	assert.Equal(t, "AQAB", jwks.Keys[1].E)
+}
+
+func TestKeyRotator(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	m := NewKeyManager(time.Minute)
+	m.now = func() time.Time {
+		return now
+	}
+	r := NewKeyRotator(m, nil, dir, AlgEdDSA, time.Hour*24, time.Hour*24*8)
+
+	// 目录是空的，生成第一个密钥
+	require.NoError(t, r.Reload())
+	require.Len(t, m.Keys(), 1)
+	first := m.Keys()[0]
+
+	// 还没到轮换的时间
+	now = now.Add(time.Hour)
+	require.NoError(t, r.Reload())
+	assert.Len(t, m.Keys(), 1)
+
+	// 到了轮换的时间，生成新的密钥，另外一个实例加载同一个目录也能拿到
+	now = now.Add(time.Hour * 24)
+	require.NoError(t, r.Reload())
+	assert.Len(t, m.Keys(), 2)
+	other := NewKeyManager(time.Minute)
+	require.NoError(t, NewKeyRotator(other, nil, dir, AlgEdDSA, 0, 0).Reload())
+	assert.Len(t, other.Keys(), 2)
+
+	// 旧的密钥被替换超过 retention 之后删掉，同时又轮换出一个新的密钥
+	now = now.Add(time.Hour*24*8 + time.Hour)
+	require.NoError(t, r.Reload())
+	assert.Len(t, m.Keys(), 2)
+	for _, k := range m.Keys() {
+		assert.NotEqual(t, first.Kid, k.Kid)
+	}
+	_, err := os.Stat(dir + "/" + first.Kid + ".pem")
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,96 @@
+package jwt
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"time"
+)
+
+// ErrMFAChallengeInvalid 两步验证的凭证过期了、用过了，或者验证码错误次数太多，需要重新输入密码
+var ErrMFAChallengeInvalid = errors.New("两步验证凭证无效")
+
+const (
+	mfaChallengeExpiration = time.Minute * 5
+	// 一个凭证最多尝试几次验证码，防止在有效期内暴力破解 6 位数字
+	mfaMaxAttempts = 5
+)
+
+// MFAClaims 密码验证通过之后签发，只能用来完成两步验证，不能访问其它接口
+type MFAClaims struct {
+	jwt.RegisteredClaims
+	Uid       int64
+	UserAgent string
+}
+
+func (h *RedisJWTHandler) mfaUsedKey(jti string) string {
+	return fmt.Sprintf("users:mfa:used:%s", jti)
+}
+
+func (h *RedisJWTHandler) mfaAttemptsKey(jti string) string {
+	return fmt.Sprintf("users:mfa:attempts:%s", jti)
+}
+
+func (h *RedisJWTHandler) SetMFAChallengeToken(ctx *gin.Context, uid int64) (string, error) {
+	mc := MFAClaims{
+		Uid:       uid,
+		UserAgent: ctx.GetHeader("User-Agent"),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(mfaChallengeExpiration)),
+		},
+	}
+	return h.keys.sign(mc, typMFA)
+}
+
+func (h *RedisJWTHandler) ParseMFAChallengeToken(ctx *gin.Context, tokenStr string) (MFAClaims, error) {
+	var mc MFAClaims
+	if err := h.keys.parse(tokenStr, typMFA, &mc); err != nil {
+		return MFAClaims{}, err
+	}
+	if mc.ID == "" || mc.UserAgent != ctx.GetHeader("User-Agent") {
+		return MFAClaims{}, ErrMFAChallengeInvalid
+	}
+	cnt, err := h.client.Exists(ctx, h.mfaUsedKey(mc.ID)).Result()
+	if err != nil {
+		return MFAClaims{}, err
+	}
+	if cnt > 0 {
+		return MFAClaims{}, ErrMFAChallengeInvalid
+	}
+	return mc, nil
+}
+
+func (h *RedisJWTHandler) RecordMFAFailure(ctx *gin.Context, mc MFAClaims) error {
+	key := h.mfaAttemptsKey(mc.ID)
+	cnt, err := h.client.Incr(ctx, key).Result()
+	if err != nil {
+		return err
+	}
+	if cnt == 1 {
+		if err = h.client.Expire(ctx, key, mfaChallengeExpiration).Err(); err != nil {
+			return err
+		}
+	}
+	if cnt < mfaMaxAttempts {
+		return nil
+	}
+	// 次数用完了，凭证作废
+	if err = h.client.Set(ctx, h.mfaUsedKey(mc.ID), "", mfaChallengeExpiration).Err(); err != nil {
+		return err
+	}
+	return ErrMFAChallengeInvalid
+}
+
+func (h *RedisJWTHandler) ConsumeMFAChallenge(ctx *gin.Context, mc MFAClaims) error {
+	ok, err := h.client.SetNX(ctx, h.mfaUsedKey(mc.ID), "", mfaChallengeExpiration).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrMFAChallengeInvalid
+	}
+	return nil
+}
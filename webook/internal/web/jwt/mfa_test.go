@@ -0,0 +1,60 @@
+package jwt
+
+import (
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRedisJWTHandler_MFAChallenge(t *testing.T) {
+	mr := miniredis.RunT(t)
+	key, err := GenerateKey(AlgEdDSA, time.Now())
+	require.NoError(t, err)
+	keys := NewKeyManager(0)
+	require.NoError(t, keys.SetKeys([]*Key{key}))
+	h := NewRedisJWTHandler(redis.NewClient(&redis.Options{Addr: mr.Addr()}), keys, 0)
+	newCtx := func(ua string) *gin.Context {
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ctx.Request = httptest.NewRequest(http.MethodPost, "/users/login/mfa", nil)
+		ctx.Request.Header.Set("User-Agent", ua)
+		return ctx
+	}
+
+	token, err := h.SetMFAChallengeToken(newCtx("chrome"), 123)
+	require.NoError(t, err)
+
+	// 两步验证的凭证不能当成短 token 使用
+	_, err = h.ParseToken(token)
+	assert.ErrorIs(t, err, ErrTokenTypeWrong)
+	// 换了浏览器不能用
+	_, err = h.ParseMFAChallengeToken(newCtx("firefox"), token)
+	assert.Equal(t, ErrMFAChallengeInvalid, err)
+
+	mc, err := h.ParseMFAChallengeToken(newCtx("chrome"), token)
+	require.NoError(t, err)
+	assert.Equal(t, int64(123), mc.Uid)
+
+	// 错误次数用完之后凭证作废
+	for i := 1; i < mfaMaxAttempts; i++ {
+		require.NoError(t, h.RecordMFAFailure(newCtx("chrome"), mc))
+	}
+	assert.Equal(t, ErrMFAChallengeInvalid, h.RecordMFAFailure(newCtx("chrome"), mc))
+	_, err = h.ParseMFAChallengeToken(newCtx("chrome"), token)
+	assert.Equal(t, ErrMFAChallengeInvalid, err)
+
+	// 凭证只能用一次
+	token, err = h.SetMFAChallengeToken(newCtx("chrome"), 123)
+	require.NoError(t, err)
+	mc, err = h.ParseMFAChallengeToken(newCtx("chrome"), token)
+	require.NoError(t, err)
+	require.NoError(t, h.ConsumeMFAChallenge(newCtx("chrome"), mc))
+	assert.Equal(t, ErrMFAChallengeInvalid, h.ConsumeMFAChallenge(newCtx("chrome"), mc))
+	_, err = h.ParseMFAChallengeToken(newCtx("chrome"), token)
+	assert.Equal(t, ErrMFAChallengeInvalid, err)
+}
@@ -1,43 +1,73 @@
 package jwt
 
 import (
-	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
-	"net/http"
 	"strings"
 	"time"
 )
 
 type RedisJWTHandler struct {
 	client       redis.Cmdable
+	keys         *KeyManager
 	rcExpiration time.Duration
+	// 最多同时登录多少个设备，超过之后把最早登录的踢下线，0 表示不限制
+	maxSessions int
+	// Redis 不可用的时候怎么校验 ssid，为 nil 的时候直接拒绝
+	degrade *SessionDegradePolicy
+	// 为 nil 的时候短 token 里面没有角色
+	roles RoleProvider
 }
 
-func NewRedisJWTHandler(client redis.Cmdable) Handler {
+func NewRedisJWTHandler(client redis.Cmdable, keys *KeyManager, maxSessions int) *RedisJWTHandler {
 	return &RedisJWTHandler{
 		client:       client,
+		keys:         keys,
 		rcExpiration: time.Hour * 24 * 7,
+		maxSessions:  maxSessions,
 	}
 }
 
+// LoadRoles 签发短 token 的时候带上用户的角色
+// 角色变了之后，要等到下一次刷新短 token 才会更新
+func (h *RedisJWTHandler) LoadRoles(p RoleProvider) *RedisJWTHandler {
+	h.roles = p
+	return h
+}
+
+func (h *RedisJWTHandler) ParseToken(tokenStr string) (UserClaims, error) {
+	var uc UserClaims
+	err := h.keys.parse(tokenStr, typAccess, &uc)
+	return uc, err
+}
+
+func (h *RedisJWTHandler) ParseRefreshToken(tokenStr string) (RefreshClaims, error) {
+	var rc RefreshClaims
+	err := h.keys.parse(tokenStr, typRefresh, &rc)
+	return rc, err
+}
+
 func (h *RedisJWTHandler) CheckSession(ctx *gin.Context, ssid string) error {
 	// 在这里去校验 ssid 是否失效，因为我们可以在前面先校验一下 token ，避免无效的查询 Redis
 
 	cnt, err := h.client.Exists(ctx, fmt.Sprintf("users:ssid:%s", ssid)).Result()
 
 	if err != nil {
-		return err
+		// Redis 出问题的时候，按照降级策略判定，不至于所有用户都被踢下线
+		return h.checkSessionDegraded(ctx, ssid, err)
 	}
 
-	// 这种判定方式过于严格，因为一旦 redis 崩溃了，就无法继访问服务
+	// users:ssid:xxx 是作废的 ssid（退出登录、被踢下线），所以 key 存在的时候拒绝，这里不是写反了
 	if cnt > 0 {
-		// ssid 无效或者 redis 有问题
-		ctx.AbortWithStatus(http.StatusUnauthorized)
-		return errors.New("token 无效")
+		if h.degrade != nil && h.degrade.Revoked != nil {
+			// 顺便记到本地，Redis 崩溃之后也能拒绝
+			h.degrade.Revoked.Add(ssid)
+		}
+		// 只返回错误，要不要 401 由调用方决定，可选登录的路由按照没有登录处理
+		return ErrSessionRevoked
 	}
 	return nil
 }
@@ -62,9 +92,21 @@ func (h *RedisJWTHandler) ExtractToken(ctx *gin.Context) string {
 func (h *RedisJWTHandler) SetLoginToken(ctx *gin.Context, uid int64) error {
 	// 生成ssid，这个是长的uuid
 	ssid := uuid.New().String()
+	ua := ctx.GetHeader("User-Agent")
+	err := h.registerSession(ctx, Session{
+		Ssid:      ssid,
+		Uid:       uid,
+		Device:    deviceFromUA(ua),
+		UserAgent: ua,
+		IP:        ctx.ClientIP(),
+		LoginAt:   time.Now(),
+	})
+	if err != nil {
+		return err
+	}
 
 	// 若没返回错误，则登陆成功，设置JWTToken
-	err := h.setRefreshJWTToken(ctx, uid, ssid)
+	err = h.setRefreshJWTToken(ctx, uid, ssid)
 	if err != nil {
 		return err
 
@@ -78,24 +120,64 @@ func (h *RedisJWTHandler) ClearToken(ctx *gin.Context) error {
 	ctx.Header("x-jwt-token", "")
 	ctx.Header("x-refresh-token", "")
 	uc := ctx.MustGet("user").(UserClaims)
-	// 这里的过期时间设置为长 token 的过期时间就可以，因为长 token 都过期了，那么检不检测 ssid 都无所谓了
-	err := h.client.Set(ctx, fmt.Sprintf("users:ssid:%s", uc.Ssid), "", h.rcExpiration).Err()
-	return err
+	return h.revokeSession(ctx, uc.Uid, uc.Ssid)
+}
+
+// RotateRefreshToken 长 token 只能用一次，每次刷新都换一个新的长 token
+// 旧的长 token 的 jti 记录在 Redis 里面，如果被再次使用，说明长 token 很可能被盗了
+// 这时候不知道谁是真正的用户，所以把整个 ssid 都作废，大家都需要重新登录
+func (h *RedisJWTHandler) RotateRefreshToken(ctx *gin.Context, rc RefreshClaims) error {
+	if rc.ID == "" {
+		// 没有 jti 的长 token 没办法检测重复使用
+		return ErrRefreshTokenReused
+	}
+	// 记录到旧的长 token 过期就可以了，过期之后本来就用不了
+	expiration := h.rcExpiration
+	if rc.ExpiresAt != nil {
+		expiration = time.Until(rc.ExpiresAt.Time)
+	}
+	ok, err := h.client.SetNX(ctx, fmt.Sprintf("users:refresh:used:%s", rc.ID), rc.Ssid, expiration).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if err = h.revokeSession(ctx, rc.Uid, rc.Ssid); err != nil {
+			return err
+		}
+		return ErrRefreshTokenReused
+	}
+	if err = h.touchSession(ctx, rc.Uid, rc.Ssid); err != nil {
+		return err
+	}
+	err = h.setRefreshJWTToken(ctx, rc.Uid, rc.Ssid)
+	if err != nil {
+		return err
+	}
+	return h.SetJWTToken(ctx, rc.Uid, rc.Ssid)
 }
 
 // 因为多处需要使用到这个方法，我们把它抽出来，单独放在一个地方，然后在使用到的地方组合它
 // 设置短token
 func (h *RedisJWTHandler) SetJWTToken(ctx *gin.Context, uid int64, ssid string) error {
+	var roles []string
+	if h.roles != nil {
+		var err error
+		roles, err = h.roles.Roles(ctx, uid)
+		if err != nil {
+			return err
+		}
+	}
 
 	uc := UserClaims{ // Claims就表示数据
 		Uid:       uid,
 		Ssid:      ssid,
 		UserAgent: ctx.GetHeader("User-Agent"),
+		Roles:     roles,
 		// 设置过期时间
 		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute * 30))},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS512, uc) // SigningMethod的安全性和性能有一些差异，没有要求可随意选
-	tokenStr, err := token.SignedString(JWTKey)            // 这里token是一个结构体，但是传到前端需要一串字符，通过这个方法转换，其中不同的SigningMethod有不同的参数类型
+	// 使用非对称密钥签名，header 里面带上 kid，其它服务通过 /.well-known/jwks.json 拿到公钥就可以验证
+	tokenStr, err := h.keys.sign(uc, typAccess)
 
 	if err != nil {
 		return err
@@ -111,11 +193,12 @@ func (h *RedisJWTHandler) setRefreshJWTToken(ctx *gin.Context, uid int64, ssid s
 		Ssid: ssid,
 		// 设置过期时间，长token设置为7天
 		RegisteredClaims: jwt.RegisteredClaims{
+			// jti，用来检测长 token 有没有被重复使用
+			ID:        uuid.New().String(),
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(h.rcExpiration)),
 		},
 	}
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS512, rc)
-	refreshTokenStr, err := refreshToken.SignedString(RefreshJWTKey)
+	refreshTokenStr, err := h.keys.sign(rc, typRefresh)
 	if err != nil {
 		return err
 	}
@@ -136,7 +219,6 @@ type UserClaims struct {
 	Ssid                 string
 	Uid                  int64
 	UserAgent            string
+	// Roles 用户的角色，需要角色的路由会校验
+	Roles []string
 }
-
-var JWTKey = []byte("uF7hZ5sW5fZ7jC1mY1wS9qQ4nQ2gN7lJ")
-var RefreshJWTKey = []byte("uF7hZ5sW5fZ7jC1mY1wS9qQ4nQ2gN7VB")
@@ -0,0 +1,114 @@
+package jwt
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRedisJWTHandler_RotateRefreshToken(t *testing.T) {
+	mr := miniredis.RunT(t)
+	key, err := GenerateKey(AlgEdDSA, time.Now())
+	require.NoError(t, err)
+	keys := NewKeyManager(0)
+	require.NoError(t, keys.SetKeys([]*Key{key}))
+	h := NewRedisJWTHandler(redis.NewClient(&redis.Options{Addr: mr.Addr()}), keys, 0)
+
+	newCtx := func() (*gin.Context, *httptest.ResponseRecorder) {
+		recorder := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(recorder)
+		ctx.Request = httptest.NewRequest(http.MethodPost, "/users/refresh_token", nil)
+		return ctx, recorder
+	}
+	refresh := func(tokenStr string) (*httptest.ResponseRecorder, error) {
+		ctx, recorder := newCtx()
+		rc, err := h.ParseRefreshToken(tokenStr)
+		require.NoError(t, err)
+		if err = h.CheckSession(ctx, rc.Ssid); err != nil {
+			return recorder, err
+		}
+		return recorder, h.RotateRefreshToken(ctx, rc)
+	}
+
+	ctx, recorder := newCtx()
+	require.NoError(t, h.SetLoginToken(ctx, 123))
+	first := recorder.Header().Get("x-refresh-token")
+
+	// 刷新之后拿到新的长 token
+	recorder, err = refresh(first)
+	require.NoError(t, err)
+	second := recorder.Header().Get("x-refresh-token")
+	assert.NotEmpty(t, second)
+	assert.NotEqual(t, first, second)
+	assert.NotEmpty(t, recorder.Header().Get("x-jwt-token"))
+
+	// 旧的长 token 被重复使用，整个 ssid 失效
+	_, err = refresh(first)
+	assert.Equal(t, ErrRefreshTokenReused, err)
+	// 新的长 token 也不能用了
+	_, err = refresh(second)
+	assert.Error(t, err)
+}
+
+func TestRedisJWTHandler_Sessions(t *testing.T) {
+	mr := miniredis.RunT(t)
+	key, err := GenerateKey(AlgEdDSA, time.Now())
+	require.NoError(t, err)
+	keys := NewKeyManager(0)
+	require.NoError(t, keys.SetKeys([]*Key{key}))
+	h := NewRedisJWTHandler(redis.NewClient(&redis.Options{Addr: mr.Addr()}), keys, 2)
+
+	uas := []string{
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 Version/17.0 Mobile/15E148 Safari/604.1",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 Chrome/120.0.0.0 Safari/537.36 Edg/120.0.0.0",
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 14_0) AppleWebKit/537.36 Chrome/120.0.0.0 Safari/537.36",
+	}
+	ssids := make([]string, 0, len(uas))
+	for _, ua := range uas {
+		recorder := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(recorder)
+		ctx.Request = httptest.NewRequest(http.MethodPost, "/users/login", nil)
+		ctx.Request.Header.Set("User-Agent", ua)
+		require.NoError(t, h.SetLoginToken(ctx, 123))
+		uc, err := h.ParseToken(recorder.Header().Get("x-jwt-token"))
+		require.NoError(t, err)
+		ssids = append(ssids, uc.Ssid)
+		// 登录时间是毫秒精度，保证顺序
+		time.Sleep(time.Millisecond * 2)
+	}
+	checkSession := func(ssid string) error {
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		return h.CheckSession(ctx, ssid)
+	}
+
+	// 最多同时登录两个设备，最早登录的 iPhone 被踢下线
+	assert.Error(t, checkSession(ssids[0]))
+	sessions, err := h.Sessions(context.Background(), 123)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	assert.Equal(t, ssids[1], sessions[0].Ssid)
+	assert.Equal(t, "Windows Edge", sessions[0].Device)
+	assert.Equal(t, ssids[2], sessions[1].Ssid)
+	assert.Equal(t, "Mac Chrome", sessions[1].Device)
+
+	// 不能踢别人的设备
+	assert.Equal(t, ErrSessionNotFound, h.RevokeSession(context.Background(), 456, ssids[1]))
+
+	// 除了当前设备，其它设备都下线
+	require.NoError(t, h.RevokeOtherSessions(context.Background(), 123, ssids[2]))
+	assert.Error(t, checkSession(ssids[1]))
+	assert.NoError(t, checkSession(ssids[2]))
+	sessions, err = h.Sessions(context.Background(), 123)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+
+	require.NoError(t, h.RevokeSession(context.Background(), 123, ssids[2]))
+	assert.Error(t, checkSession(ssids[2]))
+}
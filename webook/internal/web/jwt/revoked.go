@@ -0,0 +1,81 @@
+package jwt
+
+import (
+	"context"
+	"github.com/hashicorp/golang-lru/simplelru"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+// 作废 ssid 的时候发布到这个频道，所有实例都记到本地
+const revokedSsidChannel = "users:ssid:revoked"
+
+// LocalRevokedSessions 本地记录最近被作废的 ssid，Redis 不可用的时候用来判断 ssid 是否有效
+// 这里用 LRU 而不是布隆过滤器：布隆过滤器有误判，会把正常的用户踢下线，而且不能删除过期的 ssid
+// 容量满了之后淘汰最早的，所以只能保证“最近”作废的 ssid 不会被放过
+type LocalRevokedSessions struct {
+	lock  sync.Mutex
+	cache *simplelru.LRU
+	// 和 users:ssid:xxx 的过期时间一样，过期之后长 token 本来就不能用了
+	expiration time.Duration
+	now        func() time.Time
+}
+
+func NewLocalRevokedSessions(capacity int, expiration time.Duration) *LocalRevokedSessions {
+	c, err := simplelru.NewLRU(capacity, nil)
+	if err != nil {
+		panic(err)
+	}
+	return &LocalRevokedSessions{
+		cache:      c,
+		expiration: expiration,
+		now:        time.Now,
+	}
+}
+
+func (l *LocalRevokedSessions) Add(ssid string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.cache.Add(ssid, l.now().Add(l.expiration))
+}
+
+func (l *LocalRevokedSessions) Contains(ssid string) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	val, ok := l.cache.Get(ssid)
+	if !ok {
+		return false
+	}
+	if !val.(time.Time).After(l.now()) {
+		l.cache.Remove(ssid)
+		return false
+	}
+	return true
+}
+
+// Subscribe 订阅其它实例作废的 ssid，直到 ctx 被取消
+// Redis 断开之后 go-redis 会自动重连，重连期间作废的 ssid 会漏掉，这是降级方案可以接受的
+func (l *LocalRevokedSessions) Subscribe(ctx context.Context, client redis.UniversalClient) {
+	ps := client.Subscribe(ctx, revokedSsidChannel)
+	go func() {
+		defer func() {
+			if err := ps.Close(); err != nil {
+				zap.L().Error("关闭作废 ssid 的订阅失败", zap.Error(err))
+			}
+		}()
+		ch := ps.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+				l.Add(msg.Payload)
+			}
+		}
+	}()
+}
@@ -0,0 +1,210 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrSessionNotFound ssid 不存在，或者不属于这个用户
+var ErrSessionNotFound = errors.New("登录会话不存在")
+
+// Session 一次登录，也就是一个设备上的登录状态，对应一个 ssid
+type Session struct {
+	Ssid      string
+	Uid       int64
+	Device    string
+	UserAgent string
+	IP        string
+	LoginAt   time.Time
+	// LastSeen 最近一次刷新 token 的时间，所以精度是短 token 的有效期
+	LastSeen time.Time
+}
+
+// 用户登录的全部 ssid，score 是登录时间
+func (h *RedisJWTHandler) sessionsKey(uid int64) string {
+	return fmt.Sprintf("users:sessions:%d", uid)
+}
+
+// 一次登录的详细信息
+func (h *RedisJWTHandler) sessionKey(ssid string) string {
+	return fmt.Sprintf("users:session:%s", ssid)
+}
+
+// registerSession 记录新的登录，超过最多同时登录的设备数量的时候，把最早登录的踢下线
+func (h *RedisJWTHandler) registerSession(ctx context.Context, sess Session) error {
+	if h.maxSessions > 0 {
+		sessions, err := h.Sessions(ctx, sess.Uid)
+		if err != nil {
+			return err
+		}
+		// Sessions 按照登录时间从早到晚排序
+		for i := 0; i <= len(sessions)-h.maxSessions; i++ {
+			err = h.revokeSession(ctx, sess.Uid, sessions[i].Ssid)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	now := sess.LoginAt.UnixMilli()
+	pipe := h.client.TxPipeline()
+	pipe.HSet(ctx, h.sessionKey(sess.Ssid),
+		"uid", sess.Uid,
+		"device", sess.Device,
+		"ua", sess.UserAgent,
+		"ip", sess.IP,
+		"login_at", now,
+		"last_seen", now)
+	pipe.Expire(ctx, h.sessionKey(sess.Ssid), h.rcExpiration)
+	pipe.ZAdd(ctx, h.sessionsKey(sess.Uid), redis.Z{Score: float64(now), Member: sess.Ssid})
+	pipe.Expire(ctx, h.sessionsKey(sess.Uid), h.rcExpiration)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// touchSession 刷新 token 的时候更新最近活跃时间，同时延长过期时间
+func (h *RedisJWTHandler) touchSession(ctx context.Context, uid int64, ssid string) error {
+	pipe := h.client.TxPipeline()
+	pipe.HSet(ctx, h.sessionKey(ssid), "last_seen", time.Now().UnixMilli())
+	pipe.Expire(ctx, h.sessionKey(ssid), h.rcExpiration)
+	pipe.Expire(ctx, h.sessionsKey(uid), h.rcExpiration)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Sessions 按照登录时间从早到晚排序，顺便清理已经过期的 ssid
+func (h *RedisJWTHandler) Sessions(ctx context.Context, uid int64) ([]Session, error) {
+	ssids, err := h.client.ZRange(ctx, h.sessionsKey(uid), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(ssids) == 0 {
+		return nil, nil
+	}
+	pipe := h.client.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, 0, len(ssids))
+	for _, ssid := range ssids {
+		cmds = append(cmds, pipe.HGetAll(ctx, h.sessionKey(ssid)))
+	}
+	if _, err = pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	res := make([]Session, 0, len(ssids))
+	var expired []any
+	for i, cmd := range cmds {
+		vals := cmd.Val()
+		if len(vals) == 0 {
+			expired = append(expired, ssids[i])
+			continue
+		}
+		loginAt, _ := strconv.ParseInt(vals["login_at"], 10, 64)
+		lastSeen, _ := strconv.ParseInt(vals["last_seen"], 10, 64)
+		res = append(res, Session{
+			Ssid:      ssids[i],
+			Uid:       uid,
+			Device:    vals["device"],
+			UserAgent: vals["ua"],
+			IP:        vals["ip"],
+			LoginAt:   time.UnixMilli(loginAt),
+			LastSeen:  time.UnixMilli(lastSeen),
+		})
+	}
+	if len(expired) > 0 {
+		err = h.client.ZRem(ctx, h.sessionsKey(uid), expired...).Err()
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].LoginAt.Before(res[j].LoginAt)
+	})
+	return res, nil
+}
+
+func (h *RedisJWTHandler) RevokeSession(ctx context.Context, uid int64, ssid string) error {
+	// 只能踢自己的设备
+	_, err := h.client.ZScore(ctx, h.sessionsKey(uid), ssid).Result()
+	if err == redis.Nil {
+		return ErrSessionNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return h.revokeSession(ctx, uid, ssid)
+}
+
+func (h *RedisJWTHandler) RevokeOtherSessions(ctx context.Context, uid int64, keepSsid string) error {
+	ssids, err := h.client.ZRange(ctx, h.sessionsKey(uid), 0, -1).Result()
+	if err != nil {
+		return err
+	}
+	for _, ssid := range ssids {
+		if ssid == keepSsid {
+			continue
+		}
+		if err = h.revokeSession(ctx, uid, ssid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// revokeSession 让 ssid 失效，这个 ssid 下面的长短 token 都不能用了
+func (h *RedisJWTHandler) revokeSession(ctx context.Context, uid int64, ssid string) error {
+	if h.degrade != nil && h.degrade.Revoked != nil {
+		// 先记到本地，就算 Redis 出错了，这个实例也不会再放行
+		h.degrade.Revoked.Add(ssid)
+	}
+	pipe := h.client.TxPipeline()
+	// 这里的过期时间设置为长 token 的过期时间就可以，因为长 token 都过期了，那么检不检测 ssid 都无所谓了
+	pipe.Set(ctx, fmt.Sprintf("users:ssid:%s", ssid), "", h.rcExpiration)
+	pipe.Del(ctx, h.sessionKey(ssid))
+	pipe.ZRem(ctx, h.sessionsKey(uid), ssid)
+	// 通知其它实例记到本地
+	pipe.Publish(ctx, revokedSsidChannel, ssid)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// deviceFromUA 从 User-Agent 里面粗略地看出是什么设备，给用户看的，不需要很准确
+func deviceFromUA(ua string) string {
+	var os, browser string
+	switch {
+	case strings.Contains(ua, "iPhone"):
+		os = "iPhone"
+	case strings.Contains(ua, "iPad"):
+		os = "iPad"
+	case strings.Contains(ua, "Android"):
+		os = "Android"
+	case strings.Contains(ua, "Windows"):
+		os = "Windows"
+	case strings.Contains(ua, "Mac OS"):
+		os = "Mac"
+	case strings.Contains(ua, "Linux"):
+		os = "Linux"
+	default:
+		os = "未知设备"
+	}
+	// Edge 和 Chrome 的 UA 里面都有 Chrome，Chrome 的 UA 里面也有 Safari，所以顺序不能乱
+	switch {
+	case strings.Contains(ua, "MicroMessenger"):
+		browser = "微信"
+	case strings.Contains(ua, "Edg/"):
+		browser = "Edge"
+	case strings.Contains(ua, "Chrome/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "Firefox/"):
+		browser = "Firefox"
+	case strings.Contains(ua, "Safari/"):
+		browser = "Safari"
+	}
+	if browser == "" {
+		return os
+	}
+	return os + " " + browser
+}
@@ -1,6 +1,22 @@
 package jwt
 
-import "github.com/gin-gonic/gin"
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	// ErrRefreshTokenReused 长 token 已经用过了，整个 ssid 都已经被作废
+	ErrRefreshTokenReused = errors.New("长 token 被重复使用")
+	// ErrSessionRevoked ssid 已经被作废了，比如退出登录或者被踢下线
+	ErrSessionRevoked = errors.New("token 无效")
+)
+
+// RoleProvider 查询用户的角色，签发短 token 的时候放进 UserClaims
+type RoleProvider interface {
+	Roles(ctx context.Context, uid int64) ([]string, error)
+}
 
 type Handler interface {
 	ExtractToken(ctx *gin.Context) string
@@ -8,4 +24,26 @@ type Handler interface {
 	SetJWTToken(ctx *gin.Context, uid int64, ssid string) error
 	CheckSession(ctx *gin.Context, ssid string) error
 	ClearToken(ctx *gin.Context) error
+	// ParseToken 解析并校验短 token
+	ParseToken(tokenStr string) (UserClaims, error)
+	// ParseRefreshToken 解析并校验长 token
+	ParseRefreshToken(tokenStr string) (RefreshClaims, error)
+	// RotateRefreshToken 用长 token 换一对新的长短 token，旧的长 token 被重复使用的时候返回 ErrRefreshTokenReused
+	RotateRefreshToken(ctx *gin.Context, rc RefreshClaims) error
+
+	// Sessions 用户在哪些设备上登录了，按照登录时间从早到晚排序
+	Sessions(ctx context.Context, uid int64) ([]Session, error)
+	// RevokeSession 让用户的某个设备下线，ssid 不属于这个用户的时候返回 ErrSessionNotFound
+	RevokeSession(ctx context.Context, uid int64, ssid string) error
+	// RevokeOtherSessions 除了 keepSsid，其它设备都下线，keepSsid 为空的时候全部下线
+	RevokeOtherSessions(ctx context.Context, uid int64, keepSsid string) error
+
+	// SetMFAChallengeToken 密码验证通过之后，签发一个短时间有效的两步验证凭证
+	SetMFAChallengeToken(ctx *gin.Context, uid int64) (string, error)
+	// ParseMFAChallengeToken 凭证已经用过或者作废的时候返回 ErrMFAChallengeInvalid
+	ParseMFAChallengeToken(ctx *gin.Context, tokenStr string) (MFAClaims, error)
+	// RecordMFAFailure 记录一次验证码错误，次数用完之后凭证作废，返回 ErrMFAChallengeInvalid
+	RecordMFAFailure(ctx *gin.Context, mc MFAClaims) error
+	// ConsumeMFAChallenge 两步验证通过之后作废凭证，凭证只能用一次
+	ConsumeMFAChallenge(ctx *gin.Context, mc MFAClaims) error
 }
@@ -2,80 +2,67 @@ package middleware
 
 import (
 	ijwt "Learn_Go/webook/internal/web/jwt"
+	"Learn_Go/webook/pkg/ginx/authz"
+	"errors"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"net/http"
 )
 
+var errUserAgentMismatch = errors.New("User-Agent 和登录的时候不一致")
+
 type LoginJWTMiddlewareBuilder struct {
 	ijwt.Handler
+	// 每个路由在注册的时候声明了是否需要登录，需要什么角色
+	policies *authz.Registry
+	// 为 nil 的时候只看短 token 里面的角色
+	roles ijwt.RoleProvider
 }
 
-func NewLoginJWTMiddlewareBuilder(hdl ijwt.Handler) *LoginJWTMiddlewareBuilder {
+func NewLoginJWTMiddlewareBuilder(hdl ijwt.Handler, policies *authz.Registry) *LoginJWTMiddlewareBuilder {
 	return &LoginJWTMiddlewareBuilder{
-		Handler: hdl,
+		Handler:  hdl,
+		policies: policies,
 	}
 }
 
+// CheckRolesWith 需要角色的路由查询用户最新的角色，而不是只看短 token 里面的
+// 这样收回角色之后立刻生效，不用等短 token 过期；需要角色的路由很少，而且角色有缓存，开销可以接受
+func (m *LoginJWTMiddlewareBuilder) CheckRolesWith(p ijwt.RoleProvider) *LoginJWTMiddlewareBuilder {
+	m.roles = p
+	return m
+}
+
 func (m *LoginJWTMiddlewareBuilder) CheckLogin() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 
-		path := ctx.Request.URL.Path
-		if path == "/users/signup" ||
-			path == "/users/login" ||
-			path == "/users/login_sms/code/send" ||
-			path == "/users/login_sms" ||
-			path == "/oauth2/wechat/authurl" ||
-			path == "/oauth2/wechat/callback" {
-			// 不需要登录校验
+		// FullPath 是注册路由时候的路径，比如 /articles/:id
+		fullPath := ctx.FullPath()
+		if fullPath == "" {
+			// 没有匹配上任何路由，交给 gin 返回 404
 			return
 		}
-
-		tokenStr := m.ExtractToken(ctx)
-		var uc ijwt.UserClaims
-		token, err := jwt.ParseWithClaims(tokenStr, &uc, func(token *jwt.Token) (interface{}, error) { // 这里 uc 需要传指针
-			return ijwt.JWTKey, nil
-		})
-		if err != nil {
-			// token不对，token是伪造的
-			ctx.AbortWithStatus(http.StatusUnauthorized)
-			return
-		}
-
-		if token == nil || !token.Valid { // 这里其实 Valid 校验就可以了，包括过期
-			// token解析出来了，但是token可能是非法的，或者是过期了
-			ctx.AbortWithStatus(http.StatusUnauthorized)
+		// 启动的时候已经校验过所有路由都声明了策略，万一没有声明，按照需要登录处理
+		policy, ok := m.policies.Lookup(ctx.Request.Method, fullPath)
+		if ok && policy.Level == authz.LevelPublic {
+			// 不需要登录校验
 			return
 		}
 
-		if uc.UserAgent != ctx.GetHeader("User-Agent") {
-
-			// 后期讲到监控告警的时候，这个地方要埋点
-			// 能够进来这个分支的，大概率是攻击者
-			ctx.AbortWithStatus(http.StatusUnauthorized)
+		if ok && policy.Level == authz.LevelOptional {
+			// token 有问题的时候按照没有登录处理，不返回 401
+			if uc, err := m.authenticate(ctx); err == nil {
+				ctx.Set("user", uc)
+			}
 			return
 		}
 
-		// 在这里去校验 ssid 是否失效，因为我们可以在前面先校验一下 token ，避免无效的查询 Redis
-
-		//cnt, err := m.cmd.Exists(ctx, fmt.Sprintf("users:ssid:%s", uc.Ssid)).Result()
-		err = m.CheckSession(ctx, uc.Ssid) // jwt 改造成面向接口编程之后，这里面判断了 ssid 的有效性
-
-		// 这种判定方式过于严格，因为一旦 redis 崩溃了，就无法继访问服务
+		uc, err := m.authenticate(ctx)
 		if err != nil {
-			// ssid 无效或者 redis 有问题
+			// token 不对或者 ssid 已经失效
 			ctx.AbortWithStatus(http.StatusUnauthorized)
 			return
 		}
 
-		// 这种写法可以兼容 redis 异常的情况，就是即便 redis 崩溃了，但是用户依然可以访问服务
-		// 但是要做好监控，有没有 error
-		//if cnt > 0 {
-		//	// ssid 无效
-		//	ctx.AbortWithStatus(http.StatusUnauthorized)
-		//	return
-		//}
-
 		// *****************************************************************************************
 		// 这里是自动刷新JWTtoken，但是我们使用了长短token机制，所以自动刷新机制需要屏蔽
 		//// 刷新登陆状态
@@ -97,7 +84,46 @@ func (m *LoginJWTMiddlewareBuilder) CheckLogin() gin.HandlerFunc {
 		//
 		//}
 		// *******************************************************************************************
+		if len(policy.Roles) > 0 && m.roles != nil {
+			roles, err := m.roles.Roles(ctx, uc.Uid)
+			if err != nil {
+				ctx.AbortWithStatus(http.StatusInternalServerError)
+				return
+			}
+			uc.Roles = roles
+		}
+		if !policy.Allow(uc.Roles) {
+			// 登录了，但是没有权限
+			ctx.AbortWithStatus(http.StatusForbidden)
+			return
+		}
 		ctx.Set("user", uc) // 设置缓存，节省时间，后续可直接获取uc
 
 	}
 }
+
+// authenticate 校验 token 和 ssid
+func (m *LoginJWTMiddlewareBuilder) authenticate(ctx *gin.Context) (ijwt.UserClaims, error) {
+	tokenStr := m.ExtractToken(ctx)
+	// 这里面校验了签名、kid 和过期时间
+	uc, err := m.ParseToken(tokenStr)
+	if err != nil {
+		// token不对，token是伪造的，或者是过期了
+		return ijwt.UserClaims{}, err
+	}
+
+	if uc.UserAgent != ctx.GetHeader("User-Agent") {
+
+		// 后期讲到监控告警的时候，这个地方要埋点
+		// 能够进来这个分支的，大概率是攻击者
+		return ijwt.UserClaims{}, errUserAgentMismatch
+	}
+
+	// 先校验 token 再校验 ssid 是否失效，避免无效的查询 Redis
+	// Redis 出问题的时候怎么处理由 CheckSession 的降级策略决定
+	if err = m.CheckSession(ctx, uc.Ssid); err != nil {
+		// ssid 无效或者 redis 有问题
+		return ijwt.UserClaims{}, err
+	}
+	return uc, nil
+}
@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	ijwt "Learn_Go/webook/internal/web/jwt"
+	"Learn_Go/webook/pkg/ginx/authz"
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// 测试用的角色，可以随时修改
+type roleMap map[int64][]string
+
+func (m roleMap) Roles(ctx context.Context, uid int64) ([]string, error) {
+	return m[uid], nil
+}
+
+func TestLoginJWTMiddlewareBuilder_Roles(t *testing.T) {
+	mr := miniredis.RunT(t)
+	key, err := ijwt.GenerateKey(ijwt.AlgEdDSA, time.Now())
+	require.NoError(t, err)
+	keys := ijwt.NewKeyManager(0)
+	require.NoError(t, keys.SetKeys([]*ijwt.Key{key}))
+	roles := roleMap{123: {"user", "admin"}}
+	hdl := ijwt.NewRedisJWTHandler(redis.NewClient(&redis.Options{Addr: mr.Addr()}), keys, 0).LoadRoles(roles)
+
+	// 登录，短 token 里面带上了 admin
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/users/login", nil)
+	require.NoError(t, hdl.SetLoginToken(ctx, 123))
+	token := recorder.Header().Get("x-jwt-token")
+	uc, err := hdl.ParseToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user", "admin"}, uc.Roles)
+
+	policies := authz.NewRegistry()
+	server := gin.New()
+	server.Use(NewLoginJWTMiddlewareBuilder(hdl, policies).CheckRolesWith(roles).CheckLogin())
+	router := authz.NewRouter(server, policies)
+	router.With(authz.Login()).GET("/profile", func(ctx *gin.Context) {})
+	router.With(authz.Roles("admin")).GET("/admin", func(ctx *gin.Context) {})
+	do := func(path string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+
+	assert.Equal(t, http.StatusOK, do("/admin"))
+	// 收回 admin 之后，虽然短 token 里面还有 admin，但是立刻就不能访问了
+	roles[123] = []string{"user"}
+	assert.Equal(t, http.StatusForbidden, do("/admin"))
+	// 不需要角色的路由不受影响
+	assert.Equal(t, http.StatusOK, do("/profile"))
+}
+
+func TestLoginJWTMiddlewareBuilder_Optional(t *testing.T) {
+	mr := miniredis.RunT(t)
+	key, err := ijwt.GenerateKey(ijwt.AlgEdDSA, time.Now())
+	require.NoError(t, err)
+	keys := ijwt.NewKeyManager(0)
+	require.NoError(t, keys.SetKeys([]*ijwt.Key{key}))
+	hdl := ijwt.NewRedisJWTHandler(redis.NewClient(&redis.Options{Addr: mr.Addr()}), keys, 0)
+
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/users/login", nil)
+	require.NoError(t, hdl.SetLoginToken(ctx, 123))
+	token := recorder.Header().Get("x-jwt-token")
+
+	// 另一个设备登录之后退出了，短 token 还没过期，但是 ssid 已经作废了
+	recorder = httptest.NewRecorder()
+	ctx, _ = gin.CreateTestContext(recorder)
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/users/login", nil)
+	require.NoError(t, hdl.SetLoginToken(ctx, 123))
+	revoked := recorder.Header().Get("x-jwt-token")
+	uc, err := hdl.ParseToken(revoked)
+	require.NoError(t, err)
+	require.NoError(t, hdl.RevokeSession(context.Background(), 123, uc.Ssid))
+
+	policies := authz.NewRegistry()
+	server := gin.New()
+	server.Use(NewLoginJWTMiddlewareBuilder(hdl, policies).CheckLogin())
+	router := authz.NewRouter(server, policies)
+	router.With(authz.Login()).GET("/users/profile", func(ctx *gin.Context) {})
+	router.With(authz.Optional()).GET("/users/:id/profile", func(ctx *gin.Context) {
+		var uid int64
+		if uc, ok := ctx.Get("user"); ok {
+			uid = uc.(ijwt.UserClaims).Uid
+		}
+		ctx.JSON(http.StatusOK, uid)
+	})
+
+	testCases := []struct {
+		name    string
+		token   string
+		wantUid string
+	}{
+		{name: "没有登录", wantUid: "0"},
+		{name: "登录了", token: token, wantUid: "123"},
+		{name: "token 不对按照没有登录处理", token: token + "x", wantUid: "0"},
+		{name: "ssid 已经作废按照没有登录处理", token: revoked, wantUid: "0"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/users/456/profile", nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			assert.Equal(t, tc.wantUid, recorder.Body.String())
+		})
+	}
+
+	// 需要登录的路由还是返回 401
+	req := httptest.NewRequest(http.MethodGet, "/users/profile", nil)
+	req.Header.Set("Authorization", "Bearer "+revoked)
+	recorder = httptest.NewRecorder()
+	server.ServeHTTP(recorder, req)
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+}
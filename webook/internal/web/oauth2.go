@@ -0,0 +1,231 @@
+package web
+
+import (
+	"Learn_Go/webook/internal/domain"
+	"Learn_Go/webook/internal/service"
+	"Learn_Go/webook/internal/service/oauth2"
+	ijwt "Learn_Go/webook/internal/web/jwt"
+	"Learn_Go/webook/pkg/ginx/authz"
+	"context"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+// OAuth2Handler 第三方登录，微信、GitHub 和各种 OIDC 都走这里，用路由里面的 :provider 区分
+type OAuth2Handler struct {
+	providers    map[string]oauth2.Provider
+	userSvc      service.UserService // 第三方登陆也是属于userSvc的服务的
+	stateSvc     service.OAuthStateService
+	ijwt.Handler // 这个不用初始化，因为这个结构体，如果是指针的话就需要初始化
+	// state 绑定的浏览器 cookie，只在回调的时候带上
+	stateCookieName string
+}
+
+func NewOAuth2Handler(providers []oauth2.Provider, userSvc service.UserService,
+	stateSvc service.OAuthStateService, jwthdl ijwt.Handler) *OAuth2Handler {
+	m := make(map[string]oauth2.Provider, len(providers))
+	for _, p := range providers {
+		m[p.Name()] = p
+	}
+	return &OAuth2Handler{
+		providers:       m,
+		userSvc:         userSvc,
+		stateSvc:        stateSvc,
+		stateCookieName: "oauth2-state",
+		Handler:         jwthdl,
+	}
+}
+
+func (o *OAuth2Handler) RegisterRoutes(server *authz.Router) {
+	g := server.Group("/oauth2/:provider")
+	// GET /oauth2/:provider/authurl?redirect=/articles 登录成功之后前端跳转到 redirect
+	g.With(authz.Public()).GET("/authurl", o.OAuth2URL)
+	g.With(authz.Public()).Any("/callback", o.Callback)
+	// 已经登录的用户绑定第三方账号，授权之后同样回调到 /callback
+	// GET /oauth2/:provider/bindurl?merge=true 已经属于其它账号的时候把那个账号合并过来
+	g.With(authz.Login()).GET("/bindurl", o.BindURL)
+}
+
+// provider 不支持的时候已经写好了响应
+func (o *OAuth2Handler) provider(ctx *gin.Context) (oauth2.Provider, bool) {
+	p, ok := o.providers[ctx.Param("provider")]
+	if !ok {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "不支持的登录方式",
+		})
+	}
+	return p, ok
+}
+
+func (o *OAuth2Handler) OAuth2URL(ctx *gin.Context) {
+	o.writeAuthURL(ctx, domain.OAuthState{
+		Redirect: ctx.Query("redirect"),
+	})
+}
+
+func (o *OAuth2Handler) BindURL(ctx *gin.Context) {
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	o.writeAuthURL(ctx, domain.OAuthState{
+		BindUid:  uc.Uid,
+		Merge:    ctx.Query("merge") == "true",
+		Redirect: ctx.Query("redirect"),
+	})
+}
+
+// writeAuthURL st 里面的 BindUid、Merge 和 Redirect，回调的时候原样拿回来
+func (o *OAuth2Handler) writeAuthURL(ctx *gin.Context, st domain.OAuthState) {
+	p, ok := o.provider(ctx)
+	if !ok {
+		return
+	}
+	st.Provider = p.Name()
+	state, st, err := o.stateSvc.Create(ctx, st)
+	switch err {
+	case nil:
+	case service.ErrRedirectNotAllowed:
+		ctx.JSON(http.StatusOK, Result{
+			Msg:  "不允许跳转到这个地址",
+			Code: 4,
+		})
+		return
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Msg:  "服务器异常",
+			Code: 5,
+		})
+		zap.L().Error("保存第三方登录的 state 失败", zap.Error(err))
+		return
+	}
+	val, err := p.AuthURL(ctx, state, st.Verifier)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Msg:  "构造跳转URL失败",
+			Code: 5,
+		})
+		zap.L().Error("构造第三方登录的跳转URL失败", zap.String("provider", p.Name()), zap.Error(err))
+		return
+	}
+	// state 只有和这个 cookie 一起才能用，别人拿到 state 也没用
+	// 第三方回调是跨站跳转过来的，SameSite 只能是 Lax
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(o.stateCookieName, st.Browser, 600, "/oauth2/"+p.Name()+"/callback", "", ctx.Request.TLS != nil, true)
+	// 若不返回错误，就拿到构造好的跳转URL，将它传给前端
+	ctx.JSON(http.StatusOK, Result{
+		Data: val,
+	})
+}
+
+func (o *OAuth2Handler) Callback(ctx *gin.Context) {
+	p, ok := o.provider(ctx)
+	if !ok {
+		return
+	}
+	st, ok := o.consumeState(ctx, p.Name())
+	if !ok {
+		return
+	}
+	token, err := p.Exchange(ctx, ctx.Query("code"), st.Verifier)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Msg:  "授权码有误",
+			Code: 4,
+		})
+		zap.L().Warn("第三方登录授权码换取 token 失败", zap.String("provider", p.Name()), zap.Error(err))
+		return
+	}
+	id, err := p.Identity(ctx, token)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Msg:  "系统错误",
+			Code: 5,
+		})
+		zap.L().Error("获取第三方登录的用户身份失败", zap.String("provider", p.Name()), zap.Error(err))
+		return
+	}
+
+	if st.BindUid > 0 {
+		merged, err := o.userSvc.BindOAuth(ctx, st.BindUid, id, st.Merge)
+		writeBindResult(ctx, o.Handler, st.BindUid, merged, err)
+		return
+	}
+
+	// 第三方登陆也可能第一次登陆，所以如果是第一次登陆就先注册
+	u, err := o.userSvc.FindOrCreateByOAuth(ctx, id, o.profile(p, token, id))
+
+	if msg, blocked := loginBlockedMsg(err); blocked {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  msg,
+		})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		return
+	}
+	// 如果没有返回错误，那么就登陆成功，设置jwtToken
+	err = o.SetLoginToken(ctx, u.Id)
+	if err != nil {
+		ctx.String(http.StatusOK, "系统错误！")
+		return
+
+	}
+	// redirect 为空的时候 Data 也是空的，前端留在当前页面
+	var data any
+	if st.Redirect != "" {
+		data = st.Redirect
+	}
+	ctx.JSON(http.StatusOK, Result{
+		Msg:  "登陆成功",
+		Data: data,
+	})
+}
+
+// profile 第三方不支持单独获取用户信息的时候返回 nil
+func (o *OAuth2Handler) profile(p oauth2.Provider, token oauth2.Token,
+	id domain.OAuthIdentity) func(ctx context.Context) (domain.OAuthIdentity, error) {
+	pf, ok := p.(oauth2.ProfileFetcher)
+	if !ok {
+		return nil
+	}
+	return func(ctx context.Context) (domain.OAuthIdentity, error) {
+		return pf.Profile(ctx, token, id)
+	}
+}
+
+// consumeState 返回 false 的时候已经写好了响应
+func (o *OAuth2Handler) consumeState(ctx *gin.Context, provider string) (domain.OAuthState, bool) {
+	// 没有 cookie 的时候 browser 是空字符串，Consume 会返回不匹配
+	browser, _ := ctx.Cookie(o.stateCookieName)
+	st, err := o.stateSvc.Consume(ctx, ctx.Query("state"), provider, browser)
+	switch err {
+	case nil:
+		// 用过了就删掉
+		ctx.SetCookie(o.stateCookieName, "", -1, "/oauth2/"+provider+"/callback", "", ctx.Request.TLS != nil, true)
+		return st, true
+	case service.ErrOAuthStateNotFound:
+		ctx.JSON(http.StatusOK, Result{
+			Msg:  "登录已过期，请重新登录",
+			Code: 4,
+		})
+	case service.ErrOAuthStateMismatch:
+		// state 不匹配，有人搞你
+		ctx.JSON(http.StatusOK, Result{
+			Msg:  "非法请求",
+			Code: 4,
+		})
+		zap.L().Warn("第三方登录的 state 不匹配", zap.String("provider", provider), zap.String("ip", ctx.ClientIP()))
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Msg:  "系统错误",
+			Code: 5,
+		})
+		zap.L().Error("校验第三方登录的 state 失败", zap.Error(err))
+	}
+	return domain.OAuthState{}, false
+}
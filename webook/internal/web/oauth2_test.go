@@ -0,0 +1,264 @@
+package web
+
+import (
+	"Learn_Go/webook/internal/domain"
+	"Learn_Go/webook/internal/repository"
+	"Learn_Go/webook/internal/repository/cache"
+	"Learn_Go/webook/internal/service"
+	svcmocks "Learn_Go/webook/internal/service/mocks"
+	"Learn_Go/webook/internal/service/oauth2"
+	"Learn_Go/webook/internal/service/oauth2/oidc"
+	"Learn_Go/webook/internal/service/oauth2/oidc/oidctest"
+	"Learn_Go/webook/internal/service/oauth2/wechat"
+	ijwt "Learn_Go/webook/internal/web/jwt"
+	"Learn_Go/webook/pkg/ginx/authz"
+	"Learn_Go/webook/pkg/logger"
+	"encoding/json"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestOAuth2Handler_Callback(t *testing.T) {
+	wantId := domain.OAuthIdentity{
+		Provider: "google",
+		Subject:  "user-1",
+		Email:    "user-1@example.com",
+		Nickname: "User 1",
+	}
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) service.UserService
+		// login 不为 0 的时候是已经登录的用户在绑定
+		login int64
+		// redirect 登录成功之后跳转的地址
+		redirect string
+		// callback 修改回调的参数
+		callback func(q url.Values, cookie *http.Cookie)
+		// replay 同一个回调请求重复一次
+		replay bool
+
+		wantRes   Result
+		wantToken bool
+	}{
+		{
+			name: "第一次登录",
+			mock: func(ctrl *gomock.Controller) service.UserService {
+				userSvc := svcmocks.NewMockUserService(ctrl)
+				userSvc.EXPECT().FindOrCreateByOAuth(gomock.Any(), wantId, nil).Return(domain.User{Id: 123}, nil)
+				return userSvc
+			},
+			wantRes:   Result{Msg: "登陆成功"},
+			wantToken: true,
+		},
+		{
+			name: "登录之后跳转",
+			mock: func(ctrl *gomock.Controller) service.UserService {
+				userSvc := svcmocks.NewMockUserService(ctrl)
+				userSvc.EXPECT().FindOrCreateByOAuth(gomock.Any(), wantId, nil).Return(domain.User{Id: 123}, nil)
+				return userSvc
+			},
+			redirect:  "https://meoying.com/articles/1",
+			wantRes:   Result{Msg: "登陆成功", Data: "https://meoying.com/articles/1"},
+			wantToken: true,
+		},
+		{
+			name: "state 只能用一次",
+			mock: func(ctrl *gomock.Controller) service.UserService {
+				userSvc := svcmocks.NewMockUserService(ctrl)
+				userSvc.EXPECT().FindOrCreateByOAuth(gomock.Any(), wantId, nil).Return(domain.User{Id: 123}, nil)
+				return userSvc
+			},
+			replay:  true,
+			wantRes: Result{Code: 4, Msg: "登录已过期，请重新登录"},
+		},
+		{
+			name: "账号被封禁",
+			mock: func(ctrl *gomock.Controller) service.UserService {
+				userSvc := svcmocks.NewMockUserService(ctrl)
+				userSvc.EXPECT().FindOrCreateByOAuth(gomock.Any(), wantId, nil).Return(domain.User{}, service.ErrUserSuspended)
+				return userSvc
+			},
+			wantRes: Result{Code: 4, Msg: "账号已被封禁"},
+		},
+		{
+			name: "绑定第三方账号",
+			mock: func(ctrl *gomock.Controller) service.UserService {
+				userSvc := svcmocks.NewMockUserService(ctrl)
+				userSvc.EXPECT().BindOAuth(gomock.Any(), int64(123), wantId, false).Return(int64(0), nil)
+				return userSvc
+			},
+			login:   123,
+			wantRes: Result{Msg: "绑定成功"},
+		},
+		{
+			name: "伪造的 state 和过期的一样处理",
+			mock: func(ctrl *gomock.Controller) service.UserService {
+				return svcmocks.NewMockUserService(ctrl)
+			},
+			callback: func(q url.Values, cookie *http.Cookie) {
+				q.Set("state", "other-state")
+			},
+			wantRes: Result{Code: 4, Msg: "登录已过期，请重新登录"},
+		},
+		{
+			name: "别的浏览器拿到了 state",
+			mock: func(ctrl *gomock.Controller) service.UserService {
+				return svcmocks.NewMockUserService(ctrl)
+			},
+			callback: func(q url.Values, cookie *http.Cookie) {
+				cookie.Value = "other-browser"
+			},
+			wantRes: Result{Code: 4, Msg: "非法请求"},
+		},
+		{
+			name: "没有 state 的 cookie",
+			mock: func(ctrl *gomock.Controller) service.UserService {
+				return svcmocks.NewMockUserService(ctrl)
+			},
+			callback: func(q url.Values, cookie *http.Cookie) {
+				cookie.Value = ""
+			},
+			wantRes: Result{Code: 4, Msg: "非法请求"},
+		},
+		{
+			name: "授权码被用过了",
+			mock: func(ctrl *gomock.Controller) service.UserService {
+				return svcmocks.NewMockUserService(ctrl)
+			},
+			callback: func(q url.Values, cookie *http.Cookie) {
+				q.Set("code", "used-code")
+			},
+			wantRes: Result{Code: 4, Msg: "授权码有误"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			idp := oidctest.NewServer("client-id", "client-secret")
+			defer idp.Close()
+			mr := miniredis.RunT(t)
+			keys := ijwt.NewKeyManager(0)
+			key, err := ijwt.GenerateKey(ijwt.AlgEdDSA, time.Now())
+			require.NoError(t, err)
+			require.NoError(t, keys.SetKeys([]*ijwt.Key{key}))
+
+			provider := oidc.NewProvider(oidc.Config{
+				Name:         "google",
+				Issuer:       idp.URL,
+				ClientId:     "client-id",
+				ClientSecret: "client-secret",
+				RedirectURL:  "http://localhost/oauth2/google/callback",
+			}, http.DefaultClient)
+			client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			stateSvc := service.NewOAuthStateService(
+				repository.NewOAuthStateRepository(cache.NewRedisOAuthStateCache(client)), []string{"https://meoying.com"})
+			h := NewOAuth2Handler([]oauth2.Provider{provider}, tc.mock(ctrl), stateSvc,
+				ijwt.NewRedisJWTHandler(client, keys, 0))
+			server := gin.New()
+			server.Use(func(ctx *gin.Context) {
+				if tc.login > 0 {
+					ctx.Set("user", ijwt.UserClaims{Uid: tc.login})
+				}
+			})
+			h.RegisterRoutes(authz.NewRouter(server, authz.NewRegistry()))
+
+			// 1. 拿到跳转的地址，state 放在 cookie 里面
+			path := "/oauth2/google/authurl"
+			if tc.login > 0 {
+				path = "/oauth2/google/bindurl"
+			}
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path+"?redirect="+url.QueryEscape(tc.redirect), nil))
+			var res Result
+			require.NoError(t, json.NewDecoder(recorder.Body).Decode(&res))
+			authURL, ok := res.Data.(string)
+			require.True(t, ok, res.Msg)
+			cookies := recorder.Result().Cookies()
+			require.Len(t, cookies, 1)
+			cookie := cookies[0]
+			assert.Equal(t, "/oauth2/google/callback", cookie.Path)
+
+			// 2. 用户在 IdP 上同意授权
+			code, state, err := idp.Authorize(authURL)
+			require.NoError(t, err)
+
+			// 3. IdP 回调
+			q := url.Values{}
+			q.Set("code", code)
+			q.Set("state", state)
+			if tc.callback != nil {
+				tc.callback(q, cookie)
+			}
+			callback := func() *httptest.ResponseRecorder {
+				req := httptest.NewRequest(http.MethodGet, "/oauth2/google/callback?"+q.Encode(), nil)
+				if cookie.Value != "" {
+					req.AddCookie(cookie)
+				}
+				recorder := httptest.NewRecorder()
+				server.ServeHTTP(recorder, req)
+				return recorder
+			}
+			recorder = callback()
+			if tc.replay {
+				recorder = callback()
+			}
+			res = Result{}
+			require.NoError(t, json.NewDecoder(recorder.Body).Decode(&res))
+			assert.Equal(t, tc.wantRes, res)
+			assert.Equal(t, tc.wantToken, recorder.Header().Get("x-jwt-token") != "")
+		})
+	}
+}
+
+func TestOAuth2Handler_OAuth2URL(t *testing.T) {
+	testCases := []struct {
+		name    string
+		path    string
+		wantRes Result
+	}{
+		{
+			name:    "不支持的第三方",
+			path:    "/oauth2/unknown/authurl",
+			wantRes: Result{Code: 4, Msg: "不支持的登录方式"},
+		},
+		{
+			name:    "跳转到别的站点",
+			path:    "/oauth2/wechat/authurl?redirect=" + url.QueryEscape("https://evil.com/login"),
+			wantRes: Result{Code: 4, Msg: "不允许跳转到这个地址"},
+		},
+		{
+			name:    "没有 scheme 的别的站点",
+			path:    "/oauth2/wechat/authurl?redirect=" + url.QueryEscape("//evil.com"),
+			wantRes: Result{Code: 4, Msg: "不允许跳转到这个地址"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mr := miniredis.RunT(t)
+			client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			stateSvc := service.NewOAuthStateService(
+				repository.NewOAuthStateRepository(cache.NewRedisOAuthStateCache(client)), []string{"https://meoying.com"})
+			h := NewOAuth2Handler([]oauth2.Provider{wechat.NewWechatService(wechat.Config{}, http.DefaultClient, logger.NewNopLogger())},
+				nil, stateSvc, nil)
+			server := gin.New()
+			h.RegisterRoutes(authz.NewRouter(server, authz.NewRegistry()))
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			var res Result
+			require.NoError(t, json.NewDecoder(recorder.Body).Decode(&res))
+			assert.Equal(t, tc.wantRes, res)
+			// 没有生成 state
+			assert.Empty(t, mr.Keys())
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package web
+
+import (
+	"Learn_Go/webook/internal/domain"
+	"Learn_Go/webook/internal/service"
+	ijwt "Learn_Go/webook/internal/web/jwt"
+	"Learn_Go/webook/pkg/ginx/authz"
+	"Learn_Go/webook/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ProfileHandler 别人的主页和自己主页的隐私设置
+// 自己的完整资料还是 UserHandler.Profile
+type ProfileHandler struct {
+	svc service.ProfileService
+	l   logger.LoggerV1
+}
+
+func NewProfileHandler(svc service.ProfileService, l logger.LoggerV1) *ProfileHandler {
+	return &ProfileHandler{
+		svc: svc,
+		l:   l,
+	}
+}
+
+func (h *ProfileHandler) RegisterRouters(server *authz.Router) {
+	ug := server.Group("/users")
+	// 不登录也能看，登录了的话按照和主人的关系多展示一些
+	ug.With(authz.Optional()).GET("/:id/profile", h.PublicProfile)
+	login := ug.With(authz.Login())
+	login.GET("/privacy", h.Privacy)
+	login.POST("/privacy", h.UpdatePrivacy)
+}
+
+func (h *ProfileHandler) PublicProfile(ctx *gin.Context) {
+	uid, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil || uid <= 0 {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "用户不存在",
+		})
+		return
+	}
+	var viewer int64
+	if uc, ok := ctx.Get("user"); ok {
+		viewer = uc.(ijwt.UserClaims).Uid
+	}
+	p, err := h.svc.Profile(ctx, viewer, uid)
+	switch err {
+	case nil:
+	case service.ErrUserNotFound:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "用户不存在",
+		})
+		return
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		h.l.Error("查询用户主页失败", logger.Int64("uid", uid), logger.Error(err))
+		return
+	}
+	type profileVo struct {
+		Id       int64  `json:"id"`
+		Nickname string `json:"nickname"`
+		Avatar   string `json:"avatar"`
+		// 下面这些看不到的时候是空字符串
+		Email    string `json:"email"`
+		Phone    string `json:"phone"`
+		Birthday string `json:"birthday"`
+		AboutMe  string `json:"aboutMe"`
+		Ctime    string `json:"ctime"`
+	}
+	vo := profileVo{
+		Id:       p.Id,
+		Nickname: p.Nickname,
+		Avatar:   p.Avatar,
+		Email:    p.Email,
+		Phone:    p.Phone,
+		AboutMe:  p.AboutMe,
+		Ctime:    p.Ctime.Format(time.DateOnly),
+	}
+	// 看不到或者没有设置过生日
+	if !p.Birthday.IsZero() && p.Birthday.UnixMilli() != 0 {
+		vo.Birthday = p.Birthday.Format(time.DateOnly)
+	}
+	ctx.JSON(http.StatusOK, Result{Data: vo})
+}
+
+type privacyVo struct {
+	Email    string `json:"email"`
+	Phone    string `json:"phone"`
+	Birthday string `json:"birthday"`
+	AboutMe  string `json:"aboutMe"`
+}
+
+func (h *ProfileHandler) Privacy(ctx *gin.Context) {
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	p, err := h.svc.Privacy(ctx, uc.Uid)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		h.l.Error("查询隐私设置失败", logger.Int64("uid", uc.Uid), logger.Error(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{Data: privacyVo{
+		Email:    p.Email.String(),
+		Phone:    p.Phone.String(),
+		Birthday: p.Birthday.String(),
+		AboutMe:  p.AboutMe.String(),
+	}})
+}
+
+// UpdatePrivacy 每个字段是 self、followers 或者 public，没有传的字段不修改
+func (h *ProfileHandler) UpdatePrivacy(ctx *gin.Context) {
+	var req privacyVo
+	if err := ctx.Bind(&req); err != nil {
+		return
+	}
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	p, err := h.svc.Privacy(ctx, uc.Uid)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		h.l.Error("查询隐私设置失败", logger.Int64("uid", uc.Uid), logger.Error(err))
+		return
+	}
+	fields := []struct {
+		val string
+		dst *domain.Visibility
+	}{
+		{val: req.Email, dst: &p.Email},
+		{val: req.Phone, dst: &p.Phone},
+		{val: req.Birthday, dst: &p.Birthday},
+		{val: req.AboutMe, dst: &p.AboutMe},
+	}
+	for _, f := range fields {
+		if f.val == "" {
+			continue
+		}
+		v, ok := domain.ParseVisibility(f.val)
+		if !ok {
+			ctx.JSON(http.StatusOK, Result{
+				Code: 4,
+				Msg:  "可见范围只能是 self、followers 或者 public",
+			})
+			return
+		}
+		*f.dst = v
+	}
+	if err = h.svc.UpdatePrivacy(ctx, uc.Uid, p); err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		h.l.Error("修改隐私设置失败", logger.Int64("uid", uc.Uid), logger.Error(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{Msg: "修改成功"})
+}
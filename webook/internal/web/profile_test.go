@@ -0,0 +1,184 @@
+package web
+
+import (
+	"Learn_Go/webook/internal/domain"
+	"Learn_Go/webook/internal/service"
+	svcmocks "Learn_Go/webook/internal/service/mocks"
+	ijwt "Learn_Go/webook/internal/web/jwt"
+	"Learn_Go/webook/pkg/ginx/authz"
+	"Learn_Go/webook/pkg/logger"
+	"bytes"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestProfileHandler_PublicProfile(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) service.ProfileService
+		// 0 表示没有登录
+		viewer int64
+		path   string
+
+		wantRes Result
+	}{
+		{
+			name: "没有登录",
+			mock: func(ctrl *gomock.Controller) service.ProfileService {
+				svc := svcmocks.NewMockProfileService(ctrl)
+				svc.EXPECT().Profile(gomock.Any(), int64(0), int64(123)).Return(domain.PublicProfile{
+					Id:       123,
+					Nickname: "大明",
+					Phone:    "138****5678",
+					Ctime:    time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local),
+				}, nil)
+				return svc
+			},
+			path: "/users/123/profile",
+			wantRes: Result{Data: map[string]any{
+				"id":       float64(123),
+				"nickname": "大明",
+				"avatar":   "",
+				"email":    "",
+				"phone":    "138****5678",
+				"birthday": "",
+				"aboutMe":  "",
+				"ctime":    "2024-01-01",
+			}},
+		},
+		{
+			name: "登录了",
+			mock: func(ctrl *gomock.Controller) service.ProfileService {
+				svc := svcmocks.NewMockProfileService(ctrl)
+				svc.EXPECT().Profile(gomock.Any(), int64(456), int64(123)).Return(domain.PublicProfile{
+					Id:       123,
+					Birthday: time.Date(2000, 1, 1, 0, 0, 0, 0, time.Local),
+					Ctime:    time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local),
+				}, nil)
+				return svc
+			},
+			viewer: 456,
+			path:   "/users/123/profile",
+			wantRes: Result{Data: map[string]any{
+				"id":       float64(123),
+				"nickname": "",
+				"avatar":   "",
+				"email":    "",
+				"phone":    "",
+				"birthday": "2000-01-01",
+				"aboutMe":  "",
+				"ctime":    "2024-01-01",
+			}},
+		},
+		{
+			name: "用户不存在",
+			mock: func(ctrl *gomock.Controller) service.ProfileService {
+				svc := svcmocks.NewMockProfileService(ctrl)
+				svc.EXPECT().Profile(gomock.Any(), int64(0), int64(123)).
+					Return(domain.PublicProfile{}, service.ErrUserNotFound)
+				return svc
+			},
+			path:    "/users/123/profile",
+			wantRes: Result{Code: 4, Msg: "用户不存在"},
+		},
+		{
+			name: "id 不对",
+			mock: func(ctrl *gomock.Controller) service.ProfileService {
+				return svcmocks.NewMockProfileService(ctrl)
+			},
+			path:    "/users/abc/profile",
+			wantRes: Result{Code: 4, Msg: "用户不存在"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			h := NewProfileHandler(tc.mock(ctrl), logger.NewNopLogger())
+			server := gin.New()
+			server.Use(func(ctx *gin.Context) {
+				if tc.viewer > 0 {
+					ctx.Set("user", ijwt.UserClaims{Uid: tc.viewer})
+				}
+			})
+			h.RegisterRouters(authz.NewRouter(server, authz.NewRegistry()))
+
+			req, err := http.NewRequest(http.MethodGet, tc.path, nil)
+			require.NoError(t, err)
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			var res Result
+			require.NoError(t, json.NewDecoder(recorder.Body).Decode(&res))
+			assert.Equal(t, tc.wantRes, res)
+		})
+	}
+}
+
+func TestProfileHandler_UpdatePrivacy(t *testing.T) {
+	testCases := []struct {
+		name    string
+		mock    func(ctrl *gomock.Controller) service.ProfileService
+		reqBody string
+
+		wantRes Result
+	}{
+		{
+			name: "只修改传了的字段",
+			mock: func(ctrl *gomock.Controller) service.ProfileService {
+				svc := svcmocks.NewMockProfileService(ctrl)
+				svc.EXPECT().Privacy(gomock.Any(), int64(123)).Return(domain.DefaultPrivacy, nil)
+				svc.EXPECT().UpdatePrivacy(gomock.Any(), int64(123), domain.PrivacySettings{
+					Email:    domain.VisibilitySelf,
+					Phone:    domain.VisibilityFollowers,
+					Birthday: domain.VisibilityFollowers,
+					AboutMe:  domain.VisibilitySelf,
+				}).Return(nil)
+				return svc
+			},
+			reqBody: `{"phone": "followers", "aboutMe": "self"}`,
+			wantRes: Result{Msg: "修改成功"},
+		},
+		{
+			name: "可见范围不对",
+			mock: func(ctrl *gomock.Controller) service.ProfileService {
+				svc := svcmocks.NewMockProfileService(ctrl)
+				svc.EXPECT().Privacy(gomock.Any(), int64(123)).Return(domain.DefaultPrivacy, nil)
+				return svc
+			},
+			reqBody: `{"email": "friends"}`,
+			wantRes: Result{Code: 4, Msg: "可见范围只能是 self、followers 或者 public"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			h := NewProfileHandler(tc.mock(ctrl), logger.NewNopLogger())
+			server := gin.New()
+			server.Use(func(ctx *gin.Context) {
+				ctx.Set("user", ijwt.UserClaims{Uid: 123})
+			})
+			h.RegisterRouters(authz.NewRouter(server, authz.NewRegistry()))
+
+			req, err := http.NewRequest(http.MethodPost, "/users/privacy", bytes.NewReader([]byte(tc.reqBody)))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			var res Result
+			require.NoError(t, json.NewDecoder(recorder.Body).Decode(&res))
+			assert.Equal(t, tc.wantRes, res)
+		})
+	}
+}
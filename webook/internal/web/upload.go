@@ -0,0 +1,143 @@
+package web
+
+import (
+	"Learn_Go/webook/internal/service"
+	"Learn_Go/webook/internal/service/storage"
+	ijwt "Learn_Go/webook/internal/web/jwt"
+	"Learn_Go/webook/pkg/ginx/authz"
+	"Learn_Go/webook/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+)
+
+// maxUploadSize 请求体的上限，具体每种图片的限制在 service 里面
+const maxUploadSize = 10<<20 + 1<<16
+
+// UploadHandler 上传头像和文章图片，文件放在 multipart 的 file 字段
+type UploadHandler struct {
+	svc service.ImageService
+	// files 本地存储的时候由我们自己提供下载，其它存储为 nil
+	files http.Handler
+	l     logger.LoggerV1
+}
+
+func NewUploadHandler(svc service.ImageService, store storage.Storage, l logger.LoggerV1) *UploadHandler {
+	h := &UploadHandler{
+		svc: svc,
+		l:   l,
+	}
+	if files, ok := store.(http.Handler); ok {
+		h.files = files
+	}
+	return h
+}
+
+func (h *UploadHandler) RegisterRouters(server *authz.Router) {
+	server.Group("/users").With(authz.Login()).POST("/avatar", h.UploadAvatar)
+	server.Group("/articles").With(authz.Login()).POST("/images", h.UploadArticleImage)
+	if h.files != nil {
+		// 本地存储的 baseURL 要配置成 /files
+		server.With(authz.Public()).GET("/files/*key", gin.WrapH(http.StripPrefix("/files", h.files)))
+	}
+}
+
+func (h *UploadHandler) UploadAvatar(ctx *gin.Context) {
+	data, ok := h.readFile(ctx)
+	if !ok {
+		return
+	}
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	url, err := h.svc.UploadAvatar(ctx, uc.Uid, data)
+	if h.writeErr(ctx, err) {
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{
+		Msg:  "上传成功",
+		Data: url,
+	})
+}
+
+func (h *UploadHandler) UploadArticleImage(ctx *gin.Context) {
+	data, ok := h.readFile(ctx)
+	if !ok {
+		return
+	}
+	img, err := h.svc.UploadArticleImage(ctx, data)
+	if h.writeErr(ctx, err) {
+		return
+	}
+	type imageVo struct {
+		URL      string `json:"url"`
+		ThumbURL string `json:"thumbUrl"`
+		Width    int    `json:"width"`
+		Height   int    `json:"height"`
+	}
+	ctx.JSON(http.StatusOK, Result{
+		Msg: "上传成功",
+		Data: imageVo{
+			URL:      img.URL,
+			ThumbURL: img.ThumbURL,
+			Width:    img.Width,
+			Height:   img.Height,
+		},
+	})
+}
+
+// readFile 返回 false 的时候已经写好了响应
+func (h *UploadHandler) readFile(ctx *gin.Context) ([]byte, bool) {
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxUploadSize)
+	fh, err := ctx.FormFile("file")
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "请选择不超过 10M 的图片",
+		})
+		return nil, false
+	}
+	f, err := fh.Open()
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		h.l.Error("读取上传的文件失败", logger.Error(err))
+		return nil, false
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		h.l.Error("读取上传的文件失败", logger.Error(err))
+		return nil, false
+	}
+	return data, true
+}
+
+// writeErr err 不为 nil 的时候写好响应，返回 true
+func (h *UploadHandler) writeErr(ctx *gin.Context, err error) bool {
+	switch err {
+	case nil:
+		return false
+	case service.ErrImageTooLarge:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "图片太大",
+		})
+	case service.ErrInvalidImage:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "只支持 JPEG、PNG 和 GIF 格式的图片",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		h.l.Error("上传图片失败", logger.Error(err))
+	}
+	return true
+}
@@ -0,0 +1,149 @@
+package web
+
+import (
+	"Learn_Go/webook/internal/domain"
+	"Learn_Go/webook/internal/service"
+	svcmocks "Learn_Go/webook/internal/service/mocks"
+	"Learn_Go/webook/internal/service/storage/localstorage"
+	ijwt "Learn_Go/webook/internal/web/jwt"
+	"Learn_Go/webook/pkg/ginx/authz"
+	"Learn_Go/webook/pkg/logger"
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newUploadRequest(t *testing.T, path, field string, data []byte) *http.Request {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	fw, err := w.CreateFormFile(field, "a.png")
+	require.NoError(t, err)
+	_, err = fw.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	req, err := http.NewRequest(http.MethodPost, path, &body)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	return req
+}
+
+func TestUploadHandler(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) service.ImageService
+		req  func(t *testing.T) *http.Request
+
+		wantRes Result
+	}{
+		{
+			name: "上传头像",
+			mock: func(ctrl *gomock.Controller) service.ImageService {
+				svc := svcmocks.NewMockImageService(ctrl)
+				svc.EXPECT().UploadAvatar(gomock.Any(), int64(123), []byte("png")).Return("/files/avatars/a.png", nil)
+				return svc
+			},
+			req: func(t *testing.T) *http.Request {
+				return newUploadRequest(t, "/users/avatar", "file", []byte("png"))
+			},
+			wantRes: Result{Msg: "上传成功", Data: "/files/avatars/a.png"},
+		},
+		{
+			name: "上传文章图片",
+			mock: func(ctrl *gomock.Controller) service.ImageService {
+				svc := svcmocks.NewMockImageService(ctrl)
+				svc.EXPECT().UploadArticleImage(gomock.Any(), []byte("png")).Return(domain.Image{
+					URL:      "/files/images/a.png",
+					ThumbURL: "/files/images/a_480.png",
+					Width:    800,
+					Height:   600,
+				}, nil)
+				return svc
+			},
+			req: func(t *testing.T) *http.Request {
+				return newUploadRequest(t, "/articles/images", "file", []byte("png"))
+			},
+			wantRes: Result{Msg: "上传成功", Data: map[string]any{
+				"url":      "/files/images/a.png",
+				"thumbUrl": "/files/images/a_480.png",
+				"width":    float64(800),
+				"height":   float64(600),
+			}},
+		},
+		{
+			name: "没有文件",
+			mock: func(ctrl *gomock.Controller) service.ImageService {
+				return svcmocks.NewMockImageService(ctrl)
+			},
+			req: func(t *testing.T) *http.Request {
+				return newUploadRequest(t, "/users/avatar", "avatar", []byte("png"))
+			},
+			wantRes: Result{Code: 4, Msg: "请选择不超过 10M 的图片"},
+		},
+		{
+			name: "请求体太大",
+			mock: func(ctrl *gomock.Controller) service.ImageService {
+				return svcmocks.NewMockImageService(ctrl)
+			},
+			req: func(t *testing.T) *http.Request {
+				return newUploadRequest(t, "/users/avatar", "file", make([]byte, maxUploadSize))
+			},
+			wantRes: Result{Code: 4, Msg: "请选择不超过 10M 的图片"},
+		},
+		{
+			name: "不支持的格式",
+			mock: func(ctrl *gomock.Controller) service.ImageService {
+				svc := svcmocks.NewMockImageService(ctrl)
+				svc.EXPECT().UploadAvatar(gomock.Any(), int64(123), []byte("svg")).Return("", service.ErrInvalidImage)
+				return svc
+			},
+			req: func(t *testing.T) *http.Request {
+				return newUploadRequest(t, "/users/avatar", "file", []byte("svg"))
+			},
+			wantRes: Result{Code: 4, Msg: "只支持 JPEG、PNG 和 GIF 格式的图片"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			h := NewUploadHandler(tc.mock(ctrl), localstorage.NewStorage(t.TempDir(), "/files"), logger.NewNopLogger())
+			server := gin.New()
+			server.Use(func(ctx *gin.Context) {
+				ctx.Set("user", ijwt.UserClaims{Uid: 123})
+			})
+			h.RegisterRouters(authz.NewRouter(server, authz.NewRegistry()))
+
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, tc.req(t))
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			var res Result
+			require.NoError(t, json.NewDecoder(recorder.Body).Decode(&res))
+			assert.Equal(t, tc.wantRes, res)
+		})
+	}
+}
+
+// 本地存储的时候 /files 可以下载上传的文件
+func TestUploadHandler_Files(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := localstorage.NewStorage(t.TempDir(), "/files")
+	require.NoError(t, store.Put(context.Background(), "images/ab/abc.png", []byte("png"), "image/png"))
+	h := NewUploadHandler(svcmocks.NewMockImageService(ctrl), store, logger.NewNopLogger())
+	server := gin.New()
+	h.RegisterRouters(authz.NewRouter(server, authz.NewRegistry()))
+
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, store.URL("images/ab/abc.png"), nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "png", recorder.Body.String())
+	assert.Equal(t, "nosniff", recorder.Header().Get("X-Content-Type-Options"))
+}
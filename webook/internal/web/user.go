@@ -3,12 +3,13 @@ package web
 import (
 	"Learn_Go/webook/internal/domain"
 	"Learn_Go/webook/internal/service"
+	"Learn_Go/webook/internal/service/channel"
 	ijwt "Learn_Go/webook/internal/web/jwt"
+	"Learn_Go/webook/pkg/ginx/authz"
 	"fmt"
 	regexp "github.com/dlclark/regexp2"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"go.uber.org/zap"
 	"net/http"
 	"time"
@@ -20,30 +21,43 @@ const (
 	emailRegexPattern = "^\\w+([-+.]\\w+)*@\\w+([-.]\\w+)*\\.\\w+([-.]\\w+)*$"
 	// 和上面比起来，用 ` 看起来就比较清爽
 	passwordRegexPattern = `^(?=.*[A-Za-z])(?=.*\d)(?=.*[$@$!%*#?&])[A-Za-z\d$@$!%*#?&]{8,}$` // 官方的正则表达式不支持 ?= 这种写法，因此会报错，可以使用开源的正则表达式匹配库
-	bizLogin             = "login"
+	// 中国大陆的手机号
+	phoneRegexPattern = `^1[3-9]\d{9}$`
+	// 短信和邮箱登录的验证码分开存，邮箱收到的验证码不能拿来走手机号登录
+	bizLoginSMS      = "login_sms"
+	bizLoginEmail    = "login_email"
+	bizResetPassword = "reset_password"
+	bizBind          = "bind"
 )
 
 type UserHandler struct {
 	emailRexExp    *regexp.Regexp
 	passwordRexExp *regexp.Regexp
+	phoneRexExp    *regexp.Regexp
 	svc            service.UserService
 	codeSvc        service.CodeService
+	captchaSvc     service.CaptchaService
+	mfaSvc         service.MFAService
 	ijwt.Handler
 }
 
-func NewUserHandler(svc service.UserService, codeSvc service.CodeService, jwthdl ijwt.Handler) *UserHandler { // 预编译正则表达式，保证正则表达式正确，性能优化
+func NewUserHandler(svc service.UserService, codeSvc service.CodeService, captchaSvc service.CaptchaService,
+	mfaSvc service.MFAService, jwthdl ijwt.Handler) *UserHandler { // 预编译正则表达式，保证正则表达式正确，性能优化
 	return &UserHandler{
 		emailRexExp:    regexp.MustCompile(emailRegexPattern, regexp.None),
 		passwordRexExp: regexp.MustCompile(passwordRegexPattern, regexp.None),
+		phoneRexExp:    regexp.MustCompile(phoneRegexPattern, regexp.None),
 		svc:            svc,
 		codeSvc:        codeSvc,
+		captchaSvc:     captchaSvc,
+		mfaSvc:         mfaSvc,
 		Handler:        jwthdl,
 	}
 }
 
 // 分散注册路由 由各自的 Handler 注册自己的路由；优点：有条理  缺点：不好找
 
-func (h *UserHandler) RegisterRouters(server *gin.Engine) {
+func (h *UserHandler) RegisterRouters(server *authz.Router) {
 	//server.POST("/users/signup", h.SignUp)
 	//server.POST("/users/login", h.LogIn)
 	//server.POST("/users/edit", h.Edit)
@@ -51,27 +65,56 @@ func (h *UserHandler) RegisterRouters(server *gin.Engine) {
 
 	// 为了处理 /users 前缀写错，可以使用分组路由
 	ug := server.Group("/users")
+	// 不需要登录的路由
+	pub := ug.With(authz.Public())
+	// 需要登录的路由
+	login := ug.With(authz.Login())
 	// POST /users/signup
-	ug.POST("/signup", h.SignUp)
+	pub.POST("/signup", h.SignUp)
 	// POST /users/login
 	//ug.POST("/login", h.LogIn)
-	ug.POST("/login", h.LogInJWT)
-	ug.POST("/logout", h.LogoutJWT)
-	ug.POST("/refresh_token", h.RefreshToken)
+	pub.POST("/login", h.LogInJWT)
+	// 开启了两步验证的用户，密码正确之后还要输入验证码
+	pub.POST("/login/mfa", h.LoginMFA)
+	login.POST("/logout", h.LogoutJWT)
+	// 长 token 在 RefreshToken 里面自己校验
+	pub.POST("/refresh_token", h.RefreshToken)
+	// 登录的设备管理
+	login.GET("/sessions", h.Sessions)
+	login.POST("/sessions/revoke", h.RevokeSession)
 	// POST /users/edit
-	ug.POST("/edit", h.Edit)
+	login.POST("/edit", h.Edit)
 	// POST /users/profile
-	ug.GET("/profile", h.Profile)
+	login.GET("/profile", h.Profile)
+	// 两步验证的设置
+	login.POST("/mfa/totp/enroll", h.EnrollTOTP)
+	login.POST("/mfa/totp/confirm", h.ConfirmTOTP)
+	login.POST("/mfa/totp/disable", h.DisableTOTP)
+	// 修改密码和忘记密码
+	login.POST("/password/change", h.ChangePassword)
+	pub.POST("/password/reset/code/send", h.SendResetPasswordCode)
+	pub.POST("/password/reset", h.ResetPassword)
+	// 绑定手机号和邮箱，第三方账号的绑定在 OAuth2Handler 里面
+	login.POST("/bind/code/send", h.SendBindCode)
+	login.POST("/bind/phone", h.BindPhone)
+	login.POST("/bind/email", h.BindEmail)
+	login.POST("/unbind", h.Unbind)
 
 	// 手机验证码登陆相关功能
-	ug.POST("/login_sms/code/send", h.SendSMSLoginCode)
-	ug.POST("/login_sms", h.LoginSMS)
+	pub.POST("/login_sms/code/send", h.SendSMSLoginCode)
+	pub.POST("/login_sms", h.LoginSMS)
+	// 邮箱验证码登录，只支持已经绑定了邮箱的用户
+	pub.POST("/login_email/code/send", h.SendEmailLoginCode)
+	pub.POST("/login_email", h.LoginEmail)
 
 }
 
 func (h *UserHandler) SendSMSLoginCode(ctx *gin.Context) {
 	type Req struct {
-		Phone string `json:"phone"`
+		Phone         string `json:"phone"`
+		CaptchaTicket string `json:"captchaTicket"`
+		// 收不到短信的时候，前端可以让用户选择语音验证码
+		Channel string `json:"channel"`
 	}
 
 	var req Req
@@ -89,7 +132,29 @@ func (h *UserHandler) SendSMSLoginCode(ctx *gin.Context) {
 		})
 		return
 	}
-	err := h.codeSvc.Send(ctx, bizLogin, req.Phone)
+	if !h.checkPhone(ctx, req.Phone) {
+		return
+	}
+	// 邮箱验证码走 /users/login_email
+	if channel.Channel(req.Channel) == channel.Email {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "不支持的发送方式",
+		})
+		return
+	}
+
+	// 同一个手机号或者同一个 IP 发送次数过多，就要求先通过图形验证码
+	captchaKeys := []string{"phone:" + req.Phone, "ip:" + ctx.ClientIP()}
+	if !h.passCaptcha(ctx, captchaBizLoginSMS, req.CaptchaTicket, captchaKeys...) {
+		return
+	}
+	// 每一次发送都记一次数，记录失败不影响发送
+	if err := h.captchaSvc.RecordFailure(ctx, captchaBizLoginSMS, captchaKeys...); err != nil {
+		zap.L().Error("记录验证码发送次数失败", zap.Error(err))
+	}
+
+	err := h.codeSvc.SendVia(ctx, bizLoginSMS, req.Phone, channel.Channel(req.Channel))
 	switch err {
 	case nil:
 		ctx.JSON(http.StatusOK, Result{
@@ -104,6 +169,11 @@ func (h *UserHandler) SendSMSLoginCode(ctx *gin.Context) {
 		// 少数这种情况可以接受
 		// 但是频繁出现这种情况，就代表有人在搞你的系统
 		zap.L().Warn("频繁发送验证码")
+	case service.ErrCodeChannelUnsupported:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "不支持的发送方式",
+		})
 	default:
 		ctx.JSON(http.StatusOK, Result{
 			Code: 5,
@@ -126,7 +196,11 @@ func (h *UserHandler) LoginSMS(ctx *gin.Context) {
 		return
 	}
 
-	ok, err := h.codeSvc.Verify(ctx, bizLogin, req.Phone, req.Code)
+	if !h.checkPhone(ctx, req.Phone) {
+		return
+	}
+
+	ok, err := h.codeSvc.Verify(ctx, bizLoginSMS, req.Phone, req.Code)
 
 	// 如果返回错误，则为系统错误，如果没有错误，则代表验证码发送成功
 	if err != nil {
@@ -145,19 +219,35 @@ func (h *UserHandler) LoginSMS(ctx *gin.Context) {
 		return
 	}
 
+	captchaKeys := []string{"phone:" + req.Phone, "ip:" + ctx.ClientIP()}
 	// 如果没有返回错误，则提醒验证码错误，包括验证码输入错误和验证次数耗尽
 	if !ok {
+		if err = h.captchaSvc.RecordFailure(ctx, captchaBizLoginSMS, captchaKeys...); err != nil {
+			zap.L().Error("记录验证码登录失败次数失败", zap.Error(err))
+		}
 		ctx.JSON(http.StatusOK, Result{
 			Code: 4,
 			Msg:  "验证码错误，请重新输入",
 		})
 		return
 	}
+	// 只清空账号维度的计数，IP 维度的让它自己过期
+	// 不然攻击者登录一下自己的账号就能把 IP 的计数清掉
+	if err = h.captchaSvc.Reset(ctx, captchaBizLoginSMS, captchaKeys[0]); err != nil {
+		zap.L().Error("清空验证码登录失败次数失败", zap.Error(err))
+	}
 
 	// 按理说这里应该是 FindById，然后设置 JWTtoken ，但是，使用手机验证码登陆有可能第一次登陆直接注册，所以定义一个新方法
 
 	u, err := h.svc.FindOrCreate(ctx, req.Phone)
 
+	if msg, blocked := loginBlockedMsg(err); blocked {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  msg,
+		})
+		return
+	}
 	if err != nil {
 		ctx.JSON(http.StatusOK, Result{
 			Code: 5,
@@ -165,6 +255,10 @@ func (h *UserHandler) LoginSMS(ctx *gin.Context) {
 		})
 		return
 	}
+	// 验证码只能证明手机号是自己的，开启了两步验证的还要再验证一次
+	if h.requireMFA(ctx, u.Id) {
+		return
+	}
 	err = h.SetLoginToken(ctx, u.Id)
 	if err != nil {
 		ctx.String(http.StatusOK, "系统错误！")
@@ -176,6 +270,167 @@ func (h *UserHandler) LoginSMS(ctx *gin.Context) {
 
 }
 
+// checkPhone 返回 false 的时候已经写好了响应
+func (h *UserHandler) checkPhone(ctx *gin.Context, phone string) bool {
+	ok, err := h.phoneRexExp.MatchString(phone)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		return false
+	}
+	if !ok {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "非法手机号格式",
+		})
+		return false
+	}
+	return true
+}
+
+func (h *UserHandler) SendEmailLoginCode(ctx *gin.Context) {
+	type Req struct {
+		Email         string `json:"email"`
+		CaptchaTicket string `json:"captchaTicket"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		return
+	}
+
+	isEmail, err := h.emailRexExp.MatchString(req.Email)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		return
+	}
+	if !isEmail {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "非法邮箱格式",
+		})
+		return
+	}
+
+	// 和手机验证码共用发送次数的统计，只是维度换成了邮箱
+	captchaKeys := []string{"email:" + req.Email, "ip:" + ctx.ClientIP()}
+	if !h.passCaptcha(ctx, captchaBizLoginSMS, req.CaptchaTicket, captchaKeys...) {
+		return
+	}
+	if err = h.captchaSvc.RecordFailure(ctx, captchaBizLoginSMS, captchaKeys...); err != nil {
+		zap.L().Error("记录验证码发送次数失败", zap.Error(err))
+	}
+
+	// 邮箱验证码登录不会创建新用户，所以先确认这个邮箱已经绑定了账号
+	_, err = h.svc.FindByEmail(ctx, req.Email)
+	switch err {
+	case nil:
+	case service.ErrUserNotFound:
+		// 和发送成功的响应一样，不让别人用这个接口试出来哪些邮箱注册过
+		ctx.JSON(http.StatusOK, Result{
+			Msg: "发送成功",
+		})
+		return
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		return
+	}
+
+	err = h.codeSvc.SendVia(ctx, bizLoginEmail, req.Email, channel.Email)
+	switch err {
+	case nil:
+		ctx.JSON(http.StatusOK, Result{
+			Msg: "发送成功",
+		})
+	case service.ErrCodeSendTooMany:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "邮件发送太频繁，请稍后再试",
+		})
+		zap.L().Warn("频繁发送邮箱验证码")
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		zap.L().Error("发送邮箱验证码失败", zap.Error(err))
+	}
+}
+
+func (h *UserHandler) LoginEmail(ctx *gin.Context) {
+	type Req struct {
+		Email string `json:"email"`
+		Code  string `json:"code"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		return
+	}
+
+	ok, err := h.codeSvc.Verify(ctx, bizLoginEmail, req.Email, req.Code)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统异常",
+		})
+		zap.L().Error("邮箱验证码验证失败", zap.Error(err))
+		return
+	}
+	captchaKeys := []string{"email:" + req.Email, "ip:" + ctx.ClientIP()}
+	if !ok {
+		if err = h.captchaSvc.RecordFailure(ctx, captchaBizLoginSMS, captchaKeys...); err != nil {
+			zap.L().Error("记录验证码登录失败次数失败", zap.Error(err))
+		}
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "验证码错误，请重新输入",
+		})
+		return
+	}
+	// 只清空账号维度的计数，IP 维度的让它自己过期
+	// 不然攻击者登录一下自己的账号就能把 IP 的计数清掉
+	if err = h.captchaSvc.Reset(ctx, captchaBizLoginSMS, captchaKeys[0]); err != nil {
+		zap.L().Error("清空验证码登录失败次数失败", zap.Error(err))
+	}
+
+	u, err := h.svc.LoginByEmail(ctx, req.Email)
+	if msg, blocked := loginBlockedMsg(err); blocked {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  msg,
+		})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		return
+	}
+	if h.requireMFA(ctx, u.Id) {
+		return
+	}
+	err = h.SetLoginToken(ctx, u.Id)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{
+		Msg: "登陆成功",
+	})
+}
+
 func (h *UserHandler) SignUp(ctx *gin.Context) {
 
 	//内部类，除了SignUp，其它方法用不了； 用于接收前端数据
@@ -284,8 +539,9 @@ func (h *UserHandler) LogIn(ctx *gin.Context) {
 
 func (h *UserHandler) LogInJWT(ctx *gin.Context) {
 	type LoginReq struct {
-		Email    string `json:"email"` //标签
-		Password string `json:"password"`
+		Email         string `json:"email"` //标签
+		Password      string `json:"password"`
+		CaptchaTicket string `json:"captchaTicket"`
 	}
 
 	var req LoginReq
@@ -294,13 +550,33 @@ func (h *UserHandler) LogInJWT(ctx *gin.Context) {
 		return
 	}
 
+	// 同一个邮箱或者同一个 IP 登录失败次数过多，就要求先通过图形验证码
+	captchaKeys := []string{"email:" + req.Email, "ip:" + ctx.ClientIP()}
+	if !h.passCaptcha(ctx, captchaBizLogin, req.CaptchaTicket, captchaKeys...) {
+		return
+	}
+
 	u, err := h.svc.Login(ctx, req.Email, req.Password) // 这里的 u 用于登录校验
 
 	// 判定登陆时，账户和密码是否输入正确
 	switch err {
 	case service.ErrInvalidUserOrPassword:
+		if err = h.captchaSvc.RecordFailure(ctx, captchaBizLogin, captchaKeys...); err != nil {
+			zap.L().Error("记录登录失败次数失败", zap.Error(err))
+		}
 		ctx.String(http.StatusOK, "账号或密码错误，请重新输入！")
+	case service.ErrUserSuspended, service.ErrUserDeleted:
+		msg, _ := loginBlockedMsg(err)
+		ctx.String(http.StatusOK, msg)
 	case nil:
+		// 开启了两步验证的，等 LoginMFA 通过之后再清空失败次数
+		if h.requireMFA(ctx, u.Id) {
+			return
+		}
+		// 只清空账号维度的计数，IP 维度的让它自己过期
+		if err = h.captchaSvc.Reset(ctx, captchaBizLogin, captchaKeys[0]); err != nil {
+			zap.L().Error("清空登录失败次数失败", zap.Error(err))
+		}
 		err = h.SetLoginToken(ctx, u.Id)
 		if err != nil {
 			ctx.String(http.StatusOK, "系统错误！")
@@ -313,6 +589,53 @@ func (h *UserHandler) LogInJWT(ctx *gin.Context) {
 	}
 }
 
+// loginBlockedMsg 账号被封禁或者已经注销的时候返回给用户的提示
+func loginBlockedMsg(err error) (string, bool) {
+	switch err {
+	case service.ErrUserSuspended:
+		return "账号已被封禁", true
+	case service.ErrUserDeleted:
+		return "账号已注销", true
+	default:
+		return "", false
+	}
+}
+
+// passCaptcha 判断是否需要图形验证码，需要的话就校验票据
+// 返回 false 的时候已经写好了响应
+func (h *UserHandler) passCaptcha(ctx *gin.Context, biz, ticket string, keys ...string) bool {
+	required, err := h.captchaSvc.Required(ctx, biz, keys...)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		zap.L().Error("检查是否需要图形验证码失败", zap.Error(err))
+		return false
+	}
+	if !required {
+		return true
+	}
+	ok, err := h.captchaSvc.CheckTicket(ctx, biz, ticket)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		zap.L().Error("校验图形验证码票据失败", zap.Error(err))
+		return false
+	}
+	if !ok {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "请先完成图形验证码",
+			Data: captchaRequired,
+		})
+		return false
+	}
+	return true
+}
+
 func (h *UserHandler) Edit(ctx *gin.Context) {
 
 	type EditReq struct {
@@ -424,12 +747,14 @@ func (h *UserHandler) Profile(ctx *gin.Context) {
 
 	type User struct {
 		Nickname string `json:"nickname"`
+		Avatar   string `json:"avatar"`
 		Email    string `json:"email"`
 		AboutMe  string `json:"aboutMe"`
 		Birthday string `json:"birthday"`
 	}
 	ctx.JSON(http.StatusOK, User{
 		Nickname: u.NickName,
+		Avatar:   u.Avatar,
 		Email:    u.Email,
 		AboutMe:  u.AboutMe,
 		Birthday: u.BirthDay.Format(time.DateOnly),
@@ -440,20 +765,12 @@ func (h *UserHandler) Profile(ctx *gin.Context) {
 func (h *UserHandler) RefreshToken(ctx *gin.Context) {
 	// 约定 前端在 Authorization 里面带上这个 refresh_token
 	tokenStr := h.ExtractToken(ctx)
-	var rc ijwt.RefreshClaims
-	token, err := jwt.ParseWithClaims(tokenStr, &rc, func(token *jwt.Token) (interface{}, error) {
-		return ijwt.RefreshJWTKey, nil
-	})
+	rc, err := h.ParseRefreshToken(tokenStr)
 	if err != nil {
 		ctx.AbortWithStatus(http.StatusUnauthorized)
 		return
 	}
 
-	if token == nil || token.Valid {
-		ctx.AbortWithStatus(http.StatusUnauthorized)
-		return
-	}
-
 	// 在这里去校验 ssid 是否失效，因为我们可以在前面先校验一下 token ，避免无效的查询 Redis
 
 	//cnt, err := h.client.Exists(ctx, fmt.Sprintf("users:ssid:%s", rc.Ssid)).Result()
@@ -474,10 +791,14 @@ func (h *UserHandler) RefreshToken(ctx *gin.Context) {
 	//	return
 	//}
 
-	// 在这里重新设置新的JWTToken，并且重新生成了长token
-	// 但是之前的长token也还有效，所以缺点是如果别人拿到了之前的长token也还可以用
-	// 如果说我们就设置为7天登陆一次，那么这里就不要重新生成长token
-	err = h.SetJWTToken(ctx, rc.Uid, rc.Ssid)
+	// 每次刷新都会换一个新的长 token，旧的长 token 就不能再用了
+	// 如果别人偷了长 token 并且用过了，真正的用户再用的时候（或者反过来）会发现重复使用，整个 ssid 都会失效
+	err = h.RotateRefreshToken(ctx, rc)
+	if err == ijwt.ErrRefreshTokenReused {
+		zap.L().Warn("长 token 被重复使用，可能被盗", zap.Int64("uid", rc.Uid), zap.String("ssid", rc.Ssid))
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
 	if err != nil {
 		ctx.AbortWithStatus(http.StatusUnauthorized)
 		return
@@ -501,3 +822,93 @@ func (h *UserHandler) LogoutJWT(ctx *gin.Context) {
 		Msg: "退出登陆成功！",
 	})
 }
+
+func (h *UserHandler) Sessions(ctx *gin.Context) {
+	uc, ok := ctx.MustGet("user").(ijwt.UserClaims)
+	if !ok {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	sessions, err := h.Handler.Sessions(ctx, uc.Uid)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		zap.L().Error("查询登录设备失败", zap.Int64("uid", uc.Uid), zap.Error(err))
+		return
+	}
+	type Session struct {
+		Ssid      string `json:"ssid"`
+		Device    string `json:"device"`
+		UserAgent string `json:"userAgent"`
+		IP        string `json:"ip"`
+		LoginAt   int64  `json:"loginAt"`
+		LastSeen  int64  `json:"lastSeen"`
+		// 是不是当前正在使用的设备
+		Current bool `json:"current"`
+	}
+	res := make([]Session, 0, len(sessions))
+	for _, sess := range sessions {
+		res = append(res, Session{
+			Ssid:      sess.Ssid,
+			Device:    sess.Device,
+			UserAgent: sess.UserAgent,
+			IP:        sess.IP,
+			LoginAt:   sess.LoginAt.UnixMilli(),
+			LastSeen:  sess.LastSeen.UnixMilli(),
+			Current:   sess.Ssid == uc.Ssid,
+		})
+	}
+	ctx.JSON(http.StatusOK, Result{
+		Data: res,
+	})
+}
+
+func (h *UserHandler) RevokeSession(ctx *gin.Context) {
+	type Req struct {
+		// 要下线的设备
+		Ssid string `json:"ssid"`
+		// 为 true 的时候，除了当前设备，其它设备全部下线，忽略 Ssid
+		Others bool `json:"others"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		return
+	}
+	uc, ok := ctx.MustGet("user").(ijwt.UserClaims)
+	if !ok {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	var err error
+	switch {
+	case req.Others:
+		err = h.RevokeOtherSessions(ctx, uc.Uid, uc.Ssid)
+	case req.Ssid != "":
+		err = h.Handler.RevokeSession(ctx, uc.Uid, req.Ssid)
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "请选择要下线的设备",
+		})
+		return
+	}
+	switch err {
+	case nil:
+		ctx.JSON(http.StatusOK, Result{
+			Msg: "下线成功",
+		})
+	case ijwt.ErrSessionNotFound:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "设备不存在或者已经下线",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		zap.L().Error("下线设备失败", zap.Int64("uid", uc.Uid), zap.Error(err))
+	}
+}
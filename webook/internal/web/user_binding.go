@@ -0,0 +1,200 @@
+package web
+
+import (
+	"Learn_Go/webook/internal/domain"
+	"Learn_Go/webook/internal/service"
+	"Learn_Go/webook/internal/service/channel"
+	ijwt "Learn_Go/webook/internal/web/jwt"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+// 告诉前端这个手机号（邮箱、微信）已经属于其它账号了，可以让用户选择合并账号
+// 合并需要重新校验一次，然后带上 merge=true
+const bindingConflict = "binding_conflict"
+
+func (h *UserHandler) SendBindCode(ctx *gin.Context) {
+	type Req struct {
+		// 要绑定的邮箱或者手机号
+		Target string `json:"target"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		return
+	}
+	if _, ok := h.checkTarget(ctx, req.Target); !ok {
+		return
+	}
+	err := h.codeSvc.SendVia(ctx, bizBind, req.Target, "")
+	switch err {
+	case nil:
+		ctx.JSON(http.StatusOK, Result{
+			Msg: "发送成功",
+		})
+	case service.ErrCodeSendTooMany:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "发送太频繁，请稍后再试",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		zap.L().Error("发送绑定验证码失败", zap.Error(err))
+	}
+}
+
+type bindReq struct {
+	Phone string `json:"phone"`
+	Email string `json:"email"`
+	Code  string `json:"code"`
+	// 已经属于其它账号的时候，是否把那个账号合并过来
+	Merge bool `json:"merge"`
+}
+
+func (h *UserHandler) BindPhone(ctx *gin.Context) {
+	var req bindReq
+	if err := ctx.Bind(&req); err != nil {
+		return
+	}
+	if req.Phone == "" || channel.IsEmail(req.Phone) {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "请输入手机号",
+		})
+		return
+	}
+	if !h.verifyBindCode(ctx, req.Phone, req.Code) {
+		return
+	}
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	merged, err := h.svc.BindPhone(ctx, uc.Uid, req.Phone, req.Merge)
+	writeBindResult(ctx, h.Handler, uc.Uid, merged, err)
+}
+
+func (h *UserHandler) BindEmail(ctx *gin.Context) {
+	var req bindReq
+	if err := ctx.Bind(&req); err != nil {
+		return
+	}
+	// 和注册的时候一样校验邮箱格式，只有 @ 的地址不能绑定
+	isEmail, err := h.emailRexExp.MatchString(req.Email)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		return
+	}
+	if !isEmail {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "非法邮箱格式",
+		})
+		return
+	}
+	if !h.verifyBindCode(ctx, req.Email, req.Code) {
+		return
+	}
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	merged, err := h.svc.BindEmail(ctx, uc.Uid, req.Email, req.Merge)
+	writeBindResult(ctx, h.Handler, uc.Uid, merged, err)
+}
+
+// verifyBindCode 返回 false 的时候已经写好了响应
+func (h *UserHandler) verifyBindCode(ctx *gin.Context, target, code string) bool {
+	ok, err := h.codeSvc.Verify(ctx, bizBind, target, code)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		zap.L().Error("绑定验证码校验失败", zap.Error(err))
+		return false
+	}
+	if !ok {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "验证码错误，请重新输入",
+		})
+		return false
+	}
+	return true
+}
+
+// writeBindResult 手机号、邮箱和微信的绑定共用
+// 合并了其它账号的时候，那个账号已经注销了，它的设备全部下线
+func writeBindResult(ctx *gin.Context, sessions ijwt.Handler, uid, merged int64, err error) {
+	switch err {
+	case nil:
+	case service.ErrBindingConflict:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "已经绑定了其它账号",
+			Data: bindingConflict,
+		})
+		return
+	case service.ErrUserSuspended, service.ErrUserDeleted:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "已经绑定的账号不可用，不能合并",
+		})
+		return
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		zap.L().Error("绑定失败", zap.Int64("uid", uid), zap.Error(err))
+		return
+	}
+	if merged <= 0 {
+		ctx.JSON(http.StatusOK, Result{
+			Msg: "绑定成功",
+		})
+		return
+	}
+	if err = sessions.RevokeOtherSessions(ctx, merged, ""); err != nil {
+		zap.L().Error("合并账号之后下线设备失败", zap.Int64("uid", merged), zap.Error(err))
+	}
+	ctx.JSON(http.StatusOK, Result{
+		Msg: "合并成功",
+	})
+}
+
+func (h *UserHandler) Unbind(ctx *gin.Context) {
+	type Req struct {
+		// phone、email 或者第三方登录的名字，比如 wechat
+		Type string `json:"type"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		return
+	}
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	err := h.svc.Unbind(ctx, uc.Uid, domain.BindingType(req.Type))
+	switch err {
+	case nil:
+		ctx.JSON(http.StatusOK, Result{
+			Msg: "解绑成功",
+		})
+	case service.ErrLastBinding:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "至少要保留一种登录方式",
+		})
+	case service.ErrBindingTypeInvalid:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "不支持的绑定方式",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		zap.L().Error("解绑失败", zap.Int64("uid", uc.Uid), zap.Error(err))
+	}
+}
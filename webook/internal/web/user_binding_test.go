@@ -0,0 +1,167 @@
+package web
+
+import (
+	"Learn_Go/webook/internal/service"
+	svcmocks "Learn_Go/webook/internal/service/mocks"
+	ijwt "Learn_Go/webook/internal/web/jwt"
+	"Learn_Go/webook/pkg/ginx/authz"
+	"bytes"
+	"encoding/json"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUserHandler_BindPhone(t *testing.T) {
+	testCases := []struct {
+		name    string
+		mock    func(ctrl *gomock.Controller) (service.UserService, service.CodeService)
+		reqBody string
+
+		wantRes Result
+		// 被合并的账号是不是已经下线了
+		wantRevoked bool
+	}{
+		{
+			name: "绑定成功",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CodeService) {
+				userSvc := svcmocks.NewMockUserService(ctrl)
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				codeSvc.EXPECT().Verify(gomock.Any(), "bind", "15012345678", "123456").Return(true, nil)
+				userSvc.EXPECT().BindPhone(gomock.Any(), int64(123), "15012345678", false).Return(int64(0), nil)
+				return userSvc, codeSvc
+			},
+			reqBody: `{"phone":"15012345678","code":"123456"}`,
+			wantRes: Result{Msg: "绑定成功"},
+		},
+		{
+			name: "手机号属于其它账号",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CodeService) {
+				userSvc := svcmocks.NewMockUserService(ctrl)
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				codeSvc.EXPECT().Verify(gomock.Any(), "bind", "15012345678", "123456").Return(true, nil)
+				userSvc.EXPECT().BindPhone(gomock.Any(), int64(123), "15012345678", false).Return(int64(0), service.ErrBindingConflict)
+				return userSvc, codeSvc
+			},
+			reqBody: `{"phone":"15012345678","code":"123456"}`,
+			wantRes: Result{Code: 4, Msg: "已经绑定了其它账号", Data: bindingConflict},
+		},
+		{
+			name: "合并其它账号",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CodeService) {
+				userSvc := svcmocks.NewMockUserService(ctrl)
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				codeSvc.EXPECT().Verify(gomock.Any(), "bind", "15012345678", "123456").Return(true, nil)
+				userSvc.EXPECT().BindPhone(gomock.Any(), int64(123), "15012345678", true).Return(int64(456), nil)
+				return userSvc, codeSvc
+			},
+			reqBody:     `{"phone":"15012345678","code":"123456","merge":true}`,
+			wantRes:     Result{Msg: "合并成功"},
+			wantRevoked: true,
+		},
+		{
+			name: "不能把邮箱当成手机号绑定",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CodeService) {
+				return svcmocks.NewMockUserService(ctrl), svcmocks.NewMockCodeService(ctrl)
+			},
+			reqBody: `{"phone":"123@qq.com","code":"123456"}`,
+			wantRes: Result{Code: 4, Msg: "请输入手机号"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mr := miniredis.RunT(t)
+			client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			// 被合并的账号已经在一个设备上登录了
+			mr.ZAdd("users:sessions:456", 1, "ssid-456")
+
+			userSvc, codeSvc := tc.mock(ctrl)
+			h := NewUserHandler(userSvc, codeSvc, svcmocks.NewMockCaptchaService(ctrl), svcmocks.NewMockMFAService(ctrl),
+				ijwt.NewRedisJWTHandler(client, ijwt.NewKeyManager(0), 0))
+			server := gin.New()
+			server.Use(func(ctx *gin.Context) {
+				ctx.Set("user", ijwt.UserClaims{Uid: 123})
+			})
+			h.RegisterRouters(authz.NewRouter(server, authz.NewRegistry()))
+
+			req, err := http.NewRequest(http.MethodPost, "/users/bind/phone", bytes.NewReader([]byte(tc.reqBody)))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			var res Result
+			require.NoError(t, json.NewDecoder(recorder.Body).Decode(&res))
+			assert.Equal(t, tc.wantRes, res)
+			assert.Equal(t, tc.wantRevoked, mr.Exists("users:ssid:ssid-456"))
+		})
+	}
+}
+
+func TestUserHandler_BindEmail(t *testing.T) {
+	testCases := []struct {
+		name    string
+		mock    func(ctrl *gomock.Controller) (service.UserService, service.CodeService)
+		reqBody string
+
+		wantRes Result
+	}{
+		{
+			name: "绑定成功",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CodeService) {
+				userSvc := svcmocks.NewMockUserService(ctrl)
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				codeSvc.EXPECT().Verify(gomock.Any(), "bind", "123@qq.com", "123456").Return(true, nil)
+				userSvc.EXPECT().BindEmail(gomock.Any(), int64(123), "123@qq.com", false).Return(int64(0), nil)
+				return userSvc, codeSvc
+			},
+			reqBody: `{"email":"123@qq.com","code":"123456"}`,
+			wantRes: Result{Msg: "绑定成功"},
+		},
+		{
+			// 带 @ 但是不符合注册时候的邮箱格式
+			name: "非法邮箱格式",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CodeService) {
+				return svcmocks.NewMockUserService(ctrl), svcmocks.NewMockCodeService(ctrl)
+			},
+			reqBody: `{"email":"123@","code":"123456"}`,
+			wantRes: Result{Code: 4, Msg: "非法邮箱格式"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mr := miniredis.RunT(t)
+			client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+
+			userSvc, codeSvc := tc.mock(ctrl)
+			h := NewUserHandler(userSvc, codeSvc, svcmocks.NewMockCaptchaService(ctrl), svcmocks.NewMockMFAService(ctrl),
+				ijwt.NewRedisJWTHandler(client, ijwt.NewKeyManager(0), 0))
+			server := gin.New()
+			server.Use(func(ctx *gin.Context) {
+				ctx.Set("user", ijwt.UserClaims{Uid: 123})
+			})
+			h.RegisterRouters(authz.NewRouter(server, authz.NewRegistry()))
+
+			req, err := http.NewRequest(http.MethodPost, "/users/bind/email", bytes.NewReader([]byte(tc.reqBody)))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			var res Result
+			require.NoError(t, json.NewDecoder(recorder.Body).Decode(&res))
+			assert.Equal(t, tc.wantRes, res)
+		})
+	}
+}
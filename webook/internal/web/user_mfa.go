@@ -0,0 +1,260 @@
+package web
+
+import (
+	"Learn_Go/webook/internal/service"
+	ijwt "Learn_Go/webook/internal/web/jwt"
+	"encoding/base64"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+)
+
+// mfaRequired 密码正确，但是还需要两步验证，前端拿着 mfaToken 调用 /users/login/mfa
+type mfaRequired struct {
+	MFARequired bool   `json:"mfaRequired"`
+	MFAToken    string `json:"mfaToken"`
+}
+
+// requireMFA 开启了两步验证的用户，先不登录，返回两步验证的凭证
+// 返回 true 的时候已经写好了响应
+func (h *UserHandler) requireMFA(ctx *gin.Context, uid int64) bool {
+	enabled, err := h.mfaSvc.Enabled(ctx, uid)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		zap.L().Error("查询是否开启两步验证失败", zap.Int64("uid", uid), zap.Error(err))
+		return true
+	}
+	if !enabled {
+		return false
+	}
+	token, err := h.SetMFAChallengeToken(ctx, uid)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		zap.L().Error("签发两步验证凭证失败", zap.Int64("uid", uid), zap.Error(err))
+		return true
+	}
+	ctx.JSON(http.StatusOK, Result{
+		Msg:  "请输入两步验证的验证码",
+		Data: mfaRequired{MFARequired: true, MFAToken: token},
+	})
+	return true
+}
+
+func (h *UserHandler) LoginMFA(ctx *gin.Context) {
+	type Req struct {
+		MFAToken string `json:"mfaToken"`
+		// 验证器应用上的 6 位验证码，或者一个恢复码
+		Code string `json:"code"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		return
+	}
+	mc, err := h.ParseMFAChallengeToken(ctx, req.MFAToken)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "验证已过期，请重新登录",
+		})
+		return
+	}
+	err = h.mfaSvc.Verify(ctx, mc.Uid, req.Code)
+	switch err {
+	case nil:
+	case service.ErrMFACodeWrong:
+		err = h.RecordMFAFailure(ctx, mc)
+		if err == ijwt.ErrMFAChallengeInvalid {
+			ctx.JSON(http.StatusOK, Result{
+				Code: 4,
+				Msg:  "验证码错误次数过多，请重新登录",
+			})
+			return
+		}
+		if err != nil {
+			zap.L().Error("记录两步验证失败次数失败", zap.Int64("uid", mc.Uid), zap.Error(err))
+		}
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "验证码错误",
+		})
+		return
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		zap.L().Error("两步验证失败", zap.Int64("uid", mc.Uid), zap.Error(err))
+		return
+	}
+	// 凭证只能用一次，防止被截获之后重放
+	err = h.ConsumeMFAChallenge(ctx, mc)
+	if err == ijwt.ErrMFAChallengeInvalid {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "验证已过期，请重新登录",
+		})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		return
+	}
+	if err = h.SetLoginToken(ctx, mc.Uid); err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		return
+	}
+	h.resetLoginCaptcha(ctx, mc.Uid)
+	ctx.JSON(http.StatusOK, Result{
+		Msg: "登陆成功",
+	})
+}
+
+// resetLoginCaptcha 密码和两步验证都通过了，才清空密码登录的失败次数
+// 只清空账号维度的，IP 维度的让它自己过期
+func (h *UserHandler) resetLoginCaptcha(ctx *gin.Context, uid int64) {
+	u, err := h.svc.FindById(ctx, uid)
+	if err != nil {
+		zap.L().Error("清空登录失败次数失败", zap.Int64("uid", uid), zap.Error(err))
+		return
+	}
+	if err = h.captchaSvc.Reset(ctx, captchaBizLogin, "email:"+u.Email); err != nil {
+		zap.L().Error("清空登录失败次数失败", zap.Int64("uid", uid), zap.Error(err))
+	}
+}
+
+func (h *UserHandler) EnrollTOTP(ctx *gin.Context) {
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	u, err := h.svc.FindById(ctx, uc.Uid)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		return
+	}
+	// 验证器应用上显示的账号名
+	account := u.Email
+	if account == "" {
+		account = u.Phone
+	}
+	if account == "" {
+		account = strconv.FormatInt(u.Id, 10)
+	}
+	enrollment, err := h.mfaSvc.Enroll(ctx, uc.Uid, account)
+	switch err {
+	case nil:
+		type Enrollment struct {
+			Secret string `json:"secret"`
+			URI    string `json:"uri"`
+			// base64 编码的 PNG 图片
+			QRCode string `json:"qrCode"`
+		}
+		ctx.JSON(http.StatusOK, Result{
+			Data: Enrollment{
+				Secret: enrollment.Secret,
+				URI:    enrollment.URI,
+				QRCode: base64.StdEncoding.EncodeToString(enrollment.QRCode),
+			},
+		})
+	case service.ErrMFAAlreadyEnabled:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "已经开启了两步验证",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		zap.L().Error("绑定验证器应用失败", zap.Int64("uid", uc.Uid), zap.Error(err))
+	}
+}
+
+func (h *UserHandler) ConfirmTOTP(ctx *gin.Context) {
+	type Req struct {
+		Code string `json:"code"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		return
+	}
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	codes, err := h.mfaSvc.Confirm(ctx, uc.Uid, req.Code)
+	switch err {
+	case nil:
+		// 恢复码只有这一次能看到
+		ctx.JSON(http.StatusOK, Result{
+			Msg:  "两步验证已开启，请妥善保存恢复码",
+			Data: codes,
+		})
+	case service.ErrMFACodeWrong:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "验证码错误",
+		})
+	case service.ErrMFANotEnrolled:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "请先绑定验证器应用",
+		})
+	case service.ErrMFAAlreadyEnabled:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "已经开启了两步验证",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		zap.L().Error("确认两步验证失败", zap.Int64("uid", uc.Uid), zap.Error(err))
+	}
+}
+
+func (h *UserHandler) DisableTOTP(ctx *gin.Context) {
+	type Req struct {
+		// 验证码或者恢复码
+		Code string `json:"code"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		return
+	}
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	err := h.mfaSvc.Disable(ctx, uc.Uid, req.Code)
+	switch err {
+	case nil:
+		ctx.JSON(http.StatusOK, Result{
+			Msg: "两步验证已关闭",
+		})
+	case service.ErrMFACodeWrong:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "验证码错误",
+		})
+	case service.ErrMFANotEnrolled:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "没有开启两步验证",
+		})
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		zap.L().Error("关闭两步验证失败", zap.Int64("uid", uc.Uid), zap.Error(err))
+	}
+}
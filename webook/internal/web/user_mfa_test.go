@@ -0,0 +1,94 @@
+package web
+
+import (
+	"Learn_Go/webook/internal/domain"
+	"Learn_Go/webook/internal/service"
+	svcmocks "Learn_Go/webook/internal/service/mocks"
+	"Learn_Go/webook/pkg/ginx/authz"
+	"bytes"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUserHandler_LoginMFA(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) (service.UserService, service.CaptchaService, service.MFAService)
+
+		code    string
+		wantRes Result
+	}{
+		{
+			name: "两步验证通过之后才清空失败次数",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CaptchaService, service.MFAService) {
+				userSvc := svcmocks.NewMockUserService(ctrl)
+				captchaSvc := svcmocks.NewMockCaptchaService(ctrl)
+				mfaSvc := svcmocks.NewMockMFAService(ctrl)
+				captchaSvc.EXPECT().Required(gomock.Any(), "login", "email:123@qq.com", gomock.Any()).Return(false, nil)
+				userSvc.EXPECT().Login(gomock.Any(), "123@qq.com", "hello#world123").Return(domain.User{Id: 123}, nil)
+				mfaSvc.EXPECT().Enabled(gomock.Any(), int64(123)).Return(true, nil)
+				mfaSvc.EXPECT().Verify(gomock.Any(), int64(123), "123456").Return(nil)
+				userSvc.EXPECT().FindById(gomock.Any(), int64(123)).Return(domain.User{Id: 123, Email: "123@qq.com"}, nil)
+				captchaSvc.EXPECT().Reset(gomock.Any(), "login", "email:123@qq.com").Return(nil)
+				return userSvc, captchaSvc, mfaSvc
+			},
+			code:    "123456",
+			wantRes: Result{Msg: "登陆成功"},
+		},
+		{
+			// 只知道密码的人不能通过登录成功来清空失败次数
+			name: "两步验证失败，不清空失败次数",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CaptchaService, service.MFAService) {
+				userSvc := svcmocks.NewMockUserService(ctrl)
+				captchaSvc := svcmocks.NewMockCaptchaService(ctrl)
+				mfaSvc := svcmocks.NewMockMFAService(ctrl)
+				captchaSvc.EXPECT().Required(gomock.Any(), "login", "email:123@qq.com", gomock.Any()).Return(false, nil)
+				userSvc.EXPECT().Login(gomock.Any(), "123@qq.com", "hello#world123").Return(domain.User{Id: 123}, nil)
+				mfaSvc.EXPECT().Enabled(gomock.Any(), int64(123)).Return(true, nil)
+				mfaSvc.EXPECT().Verify(gomock.Any(), int64(123), "654321").Return(service.ErrMFACodeWrong)
+				return userSvc, captchaSvc, mfaSvc
+			},
+			code:    "654321",
+			wantRes: Result{Code: 4, Msg: "验证码错误"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			userSvc, captchaSvc, mfaSvc := tc.mock(ctrl)
+			server := gin.New()
+			h := NewUserHandler(userSvc, svcmocks.NewMockCodeService(ctrl), captchaSvc, mfaSvc, newTestJWTHandler(t))
+			h.RegisterRouters(authz.NewRouter(server, authz.NewRegistry()))
+			do := func(path, body string) Result {
+				req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader([]byte(body)))
+				req.Header.Set("Content-Type", "application/json")
+				recorder := httptest.NewRecorder()
+				server.ServeHTTP(recorder, req)
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var res Result
+				require.NoError(t, json.NewDecoder(recorder.Body).Decode(&res))
+				return res
+			}
+
+			// 密码正确，拿到两步验证的凭证
+			res := do("/users/login", `{"email":"123@qq.com","password":"hello#world123"}`)
+			data, ok := res.Data.(map[string]any)
+			require.True(t, ok)
+			token, _ := data["mfaToken"].(string)
+			require.NotEmpty(t, token)
+
+			reqBody, err := json.Marshal(map[string]string{"mfaToken": token, "code": tc.code})
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantRes, do("/users/login/mfa", string(reqBody)))
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package web
+
+import (
+	"Learn_Go/webook/internal/domain"
+	"Learn_Go/webook/internal/service"
+	"Learn_Go/webook/internal/service/channel"
+	ijwt "Learn_Go/webook/internal/web/jwt"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+)
+
+// checkNewPassword 校验新密码的格式，和注册的时候要求一样
+// 返回 false 的时候已经写好了响应
+func (h *UserHandler) checkNewPassword(ctx *gin.Context, password, confirmPassword string) bool {
+	if password != confirmPassword {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "两次密码输入不一致",
+		})
+		return false
+	}
+	ok, err := h.passwordRexExp.MatchString(password)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		return false
+	}
+	if !ok {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "密码必须包含字母、数字、特殊字符，并且不少于八位",
+		})
+		return false
+	}
+	return true
+}
+
+func (h *UserHandler) ChangePassword(ctx *gin.Context) {
+	type Req struct {
+		OldPassword     string `json:"oldPassword"`
+		NewPassword     string `json:"newPassword"`
+		ConfirmPassword string `json:"confirmPassword"`
+		CaptchaTicket   string `json:"captchaTicket"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		return
+	}
+	uc := ctx.MustGet("user").(ijwt.UserClaims)
+	if !h.checkNewPassword(ctx, req.NewPassword, req.ConfirmPassword) {
+		return
+	}
+	// 原密码错误次数过多，就要求先通过图形验证码
+	captchaKey := "uid:" + strconv.FormatInt(uc.Uid, 10)
+	if !h.passCaptcha(ctx, captchaBizChangePassword, req.CaptchaTicket, captchaKey) {
+		return
+	}
+	err := h.svc.ChangePassword(ctx, uc.Uid, req.OldPassword, req.NewPassword)
+	switch err {
+	case nil:
+		if err = h.captchaSvc.Reset(ctx, captchaBizChangePassword, captchaKey); err != nil {
+			zap.L().Error("清空修改密码失败次数失败", zap.Int64("uid", uc.Uid), zap.Error(err))
+		}
+	case service.ErrOldPasswordWrong:
+		if err = h.captchaSvc.RecordFailure(ctx, captchaBizChangePassword, captchaKey); err != nil {
+			zap.L().Error("记录修改密码失败次数失败", zap.Int64("uid", uc.Uid), zap.Error(err))
+		}
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "原密码错误",
+		})
+		return
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		zap.L().Error("修改密码失败", zap.Int64("uid", uc.Uid), zap.Error(err))
+		return
+	}
+	// 密码可能已经泄露了，其它设备全部下线，当前设备不受影响
+	if err = h.RevokeOtherSessions(ctx, uc.Uid, uc.Ssid); err != nil {
+		zap.L().Error("修改密码之后下线其它设备失败", zap.Int64("uid", uc.Uid), zap.Error(err))
+	}
+	ctx.JSON(http.StatusOK, Result{
+		Msg: "修改成功",
+	})
+}
+
+// checkTarget 校验接收验证码的邮箱或者手机号，返回图形验证码计数用的 key
+// 返回 false 的时候已经写好了响应
+func (h *UserHandler) checkTarget(ctx *gin.Context, target string) (captchaKey string, ok bool) {
+	if target == "" {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "请输入邮箱或者手机号",
+		})
+		return "", false
+	}
+	if !channel.IsEmail(target) {
+		return "phone:" + target, true
+	}
+	isEmail, err := h.emailRexExp.MatchString(target)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		return "", false
+	}
+	if !isEmail {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "非法邮箱格式",
+		})
+		return "", false
+	}
+	return "email:" + target, true
+}
+
+func (h *UserHandler) findByTarget(ctx *gin.Context, target string) (domain.User, error) {
+	if channel.IsEmail(target) {
+		return h.svc.FindByEmail(ctx, target)
+	}
+	return h.svc.FindByPhone(ctx, target)
+}
+
+func (h *UserHandler) SendResetPasswordCode(ctx *gin.Context) {
+	type Req struct {
+		// 邮箱或者手机号
+		Target        string `json:"target"`
+		CaptchaTicket string `json:"captchaTicket"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		return
+	}
+	captchaKey, ok := h.checkTarget(ctx, req.Target)
+	if !ok {
+		return
+	}
+	captchaKeys := []string{captchaKey, "ip:" + ctx.ClientIP()}
+	if !h.passCaptcha(ctx, captchaBizResetPassword, req.CaptchaTicket, captchaKeys...) {
+		return
+	}
+	if err := h.captchaSvc.RecordFailure(ctx, captchaBizResetPassword, captchaKeys...); err != nil {
+		zap.L().Error("记录验证码发送次数失败", zap.Error(err))
+	}
+
+	_, err := h.findByTarget(ctx, req.Target)
+	switch err {
+	case nil:
+	case service.ErrUserNotFound:
+		// 和发送成功的响应一样，不让别人用这个接口试出来哪些邮箱和手机号注册过
+		ctx.JSON(http.StatusOK, Result{
+			Msg: "发送成功",
+		})
+		return
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		zap.L().Error("查询用户失败", zap.Error(err))
+		return
+	}
+
+	err = h.codeSvc.SendVia(ctx, bizResetPassword, req.Target, "")
+	switch err {
+	case nil:
+		ctx.JSON(http.StatusOK, Result{
+			Msg: "发送成功",
+		})
+	case service.ErrCodeSendTooMany:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "发送太频繁，请稍后再试",
+		})
+		zap.L().Warn("频繁发送重置密码验证码")
+	default:
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		zap.L().Error("发送重置密码验证码失败", zap.Error(err))
+	}
+}
+
+func (h *UserHandler) ResetPassword(ctx *gin.Context) {
+	type Req struct {
+		Target          string `json:"target"`
+		Code            string `json:"code"`
+		NewPassword     string `json:"newPassword"`
+		ConfirmPassword string `json:"confirmPassword"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		return
+	}
+	// 先校验密码格式，格式不对的时候不要浪费验证码的校验次数
+	if !h.checkNewPassword(ctx, req.NewPassword, req.ConfirmPassword) {
+		return
+	}
+	ok, err := h.codeSvc.Verify(ctx, bizResetPassword, req.Target, req.Code)
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		zap.L().Error("重置密码验证码校验失败", zap.Error(err))
+		return
+	}
+	if !ok {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 4,
+			Msg:  "验证码错误，请重新输入",
+		})
+		return
+	}
+
+	u, err := h.findByTarget(ctx, req.Target)
+	if err == nil {
+		err = h.svc.ResetPassword(ctx, u.Id, req.NewPassword)
+	}
+	if err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		zap.L().Error("重置密码失败", zap.Error(err))
+		return
+	}
+	// 忘记密码说明账号可能已经不安全了，所有设备全部下线，用新密码重新登录
+	if err = h.RevokeOtherSessions(ctx, u.Id, ""); err != nil {
+		ctx.JSON(http.StatusOK, Result{
+			Code: 5,
+			Msg:  "系统错误",
+		})
+		zap.L().Error("重置密码之后下线设备失败", zap.Int64("uid", u.Id), zap.Error(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{
+		Msg: "重置成功，请重新登录",
+	})
+}
@@ -0,0 +1,183 @@
+package web
+
+import (
+	"Learn_Go/webook/internal/domain"
+	"Learn_Go/webook/internal/service"
+	svcmocks "Learn_Go/webook/internal/service/mocks"
+	ijwt "Learn_Go/webook/internal/web/jwt"
+	"Learn_Go/webook/pkg/ginx/authz"
+	"bytes"
+	"encoding/json"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUserHandler_ResetPassword(t *testing.T) {
+	testCases := []struct {
+		name    string
+		mock    func(ctrl *gomock.Controller) (service.UserService, service.CodeService)
+		reqBody string
+
+		wantRes Result
+		// 所有设备是不是已经下线了
+		wantRevoked bool
+	}{
+		{
+			name: "用邮箱重置成功",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CodeService) {
+				userSvc := svcmocks.NewMockUserService(ctrl)
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				codeSvc.EXPECT().Verify(gomock.Any(), "reset_password", "123@qq.com", "123456").Return(true, nil)
+				userSvc.EXPECT().FindByEmail(gomock.Any(), "123@qq.com").Return(domain.User{Id: 123}, nil)
+				userSvc.EXPECT().ResetPassword(gomock.Any(), int64(123), "hello#world123").Return(nil)
+				return userSvc, codeSvc
+			},
+			reqBody:     `{"target":"123@qq.com","code":"123456","newPassword":"hello#world123","confirmPassword":"hello#world123"}`,
+			wantRes:     Result{Msg: "重置成功，请重新登录"},
+			wantRevoked: true,
+		},
+		{
+			name: "用手机号重置成功",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CodeService) {
+				userSvc := svcmocks.NewMockUserService(ctrl)
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				codeSvc.EXPECT().Verify(gomock.Any(), "reset_password", "15012345678", "123456").Return(true, nil)
+				userSvc.EXPECT().FindByPhone(gomock.Any(), "15012345678").Return(domain.User{Id: 123}, nil)
+				userSvc.EXPECT().ResetPassword(gomock.Any(), int64(123), "hello#world123").Return(nil)
+				return userSvc, codeSvc
+			},
+			reqBody:     `{"target":"15012345678","code":"123456","newPassword":"hello#world123","confirmPassword":"hello#world123"}`,
+			wantRes:     Result{Msg: "重置成功，请重新登录"},
+			wantRevoked: true,
+		},
+		{
+			name: "验证码错误",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CodeService) {
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				codeSvc.EXPECT().Verify(gomock.Any(), "reset_password", "123@qq.com", "123456").Return(false, nil)
+				return svcmocks.NewMockUserService(ctrl), codeSvc
+			},
+			reqBody: `{"target":"123@qq.com","code":"123456","newPassword":"hello#world123","confirmPassword":"hello#world123"}`,
+			wantRes: Result{Code: 4, Msg: "验证码错误，请重新输入"},
+		},
+		{
+			name: "新密码格式不对，不校验验证码",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CodeService) {
+				return svcmocks.NewMockUserService(ctrl), svcmocks.NewMockCodeService(ctrl)
+			},
+			reqBody: `{"target":"123@qq.com","code":"123456","newPassword":"hello","confirmPassword":"hello"}`,
+			wantRes: Result{Code: 4, Msg: "密码必须包含字母、数字、特殊字符，并且不少于八位"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mr := miniredis.RunT(t)
+			client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			// 用户已经在一个设备上登录了
+			mr.ZAdd("users:sessions:123", 1, "ssid-123")
+
+			userSvc, codeSvc := tc.mock(ctrl)
+			h := NewUserHandler(userSvc, codeSvc, svcmocks.NewMockCaptchaService(ctrl), svcmocks.NewMockMFAService(ctrl),
+				ijwt.NewRedisJWTHandler(client, ijwt.NewKeyManager(0), 0))
+			server := gin.New()
+			h.RegisterRouters(authz.NewRouter(server, authz.NewRegistry()))
+
+			req, err := http.NewRequest(http.MethodPost, "/users/password/reset", bytes.NewReader([]byte(tc.reqBody)))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			var res Result
+			require.NoError(t, json.NewDecoder(recorder.Body).Decode(&res))
+			assert.Equal(t, tc.wantRes, res)
+			assert.Equal(t, tc.wantRevoked, mr.Exists("users:ssid:ssid-123"))
+		})
+	}
+}
+
+func TestUserHandler_ChangePassword(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) (service.UserService, service.CaptchaService)
+
+		reqBody string
+		wantRes Result
+	}{
+		{
+			name: "修改成功，清空失败次数",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CaptchaService) {
+				userSvc := svcmocks.NewMockUserService(ctrl)
+				captchaSvc := svcmocks.NewMockCaptchaService(ctrl)
+				captchaSvc.EXPECT().Required(gomock.Any(), "change_password", "uid:123").Return(false, nil)
+				userSvc.EXPECT().ChangePassword(gomock.Any(), int64(123), "hello#world123", "hello#world456").Return(nil)
+				captchaSvc.EXPECT().Reset(gomock.Any(), "change_password", "uid:123").Return(nil)
+				return userSvc, captchaSvc
+			},
+			reqBody: `{"oldPassword":"hello#world123","newPassword":"hello#world456","confirmPassword":"hello#world456"}`,
+			wantRes: Result{Msg: "修改成功"},
+		},
+		{
+			name: "原密码错误，记一次失败",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CaptchaService) {
+				userSvc := svcmocks.NewMockUserService(ctrl)
+				captchaSvc := svcmocks.NewMockCaptchaService(ctrl)
+				captchaSvc.EXPECT().Required(gomock.Any(), "change_password", "uid:123").Return(false, nil)
+				userSvc.EXPECT().ChangePassword(gomock.Any(), int64(123), "hello#world000", "hello#world456").
+					Return(service.ErrOldPasswordWrong)
+				captchaSvc.EXPECT().RecordFailure(gomock.Any(), "change_password", "uid:123").Return(nil)
+				return userSvc, captchaSvc
+			},
+			reqBody: `{"oldPassword":"hello#world000","newPassword":"hello#world456","confirmPassword":"hello#world456"}`,
+			wantRes: Result{Code: 4, Msg: "原密码错误"},
+		},
+		{
+			name: "失败次数过多，没有图形验证码票据",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CaptchaService) {
+				captchaSvc := svcmocks.NewMockCaptchaService(ctrl)
+				captchaSvc.EXPECT().Required(gomock.Any(), "change_password", "uid:123").Return(true, nil)
+				captchaSvc.EXPECT().CheckTicket(gomock.Any(), "change_password", "").Return(false, nil)
+				return svcmocks.NewMockUserService(ctrl), captchaSvc
+			},
+			reqBody: `{"oldPassword":"hello#world000","newPassword":"hello#world456","confirmPassword":"hello#world456"}`,
+			wantRes: Result{Code: 4, Msg: "请先完成图形验证码", Data: "captcha_required"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mr := miniredis.RunT(t)
+			client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+
+			userSvc, captchaSvc := tc.mock(ctrl)
+			h := NewUserHandler(userSvc, svcmocks.NewMockCodeService(ctrl), captchaSvc, svcmocks.NewMockMFAService(ctrl),
+				ijwt.NewRedisJWTHandler(client, ijwt.NewKeyManager(0), 0))
+			server := gin.New()
+			server.Use(func(ctx *gin.Context) {
+				ctx.Set("user", ijwt.UserClaims{Uid: 123, Ssid: "ssid-123"})
+			})
+			h.RegisterRouters(authz.NewRouter(server, authz.NewRegistry()))
+
+			req, err := http.NewRequest(http.MethodPost, "/users/password/change", bytes.NewReader([]byte(tc.reqBody)))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			var res Result
+			require.NoError(t, json.NewDecoder(recorder.Body).Decode(&res))
+			assert.Equal(t, tc.wantRes, res)
+		})
+	}
+}
@@ -4,18 +4,24 @@ import (
 	"Learn_Go/webook/internal/domain"
 	"Learn_Go/webook/internal/repository"
 	"Learn_Go/webook/internal/service"
+	"Learn_Go/webook/internal/service/channel"
 	svcmocks "Learn_Go/webook/internal/service/mocks"
 	ijwt "Learn_Go/webook/internal/web/jwt"
+	"Learn_Go/webook/pkg/ginx/authz"
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"github.com/alicebob/miniredis/v2"
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 //func TestHTTP(t *testing.T) {
@@ -290,8 +296,8 @@ func TestUserHandler_SignUp(t *testing.T) {
 			userSvc, codeSvc := tc.mock(ctrl)
 
 			server := gin.Default()
-			h := NewUserHandler(userSvc, codeSvc, ijwt.NewRedisJWTHandler(redis.NewClient(&redis.Options{Addr: ""})))
-			h.RegisterRouters(server)
+			h := NewUserHandler(userSvc, codeSvc, svcmocks.NewMockCaptchaService(ctrl), svcmocks.NewMockMFAService(ctrl), ijwt.NewRedisJWTHandler(redis.NewClient(&redis.Options{Addr: ""}), ijwt.NewKeyManager(0), 0))
+			h.RegisterRouters(authz.NewRouter(server, authz.NewRegistry()))
 
 			req := tc.reqBuilder(t)
 
@@ -327,3 +333,424 @@ func TestMock(t *testing.T) {
 	t.Log(err)
 
 }
+
+func TestUserHandler_SendSMSLoginCode(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) (service.CodeService, service.CaptchaService)
+
+		reqBody  string
+		wantCode int
+		wantRes  Result
+	}{
+		{
+			name: "发送成功",
+			mock: func(ctrl *gomock.Controller) (service.CodeService, service.CaptchaService) {
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				captchaSvc := svcmocks.NewMockCaptchaService(ctrl)
+				captchaSvc.EXPECT().Required(gomock.Any(), "login_sms", "phone:15012345678", gomock.Any()).Return(false, nil)
+				captchaSvc.EXPECT().RecordFailure(gomock.Any(), "login_sms", "phone:15012345678", gomock.Any()).Return(nil)
+				codeSvc.EXPECT().SendVia(gomock.Any(), "login_sms", "15012345678", channel.Channel("")).Return(nil)
+				return codeSvc, captchaSvc
+			},
+			reqBody:  `{"phone":"15012345678"}`,
+			wantCode: http.StatusOK,
+			wantRes:  Result{Msg: "发送成功"},
+		},
+		{
+			name: "需要图形验证码，但是没有票据",
+			mock: func(ctrl *gomock.Controller) (service.CodeService, service.CaptchaService) {
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				captchaSvc := svcmocks.NewMockCaptchaService(ctrl)
+				captchaSvc.EXPECT().Required(gomock.Any(), "login_sms", "phone:15012345678", gomock.Any()).Return(true, nil)
+				captchaSvc.EXPECT().CheckTicket(gomock.Any(), "login_sms", "").Return(false, nil)
+				return codeSvc, captchaSvc
+			},
+			reqBody:  `{"phone":"15012345678"}`,
+			wantCode: http.StatusOK,
+			wantRes:  Result{Code: 4, Msg: "请先完成图形验证码", Data: captchaRequired},
+		},
+		{
+			name: "需要图形验证码，票据有效",
+			mock: func(ctrl *gomock.Controller) (service.CodeService, service.CaptchaService) {
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				captchaSvc := svcmocks.NewMockCaptchaService(ctrl)
+				captchaSvc.EXPECT().Required(gomock.Any(), "login_sms", "phone:15012345678", gomock.Any()).Return(true, nil)
+				captchaSvc.EXPECT().CheckTicket(gomock.Any(), "login_sms", "ticket").Return(true, nil)
+				captchaSvc.EXPECT().RecordFailure(gomock.Any(), "login_sms", "phone:15012345678", gomock.Any()).Return(nil)
+				codeSvc.EXPECT().SendVia(gomock.Any(), "login_sms", "15012345678", channel.Channel("")).Return(nil)
+				return codeSvc, captchaSvc
+			},
+			reqBody:  `{"phone":"15012345678","captchaTicket":"ticket"}`,
+			wantCode: http.StatusOK,
+			wantRes:  Result{Msg: "发送成功"},
+		},
+		{
+			// 邮箱会被当成邮件发出去，之后用来在 LoginSMS 里面创建账号
+			name: "手机号填了邮箱",
+			mock: func(ctrl *gomock.Controller) (service.CodeService, service.CaptchaService) {
+				return svcmocks.NewMockCodeService(ctrl), svcmocks.NewMockCaptchaService(ctrl)
+			},
+			reqBody:  `{"phone":"123@qq.com"}`,
+			wantCode: http.StatusOK,
+			wantRes:  Result{Code: 4, Msg: "非法手机号格式"},
+		},
+		{
+			name: "不能选择邮件发送",
+			mock: func(ctrl *gomock.Controller) (service.CodeService, service.CaptchaService) {
+				return svcmocks.NewMockCodeService(ctrl), svcmocks.NewMockCaptchaService(ctrl)
+			},
+			reqBody:  `{"phone":"15012345678","channel":"email"}`,
+			wantCode: http.StatusOK,
+			wantRes:  Result{Code: 4, Msg: "不支持的发送方式"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			codeSvc, captchaSvc := tc.mock(ctrl)
+			server := gin.Default()
+			h := NewUserHandler(svcmocks.NewMockUserService(ctrl), codeSvc, captchaSvc, svcmocks.NewMockMFAService(ctrl), ijwt.NewRedisJWTHandler(redis.NewClient(&redis.Options{Addr: ""}), ijwt.NewKeyManager(0), 0))
+			h.RegisterRouters(authz.NewRouter(server, authz.NewRegistry()))
+
+			req, err := http.NewRequest(http.MethodPost, "/users/login_sms/code/send", bytes.NewReader([]byte(tc.reqBody)))
+			req.Header.Set("Content-Type", "application/json")
+			assert.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tc.wantCode, recorder.Code)
+			var res Result
+			err = json.NewDecoder(recorder.Body).Decode(&res)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantRes, res)
+		})
+	}
+}
+
+func TestUserHandler_LogInJWT(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) (service.UserService, service.CaptchaService, service.MFAService)
+
+		reqBody  string
+		wantBody string
+	}{
+		{
+			name: "登录成功，只清空账号的失败次数",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CaptchaService, service.MFAService) {
+				userSvc := svcmocks.NewMockUserService(ctrl)
+				captchaSvc := svcmocks.NewMockCaptchaService(ctrl)
+				mfaSvc := svcmocks.NewMockMFAService(ctrl)
+				captchaSvc.EXPECT().Required(gomock.Any(), "login", "email:123@qq.com", gomock.Any()).Return(false, nil)
+				userSvc.EXPECT().Login(gomock.Any(), "123@qq.com", "hello#world123").Return(domain.User{Id: 123}, nil)
+				// IP 的计数不能被清空
+				captchaSvc.EXPECT().Reset(gomock.Any(), "login", "email:123@qq.com").Return(nil)
+				mfaSvc.EXPECT().Enabled(gomock.Any(), int64(123)).Return(false, nil)
+				return userSvc, captchaSvc, mfaSvc
+			},
+			reqBody:  `{"email":"123@qq.com","password":"hello#world123"}`,
+			wantBody: "登陆成功！",
+		},
+		{
+			name: "密码错误，账号和 IP 都记一次失败",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CaptchaService, service.MFAService) {
+				userSvc := svcmocks.NewMockUserService(ctrl)
+				captchaSvc := svcmocks.NewMockCaptchaService(ctrl)
+				mfaSvc := svcmocks.NewMockMFAService(ctrl)
+				captchaSvc.EXPECT().Required(gomock.Any(), "login", "email:123@qq.com", gomock.Any()).Return(false, nil)
+				userSvc.EXPECT().Login(gomock.Any(), "123@qq.com", "hello").Return(domain.User{}, service.ErrInvalidUserOrPassword)
+				captchaSvc.EXPECT().RecordFailure(gomock.Any(), "login", "email:123@qq.com", gomock.Any()).Return(nil)
+				return userSvc, captchaSvc, mfaSvc
+			},
+			reqBody:  `{"email":"123@qq.com","password":"hello"}`,
+			wantBody: "账号或密码错误，请重新输入！",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			userSvc, captchaSvc, mfaSvc := tc.mock(ctrl)
+			server := gin.New()
+			h := NewUserHandler(userSvc, svcmocks.NewMockCodeService(ctrl), captchaSvc, mfaSvc, newTestJWTHandler(t))
+			h.RegisterRouters(authz.NewRouter(server, authz.NewRegistry()))
+
+			req := httptest.NewRequest(http.MethodPost, "/users/login", bytes.NewReader([]byte(tc.reqBody)))
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+		})
+	}
+}
+
+// newTestJWTHandler 能够签发 token 的 ijwt.Handler，登录成功的用例要用
+func newTestJWTHandler(t *testing.T) ijwt.Handler {
+	mr := miniredis.RunT(t)
+	keys := ijwt.NewKeyManager(0)
+	key, err := ijwt.GenerateKey(ijwt.AlgEdDSA, time.Now())
+	require.NoError(t, err)
+	require.NoError(t, keys.SetKeys([]*ijwt.Key{key}))
+	return ijwt.NewRedisJWTHandler(redis.NewClient(&redis.Options{Addr: mr.Addr()}), keys, 0)
+}
+
+func TestUserHandler_LoginSMS(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) (service.UserService, service.CodeService, service.CaptchaService, service.MFAService)
+
+		reqBody string
+		wantRes Result
+		// 开启了两步验证，返回两步验证的凭证，不登录
+		wantMFA bool
+	}{
+		{
+			name: "登录成功",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CodeService, service.CaptchaService, service.MFAService) {
+				userSvc := svcmocks.NewMockUserService(ctrl)
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				captchaSvc := svcmocks.NewMockCaptchaService(ctrl)
+				mfaSvc := svcmocks.NewMockMFAService(ctrl)
+				codeSvc.EXPECT().Verify(gomock.Any(), "login_sms", "15012345678", "123456").Return(true, nil)
+				captchaSvc.EXPECT().Reset(gomock.Any(), "login_sms", "phone:15012345678").Return(nil)
+				userSvc.EXPECT().FindOrCreate(gomock.Any(), "15012345678").Return(domain.User{Id: 123}, nil)
+				mfaSvc.EXPECT().Enabled(gomock.Any(), int64(123)).Return(false, nil)
+				return userSvc, codeSvc, captchaSvc, mfaSvc
+			},
+			reqBody: `{"phone":"15012345678","code":"123456"}`,
+			wantRes: Result{Msg: "登陆成功"},
+		},
+		{
+			// 邮箱登录的验证码不能拿来创建一个手机号是邮箱的账号
+			name: "手机号填了邮箱",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CodeService, service.CaptchaService, service.MFAService) {
+				return svcmocks.NewMockUserService(ctrl), svcmocks.NewMockCodeService(ctrl), svcmocks.NewMockCaptchaService(ctrl),
+					svcmocks.NewMockMFAService(ctrl)
+			},
+			reqBody: `{"phone":"123@qq.com","code":"123456"}`,
+			wantRes: Result{Code: 4, Msg: "非法手机号格式"},
+		},
+		{
+			name: "验证码错误",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CodeService, service.CaptchaService, service.MFAService) {
+				userSvc := svcmocks.NewMockUserService(ctrl)
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				captchaSvc := svcmocks.NewMockCaptchaService(ctrl)
+				mfaSvc := svcmocks.NewMockMFAService(ctrl)
+				codeSvc.EXPECT().Verify(gomock.Any(), "login_sms", "15012345678", "123456").Return(false, nil)
+				captchaSvc.EXPECT().RecordFailure(gomock.Any(), "login_sms", "phone:15012345678", gomock.Any()).Return(nil)
+				return userSvc, codeSvc, captchaSvc, mfaSvc
+			},
+			reqBody: `{"phone":"15012345678","code":"123456"}`,
+			wantRes: Result{Code: 4, Msg: "验证码错误，请重新输入"},
+		},
+		{
+			name: "开启了两步验证",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CodeService, service.CaptchaService, service.MFAService) {
+				userSvc := svcmocks.NewMockUserService(ctrl)
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				captchaSvc := svcmocks.NewMockCaptchaService(ctrl)
+				mfaSvc := svcmocks.NewMockMFAService(ctrl)
+				codeSvc.EXPECT().Verify(gomock.Any(), "login_sms", "15012345678", "123456").Return(true, nil)
+				captchaSvc.EXPECT().Reset(gomock.Any(), "login_sms", "phone:15012345678").Return(nil)
+				userSvc.EXPECT().FindOrCreate(gomock.Any(), "15012345678").Return(domain.User{Id: 123}, nil)
+				mfaSvc.EXPECT().Enabled(gomock.Any(), int64(123)).Return(true, nil)
+				return userSvc, codeSvc, captchaSvc, mfaSvc
+			},
+			reqBody: `{"phone":"15012345678","code":"123456"}`,
+			wantMFA: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			userSvc, codeSvc, captchaSvc, mfaSvc := tc.mock(ctrl)
+			server := gin.New()
+			h := NewUserHandler(userSvc, codeSvc, captchaSvc, mfaSvc, newTestJWTHandler(t))
+			h.RegisterRouters(authz.NewRouter(server, authz.NewRegistry()))
+
+			req := httptest.NewRequest(http.MethodPost, "/users/login_sms", bytes.NewReader([]byte(tc.reqBody)))
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			var res Result
+			require.NoError(t, json.NewDecoder(recorder.Body).Decode(&res))
+			if tc.wantMFA {
+				data, ok := res.Data.(map[string]any)
+				require.True(t, ok)
+				assert.Equal(t, true, data["mfaRequired"])
+				assert.NotEmpty(t, data["mfaToken"])
+				assert.Empty(t, recorder.Header().Get("x-jwt-token"))
+				return
+			}
+			assert.Equal(t, tc.wantRes, res)
+		})
+	}
+}
+
+func TestUserHandler_SendEmailLoginCode(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) (service.UserService, service.CodeService, service.CaptchaService)
+
+		reqBody string
+		wantRes Result
+	}{
+		{
+			name: "发送成功",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CodeService, service.CaptchaService) {
+				userSvc := svcmocks.NewMockUserService(ctrl)
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				captchaSvc := svcmocks.NewMockCaptchaService(ctrl)
+				captchaSvc.EXPECT().Required(gomock.Any(), "login_sms", "email:123@qq.com", gomock.Any()).Return(false, nil)
+				captchaSvc.EXPECT().RecordFailure(gomock.Any(), "login_sms", "email:123@qq.com", gomock.Any()).Return(nil)
+				userSvc.EXPECT().FindByEmail(gomock.Any(), "123@qq.com").Return(domain.User{Id: 123}, nil)
+				codeSvc.EXPECT().SendVia(gomock.Any(), "login_email", "123@qq.com", channel.Email).Return(nil)
+				return userSvc, codeSvc, captchaSvc
+			},
+			reqBody: `{"email":"123@qq.com"}`,
+			wantRes: Result{Msg: "发送成功"},
+		},
+		{
+			// 响应和发送成功一样，不能用来试探邮箱有没有注册
+			name: "邮箱没有注册",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CodeService, service.CaptchaService) {
+				userSvc := svcmocks.NewMockUserService(ctrl)
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				captchaSvc := svcmocks.NewMockCaptchaService(ctrl)
+				captchaSvc.EXPECT().Required(gomock.Any(), "login_sms", "email:123@qq.com", gomock.Any()).Return(false, nil)
+				captchaSvc.EXPECT().RecordFailure(gomock.Any(), "login_sms", "email:123@qq.com", gomock.Any()).Return(nil)
+				userSvc.EXPECT().FindByEmail(gomock.Any(), "123@qq.com").Return(domain.User{}, service.ErrUserNotFound)
+				return userSvc, codeSvc, captchaSvc
+			},
+			reqBody: `{"email":"123@qq.com"}`,
+			wantRes: Result{Msg: "发送成功"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			userSvc, codeSvc, captchaSvc := tc.mock(ctrl)
+			server := gin.New()
+			h := NewUserHandler(userSvc, codeSvc, captchaSvc, svcmocks.NewMockMFAService(ctrl), newTestJWTHandler(t))
+			h.RegisterRouters(authz.NewRouter(server, authz.NewRegistry()))
+
+			req := httptest.NewRequest(http.MethodPost, "/users/login_email/code/send", bytes.NewReader([]byte(tc.reqBody)))
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			var res Result
+			require.NoError(t, json.NewDecoder(recorder.Body).Decode(&res))
+			assert.Equal(t, tc.wantRes, res)
+		})
+	}
+}
+
+func TestUserHandler_LoginEmail(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) (service.UserService, service.CodeService, service.CaptchaService, service.MFAService)
+
+		reqBody string
+		wantRes Result
+		// 开启了两步验证，返回两步验证的凭证，不登录
+		wantMFA bool
+	}{
+		{
+			name: "登录成功",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CodeService, service.CaptchaService, service.MFAService) {
+				userSvc := svcmocks.NewMockUserService(ctrl)
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				captchaSvc := svcmocks.NewMockCaptchaService(ctrl)
+				mfaSvc := svcmocks.NewMockMFAService(ctrl)
+				codeSvc.EXPECT().Verify(gomock.Any(), "login_email", "123@qq.com", "123456").Return(true, nil)
+				captchaSvc.EXPECT().Reset(gomock.Any(), "login_sms", "email:123@qq.com").Return(nil)
+				userSvc.EXPECT().LoginByEmail(gomock.Any(), "123@qq.com").Return(domain.User{Id: 123}, nil)
+				mfaSvc.EXPECT().Enabled(gomock.Any(), int64(123)).Return(false, nil)
+				return userSvc, codeSvc, captchaSvc, mfaSvc
+			},
+			reqBody: `{"email":"123@qq.com","code":"123456"}`,
+			wantRes: Result{Msg: "登陆成功"},
+		},
+		{
+			name: "账号被封禁",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CodeService, service.CaptchaService, service.MFAService) {
+				userSvc := svcmocks.NewMockUserService(ctrl)
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				captchaSvc := svcmocks.NewMockCaptchaService(ctrl)
+				mfaSvc := svcmocks.NewMockMFAService(ctrl)
+				codeSvc.EXPECT().Verify(gomock.Any(), "login_email", "123@qq.com", "123456").Return(true, nil)
+				captchaSvc.EXPECT().Reset(gomock.Any(), "login_sms", "email:123@qq.com").Return(nil)
+				userSvc.EXPECT().LoginByEmail(gomock.Any(), "123@qq.com").Return(domain.User{}, service.ErrUserSuspended)
+				return userSvc, codeSvc, captchaSvc, mfaSvc
+			},
+			reqBody: `{"email":"123@qq.com","code":"123456"}`,
+			wantRes: Result{Code: 4, Msg: "账号已被封禁"},
+		},
+		{
+			name: "开启了两步验证",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CodeService, service.CaptchaService, service.MFAService) {
+				userSvc := svcmocks.NewMockUserService(ctrl)
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				captchaSvc := svcmocks.NewMockCaptchaService(ctrl)
+				mfaSvc := svcmocks.NewMockMFAService(ctrl)
+				codeSvc.EXPECT().Verify(gomock.Any(), "login_email", "123@qq.com", "123456").Return(true, nil)
+				captchaSvc.EXPECT().Reset(gomock.Any(), "login_sms", "email:123@qq.com").Return(nil)
+				userSvc.EXPECT().LoginByEmail(gomock.Any(), "123@qq.com").Return(domain.User{Id: 123}, nil)
+				mfaSvc.EXPECT().Enabled(gomock.Any(), int64(123)).Return(true, nil)
+				return userSvc, codeSvc, captchaSvc, mfaSvc
+			},
+			reqBody: `{"email":"123@qq.com","code":"123456"}`,
+			wantMFA: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			userSvc, codeSvc, captchaSvc, mfaSvc := tc.mock(ctrl)
+			server := gin.New()
+			h := NewUserHandler(userSvc, codeSvc, captchaSvc, mfaSvc, newTestJWTHandler(t))
+			h.RegisterRouters(authz.NewRouter(server, authz.NewRegistry()))
+
+			req := httptest.NewRequest(http.MethodPost, "/users/login_email", bytes.NewReader([]byte(tc.reqBody)))
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			var res Result
+			require.NoError(t, json.NewDecoder(recorder.Body).Decode(&res))
+			if tc.wantMFA {
+				data, ok := res.Data.(map[string]any)
+				require.True(t, ok)
+				assert.Equal(t, true, data["mfaRequired"])
+				assert.NotEmpty(t, data["mfaToken"])
+				assert.Empty(t, recorder.Header().Get("x-jwt-token"))
+				return
+			}
+			assert.Equal(t, tc.wantRes, res)
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package ioc
+
+import (
+	"Learn_Go/webook/internal/repository/cache"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+)
+
+// InitCodeCache 根据配置选择验证码的存储
+// 本地开发可以使用 local，不依赖 Redis；多实例部署必须使用 redis，否则验证码不能在实例之间共享
+func InitCodeCache(cmd redis.Cmdable) cache.CodeCache {
+	type Config struct {
+		Type     string `yaml:"type"`
+		Capacity int    `yaml:"capacity"`
+	}
+	var cfg = Config{
+		Type:     "redis",
+		Capacity: 100000,
+	}
+	err := viper.UnmarshalKey("codeCache", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	if cfg.Type == "local" {
+		return cache.NewLocalCodeCache(cfg.Capacity)
+	}
+	return cache.NewRedisCodeCache(cmd)
+}
@@ -0,0 +1,32 @@
+package ioc
+
+import (
+	"Learn_Go/webook/internal/service/email"
+	"Learn_Go/webook/internal/service/email/localemail"
+	"Learn_Go/webook/internal/service/email/smtp"
+	"github.com/spf13/viper"
+)
+
+func InitEmailService() email.Service {
+	type Config struct {
+		// local 只打印日志，smtp 真的发邮件
+		Type     string `yaml:"type"`
+		Host     string `yaml:"host"`
+		Port     int    `yaml:"port"`
+		Username string `yaml:"username"`
+		Password string `yaml:"password"`
+		From     string `yaml:"from"`
+	}
+	var cfg = Config{
+		Type: "local",
+		Port: 25,
+	}
+	err := viper.UnmarshalKey("email", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	if cfg.Type != "smtp" {
+		return localemail.NewService()
+	}
+	return smtp.NewService(cfg.Host, cfg.Port, cfg.Username, cfg.Password, cfg.From)
+}
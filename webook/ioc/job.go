@@ -0,0 +1,30 @@
+package ioc
+
+import (
+	"Learn_Go/webook/internal/job"
+	"Learn_Go/webook/internal/service"
+	ijwt "Learn_Go/webook/internal/web/jwt"
+	"Learn_Go/webook/pkg/logger"
+	"github.com/spf13/viper"
+	"time"
+)
+
+// InitJobs 定时任务跟着 Web 服务一起启动，多个实例都会执行，所以任务本身要能并发执行
+func InitJobs(accountSvc service.AccountService, hdl ijwt.Handler, l logger.LoggerV1) *job.Scheduler {
+	type JobConfig struct {
+		Interval time.Duration `yaml:"interval"`
+		Timeout  time.Duration `yaml:"timeout"`
+	}
+	type Config struct {
+		AccountDeletion JobConfig `yaml:"accountDeletion"`
+	}
+	var cfg = Config{
+		AccountDeletion: JobConfig{Interval: time.Hour, Timeout: time.Minute * 10},
+	}
+	if err := viper.UnmarshalKey("jobs", &cfg); err != nil {
+		panic(err)
+	}
+	s := job.NewScheduler(l)
+	s.Add(job.NewAccountDeletionJob(accountSvc, hdl, l), cfg.AccountDeletion.Interval, cfg.AccountDeletion.Timeout)
+	return s
+}
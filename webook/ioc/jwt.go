@@ -0,0 +1,119 @@
+package ioc
+
+import (
+	"Learn_Go/webook/internal/service"
+	ijwt "Learn_Go/webook/internal/web/jwt"
+	"context"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+	"os"
+	"time"
+)
+
+// InitJWTKeyManager 初始化 JWT 的签名密钥
+// 密钥可以直接写在配置里面，也可以放在 keyDir 目录下面；配置了 rotateInterval 的话会定时在 keyDir 里面生成新的密钥
+// 都没有配置的时候，启动时生成一个只在内存里面的密钥，只适合本地开发和单机部署，重启之后所有 token 都会失效
+func InitJWTKeyManager() *ijwt.KeyManager {
+	type KeyConfig struct {
+		Kid string `yaml:"kid"`
+		// File 和 PEM 二选一
+		File      string    `yaml:"file"`
+		PEM       string    `yaml:"pem"`
+		CreatedAt time.Time `yaml:"createdAt"`
+	}
+	type Config struct {
+		// 自动轮换生成新密钥使用的算法，RS256 或者 EdDSA
+		Alg            string        `yaml:"alg"`
+		KeyDir         string        `yaml:"keyDir"`
+		RotateInterval time.Duration `yaml:"rotateInterval"`
+		ReloadInterval time.Duration `yaml:"reloadInterval"`
+		// 新的密钥过了多久才开始用来签名，要比 ReloadInterval 长，保证所有实例都已经加载了新的密钥
+		ActivationDelay time.Duration `yaml:"activationDelay"`
+		// 旧的密钥不再签名之后还要保留多久，至少是长 token 的有效期
+		Retention time.Duration `yaml:"retention"`
+		Keys      []KeyConfig   `yaml:"keys"`
+	}
+	var cfg = Config{
+		Alg:             ijwt.AlgEdDSA,
+		ReloadInterval:  time.Minute,
+		ActivationDelay: time.Minute * 3,
+		Retention:       time.Hour * 24 * 8,
+	}
+	err := viper.UnmarshalKey("jwt", &cfg)
+	if err != nil {
+		panic(err)
+	}
+
+	static := make([]*ijwt.Key, 0, len(cfg.Keys))
+	for _, kc := range cfg.Keys {
+		data := []byte(kc.PEM)
+		if kc.File != "" {
+			data, err = os.ReadFile(kc.File)
+			if err != nil {
+				panic(err)
+			}
+		}
+		key, err := ijwt.ParseKeyPEM(kc.Kid, data, kc.CreatedAt)
+		if err != nil {
+			panic(err)
+		}
+		static = append(static, key)
+	}
+
+	manager := ijwt.NewKeyManager(cfg.ActivationDelay)
+	if len(static) == 0 && cfg.KeyDir == "" {
+		zap.L().Warn("没有配置 JWT 签名密钥，使用临时生成的密钥，只适合本地开发")
+		key, err := ijwt.GenerateKey(cfg.Alg, time.Now())
+		if err != nil {
+			panic(err)
+		}
+		static = append(static, key)
+	}
+	rotator := ijwt.NewKeyRotator(manager, static, cfg.KeyDir, cfg.Alg, cfg.RotateInterval, cfg.Retention)
+	if err = rotator.Reload(); err != nil {
+		panic(err)
+	}
+	if cfg.KeyDir != "" {
+		rotator.Start(cfg.ReloadInterval)
+	}
+	return manager
+}
+
+// InitJWTHandler maxSessions 是最多同时登录多少个设备，0 表示不限制
+// session 是 Redis 不可用的时候校验 ssid 的降级策略
+// 签发短 token 的时候通过 roles 查询用户的角色
+func InitJWTHandler(cmd redis.Cmdable, keys *ijwt.KeyManager, roles service.RoleService) ijwt.Handler {
+	type SessionConfig struct {
+		FailOpen bool `yaml:"failOpen"`
+		// 本地最多记录多少个最近作废的 ssid
+		RevokedCapacity int      `yaml:"revokedCapacity"`
+		SensitivePaths  []string `yaml:"sensitivePaths"`
+	}
+	type Config struct {
+		MaxSessions int           `yaml:"maxSessions"`
+		Session     SessionConfig `yaml:"session"`
+	}
+	var cfg = Config{
+		MaxSessions: 5,
+		Session: SessionConfig{
+			FailOpen:        true,
+			RevokedCapacity: 100000,
+		},
+	}
+	err := viper.UnmarshalKey("jwt", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	// 和长 token 的有效期一样
+	revoked := ijwt.NewLocalRevokedSessions(cfg.Session.RevokedCapacity, time.Hour*24*7)
+	if client, ok := cmd.(redis.UniversalClient); ok {
+		revoked.Subscribe(context.Background(), client)
+	}
+	return ijwt.NewRedisJWTHandler(cmd, keys, cfg.MaxSessions).Degrade(&ijwt.SessionDegradePolicy{
+		FailOpen:       cfg.Session.FailOpen,
+		Revoked:        revoked,
+		SensitivePaths: cfg.Session.SensitivePaths,
+		Degraded:       registerCounter(ijwt.NewDegradedCounter()),
+	}).LoadRoles(roles)
+}
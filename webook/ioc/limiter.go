@@ -0,0 +1,129 @@
+package ioc
+
+import (
+	ijwt "Learn_Go/webook/internal/web/jwt"
+	"Learn_Go/webook/pkg/ginx/middleware/ratelimit"
+	"Learn_Go/webook/pkg/limiter"
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+type rateLimitConfig struct {
+	Interval time.Duration `yaml:"interval"`
+	Rate     int           `yaml:"rate"`
+	// 部署的实例数量，用来计算降级之后每个实例的阈值
+	Replicas int `yaml:"replicas"`
+	// 本地限流最多记录多少个 key
+	MaxKeys       int           `yaml:"maxKeys"`
+	ProbeInterval time.Duration `yaml:"probeInterval"`
+	// 多久检查一次规则有没有变化
+	ReloadInterval time.Duration    `yaml:"reloadInterval"`
+	Rules          []ratelimit.Rule `yaml:"rules"`
+}
+
+func loadRateLimitConfig() rateLimitConfig {
+	var cfg = rateLimitConfig{
+		Interval:       time.Second,
+		Rate:           100,
+		Replicas:       1,
+		MaxKeys:        100000,
+		ProbeInterval:  time.Second,
+		ReloadInterval: time.Second * 10,
+	}
+	err := viper.UnmarshalKey("ratelimit", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	if cfg.Replicas < 1 {
+		cfg.Replicas = 1
+	}
+	return cfg
+}
+
+// newFallbackLimiter 正常情况下用 Redis 做集群限流
+// Redis 出问题的时候降级到本地限流，本地阈值按照实例数量平摊，探活成功之后切换回 Redis
+func newFallbackLimiter(cmd redis.Cmdable, cfg rateLimitConfig, primary, fallback limiter.Limiter) limiter.Limiter {
+	return limiter.NewFallbackLimiter(primary, fallback, func(ctx context.Context) error {
+		return cmd.Ping(ctx).Err()
+	}).ProbeInterval(cfg.ProbeInterval)
+}
+
+// localRate 向上取整，宁可稍微放多一点，也不要降级之后把正常用户限流了
+func localRate(rate, replicas int) int {
+	return (rate + replicas - 1) / replicas
+}
+
+// initRateLimiter 全局按照 IP 限流
+func initRateLimiter(cmd redis.Cmdable) limiter.Limiter {
+	cfg := loadRateLimitConfig()
+	return newFallbackLimiter(cmd, cfg,
+		limiter.NewRedisSlidingWindowLimiter(cmd, cfg.Interval, cfg.Rate),
+		limiter.NewLocalSlidingWindowLimiter(cfg.Interval, localRate(cfg.Rate, cfg.Replicas), cfg.MaxKeys))
+}
+
+// initRuleRateLimiter 按照配置里面的规则限流，规则修改之后定时重新加载
+// 要放在登录校验之后，才能拿到 uid
+func initRuleRateLimiter(cmd redis.Cmdable) gin.HandlerFunc {
+	cfg := loadRateLimitConfig()
+	redisFactory := ratelimit.RedisLimiterFactory(cmd)
+	localFactory := ratelimit.LocalLimiterFactory(cfg.MaxKeys)
+	builder := ratelimit.NewRulesBuilder(func(r ratelimit.Rule) (limiter.Limiter, error) {
+		primary, err := redisFactory(r)
+		if err != nil {
+			return nil, err
+		}
+		r.Rate = localRate(r.Rate, cfg.Replicas)
+		if r.Capacity > 0 {
+			r.Capacity = localRate(r.Capacity, cfg.Replicas)
+		}
+		fallback, err := localFactory(r)
+		if err != nil {
+			return nil, err
+		}
+		return newFallbackLimiter(cmd, cfg, primary, fallback), nil
+	}).KeyFunc("uid", func(ctx *gin.Context) string {
+		val, ok := ctx.Get("user")
+		if !ok {
+			return ""
+		}
+		uc, ok := val.(ijwt.UserClaims)
+		if !ok {
+			return ""
+		}
+		return strconv.FormatInt(uc.Uid, 10)
+	})
+	// 启动的时候规则不对，直接失败
+	if err := builder.Load(cfg.Rules); err != nil {
+		panic(err)
+	}
+	// 配置可能来自 etcd（WatchRemoteConfig），不会触发 OnConfigChange，所以定时检查
+	go func() {
+		rules := cfg.Rules
+		ticker := time.NewTicker(cfg.ReloadInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			newCfg := rateLimitConfig{}
+			if err := viper.UnmarshalKey("ratelimit", &newCfg); err != nil {
+				zap.L().Error("读取限流规则失败", zap.Error(err))
+				continue
+			}
+			if reflect.DeepEqual(rules, newCfg.Rules) {
+				continue
+			}
+			// 热更新的时候规则不对，继续使用原来的规则
+			if err := builder.Load(newCfg.Rules); err != nil {
+				zap.L().Error("限流规则不合法，继续使用原来的规则", zap.Error(err))
+				continue
+			}
+			rules = newCfg.Rules
+			zap.L().Info("限流规则已更新")
+		}
+	}()
+	return builder.Build()
+}
@@ -0,0 +1,95 @@
+package ioc
+
+import (
+	"Learn_Go/webook/internal/repository"
+	"Learn_Go/webook/internal/service"
+	"Learn_Go/webook/internal/service/oauth2"
+	"Learn_Go/webook/internal/service/oauth2/github"
+	"Learn_Go/webook/internal/service/oauth2/oidc"
+	"Learn_Go/webook/internal/service/oauth2/wechat"
+	"Learn_Go/webook/pkg/httpx"
+	"Learn_Go/webook/pkg/logger"
+	"fmt"
+	"github.com/spf13/viper"
+	"os"
+	"time"
+)
+
+// InitOAuth2Providers 第三方登录，每一个配置对应一个 /oauth2/:provider 路由
+// clientId 和 clientSecret 支持 ${ENV} 的写法，不要把密钥直接写在配置文件里面
+func InitOAuth2Providers(l logger.LoggerV1) []oauth2.Provider {
+	type ProviderConfig struct {
+		// Type wechat、github 或者 oidc
+		Type         string   `yaml:"type"`
+		ClientId     string   `yaml:"clientId"`
+		ClientSecret string   `yaml:"clientSecret"`
+		RedirectURL  string   `yaml:"redirectURL"`
+		Issuer       string   `yaml:"issuer"`
+		Scopes       []string `yaml:"scopes"`
+		// IdentityKey 只有微信用，openid 或者 unionid
+		IdentityKey string `yaml:"identityKey"`
+	}
+	type Config struct {
+		// Timeout 调用第三方接口的超时时间
+		Timeout time.Duration `yaml:"timeout"`
+		// Retries 网络错误或者 5xx 的时候重试几次，只重试 GET
+		Retries   int                       `yaml:"retries"`
+		Providers map[string]ProviderConfig `yaml:"providers"`
+	}
+	var cfg = Config{
+		Timeout: 5 * time.Second,
+		Retries: 2,
+	}
+	err := viper.UnmarshalKey("oauth2", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	client := httpx.NewClient(cfg.Timeout, cfg.Retries)
+	res := make([]oauth2.Provider, 0, len(cfg.Providers))
+	for name, pc := range cfg.Providers {
+		clientId, clientSecret := os.ExpandEnv(pc.ClientId), os.ExpandEnv(pc.ClientSecret)
+		if clientId == "" || clientSecret == "" {
+			panic(fmt.Sprintf("第三方登录 %s 没有配置 clientId 或者 clientSecret", name))
+		}
+		switch pc.Type {
+		case "wechat":
+			if pc.IdentityKey != "" && pc.IdentityKey != wechat.IdentityOpenId && pc.IdentityKey != wechat.IdentityUnionId {
+				panic(fmt.Sprintf("第三方登录 %s 的 identityKey %s 不支持", name, pc.IdentityKey))
+			}
+			res = append(res, wechat.NewWechatService(wechat.Config{
+				AppId:       clientId,
+				AppSecret:   clientSecret,
+				RedirectURL: pc.RedirectURL,
+				IdentityKey: pc.IdentityKey,
+			}, client, l))
+		case "github":
+			res = append(res, github.NewProvider(github.Config{
+				ClientId:     clientId,
+				ClientSecret: clientSecret,
+				RedirectURL:  pc.RedirectURL,
+			}, client))
+		case "oidc":
+			res = append(res, oidc.NewProvider(oidc.Config{
+				Name:         name,
+				Issuer:       pc.Issuer,
+				ClientId:     clientId,
+				ClientSecret: clientSecret,
+				RedirectURL:  pc.RedirectURL,
+				Scopes:       pc.Scopes,
+			}, client))
+		default:
+			panic(fmt.Sprintf("第三方登录 %s 的类型 %s 不支持", name, pc.Type))
+		}
+	}
+	return res
+}
+
+// InitOAuthStateService 登录之后允许跳转到哪些站点，站内的相对路径不需要配置
+func InitOAuthStateService(repo repository.OAuthStateRepository) service.OAuthStateService {
+	var allowlist []string
+	err := viper.UnmarshalKey("oauth2.redirectAllowlist", &allowlist)
+	if err != nil {
+		panic(err)
+	}
+	return service.NewOAuthStateService(repo, allowlist)
+}
@@ -0,0 +1,19 @@
+package ioc
+
+import (
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// registerCounter 注册到默认的 Registry，已经注册过的话（比如测试里面多次初始化）复用原来的
+func registerCounter(c *prometheus.CounterVec) *prometheus.CounterVec {
+	err := prometheus.Register(c)
+	var are prometheus.AlreadyRegisteredError
+	if errors.As(err, &are) {
+		return are.ExistingCollector.(*prometheus.CounterVec)
+	}
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
@@ -39,5 +39,6 @@ func initTencentSmsService() sms.Service {
 	if err != nil {
 		panic(err)
 	}
-	return tencent.NewService(c, "1400842696", "Lip", limiter.NewRedisSlidingWindowLimiter(rdb, time.Second, 3000))
+	// 3000 QPS 用滑动窗口的话，ZSET 里面会一直保持几千个成员，令牌桶只需要两个字段
+	return tencent.NewService(c, "1400842696", "Lip", limiter.NewRedisTokenBucketLimiter(rdb, time.Second, 3000, 3000))
 }
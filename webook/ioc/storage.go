@@ -0,0 +1,69 @@
+package ioc
+
+import (
+	"Learn_Go/webook/internal/service/storage"
+	"Learn_Go/webook/internal/service/storage/localstorage"
+	"Learn_Go/webook/internal/service/storage/s3"
+	"Learn_Go/webook/pkg/httpx"
+	"fmt"
+	"github.com/spf13/viper"
+	"os"
+	"time"
+)
+
+// InitStorage 上传的图片存在哪里，local 只适合本地开发和单机部署
+// accessKeyId 和 secretAccessKey 支持 ${ENV} 的写法
+func InitStorage() storage.Storage {
+	type LocalConfig struct {
+		Dir string `yaml:"dir"`
+		// BaseURL 本地存储的文件由 /files 提供下载，前面可以加上域名
+		BaseURL string `yaml:"baseURL"`
+	}
+	type S3Config struct {
+		Endpoint        string        `yaml:"endpoint"`
+		Region          string        `yaml:"region"`
+		Bucket          string        `yaml:"bucket"`
+		AccessKeyId     string        `yaml:"accessKeyId"`
+		SecretAccessKey string        `yaml:"secretAccessKey"`
+		PublicURL       string        `yaml:"publicURL"`
+		Timeout         time.Duration `yaml:"timeout"`
+	}
+	type Config struct {
+		// Type local 或者 s3
+		Type  string      `yaml:"type"`
+		Local LocalConfig `yaml:"local"`
+		S3    S3Config    `yaml:"s3"`
+	}
+	var cfg = Config{
+		Type: "local",
+		Local: LocalConfig{
+			Dir:     "data/files",
+			BaseURL: "/files",
+		},
+		S3: S3Config{Timeout: 10 * time.Second},
+	}
+	err := viper.UnmarshalKey("storage", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	switch cfg.Type {
+	case "local":
+		return localstorage.NewStorage(cfg.Local.Dir, cfg.Local.BaseURL)
+	case "s3":
+		sc := cfg.S3
+		ak, sk := os.ExpandEnv(sc.AccessKeyId), os.ExpandEnv(sc.SecretAccessKey)
+		if sc.Endpoint == "" || sc.Bucket == "" || ak == "" || sk == "" {
+			panic("对象存储没有配置 endpoint、bucket 或者密钥")
+		}
+		return s3.NewStorage(s3.Config{
+			Endpoint:        sc.Endpoint,
+			Region:          sc.Region,
+			Bucket:          sc.Bucket,
+			AccessKeyId:     ak,
+			SecretAccessKey: sk,
+			PublicURL:       sc.PublicURL,
+		}, httpx.NewClient(sc.Timeout, 2))
+	default:
+		panic(fmt.Sprintf("不支持的存储类型 %s", cfg.Type))
+	}
+}
@@ -0,0 +1,50 @@
+package ioc
+
+import (
+	"Learn_Go/webook/internal/repository/cache"
+	"Learn_Go/webook/pkg/codec"
+	"Learn_Go/webook/pkg/logger"
+	"context"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+	"time"
+)
+
+// InitUserCache 本地缓存加 Redis 的两级缓存
+// 修改用户之后通过 Redis 的 pub/sub 通知其它实例删掉本地缓存，消息丢了的时候 localTTL 就是最长的不一致时间
+func InitUserCache(cmd redis.Cmdable, l logger.LoggerV1) cache.UserCache {
+	type Config struct {
+		// LocalCapacity 为 0 的时候不用本地缓存
+		LocalCapacity int           `yaml:"localCapacity"`
+		LocalTTL      time.Duration `yaml:"localTTL"`
+		// Codec json、msgpack 或者 protobuf，切换之后已经在 Redis 里面的缓存还能读
+		Codec string `yaml:"codec"`
+	}
+	var cfg = Config{
+		LocalCapacity: 10000,
+		LocalTTL:      30 * time.Second,
+		Codec:         "json",
+	}
+	err := viper.UnmarshalKey("userCache", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	c, err := codec.Get(cfg.Codec)
+	if err != nil {
+		panic(err)
+	}
+	remote := cache.NewRedisUserCacheWithCodec(cmd, c)
+	if cfg.LocalCapacity <= 0 {
+		return remote
+	}
+	local := cache.NewLocalUserCache(cfg.LocalCapacity, cfg.LocalTTL)
+	var invalidation *cache.UserCacheInvalidation
+	if client, ok := cmd.(redis.UniversalClient); ok {
+		invalidation = cache.NewUserCacheInvalidation(client, local, l)
+		// 订阅失败的时候照样发布，只是这个实例收不到其它实例的通知
+		if err = invalidation.Subscribe(context.Background()); err != nil {
+			l.Error("订阅用户缓存失效消息失败", logger.Error(err))
+		}
+	}
+	return cache.NewMultiLevelUserCache(local, remote, invalidation, registerCounter(cache.NewUserCacheCounter()))
+}
@@ -0,0 +1,11 @@
+package ioc
+
+import (
+	"Learn_Go/webook/internal/service/voice"
+	"Learn_Go/webook/internal/service/voice/localvoice"
+)
+
+func InitVoiceService() voice.Service {
+	// 接入语音服务商之前，先使用本地的实现
+	return localvoice.NewService()
+}
@@ -1,32 +1,56 @@
 package ioc
 
 import (
+	"Learn_Go/webook/internal/domain"
+	"Learn_Go/webook/internal/service"
 	"Learn_Go/webook/internal/web"
 	ijwt "Learn_Go/webook/internal/web/jwt"
 	"Learn_Go/webook/internal/web/middleware"
+	"Learn_Go/webook/pkg/ginx/authz"
 	"Learn_Go/webook/pkg/ginx/middleware/ratelimit"
-	"Learn_Go/webook/pkg/limiter"
 	"Learn_Go/webook/pkg/logger"
 	"context"
 	"fmt"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/redis/go-redis/v9"
+	"net/http"
 	"strings"
 	"time"
 )
 
-func InitWebServer(mdls []gin.HandlerFunc, userHdl *web.UserHandler, authHdl *web.OAuth2WechatHandler, articleHdl *web.ArticleHandler) *gin.Engine {
+func InitWebServer(mdls []gin.HandlerFunc, policies *authz.Registry, userHdl *web.UserHandler, authHdl *web.OAuth2Handler, articleHdl *web.ArticleHandler,
+	captchaHdl *web.CaptchaHandler, jwksHdl *web.JWKSHandler, adminHdl *web.AdminHandler, accountHdl *web.AccountHandler,
+	uploadHdl *web.UploadHandler, profileHdl *web.ProfileHandler) *gin.Engine {
 	server := gin.Default()
 	server.Use(mdls...)
-	userHdl.RegisterRouters(server)
-	authHdl.RegisterRoutes(server)
-	articleHdl.RegisterRouters(server)
+	// 注册路由的时候要声明访问策略
+	router := authz.NewRouter(server, policies)
+	userHdl.RegisterRouters(router)
+	authHdl.RegisterRoutes(router)
+	articleHdl.RegisterRouters(router)
+	captchaHdl.RegisterRouters(router)
+	jwksHdl.RegisterRouters(router)
+	adminHdl.RegisterRouters(router)
+	accountHdl.RegisterRouters(router)
+	uploadHdl.RegisterRouters(router)
+	profileHdl.RegisterRouters(router)
+	// 监控指标会暴露内部的运行情况，只有管理员能看
+	router.With(authz.Roles(domain.RoleAdmin)).GET("/metrics", gin.WrapH(promhttp.Handler()))
+	pub := router.With(authz.Public())
+	pub.GET("/hello", func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, "hello，启动成功了！")
+	})
+	// 有路由忘了声明访问策略，直接启动失败
+	if err := policies.Validate(server.Routes()); err != nil {
+		panic(err)
+	}
 	return server
 
 }
 
-func InitGinMiddleWares(redisClient redis.Cmdable, hdl ijwt.Handler, l logger.LoggerV1) []gin.HandlerFunc {
+func InitGinMiddleWares(redisClient redis.Cmdable, hdl ijwt.Handler, policies *authz.Registry, roles service.RoleService, l logger.LoggerV1) []gin.HandlerFunc {
 	return []gin.HandlerFunc{
 		cors.New(cors.Config{ // 通过 Middleware（cors） 处理跨域请求
 			//AllowAllOrigins: true,	允许所有的源头
@@ -35,7 +59,8 @@ func InitGinMiddleWares(redisClient redis.Cmdable, hdl ijwt.Handler, l logger.Lo
 			AllowCredentials: true,                                      // cookie的数据是否允许传过来，正常情况下允许
 			AllowHeaders:     []string{"Content-Type", "Authorization"}, //报错，根据报错找到需要添加的headers
 			// 允许前端访问后端响应中带的头部
-			ExposeHeaders: []string{"x-jwt-token", "x-refresh-token"},
+			ExposeHeaders: []string{"x-jwt-token", "x-refresh-token",
+				"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "Retry-After"},
 			AllowOriginFunc: func(origin string) bool {
 				if strings.HasPrefix(origin, "http://localhost") {
 					return true
@@ -47,11 +72,13 @@ func InitGinMiddleWares(redisClient redis.Cmdable, hdl ijwt.Handler, l logger.Lo
 		}), func(ctx *gin.Context) {
 			fmt.Println("这是一个 Middleware")
 		},
-		ratelimit.NewBuilder(limiter.NewRedisSlidingWindowLimiter(redisClient, time.Second, 100)).Build(),
+		ratelimit.NewBuilder(initRateLimiter(redisClient)).Build(),
 		middleware.NewLogMiddlewareBuilder(func(ctx context.Context, lc middleware.LogContent) {
 			l.Debug("", logger.Field{Key: "req", Value: lc})
 		}).AllowReqBody().AllowRespBody().Build(),
-		middleware.NewLoginJWTMiddlewareBuilder(hdl).CheckLogin(),
+		middleware.NewLoginJWTMiddlewareBuilder(hdl, policies).CheckRolesWith(roles).CheckLogin(),
+		// 按照规则限流要在登录校验之后，才能按照 uid 限流
+		initRuleRateLimiter(redisClient),
 
 		// 使用 session 登录校验
 
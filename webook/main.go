@@ -3,19 +3,20 @@ package main
 import (
 	"bytes"
 	"github.com/fsnotify/fsnotify"
-	"github.com/gin-gonic/gin"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 	_ "github.com/spf13/viper/remote"
 	"go.uber.org/zap"
 	"log"
-	"net/http"
 )
 
 func main() {
 	initViperRemoteWatch()
 	initLogger() // 一般来说，需要先读取一些配置，再初始化日志模块
-	server := InitWebServer()
+	app := InitApp()
+	// 定时任务跟着 Web 服务一起启动
+	app.scheduler.Start()
+	defer app.scheduler.Stop()
 	//db := initDB()
 	//rd := initRedis()
 	//server := initWebServer()
@@ -24,11 +25,9 @@ func main() {
 
 	// 首先去除mysql和redis依赖，构造最简单的Web服务部署到k8s上
 
-	server.GET("/hello", func(ctx *gin.Context) {
-		ctx.String(http.StatusOK, "hello，启动成功了！")
-	})
+	// /hello 在 ioc.InitWebServer 里面注册，所有路由都要声明访问策略
 
-	server.Run(":8080")
+	app.server.Run(":8080")
 }
 
 func initLogger() {
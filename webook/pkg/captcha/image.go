@@ -0,0 +1,146 @@
+package captcha
+
+import (
+	"bytes"
+	"crypto/rand"
+	"image"
+	"image/color"
+	"image/png"
+	"math/big"
+)
+
+// 图形验证码的生成，只依赖标准库
+// 字符使用 5x7 的点阵字体绘制，再叠加随机偏移、干扰线和噪点，防止被简单的 OCR 识别
+
+const (
+	fontWidth  = 5
+	fontHeight = 7
+)
+
+// digitFont 0-9 的点阵，每一行用低 5 位表示，最高位在最左边
+var digitFont = [10][fontHeight]uint8{
+	{0x0E, 0x11, 0x13, 0x15, 0x19, 0x11, 0x0E}, // 0
+	{0x04, 0x0C, 0x04, 0x04, 0x04, 0x04, 0x0E}, // 1
+	{0x0E, 0x11, 0x01, 0x02, 0x04, 0x08, 0x1F}, // 2
+	{0x1F, 0x02, 0x04, 0x02, 0x01, 0x11, 0x0E}, // 3
+	{0x02, 0x06, 0x0A, 0x12, 0x1F, 0x02, 0x02}, // 4
+	{0x1F, 0x10, 0x1E, 0x01, 0x01, 0x11, 0x0E}, // 5
+	{0x06, 0x08, 0x10, 0x1E, 0x11, 0x11, 0x0E}, // 6
+	{0x1F, 0x01, 0x02, 0x04, 0x08, 0x08, 0x08}, // 7
+	{0x0E, 0x11, 0x11, 0x0E, 0x11, 0x11, 0x0E}, // 8
+	{0x0E, 0x11, 0x11, 0x0F, 0x01, 0x02, 0x0C}, // 9
+}
+
+// RandomDigits 生成 n 位随机数字，使用 crypto/rand，避免答案被预测
+func RandomDigits(n int) string {
+	res := make([]byte, n)
+	for i := range res {
+		res[i] = '0' + byte(randInt(10))
+	}
+	return string(res)
+}
+
+// GenerateImage 将 digits 绘制成 PNG 图片，digits 只能包含 0-9
+func GenerateImage(digits string, width, height int) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	bg := color.RGBA{R: 245, G: 245, B: 245, A: 255}
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, bg)
+		}
+	}
+
+	// 每个字符占用的宽度，按照字符数平分
+	cell := width / (len(digits) + 1)
+	scale := min(cell/(fontWidth+1), height/(fontHeight+2))
+	if scale < 1 {
+		scale = 1
+	}
+	for i, ch := range digits {
+		if ch < '0' || ch > '9' {
+			continue
+		}
+		// 每个字符随机上下、左右偏移一点
+		offsetX := cell/2 + i*cell + randInt(scale*2) - scale
+		offsetY := (height-fontHeight*scale)/2 + randInt(scale*2+1) - scale
+		drawDigit(img, digitFont[ch-'0'], offsetX, offsetY, scale, randColor())
+	}
+
+	// 干扰线
+	for i := 0; i < 4; i++ {
+		drawLine(img, randInt(width), randInt(height), randInt(width), randInt(height), randColor())
+	}
+	// 噪点
+	for i := 0; i < width*height/20; i++ {
+		img.Set(randInt(width), randInt(height), randColor())
+	}
+
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	return buf.Bytes(), err
+}
+
+func drawDigit(img *image.RGBA, glyph [fontHeight]uint8, x0, y0, scale int, c color.Color) {
+	for row := 0; row < fontHeight; row++ {
+		for col := 0; col < fontWidth; col++ {
+			if glyph[row]&(1<<(fontWidth-1-col)) == 0 {
+				continue
+			}
+			for dx := 0; dx < scale; dx++ {
+				for dy := 0; dy < scale; dy++ {
+					img.Set(x0+col*scale+dx, y0+row*scale+dy, c)
+				}
+			}
+		}
+	}
+}
+
+// drawLine Bresenham 画线
+func drawLine(img *image.RGBA, x0, y0, x1, y1 int, c color.Color) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	e := dx + dy
+	for {
+		img.Set(x0, y0, c)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * e
+		if e2 >= dy {
+			e += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			e += dx
+			y0 += sy
+		}
+	}
+}
+
+func randColor() color.Color {
+	return color.RGBA{R: uint8(randInt(160)), G: uint8(randInt(160)), B: uint8(randInt(160)), A: 255}
+}
+
+func randInt(n int) int {
+	if n <= 0 {
+		return 0
+	}
+	v, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0
+	}
+	return int(v.Int64())
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
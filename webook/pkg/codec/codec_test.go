@@ -0,0 +1,45 @@
+package codec
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	for _, name := range []string{"json", "msgpack", "protobuf"} {
+		c, err := Get(name)
+		require.NoError(t, err)
+		assert.Equal(t, name, c.Name())
+		got, ok := ById(c.Id())
+		assert.True(t, ok)
+		assert.Equal(t, c, got)
+	}
+	_, err := Get("xml")
+	assert.ErrorIs(t, err, ErrUnknownCodec)
+	_, ok := ById(0)
+	assert.False(t, ok)
+}
+
+func TestCodec_RoundTrip(t *testing.T) {
+	type user struct {
+		Id       int64  `json:"id" msgpack:"id"`
+		Nickname string `json:"nickname" msgpack:"nickname"`
+	}
+	for _, c := range []Codec{JSON{}, Msgpack{}} {
+		t.Run(c.Name(), func(t *testing.T) {
+			data, err := c.Marshal(user{Id: 123, Nickname: "lip"})
+			require.NoError(t, err)
+			var u user
+			require.NoError(t, c.Unmarshal(data, &u))
+			assert.Equal(t, user{Id: 123, Nickname: "lip"}, u)
+		})
+	}
+}
+
+func TestProtobuf_UnsupportedType(t *testing.T) {
+	_, err := Protobuf{}.Marshal(struct{}{})
+	assert.ErrorIs(t, err, ErrUnsupportedType)
+	var v struct{}
+	assert.ErrorIs(t, Protobuf{}.Unmarshal(nil, &v), ErrUnsupportedType)
+}
@@ -0,0 +1,22 @@
+package codec
+
+import "encoding/json"
+
+// JSON 可读性最好，体积最大
+type JSON struct{}
+
+func (JSON) Id() byte {
+	return IdJSON
+}
+
+func (JSON) Name() string {
+	return "json"
+}
+
+func (JSON) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSON) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
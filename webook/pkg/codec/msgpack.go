@@ -0,0 +1,23 @@
+package codec
+
+import "github.com/vmihailenco/msgpack/v5"
+
+// Msgpack 和 JSON 一样不需要定义 schema，字段名还在，但是数字和字符串长度是二进制的
+// 字段名用 msgpack 标签，没有标签的时候用结构体的字段名
+type Msgpack struct{}
+
+func (Msgpack) Id() byte {
+	return IdMsgpack
+}
+
+func (Msgpack) Name() string {
+	return "msgpack"
+}
+
+func (Msgpack) Marshal(v any) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (Msgpack) Unmarshal(data []byte, v any) error {
+	return msgpack.Unmarshal(data, v)
+}
@@ -0,0 +1,40 @@
+package codec
+
+import "fmt"
+
+// ProtoMessage 用 protowire 手写编解码的类型，不依赖 protoc 生成的代码
+// 编码结果要和对应的 .proto 定义兼容，新增字段只能用新的编号
+type ProtoMessage interface {
+	// AppendProto 把编码结果追加到 b 后面
+	AppendProto(b []byte) []byte
+	// UnmarshalProto 不认识的字段要跳过，这样旧版本的代码能读新版本写的数据
+	UnmarshalProto(data []byte) error
+}
+
+// Protobuf 体积最小，没有字段名，只有字段编号
+// 只支持实现了 ProtoMessage 的类型
+type Protobuf struct{}
+
+func (Protobuf) Id() byte {
+	return IdProtobuf
+}
+
+func (Protobuf) Name() string {
+	return "protobuf"
+}
+
+func (Protobuf) Marshal(v any) ([]byte, error) {
+	m, ok := v.(ProtoMessage)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedType, v)
+	}
+	return m.AppendProto(nil), nil
+}
+
+func (Protobuf) Unmarshal(data []byte, v any) error {
+	m, ok := v.(ProtoMessage)
+	if !ok {
+		return fmt.Errorf("%w: %T", ErrUnsupportedType, v)
+	}
+	return m.UnmarshalProto(data)
+}
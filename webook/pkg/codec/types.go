@@ -0,0 +1,51 @@
+// Package codec 缓存之类的地方用的序列化方式，可以通过配置切换
+package codec
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrUnknownCodec    = errors.New("未知的序列化方式")
+	ErrUnsupportedType = errors.New("序列化方式不支持这个类型")
+)
+
+// 每种序列化方式的 Id，会写进序列化的结果里面，已经用了的不能修改
+const (
+	IdJSON     byte = 1
+	IdMsgpack  byte = 2
+	IdProtobuf byte = 3
+)
+
+type Codec interface {
+	// Id 用来在读的时候找到写的时候用的 Codec，切换配置之后旧的数据还能读出来
+	Id() byte
+	// Name 配置里面用的名字
+	Name() string
+	Marshal(v any) ([]byte, error)
+	// Unmarshal v 是指针
+	Unmarshal(data []byte, v any) error
+}
+
+var codecs = []Codec{JSON{}, Msgpack{}, Protobuf{}}
+
+// Get 根据配置里面的名字找到 Codec
+func Get(name string) (Codec, error) {
+	for _, c := range codecs {
+		if c.Name() == name {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownCodec, name)
+}
+
+// ById 根据写在数据里面的 Id 找到 Codec
+func ById(id byte) (Codec, bool) {
+	for _, c := range codecs {
+		if c.Id() == id {
+			return c, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,110 @@
+package authz
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Level 访问一个路由需要什么条件
+type Level uint8
+
+const (
+	// LevelUndeclared 没有声明，启动的时候会失败
+	LevelUndeclared Level = iota
+	// LevelPublic 不需要登录
+	LevelPublic
+	// LevelLogin 需要登录
+	LevelLogin
+	// LevelOptional 不需要登录，但是登录了的话能拿到用户，比如别人的主页
+	LevelOptional
+)
+
+// Policy 路由的访问策略
+type Policy struct {
+	Level Level
+	// Roles 不为空的时候，需要登录并且拥有其中一个角色
+	Roles []string
+}
+
+func Public() Policy {
+	return Policy{Level: LevelPublic}
+}
+
+func Login() Policy {
+	return Policy{Level: LevelLogin}
+}
+
+func Optional() Policy {
+	return Policy{Level: LevelOptional}
+}
+
+// Roles 拥有其中任何一个角色就可以访问
+func Roles(roles ...string) Policy {
+	return Policy{Level: LevelLogin, Roles: roles}
+}
+
+// Allow 用户的角色是否满足要求
+func (p Policy) Allow(roles []string) bool {
+	if len(p.Roles) == 0 {
+		return true
+	}
+	for _, want := range p.Roles {
+		for _, r := range roles {
+			if r == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Registry 记录每个路由的访问策略，key 是方法和注册路由时候的路径，所以支持 :id 这种路径参数
+type Registry struct {
+	lock     sync.RWMutex
+	policies map[string]Policy
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		policies: make(map[string]Policy),
+	}
+}
+
+func (r *Registry) key(method, fullPath string) string {
+	return method + " " + fullPath
+}
+
+func (r *Registry) declare(method, fullPath string, p Policy) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.policies[r.key(method, fullPath)] = p
+}
+
+// Lookup fullPath 是 ctx.FullPath()
+func (r *Registry) Lookup(method, fullPath string) (Policy, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	p, ok := r.policies[r.key(method, fullPath)]
+	if !ok || p.Level == LevelUndeclared {
+		return Policy{}, false
+	}
+	return p, true
+}
+
+// Validate 所有的路由都必须声明访问策略，一般在注册完路由之后调用，有问题直接启动失败
+func (r *Registry) Validate(routes gin.RoutesInfo) error {
+	var missing []string
+	for _, route := range routes {
+		if _, ok := r.Lookup(route.Method, route.Path); !ok {
+			missing = append(missing, r.key(route.Method, route.Path))
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("这些路由没有声明访问策略：%s", strings.Join(missing, ", "))
+	}
+	return nil
+}
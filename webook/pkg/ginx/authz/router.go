@@ -0,0 +1,78 @@
+package authz
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// Router 包装 gin 的路由分组，注册路由的同时记录访问策略
+//
+//	ug := server.Group("/users")
+//	ug.With(authz.Public()).POST("/signup", h.SignUp)
+//	ug.With(authz.Login()).POST("/edit", h.Edit)
+type Router struct {
+	group    *gin.RouterGroup
+	registry *Registry
+	// 在这个 Router 上面注册的路由的默认策略
+	policy Policy
+}
+
+func NewRouter(server *gin.Engine, registry *Registry) *Router {
+	return &Router{
+		group:    &server.RouterGroup,
+		registry: registry,
+	}
+}
+
+// Group 分组会继承当前的默认策略
+func (r *Router) Group(relativePath string, handlers ...gin.HandlerFunc) *Router {
+	return &Router{
+		group:    r.group.Group(relativePath, handlers...),
+		registry: r.registry,
+		policy:   r.policy,
+	}
+}
+
+// With 返回一个默认策略是 p 的 Router，注册的路由还是在同一个分组下面
+func (r *Router) With(p Policy) *Router {
+	return &Router{
+		group:    r.group,
+		registry: r.registry,
+		policy:   p,
+	}
+}
+
+func (r *Router) Handle(method, relativePath string, handlers ...gin.HandlerFunc) {
+	r.group.Handle(method, relativePath, handlers...)
+	r.registry.declare(method, joinPath(r.group.BasePath(), relativePath), r.policy)
+}
+
+func (r *Router) GET(relativePath string, handlers ...gin.HandlerFunc) {
+	r.Handle(http.MethodGet, relativePath, handlers...)
+}
+
+func (r *Router) POST(relativePath string, handlers ...gin.HandlerFunc) {
+	r.Handle(http.MethodPost, relativePath, handlers...)
+}
+
+// Any 和 gin 一样，注册所有的方法
+func (r *Router) Any(relativePath string, handlers ...gin.HandlerFunc) {
+	for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodHead, http.MethodOptions, http.MethodDelete, http.MethodConnect, http.MethodTrace} {
+		r.Handle(method, relativePath, handlers...)
+	}
+}
+
+// joinPath 和 gin 计算完整路径的方式保持一致，这样才能和 ctx.FullPath() 对上
+func joinPath(base, relativePath string) string {
+	if relativePath == "" {
+		return base
+	}
+	res := path.Join(base, relativePath)
+	if strings.HasSuffix(relativePath, "/") && !strings.HasSuffix(res, "/") {
+		return res + "/"
+	}
+	return res
+}
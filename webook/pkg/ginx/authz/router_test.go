@@ -0,0 +1,65 @@
+package authz
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouter(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	server := gin.New()
+	registry := NewRegistry()
+	// 在中间件里面按照 FullPath 查找策略
+	var got Policy
+	var found bool
+	server.Use(func(ctx *gin.Context) {
+		got, found = registry.Lookup(ctx.Request.Method, ctx.FullPath())
+	})
+	router := NewRouter(server, registry)
+	ok := func(ctx *gin.Context) {}
+
+	ug := router.Group("/users")
+	ug.With(Public()).POST("/signup", ok)
+	ug.With(Login()).GET("/:id/profile", ok)
+	admin := router.Group("/admin").With(Roles("admin"))
+	admin.Group("/users").POST("/:id/ban", ok)
+	router.With(Public()).Any("/callback/", ok)
+
+	require.NoError(t, registry.Validate(server.Routes()))
+
+	testCases := []struct {
+		method string
+		path   string
+		want   Policy
+	}{
+		{method: http.MethodPost, path: "/users/signup", want: Public()},
+		{method: http.MethodGet, path: "/users/123/profile", want: Login()},
+		{method: http.MethodPost, path: "/admin/users/123/ban", want: Roles("admin")},
+		{method: http.MethodDelete, path: "/callback/", want: Public()},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tc.method, tc.path, nil))
+			assert.True(t, found)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	// 直接在 gin 上面注册的路由没有声明策略，启动的时候要失败
+	server.GET("/hello", ok)
+	// 没有声明策略的 Router 注册的路由也一样
+	router.POST("/undeclared", ok)
+	err := registry.Validate(server.Routes())
+	assert.EqualError(t, err, "这些路由没有声明访问策略：GET /hello, POST /undeclared")
+}
+
+func TestPolicy_Allow(t *testing.T) {
+	assert.True(t, Login().Allow(nil))
+	assert.False(t, Roles("admin", "moderator").Allow(nil))
+	assert.False(t, Roles("admin", "moderator").Allow([]string{"user"}))
+	assert.True(t, Roles("admin", "moderator").Allow([]string{"user", "moderator"}))
+}
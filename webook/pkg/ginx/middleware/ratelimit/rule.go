@@ -0,0 +1,137 @@
+package ratelimit
+
+import (
+	"Learn_Go/webook/pkg/limiter"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"path"
+	"strings"
+	"time"
+)
+
+// 限流算法
+const (
+	AlgorithmSlidingWindow = "sliding_window"
+	AlgorithmTokenBucket   = "token_bucket"
+	AlgorithmLeakyBucket   = "leaky_bucket"
+)
+
+// 按照 header 限流的时候，Key 的前缀，比如 header:X-Api-Key
+const headerKeyPrefix = "header:"
+
+// Rule 一条限流规则，一般从配置里面读出来
+type Rule struct {
+	// Name 规则的名字，要唯一，会作为限流 key 的一部分
+	Name string `yaml:"name"`
+	// Path 支持 path.Match 的通配符，比如 /users/*；以 /** 结尾表示匹配前缀
+	// 也可以直接写注册路由时候的路径，比如 /articles/pub/:id
+	Path string `yaml:"path"`
+	// Methods 为空的时候匹配所有方法
+	Methods []string `yaml:"methods"`
+	// Key 按照什么限流：ip、uid，或者 header:X-Api-Key 这种按照某个 header 限流
+	Key string `yaml:"key"`
+	// Algorithm 为空的时候使用滑动窗口
+	Algorithm string `yaml:"algorithm"`
+	// 滑动窗口是每个 Interval 最多 Rate 个请求，令牌桶和漏桶是每个 Interval 补充（漏掉）Rate 个
+	Interval time.Duration `yaml:"interval"`
+	Rate     int           `yaml:"rate"`
+	// Capacity 令牌桶和漏桶的容量，为空的时候和 Rate 一样
+	Capacity int `yaml:"capacity"`
+}
+
+func (r Rule) validate() error {
+	if r.Name == "" {
+		return errors.New("限流规则缺少 name")
+	}
+	if r.Path == "" {
+		return fmt.Errorf("限流规则 %s 缺少 path", r.Name)
+	}
+	if r.Interval <= 0 || r.Rate <= 0 {
+		return fmt.Errorf("限流规则 %s 的 interval 和 rate 必须大于 0", r.Name)
+	}
+	switch r.Algorithm {
+	case "", AlgorithmSlidingWindow, AlgorithmTokenBucket, AlgorithmLeakyBucket:
+	default:
+		return fmt.Errorf("限流规则 %s 的算法 %s 不支持", r.Name, r.Algorithm)
+	}
+	if r.Key == headerKeyPrefix {
+		return fmt.Errorf("限流规则 %s 缺少 header 的名字", r.Name)
+	}
+	return nil
+}
+
+// algorithm 会作为限流 key 的一部分，热更新换了算法之后不会读到原来算法的数据结构
+func (r Rule) algorithm() string {
+	if r.Algorithm == "" {
+		return AlgorithmSlidingWindow
+	}
+	return r.Algorithm
+}
+
+func (r Rule) capacity() int {
+	if r.Capacity > 0 {
+		return r.Capacity
+	}
+	return r.Rate
+}
+
+// match 请求是否命中这条规则
+func (r Rule) match(ctx *gin.Context) bool {
+	if len(r.Methods) > 0 {
+		found := false
+		for _, m := range r.Methods {
+			if strings.EqualFold(m, ctx.Request.Method) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	// 注册路由时候的路径，比如 /articles/pub/:id
+	if r.Path == ctx.FullPath() {
+		return true
+	}
+	p := ctx.Request.URL.Path
+	if prefix, ok := strings.CutSuffix(r.Path, "/**"); ok {
+		return p == prefix || strings.HasPrefix(p, prefix+"/")
+	}
+	ok, _ := path.Match(r.Path, p)
+	return ok
+}
+
+// LimiterFactory 根据规则创建限流器
+type LimiterFactory func(r Rule) (limiter.Limiter, error)
+
+// RedisLimiterFactory 创建基于 Redis 的限流器，多个实例共享阈值
+func RedisLimiterFactory(cmd redis.Cmdable) LimiterFactory {
+	return func(r Rule) (limiter.Limiter, error) {
+		switch r.Algorithm {
+		case "", AlgorithmSlidingWindow:
+			return limiter.NewRedisSlidingWindowLimiter(cmd, r.Interval, r.Rate), nil
+		case AlgorithmTokenBucket:
+			return limiter.NewRedisTokenBucketLimiter(cmd, r.Interval, r.Rate, r.capacity()), nil
+		case AlgorithmLeakyBucket:
+			return limiter.NewRedisLeakyBucketLimiter(cmd, r.Interval, r.Rate, r.capacity()), nil
+		}
+		return nil, fmt.Errorf("限流算法 %s 不支持", r.Algorithm)
+	}
+}
+
+// LocalLimiterFactory 创建本地限流器，maxKeys 是每条规则最多保存多少个 key 的状态
+func LocalLimiterFactory(maxKeys int) LimiterFactory {
+	return func(r Rule) (limiter.Limiter, error) {
+		switch r.Algorithm {
+		case "", AlgorithmSlidingWindow:
+			return limiter.NewLocalSlidingWindowLimiter(r.Interval, r.Rate, maxKeys), nil
+		case AlgorithmTokenBucket:
+			return limiter.NewLocalTokenBucketLimiter(r.Interval, r.Rate, r.capacity(), maxKeys), nil
+		case AlgorithmLeakyBucket:
+			return limiter.NewLocalLeakyBucketLimiter(r.Interval, r.Rate, r.capacity(), maxKeys), nil
+		}
+		return nil, fmt.Errorf("限流算法 %s 不支持", r.Algorithm)
+	}
+}
@@ -0,0 +1,165 @@
+package ratelimit
+
+import (
+	"Learn_Go/webook/pkg/limiter"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"math"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// KeyFunc 从请求里面取出限流的对象，返回空字符串表示这条规则不适用于这个请求
+// 比如按照 uid 限流，但是用户没有登录
+type KeyFunc func(ctx *gin.Context) string
+
+// RulesBuilder 按照规则限流，一个请求可以同时命中多条规则，每条规则有自己的对象、算法和阈值
+// 规则可以通过 Load 热更新
+type RulesBuilder struct {
+	prefix     string
+	newLimiter LimiterFactory
+	keyFuncs   map[string]KeyFunc
+
+	// Load 可能和 Build 出来的 middleware 并发执行
+	lock  sync.Mutex
+	rules atomic.Pointer[[]*ruleLimiter]
+}
+
+type ruleLimiter struct {
+	Rule
+	keyFunc KeyFunc
+	limiter limiter.Limiter
+}
+
+func NewRulesBuilder(newLimiter LimiterFactory) *RulesBuilder {
+	b := &RulesBuilder{
+		prefix:     "rule-limiter",
+		newLimiter: newLimiter,
+		keyFuncs: map[string]KeyFunc{
+			"ip": func(ctx *gin.Context) string {
+				return ctx.ClientIP()
+			},
+		},
+	}
+	b.rules.Store(&[]*ruleLimiter{})
+	return b
+}
+
+func (b *RulesBuilder) Prefix(prefix string) *RulesBuilder {
+	b.prefix = prefix
+	return b
+}
+
+// KeyFunc 注册限流对象，比如 uid，规则里面的 key 写 uid 就会使用这里注册的 fn
+func (b *RulesBuilder) KeyFunc(name string, fn KeyFunc) *RulesBuilder {
+	b.keyFuncs[name] = fn
+	return b
+}
+
+// Load 替换全部规则，任何一条规则不合法都不会生效，继续使用原来的规则
+// 没有变化的规则会复用原来的限流器，本地限流器的状态不会因为热更新而丢失
+func (b *RulesBuilder) Load(rules []Rule) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	old := make(map[string]*ruleLimiter)
+	for _, r := range *b.rules.Load() {
+		old[r.Name] = r
+	}
+	names := make(map[string]struct{}, len(rules))
+	res := make([]*ruleLimiter, 0, len(rules))
+	for _, r := range rules {
+		if err := r.validate(); err != nil {
+			return err
+		}
+		if _, ok := names[r.Name]; ok {
+			return fmt.Errorf("限流规则 %s 重复了", r.Name)
+		}
+		names[r.Name] = struct{}{}
+		keyFunc, err := b.keyFunc(r.Key)
+		if err != nil {
+			return err
+		}
+		if o, ok := old[r.Name]; ok && reflect.DeepEqual(o.Rule, r) {
+			res = append(res, o)
+			continue
+		}
+		l, err := b.newLimiter(r)
+		if err != nil {
+			return err
+		}
+		res = append(res, &ruleLimiter{Rule: r, keyFunc: keyFunc, limiter: l})
+	}
+	b.rules.Store(&res)
+	return nil
+}
+
+func (b *RulesBuilder) keyFunc(key string) (KeyFunc, error) {
+	if name, ok := strings.CutPrefix(key, headerKeyPrefix); ok {
+		return func(ctx *gin.Context) string {
+			return ctx.GetHeader(name)
+		}, nil
+	}
+	fn, ok := b.keyFuncs[key]
+	if !ok {
+		return nil, fmt.Errorf("不支持按照 %s 限流", key)
+	}
+	return fn, nil
+}
+
+func (b *RulesBuilder) Build() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// 没有触发限流的时候，返回剩余额度最少的那条规则的情况
+		var tightest *limiter.Result
+		for _, r := range *b.rules.Load() {
+			if !r.match(ctx) {
+				continue
+			}
+			key := r.keyFunc(ctx)
+			if key == "" {
+				continue
+			}
+			res, err := limiter.LimitDetail(ctx, r.limiter, fmt.Sprintf("%s:%s:%s:%s", b.prefix, r.Name, r.algorithm(), key))
+			if err != nil {
+				zap.L().Error("限流失败", zap.String("rule", r.Name), zap.Error(err))
+				// 和 Builder 一样，保守做法，出错了直接拒绝
+				ctx.AbortWithStatus(http.StatusInternalServerError)
+				return
+			}
+			if res.Limited {
+				setHeaders(ctx, res)
+				ctx.Header("Retry-After", strconv.FormatInt(seconds(res.RetryAfter), 10))
+				ctx.AbortWithStatus(http.StatusTooManyRequests)
+				return
+			}
+			if tightest == nil || res.Remaining < tightest.Remaining {
+				tightest = &res
+			}
+		}
+		if tightest != nil {
+			setHeaders(ctx, *tightest)
+		}
+		ctx.Next()
+	}
+}
+
+func setHeaders(ctx *gin.Context, res limiter.Result) {
+	if res.Limit <= 0 {
+		// 限流器没有返回详细的结果
+		return
+	}
+	ctx.Header("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+	ctx.Header("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	// 和 Retry-After 一样，是多少秒之后，而不是时间戳，避免客户端和服务端时钟不一致
+	ctx.Header("X-RateLimit-Reset", strconv.FormatInt(seconds(res.Reset), 10))
+}
+
+// seconds 向上取整，不足一秒按一秒算，避免客户端立刻重试
+func seconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,124 @@
+package ratelimit
+
+import (
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRulesBuilder(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	b := NewRulesBuilder(LocalLimiterFactory(100)).
+		KeyFunc("uid", func(ctx *gin.Context) string {
+			return ctx.GetHeader("Uid")
+		})
+	err := b.Load([]Rule{
+		{Name: "login", Path: "/users/login", Methods: []string{"POST"}, Key: "ip", Interval: time.Minute, Rate: 2},
+		{Name: "articles", Path: "/articles/**", Key: "uid", Interval: time.Minute, Rate: 3},
+		{Name: "api", Path: "/articles/detail/:id", Key: "header:X-Api-Key",
+			Algorithm: AlgorithmTokenBucket, Interval: time.Minute, Rate: 1, Capacity: 1},
+	})
+	require.NoError(t, err)
+
+	server := gin.New()
+	server.Use(b.Build())
+	ok := func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	}
+	server.POST("/users/login", ok)
+	server.GET("/users/login", ok)
+	server.POST("/articles/edit", ok)
+	server.GET("/articles/detail/:id", ok)
+
+	do := func(method, path string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	// 登录按照 IP 限流，每分钟 2 次
+	resp := do(http.MethodPost, "/users/login", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "2", resp.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "1", resp.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "60", resp.Header().Get("X-RateLimit-Reset"))
+	do(http.MethodPost, "/users/login", nil)
+	resp = do(http.MethodPost, "/users/login", nil)
+	assert.Equal(t, http.StatusTooManyRequests, resp.Code)
+	assert.Equal(t, "0", resp.Header().Get("X-RateLimit-Remaining"))
+	assert.NotEmpty(t, resp.Header().Get("Retry-After"))
+	// 方法不匹配，不受影响
+	resp = do(http.MethodGet, "/users/login", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Empty(t, resp.Header().Get("X-RateLimit-Limit"))
+
+	// 按照 uid 限流，不同用户互不影响；取不到 uid 的时候不限流
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, do(http.MethodPost, "/articles/edit", map[string]string{"Uid": "1"}).Code)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, do(http.MethodPost, "/articles/edit", map[string]string{"Uid": "1"}).Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodPost, "/articles/edit", map[string]string{"Uid": "2"}).Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodPost, "/articles/edit", nil).Code)
+
+	// 同时命中两条规则，返回剩余额度最少的那条
+	resp = do(http.MethodGet, "/articles/detail/1", map[string]string{"Uid": "3", "X-Api-Key": "key"})
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "1", resp.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "0", resp.Header().Get("X-RateLimit-Remaining"))
+	resp = do(http.MethodGet, "/articles/detail/2", map[string]string{"Uid": "3", "X-Api-Key": "key"})
+	assert.Equal(t, http.StatusTooManyRequests, resp.Code)
+	assert.Equal(t, "60", resp.Header().Get("Retry-After"))
+
+	// 非法的规则不生效，继续使用原来的规则
+	err = b.Load([]Rule{{Name: "login", Path: "/users/login", Key: "unknown", Interval: time.Minute, Rate: 10}})
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, do(http.MethodPost, "/users/login", nil).Code)
+
+	// 热更新调大阈值之后马上生效
+	err = b.Load([]Rule{{Name: "login", Path: "/users/login", Key: "ip", Interval: time.Minute, Rate: 10}})
+	require.NoError(t, err)
+	resp = do(http.MethodPost, "/users/login", nil)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "10", resp.Header().Get("X-RateLimit-Limit"))
+	// 删掉的规则不再生效
+	assert.Equal(t, http.StatusOK, do(http.MethodPost, "/articles/edit", map[string]string{"Uid": "1"}).Code)
+}
+
+func TestRulesBuilder_ReloadAlgorithm(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	b := NewRulesBuilder(RedisLimiterFactory(rdb))
+	err := b.Load([]Rule{{Name: "login", Path: "/users/login", Key: "ip", Interval: time.Minute, Rate: 2}})
+	require.NoError(t, err)
+
+	server := gin.New()
+	server.Use(b.Build())
+	server.POST("/users/login", func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+	do := func() *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/users/login", nil))
+		return recorder
+	}
+	assert.Equal(t, http.StatusOK, do().Code)
+
+	// 滑动窗口是 ZSET，令牌桶是 HASH，换了算法之后不能复用原来的 key
+	err = b.Load([]Rule{{Name: "login", Path: "/users/login", Key: "ip",
+		Algorithm: AlgorithmTokenBucket, Interval: time.Minute, Rate: 2}})
+	require.NoError(t, err)
+	resp := do()
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "1", resp.Header().Get("X-RateLimit-Remaining"))
+}
@@ -0,0 +1,54 @@
+// Package httpx 调用第三方 HTTP 接口的一些通用组件
+package httpx
+
+import (
+	"net/http"
+	"time"
+)
+
+// RetryTransport 网络错误或者 5xx 的时候重试
+// 只重试 GET 和 HEAD，别的方法重试可能会重复执行
+type RetryTransport struct {
+	// Base 为 nil 的时候用 http.DefaultTransport
+	Base http.RoundTripper
+	// Retries 最多重试几次，不包括第一次请求
+	Retries int
+	// Backoff 第 n 次重试之前等待 n * Backoff
+	Backoff time.Duration
+}
+
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return base.RoundTrip(req)
+	}
+	for i := 0; ; i++ {
+		resp, err := base.RoundTrip(req)
+		if i >= t.Retries || (err == nil && resp.StatusCode < http.StatusInternalServerError) {
+			return resp, err
+		}
+		if err == nil {
+			// 没有读的响应要关掉，不然连接不会被复用
+			resp.Body.Close()
+		}
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(time.Duration(i+1) * t.Backoff):
+		}
+	}
+}
+
+// NewClient timeout 是整个请求的超时时间，包括所有的重试
+func NewClient(timeout time.Duration, retries int) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &RetryTransport{
+			Retries: retries,
+			Backoff: 100 * time.Millisecond,
+		},
+	}
+}
@@ -0,0 +1,71 @@
+package httpx
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryTransport(t *testing.T) {
+	testCases := []struct {
+		name    string
+		method  string
+		retries int
+		// failures 前几次请求返回 502
+		failures int32
+
+		wantStatus int
+		wantCalls  int32
+	}{
+		{
+			name:       "重试之后成功",
+			method:     http.MethodGet,
+			retries:    2,
+			failures:   2,
+			wantStatus: http.StatusOK,
+			wantCalls:  3,
+		},
+		{
+			name:       "重试次数用完",
+			method:     http.MethodGet,
+			retries:    1,
+			failures:   3,
+			wantStatus: http.StatusBadGateway,
+			wantCalls:  2,
+		},
+		{
+			name:       "POST 不重试",
+			method:     http.MethodPost,
+			retries:    2,
+			failures:   1,
+			wantStatus: http.StatusBadGateway,
+			wantCalls:  1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if calls.Add(1) <= tc.failures {
+					w.WriteHeader(http.StatusBadGateway)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer server.Close()
+			client := &http.Client{Transport: &RetryTransport{Retries: tc.retries, Backoff: time.Millisecond}}
+			req, err := http.NewRequest(tc.method, server.URL, strings.NewReader(""))
+			require.NoError(t, err)
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, tc.wantStatus, resp.StatusCode)
+			assert.Equal(t, tc.wantCalls, calls.Load())
+		})
+	}
+}
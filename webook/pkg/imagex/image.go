@@ -0,0 +1,140 @@
+// Package imagex 图片的校验和缩放，只用标准库，支持 JPEG、PNG 和 GIF
+package imagex
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	// 注册 GIF 的解码器，image.Decode 才认识
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+var (
+	ErrUnsupportedType = errors.New("不支持的图片格式")
+	ErrInvalidImage    = errors.New("图片已损坏")
+	ErrTooManyPixels   = errors.New("图片尺寸太大")
+)
+
+// maxPixels 解码之前先检查宽高，防止很小的文件解码出几个 G 的图片
+// 4096 x 4096 的 RGBA 图片解码之后是 64M，几个上传同时解码也还能接受
+const maxPixels = 4096 * 4096
+
+// Info 校验通过的图片
+type Info struct {
+	// ContentType image/jpeg、image/png 或者 image/gif
+	ContentType string
+	// Ext 不带点的扩展名
+	Ext    string
+	Width  int
+	Height int
+}
+
+var types = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+}
+
+// Inspect 根据内容判断图片格式，不相信客户端传过来的 Content-Type 和文件名
+func Inspect(data []byte) (Info, error) {
+	ct := http.DetectContentType(data)
+	ext, ok := types[ct]
+	if !ok {
+		return Info{}, ErrUnsupportedType
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Info{}, ErrInvalidImage
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return Info{}, ErrInvalidImage
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return Info{}, ErrTooManyPixels
+	}
+	return Info{ContentType: ct, Ext: ext, Width: cfg.Width, Height: cfg.Height}, nil
+}
+
+// Thumbnail 生成宽高都不超过 maxW、maxH 的缩略图，保持宽高比，不会放大
+// square 为 true 的时候先从中间裁剪成正方形，头像用
+// 原图是 JPEG 的时候输出 JPEG，其它的输出 PNG 保留透明度；GIF 只取第一帧
+func Thumbnail(data []byte, maxW, maxH int, square bool) ([]byte, Info, error) {
+	info, err := Inspect(data)
+	if err != nil {
+		return nil, Info{}, err
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, Info{}, ErrInvalidImage
+	}
+	b := src.Bounds()
+	if square {
+		side := min(b.Dx(), b.Dy())
+		x0 := b.Min.X + (b.Dx()-side)/2
+		y0 := b.Min.Y + (b.Dy()-side)/2
+		b = image.Rect(x0, y0, x0+side, y0+side)
+	}
+	w, h := fit(b.Dx(), b.Dy(), maxW, maxH)
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	scale(dst, src, b)
+
+	var buf bytes.Buffer
+	res := Info{Width: w, Height: h}
+	if info.ContentType == "image/jpeg" {
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85})
+		res.ContentType, res.Ext = "image/jpeg", "jpg"
+	} else {
+		err = png.Encode(&buf, dst)
+		res.ContentType, res.Ext = "image/png", "png"
+	}
+	if err != nil {
+		return nil, Info{}, err
+	}
+	return buf.Bytes(), res, nil
+}
+
+// fit 按比例缩小到 maxW x maxH 以内
+func fit(w, h, maxW, maxH int) (int, int) {
+	if w <= maxW && h <= maxH {
+		return w, h
+	}
+	// 比较 w/maxW 和 h/maxH，谁大按谁缩放
+	if w*maxH >= h*maxW {
+		return maxW, max(1, h*maxW/w)
+	}
+	return max(1, w*maxH/h), maxH
+}
+
+// scale 区域平均的缩小算法，每个目标像素取源图对应区域所有像素的平均值
+// 比最近邻清晰，缩略图够用了
+func scale(dst *image.RGBA, src image.Image, sr image.Rectangle) {
+	db := dst.Bounds()
+	if db.Dx() == sr.Dx() && db.Dy() == sr.Dy() {
+		draw.Draw(dst, db, src, sr.Min, draw.Src)
+		return
+	}
+	for y := 0; y < db.Dy(); y++ {
+		sy0 := sr.Min.Y + y*sr.Dy()/db.Dy()
+		sy1 := max(sy0+1, sr.Min.Y+(y+1)*sr.Dy()/db.Dy())
+		for x := 0; x < db.Dx(); x++ {
+			sx0 := sr.Min.X + x*sr.Dx()/db.Dx()
+			sx1 := max(sx0+1, sr.Min.X+(x+1)*sr.Dx()/db.Dx())
+			var r, g, b, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA64(x, y, color.RGBA64{
+				R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n),
+			})
+		}
+	}
+}
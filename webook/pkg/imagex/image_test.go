@@ -0,0 +1,153 @@
+package imagex
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func encode(t *testing.T, format string, w, h int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 100, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, nil)
+	case "png":
+		err = png.Encode(&buf, img)
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	}
+	require.NoError(t, err)
+	return buf.Bytes()
+}
+
+// pngWithSize 只修改 IHDR 里面声明的宽高，像素数据还是 1 x 1 的
+func pngWithSize(t *testing.T, w, h uint32) []byte {
+	data := encode(t, "png", 1, 1)
+	binary.BigEndian.PutUint32(data[16:20], w)
+	binary.BigEndian.PutUint32(data[20:24], h)
+	// 重新计算 IHDR 的 CRC
+	binary.BigEndian.PutUint32(data[29:33], crc32.ChecksumIEEE(data[12:29]))
+	return data
+}
+
+func TestInspect(t *testing.T) {
+	testCases := []struct {
+		name    string
+		data    []byte
+		want    Info
+		wantErr error
+	}{
+		{
+			name: "JPEG",
+			data: encode(t, "jpeg", 40, 30),
+			want: Info{ContentType: "image/jpeg", Ext: "jpg", Width: 40, Height: 30},
+		},
+		{
+			name: "PNG",
+			data: encode(t, "png", 40, 30),
+			want: Info{ContentType: "image/png", Ext: "png", Width: 40, Height: 30},
+		},
+		{
+			name: "GIF",
+			data: encode(t, "gif", 40, 30),
+			want: Info{ContentType: "image/gif", Ext: "gif", Width: 40, Height: 30},
+		},
+		{
+			name:    "不是图片",
+			data:    []byte("<html><script>alert(1)</script></html>"),
+			wantErr: ErrUnsupportedType,
+		},
+		{
+			name:    "只有文件头",
+			data:    encode(t, "png", 40, 30)[:20],
+			wantErr: ErrInvalidImage,
+		},
+		{
+			// 文件头声明的尺寸很大，实际数据很少
+			name:    "像素太多",
+			data:    pngWithSize(t, 10000, 10000),
+			wantErr: ErrTooManyPixels,
+		},
+		{
+			name:    "刚好超过上限",
+			data:    pngWithSize(t, 4097, 4096),
+			wantErr: ErrTooManyPixels,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			info, err := Inspect(tc.data)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.want, info)
+		})
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	testCases := []struct {
+		name   string
+		data   []byte
+		maxW   int
+		maxH   int
+		square bool
+
+		want Info
+	}{
+		{
+			name: "按宽度缩小",
+			data: encode(t, "jpeg", 400, 200),
+			maxW: 100, maxH: 100,
+			want: Info{ContentType: "image/jpeg", Ext: "jpg", Width: 100, Height: 50},
+		},
+		{
+			name: "按高度缩小",
+			data: encode(t, "png", 200, 400),
+			maxW: 100, maxH: 100,
+			want: Info{ContentType: "image/png", Ext: "png", Width: 50, Height: 100},
+		},
+		{
+			name: "小图不放大",
+			data: encode(t, "png", 20, 10),
+			maxW: 100, maxH: 100,
+			want: Info{ContentType: "image/png", Ext: "png", Width: 20, Height: 10},
+		},
+		{
+			name: "头像裁剪成正方形",
+			data: encode(t, "jpeg", 400, 200),
+			maxW: 64, maxH: 64, square: true,
+			want: Info{ContentType: "image/jpeg", Ext: "jpg", Width: 64, Height: 64},
+		},
+		{
+			name: "GIF 输出 PNG",
+			data: encode(t, "gif", 200, 200),
+			maxW: 50, maxH: 50,
+			want: Info{ContentType: "image/png", Ext: "png", Width: 50, Height: 50},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, info, err := Thumbnail(tc.data, tc.maxW, tc.maxH, tc.square)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, info)
+			// 输出的确实是这个尺寸的图片
+			got, err := Inspect(data)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package limiter
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestBucketLimiter_Burst(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	// 一小时才补充一个，测试过程中可以认为不补充
+	testCases := []struct {
+		name    string
+		limiter Limiter
+	}{
+		{
+			name:    "Redis 令牌桶",
+			limiter: NewRedisTokenBucketLimiter(rdb, time.Hour, 1, 3),
+		},
+		{
+			name:    "Redis 漏桶",
+			limiter: NewRedisLeakyBucketLimiter(rdb, time.Hour, 1, 3),
+		},
+		{
+			name:    "本地令牌桶",
+			limiter: NewLocalTokenBucketLimiter(time.Hour, 1, 3, 100),
+		},
+		{
+			name:    "本地漏桶",
+			limiter: NewLocalLeakyBucketLimiter(time.Hour, 1, 3, 100),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			// 容量是 3，前 3 个请求通过
+			for i := 0; i < 3; i++ {
+				limited, err := tc.limiter.Limit(ctx, tc.name)
+				require.NoError(t, err)
+				assert.False(t, limited)
+			}
+			limited, err := tc.limiter.Limit(ctx, tc.name)
+			require.NoError(t, err)
+			assert.True(t, limited)
+			// 不同的 key 互不影响
+			limited, err = tc.limiter.Limit(ctx, tc.name+":other")
+			require.NoError(t, err)
+			assert.False(t, limited)
+		})
+	}
+}
+
+func TestLocalBucketLimiter_Refill(t *testing.T) {
+	now := time.Now()
+	clock := func() time.Time {
+		return now
+	}
+	tb := NewLocalTokenBucketLimiter(time.Second, 10, 10, 100)
+	tb.now = clock
+	lb := NewLocalLeakyBucketLimiter(time.Second, 10, 10, 100)
+	lb.now = clock
+
+	for _, l := range []Limiter{tb, lb} {
+		for i := 0; i < 10; i++ {
+			limited, _ := l.Limit(context.Background(), "key")
+			assert.False(t, limited)
+		}
+		limited, _ := l.Limit(context.Background(), "key")
+		assert.True(t, limited)
+	}
+
+	// 每秒 10 个，过了 100ms 之后正好能再通过一个
+	now = now.Add(time.Millisecond * 100)
+	for _, l := range []Limiter{tb, lb} {
+		limited, _ := l.Limit(context.Background(), "key")
+		assert.False(t, limited)
+		limited, _ = l.Limit(context.Background(), "key")
+		assert.True(t, limited)
+	}
+}
+
+func TestLimiter_LimitDetail(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	testCases := []struct {
+		name    string
+		limiter DetailLimiter
+	}{
+		{
+			name:    "Redis 滑动窗口",
+			limiter: NewRedisSlidingWindowLimiter(rdb, time.Hour, 3),
+		},
+		{
+			name:    "Redis 令牌桶",
+			limiter: NewRedisTokenBucketLimiter(rdb, time.Hour, 1, 3),
+		},
+		{
+			name:    "Redis 漏桶",
+			limiter: NewRedisLeakyBucketLimiter(rdb, time.Hour, 1, 3),
+		},
+		{
+			name:    "本地滑动窗口",
+			limiter: NewLocalSlidingWindowLimiter(time.Hour, 3, 100),
+		},
+		{
+			name:    "本地令牌桶",
+			limiter: NewLocalTokenBucketLimiter(time.Hour, 1, 3, 100),
+		},
+		{
+			name:    "本地漏桶",
+			limiter: NewLocalLeakyBucketLimiter(time.Hour, 1, 3, 100),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			for i := 0; i < 3; i++ {
+				res, err := tc.limiter.LimitDetail(ctx, tc.name)
+				require.NoError(t, err)
+				assert.False(t, res.Limited)
+				assert.Equal(t, 3, res.Limit)
+				assert.Equal(t, 2-i, res.Remaining)
+				assert.Equal(t, time.Duration(0), res.RetryAfter)
+				assert.True(t, res.Reset > 0 && res.Reset <= time.Hour*3)
+			}
+			res, err := tc.limiter.LimitDetail(ctx, tc.name)
+			require.NoError(t, err)
+			assert.True(t, res.Limited)
+			assert.Equal(t, 0, res.Remaining)
+			assert.True(t, res.RetryAfter > 0 && res.RetryAfter <= time.Hour)
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package limiter
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"sync/atomic"
+	"time"
+)
+
+// FallbackLimiter 正常情况下使用 primary（一般是基于 Redis 的限流器）
+// primary 出错之后切换到 fallback（一般是本地限流器），同时启动探活，探活成功之后再切换回来
+// 这样 Redis 出问题的时候，不至于所有请求都被拒绝
+
+type FallbackLimiter struct {
+	primary  Limiter
+	fallback Limiter
+	// 探活，比如 Redis 的 PING
+	probe         func(ctx context.Context) error
+	probeInterval time.Duration
+
+	// primary 是否健康
+	unhealthy atomic.Bool
+	// 保证同一时刻只有一个探活的 goroutine
+	probing atomic.Bool
+}
+
+func NewFallbackLimiter(primary Limiter, fallback Limiter, probe func(ctx context.Context) error) *FallbackLimiter {
+	return &FallbackLimiter{
+		primary:       primary,
+		fallback:      fallback,
+		probe:         probe,
+		probeInterval: time.Second,
+	}
+}
+
+func (f *FallbackLimiter) ProbeInterval(interval time.Duration) *FallbackLimiter {
+	f.probeInterval = interval
+	return f
+}
+
+func (f *FallbackLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	res, err := f.LimitDetail(ctx, key)
+	return res.Limited, err
+}
+
+func (f *FallbackLimiter) LimitDetail(ctx context.Context, key string) (Result, error) {
+	if f.unhealthy.Load() {
+		return LimitDetail(ctx, f.fallback, key)
+	}
+	res, err := LimitDetail(ctx, f.primary, key)
+	if err == nil {
+		return res, nil
+	}
+	// 超时或者被取消是调用方的问题，不代表 primary 不健康
+	if ctx.Err() != nil {
+		return Result{}, err
+	}
+	zap.L().Warn("限流器出错，切换到降级限流器", zap.Error(err))
+	f.unhealthy.Store(true)
+	f.startProbe()
+	return LimitDetail(ctx, f.fallback, key)
+}
+
+func (f *FallbackLimiter) startProbe() {
+	if !f.probing.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(f.probeInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			ctx, cancel := context.WithTimeout(context.Background(), f.probeInterval)
+			err := f.probe(ctx)
+			cancel()
+			if err == nil {
+				zap.L().Info("限流器恢复，切换回主限流器")
+				// 先结束探活再切换回去，保证切换回去之后再出错还能重新启动探活
+				f.probing.Store(false)
+				f.unhealthy.Store(false)
+				return
+			}
+		}
+	}()
+}
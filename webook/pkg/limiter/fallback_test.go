@@ -0,0 +1,87 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// stubLimiter 记录被调用的次数，并返回预设的结果
+type stubLimiter struct {
+	cnt     atomic.Int64
+	limited bool
+	err     atomic.Pointer[error]
+}
+
+func (s *stubLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	s.cnt.Add(1)
+	if err := s.err.Load(); err != nil {
+		return false, *err
+	}
+	return s.limited, nil
+}
+
+func TestFallbackLimiter(t *testing.T) {
+	primary := &stubLimiter{limited: false}
+	fallback := &stubLimiter{limited: true}
+	var healthy atomic.Bool
+	l := NewFallbackLimiter(primary, fallback, func(ctx context.Context) error {
+		if healthy.Load() {
+			return nil
+		}
+		return errors.New("redis 还没恢复")
+	}).ProbeInterval(time.Millisecond * 10)
+	ctx := context.Background()
+
+	// 正常情况下使用主限流器
+	limited, err := l.Limit(ctx, "key")
+	require.NoError(t, err)
+	assert.False(t, limited)
+
+	// 主限流器出错，这一次请求就直接走降级限流器
+	redisErr := errors.New("redis 崩溃了")
+	primary.err.Store(&redisErr)
+	limited, err = l.Limit(ctx, "key")
+	require.NoError(t, err)
+	assert.True(t, limited)
+
+	// 降级期间，不再请求主限流器
+	_, _ = l.Limit(ctx, "key")
+	assert.Equal(t, int64(2), primary.cnt.Load())
+	assert.Equal(t, int64(2), fallback.cnt.Load())
+
+	// 探活成功之后切换回来
+	primary.err.Store(nil)
+	healthy.Store(true)
+	assert.Eventually(t, func() bool {
+		return !l.unhealthy.Load()
+	}, time.Second, time.Millisecond*10)
+	limited, err = l.Limit(ctx, "key")
+	require.NoError(t, err)
+	assert.False(t, limited)
+	assert.Equal(t, int64(3), primary.cnt.Load())
+}
+
+func TestLocalSlidingWindowLimiter(t *testing.T) {
+	now := time.Now()
+	l := NewLocalSlidingWindowLimiter(time.Second, 2, 100)
+	l.now = func() time.Time {
+		return now
+	}
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		limited, _ := l.Limit(ctx, "key")
+		assert.False(t, limited)
+	}
+	limited, _ := l.Limit(ctx, "key")
+	assert.True(t, limited)
+
+	// 窗口滑过去之后就可以再请求了
+	now = now.Add(time.Second + time.Millisecond)
+	limited, _ = l.Limit(ctx, "key")
+	assert.False(t, limited)
+}
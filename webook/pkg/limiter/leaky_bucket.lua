@@ -0,0 +1,39 @@
+-- 漏桶：请求像水一样倒进桶里，桶按照固定速率漏水，桶满了就限流
+-- 每个 key 只保存水位和上一次更新的时间，内存占用是 O(1)
+
+-- 限流对象
+local key = KEYS[1]
+-- 漏水的周期，毫秒
+local interval = tonumber(ARGV[1])
+-- 每个周期漏多少
+local rate = tonumber(ARGV[2])
+-- 桶的容量
+local capacity = tonumber(ARGV[3])
+local now = tonumber(ARGV[4])
+
+local bucket = redis.call('HMGET', key, 'water', 'ts')
+local water = tonumber(bucket[1])
+local ts = tonumber(bucket[2])
+if water == nil or ts == nil then
+    water = 0
+    ts = now
+end
+
+-- 按照流逝的时间漏水，最少漏到空
+local elapsed = math.max(0, now - ts)
+water = math.max(0, water - elapsed * rate / interval)
+
+-- 返回 {是否限流, 剩余额度, 多久之后漏空（毫秒）, 多久之后可以重试（毫秒）}
+local limited = 1
+local retry = 0
+if water + 1 <= capacity then
+    water = water + 1
+    limited = 0
+else
+    retry = math.ceil((water + 1 - capacity) * interval / rate)
+end
+
+redis.call('HSET', key, 'water', water, 'ts', now)
+-- 桶漏空之后，这个 key 有没有都一样，让它过期
+redis.call('PEXPIRE', key, math.ceil(capacity * interval / rate))
+return {limited, math.floor(capacity - water), math.ceil(water * interval / rate), retry}
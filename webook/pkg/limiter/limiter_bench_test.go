@@ -0,0 +1,53 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"os"
+	"testing"
+	"time"
+)
+
+// 对比几种限流算法的开销
+// 默认使用 miniredis，要对比真实 Redis 的表现，可以设置 REDIS_ADDR，例如：
+// REDIS_ADDR=localhost:6379 go test -bench=. -benchmem ./webook/pkg/limiter/
+// 滑动窗口每个请求都会在 ZSET 里面留一个成员，阈值越高、单个 key 占用的内存越大；两种桶每个 key 都只有两个字段
+
+func benchRedis(b *testing.B) redis.Cmdable {
+	if addr := os.Getenv("REDIS_ADDR"); addr != "" {
+		return redis.NewClient(&redis.Options{Addr: addr})
+	}
+	mr := miniredis.RunT(b)
+	return redis.NewClient(&redis.Options{Addr: mr.Addr()})
+}
+
+func BenchmarkLimiter(b *testing.B) {
+	// 和 initTencentSmsService 里面的配置一样，一秒 3000 个
+	const rate = 3000
+	rdb := benchRedis(b)
+	limiters := []struct {
+		name string
+		l    Limiter
+	}{
+		{name: "RedisSlidingWindow", l: NewRedisSlidingWindowLimiter(rdb, time.Second, rate)},
+		{name: "RedisTokenBucket", l: NewRedisTokenBucketLimiter(rdb, time.Second, rate, rate)},
+		{name: "RedisLeakyBucket", l: NewRedisLeakyBucketLimiter(rdb, time.Second, rate, rate)},
+		{name: "LocalTokenBucket", l: NewLocalTokenBucketLimiter(time.Second, rate, rate, 10000)},
+		{name: "LocalLeakyBucket", l: NewLocalLeakyBucketLimiter(time.Second, rate, rate, 10000)},
+	}
+	for _, lc := range limiters {
+		b.Run(lc.name, func(b *testing.B) {
+			ctx := context.Background()
+			key := fmt.Sprintf("bench:%s", lc.name)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, err := lc.l.Limit(ctx, key)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package limiter
+
+import (
+	"github.com/hashicorp/golang-lru/simplelru"
+	"math"
+	"sync"
+	"time"
+)
+
+// localBuckets 本地限流器保存每个 key 的状态
+// key 的数量不可控（比如按照 IP 限流），所以使用 LRU 控制内存，被淘汰的 key 相当于状态重置
+
+type localBuckets struct {
+	lock  sync.Mutex
+	cache *simplelru.LRU
+}
+
+type bucketState struct {
+	// 令牌桶里面是令牌数，漏桶里面是水位
+	val float64
+	ts  time.Time
+}
+
+func newLocalBuckets(maxKeys int) *localBuckets {
+	c, err := simplelru.NewLRU(maxKeys, nil)
+	if err != nil {
+		panic(err)
+	}
+	return &localBuckets{
+		cache: c,
+	}
+}
+
+// update 在锁里面读取并修改 key 的状态，fn 返回限流的结果
+func (l *localBuckets) update(key string, fn func(state *bucketState, ok bool) Result) Result {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	var state bucketState
+	val, ok := l.cache.Get(key)
+	if ok {
+		state = val.(bucketState)
+	}
+	res := fn(&state, ok)
+	l.cache.Add(key, state)
+	return res
+}
+
+// rateDuration 按照 interval 产生 rate 个的速度，产生 n 个需要多久
+func rateDuration(n float64, interval time.Duration, rate int) time.Duration {
+	return time.Duration(math.Ceil(n * float64(interval) / float64(rate)))
+}
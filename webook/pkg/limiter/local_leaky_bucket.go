@@ -0,0 +1,54 @@
+package limiter
+
+import (
+	"context"
+	"time"
+)
+
+// LocalLeakyBucketLimiter 本地漏桶，只在单个实例内生效
+
+type LocalLeakyBucketLimiter struct {
+	buckets  *localBuckets
+	interval time.Duration
+	rate     int
+	capacity int
+	now      func() time.Time
+}
+
+// NewLocalLeakyBucketLimiter maxKeys 是最多同时保存多少个 key 的状态
+func NewLocalLeakyBucketLimiter(interval time.Duration, rate int, capacity int, maxKeys int) *LocalLeakyBucketLimiter {
+	return &LocalLeakyBucketLimiter{
+		buckets:  newLocalBuckets(maxKeys),
+		interval: interval,
+		rate:     rate,
+		capacity: capacity,
+		now:      time.Now,
+	}
+}
+
+func (b *LocalLeakyBucketLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	res, err := b.LimitDetail(ctx, key)
+	return res.Limited, err
+}
+
+func (b *LocalLeakyBucketLimiter) LimitDetail(ctx context.Context, key string) (Result, error) {
+	now := b.now()
+	return b.buckets.update(key, func(state *bucketState, ok bool) Result {
+		if !ok {
+			state.ts = now
+		}
+		elapsed := max(0, now.Sub(state.ts))
+		state.val = max(0, state.val-float64(elapsed)*float64(b.rate)/float64(b.interval))
+		state.ts = now
+		res := Result{Limit: b.capacity}
+		if state.val+1 > float64(b.capacity) {
+			res.Limited = true
+			res.RetryAfter = rateDuration(state.val+1-float64(b.capacity), b.interval, b.rate)
+		} else {
+			state.val++
+		}
+		res.Remaining = int(float64(b.capacity) - state.val)
+		res.Reset = rateDuration(state.val, b.interval, b.rate)
+		return res
+	}), nil
+}
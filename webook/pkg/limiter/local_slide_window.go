@@ -0,0 +1,74 @@
+package limiter
+
+import (
+	"context"
+	"github.com/hashicorp/golang-lru/simplelru"
+	"sync"
+	"time"
+)
+
+// LocalSlidingWindowLimiter 本地滑动窗口，语义和 RedisSlidingWindowLimiter 一样，只在单个实例内生效
+// 一般作为 Redis 不可用时候的降级方案
+
+type LocalSlidingWindowLimiter struct {
+	lock     sync.Mutex
+	windows  *simplelru.LRU
+	interval time.Duration
+	// 阈值
+	rate int
+	now  func() time.Time
+}
+
+// NewLocalSlidingWindowLimiter maxKeys 是最多同时保存多少个 key 的窗口
+func NewLocalSlidingWindowLimiter(interval time.Duration, rate int, maxKeys int) *LocalSlidingWindowLimiter {
+	c, err := simplelru.NewLRU(maxKeys, nil)
+	if err != nil {
+		panic(err)
+	}
+	return &LocalSlidingWindowLimiter{
+		windows:  c,
+		interval: interval,
+		rate:     rate,
+		now:      time.Now,
+	}
+}
+
+func (l *LocalSlidingWindowLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	res, err := l.LimitDetail(ctx, key)
+	return res.Limited, err
+}
+
+func (l *LocalSlidingWindowLimiter) LimitDetail(ctx context.Context, key string) (Result, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	now := l.now()
+	// 窗口的起始时间
+	windowStart := now.Add(-l.interval)
+
+	var reqs []time.Time
+	if val, ok := l.windows.Get(key); ok {
+		reqs = val.([]time.Time)
+	}
+	// 请求是按时间顺序追加的，找到第一个还在窗口内的请求
+	i := 0
+	for i < len(reqs) && !reqs[i].After(windowStart) {
+		i++
+	}
+	reqs = reqs[i:]
+	if len(reqs) >= l.rate {
+		l.windows.Add(key, reqs)
+		res := Result{Limited: true, Limit: l.rate, Reset: l.interval, RetryAfter: l.interval}
+		if len(reqs) > 0 {
+			// 等最早的那个请求滑出窗口就可以重试了
+			res.RetryAfter = reqs[0].Add(l.interval).Sub(now)
+			res.Reset = reqs[len(reqs)-1].Add(l.interval).Sub(now)
+		}
+		return res, nil
+	}
+	l.windows.Add(key, append(reqs, now))
+	return Result{
+		Limit:     l.rate,
+		Remaining: l.rate - len(reqs) - 1,
+		Reset:     l.interval,
+	}, nil
+}
@@ -0,0 +1,57 @@
+package limiter
+
+import (
+	"context"
+	"time"
+)
+
+// LocalTokenBucketLimiter 本地令牌桶，只在单个实例内生效
+
+type LocalTokenBucketLimiter struct {
+	buckets  *localBuckets
+	interval time.Duration
+	rate     int
+	capacity int
+	now      func() time.Time
+}
+
+// NewLocalTokenBucketLimiter maxKeys 是最多同时保存多少个 key 的状态
+func NewLocalTokenBucketLimiter(interval time.Duration, rate int, capacity int, maxKeys int) *LocalTokenBucketLimiter {
+	return &LocalTokenBucketLimiter{
+		buckets:  newLocalBuckets(maxKeys),
+		interval: interval,
+		rate:     rate,
+		capacity: capacity,
+		now:      time.Now,
+	}
+}
+
+func (b *LocalTokenBucketLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	res, err := b.LimitDetail(ctx, key)
+	return res.Limited, err
+}
+
+func (b *LocalTokenBucketLimiter) LimitDetail(ctx context.Context, key string) (Result, error) {
+	now := b.now()
+	return b.buckets.update(key, func(state *bucketState, ok bool) Result {
+		if !ok {
+			// 第一次请求，桶是满的
+			state.val = float64(b.capacity)
+			state.ts = now
+		}
+		elapsed := max(0, now.Sub(state.ts))
+		state.val = min(float64(b.capacity),
+			state.val+float64(elapsed)*float64(b.rate)/float64(b.interval))
+		state.ts = now
+		res := Result{Limit: b.capacity}
+		if state.val < 1 {
+			res.Limited = true
+			res.RetryAfter = rateDuration(1-state.val, b.interval, b.rate)
+		} else {
+			state.val--
+		}
+		res.Remaining = int(state.val)
+		res.Reset = rateDuration(float64(b.capacity)-state.val, b.interval, b.rate)
+		return res
+	}), nil
+}
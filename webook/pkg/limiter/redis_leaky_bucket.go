@@ -0,0 +1,42 @@
+package limiter
+
+import (
+	"context"
+	_ "embed"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+//go:embed leaky_bucket.lua
+var luaLeakyBucket string
+
+// RedisLeakyBucketLimiter 基于 Redis 的漏桶
+// 这里的漏桶是“计量器”的用法：桶满了直接拒绝，而不是排队等待
+
+type RedisLeakyBucketLimiter struct {
+	cmd      redis.Cmdable
+	interval time.Duration
+	// 每个 interval 漏掉多少
+	rate int
+	// 桶的容量
+	capacity int
+}
+
+func NewRedisLeakyBucketLimiter(cmd redis.Cmdable, interval time.Duration, rate int, capacity int) *RedisLeakyBucketLimiter {
+	return &RedisLeakyBucketLimiter{
+		cmd:      cmd,
+		interval: interval,
+		rate:     rate,
+		capacity: capacity,
+	}
+}
+
+func (b *RedisLeakyBucketLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	res, err := b.LimitDetail(ctx, key)
+	return res.Limited, err
+}
+
+func (b *RedisLeakyBucketLimiter) LimitDetail(ctx context.Context, key string) (Result, error) {
+	return evalResult(b.cmd.Eval(ctx, luaLeakyBucket, []string{key},
+		b.interval.Milliseconds(), b.rate, b.capacity, time.Now().UnixMilli()), b.capacity)
+}
@@ -3,6 +3,8 @@ package limiter
 import (
 	"context"
 	_ "embed"
+	"fmt"
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 	"time"
 )
@@ -26,6 +28,29 @@ func NewRedisSlidingWindowLimiter(cmd redis.Cmdable, interval time.Duration, rat
 }
 
 func (b *RedisSlidingWindowLimiter) Limit(ctx context.Context, key string) (bool, error) {
-	return b.cmd.Eval(ctx, luaScript, []string{key},
-		b.interval.Milliseconds(), b.rate, time.Now().UnixMilli()).Bool()
+	res, err := b.LimitDetail(ctx, key)
+	return res.Limited, err
+}
+
+func (b *RedisSlidingWindowLimiter) LimitDetail(ctx context.Context, key string) (Result, error) {
+	return evalResult(b.cmd.Eval(ctx, luaScript, []string{key},
+		b.interval.Milliseconds(), b.rate, time.Now().UnixMilli(), uuid.New().String()), b.rate)
+}
+
+// evalResult 解析 lua 脚本的返回值 {是否限流, 剩余额度, 恢复时间（毫秒）, 重试时间（毫秒）}
+func evalResult(cmd *redis.Cmd, limit int) (Result, error) {
+	vals, err := cmd.Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	if len(vals) != 4 {
+		return Result{}, fmt.Errorf("限流脚本返回值不对 %v", vals)
+	}
+	return Result{
+		Limited:    vals[0] == 1,
+		Limit:      limit,
+		Remaining:  int(vals[1]),
+		Reset:      time.Duration(vals[2]) * time.Millisecond,
+		RetryAfter: time.Duration(vals[3]) * time.Millisecond,
+	}, nil
 }
@@ -9,18 +9,29 @@ local window = tonumber(ARGV[1])
 -- 阈值
 local threshold = tonumber( ARGV[2])
 local now = tonumber(ARGV[3])
+-- 每个请求唯一的 member，同一毫秒内的多个请求不能互相覆盖
+local member = ARGV[4]
 -- 窗口的起始时间
 local min = now - window
 
 redis.call('ZREMRANGEBYSCORE', key, '-inf', min)
 local cnt = redis.call('ZCOUNT', key, '-inf', '+inf')
 -- local cnt = redis.call('ZCOUNT', key, min, '+inf')
+-- 返回 {是否限流, 剩余额度, 多久之后完全恢复（毫秒）, 多久之后可以重试（毫秒）}
 if cnt >= threshold then
-    -- 执行限流
-    return "true"
+    -- 执行限流，等最早的那个请求滑出窗口就可以重试了
+    local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+    if #oldest == 0 then
+        -- 阈值是 0，永远限流
+        return {1, 0, window, window}
+    end
+    local retry = tonumber(oldest[2]) + window - now
+    local newest = redis.call('ZRANGE', key, -1, -1, 'WITHSCORES')
+    local reset = tonumber(newest[2]) + window - now
+    return {1, 0, reset, retry}
 else
-    -- 把 score 和 member 都设置成 now
-    redis.call('ZADD', key, now, now)
+    -- score 设置成 now
+    redis.call('ZADD', key, now, member)
     redis.call('PEXPIRE', key, window)
-    return "false"
-end
\ No newline at end of file
+    return {0, threshold - cnt - 1, window, 0}
+end
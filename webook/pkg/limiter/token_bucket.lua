@@ -0,0 +1,40 @@
+-- 令牌桶：按照固定速率往桶里面放令牌，请求拿到令牌才能通过
+-- 每个 key 只保存令牌数和上一次更新的时间，内存占用是 O(1)
+
+-- 限流对象
+local key = KEYS[1]
+-- 放令牌的周期，毫秒
+local interval = tonumber(ARGV[1])
+-- 每个周期放多少个令牌
+local rate = tonumber(ARGV[2])
+-- 桶的容量，也就是允许的突发流量
+local capacity = tonumber(ARGV[3])
+local now = tonumber(ARGV[4])
+
+local bucket = redis.call('HMGET', key, 'tokens', 'ts')
+local tokens = tonumber(bucket[1])
+local ts = tonumber(bucket[2])
+if tokens == nil or ts == nil then
+    -- 第一次请求，桶是满的
+    tokens = capacity
+    ts = now
+end
+
+-- 按照流逝的时间补充令牌，最多补满
+local elapsed = math.max(0, now - ts)
+tokens = math.min(capacity, tokens + elapsed * rate / interval)
+
+-- 返回 {是否限流, 剩余额度, 多久之后补满（毫秒）, 多久之后可以重试（毫秒）}
+local limited = 1
+local retry = 0
+if tokens >= 1 then
+    tokens = tokens - 1
+    limited = 0
+else
+    retry = math.ceil((1 - tokens) * interval / rate)
+end
+
+redis.call('HSET', key, 'tokens', tokens, 'ts', now)
+-- 桶补满之后，这个 key 有没有都一样，让它过期
+redis.call('PEXPIRE', key, math.ceil(capacity * interval / rate))
+return {limited, math.floor(tokens), math.ceil((capacity - tokens) * interval / rate), retry}
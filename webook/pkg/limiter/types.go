@@ -1,9 +1,41 @@
 package limiter
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type Limiter interface {
 	// Limit 是否触发限流
 	// 如果返回 true， 触发限流
 	Limit(ctx context.Context, key string) (bool, error)
 }
+
+// Result 一次限流判定的详细结果，用来告诉调用方还剩多少额度、什么时候恢复
+type Result struct {
+	// Limited 是否触发限流
+	Limited bool
+	// Limit 阈值
+	Limit int
+	// Remaining 这一次请求之后还剩多少额度
+	Remaining int
+	// Reset 多久之后额度完全恢复
+	Reset time.Duration
+	// RetryAfter 触发限流的时候，多久之后可以再试
+	RetryAfter time.Duration
+}
+
+// DetailLimiter 能够返回详细结果的限流器
+type DetailLimiter interface {
+	Limiter
+	LimitDetail(ctx context.Context, key string) (Result, error)
+}
+
+// LimitDetail 如果 l 支持返回详细结果，就返回详细结果，否则只有 Limited 字段有意义
+func LimitDetail(ctx context.Context, l Limiter, key string) (Result, error) {
+	if dl, ok := l.(DetailLimiter); ok {
+		return dl.LimitDetail(ctx, key)
+	}
+	limited, err := l.Limit(ctx, key)
+	return Result{Limited: limited}, err
+}
@@ -13,3 +13,10 @@ func Int64(key string, val int64) Field {
 		val,
 	}
 }
+
+func String(key string, val string) Field {
+	return Field{
+		key,
+		val,
+	}
+}
@@ -0,0 +1,38 @@
+// Package mask 给别人看的手机号和邮箱要打码
+package mask
+
+import "strings"
+
+// Phone 保留前三位和后四位，13812345678 => 138****5678
+// 太短的号码只保留第一位和最后一位
+func Phone(phone string) string {
+	r := []rune(phone)
+	switch {
+	case len(r) == 0:
+		return ""
+	case len(r) >= 8:
+		return string(r[:3]) + strings.Repeat("*", len(r)-7) + string(r[len(r)-4:])
+	case len(r) <= 2:
+		return strings.Repeat("*", len(r))
+	default:
+		return string(r[:1]) + strings.Repeat("*", len(r)-2) + string(r[len(r)-1:])
+	}
+}
+
+// Email 只打码 @ 前面的部分，长度固定，不暴露用户名有多长
+// abcdef@qq.com => a***f@qq.com
+func Email(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return Phone(email)
+	}
+	name, domain := []rune(email[:at]), email[at:]
+	switch len(name) {
+	case 0:
+		return domain
+	case 1, 2:
+		return string(name[:1]) + "***" + domain
+	default:
+		return string(name[:1]) + "***" + string(name[len(name)-1:]) + domain
+	}
+}
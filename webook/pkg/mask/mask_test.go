@@ -0,0 +1,44 @@
+package mask
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPhone(t *testing.T) {
+	testCases := []struct {
+		phone string
+		want  string
+	}{
+		{phone: "13812345678", want: "138****5678"},
+		{phone: "+8613812345678", want: "+86*******5678"},
+		{phone: "12345", want: "1***5"},
+		{phone: "12", want: "**"},
+		{phone: "", want: ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.phone, func(t *testing.T) {
+			assert.Equal(t, tc.want, Phone(tc.phone))
+		})
+	}
+}
+
+func TestEmail(t *testing.T) {
+	testCases := []struct {
+		email string
+		want  string
+	}{
+		{email: "abcdef@qq.com", want: "a***f@qq.com"},
+		{email: "ab@qq.com", want: "a***@qq.com"},
+		{email: "a@qq.com", want: "a***@qq.com"},
+		{email: "大明@qq.com", want: "大***@qq.com"},
+		{email: "大明同学@qq.com", want: "大***学@qq.com"},
+		{email: "@qq.com", want: "@qq.com"},
+		{email: "", want: ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.email, func(t *testing.T) {
+			assert.Equal(t, tc.want, Email(tc.email))
+		})
+	}
+}
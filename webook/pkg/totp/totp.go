@@ -0,0 +1,87 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// 基于时间的一次性密码，见 RFC 6238
+// 使用和 Google Authenticator 等验证器应用一样的默认参数：HMAC-SHA1、6 位数字、30 秒一个周期
+
+const (
+	Digits = 6
+	Period = 30 * time.Second
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret 生成 160 位的随机密钥，返回 base32 编码（不带填充），用户可以手动输入到验证器应用
+func GenerateSecret() (string, error) {
+	data := make([]byte, 20)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(data), nil
+}
+
+// Counter t 所在的周期
+func Counter(t time.Time) int64 {
+	return t.Unix() / int64(Period/time.Second)
+}
+
+// Code 第 counter 个周期的验证码
+func Code(secret string, counter int64) (string, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return "", fmt.Errorf("密钥不是 base32 编码 %w", err)
+	}
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(counter))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+	// 动态截断，见 RFC 4226 5.3
+	offset := sum[len(sum)-1] & 0x0F
+	val := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7FFFFFFF
+	return fmt.Sprintf("%0*d", Digits, val%1000000), nil
+}
+
+// Validate 校验验证码，前后 skew 个周期内的验证码都可以，容忍手机和服务器的时间误差
+// 返回匹配的周期，调用方需要记录下来，同一个周期的验证码不能用两次
+func Validate(secret, code string, t time.Time, skew int) (int64, bool) {
+	if len(code) != Digits {
+		return 0, false
+	}
+	current := Counter(t)
+	for i := -skew; i <= skew; i++ {
+		want, err := Code(secret, current+int64(i))
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(want), []byte(code)) == 1 {
+			return current + int64(i), true
+		}
+	}
+	return 0, false
+}
+
+// URI 验证器应用扫码添加账号用的 otpauth URI
+// 见 https://github.com/google/google-authenticator/wiki/Key-Uri-Format
+func URI(issuer, account, secret string) string {
+	label := url.PathEscape(issuer + ":" + account)
+	vals := url.Values{}
+	vals.Set("secret", secret)
+	vals.Set("issuer", issuer)
+	vals.Set("algorithm", "SHA1")
+	vals.Set("digits", fmt.Sprint(Digits))
+	vals.Set("period", fmt.Sprint(int(Period/time.Second)))
+	return "otpauth://totp/" + label + "?" + vals.Encode()
+}
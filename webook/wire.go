@@ -8,13 +8,12 @@ import (
 	"Learn_Go/webook/internal/repository/dao"
 	"Learn_Go/webook/internal/service"
 	"Learn_Go/webook/internal/web"
-	ijwt "Learn_Go/webook/internal/web/jwt"
 	"Learn_Go/webook/ioc"
-	"github.com/gin-gonic/gin"
+	"Learn_Go/webook/pkg/ginx/authz"
 	"github.com/google/wire"
 )
 
-func InitWebServer() *gin.Engine {
+func InitApp() *App {
 	wire.Build(
 		// 第三方依赖
 		ioc.InitRedis, ioc.InitDB,
@@ -22,23 +21,39 @@ func InitWebServer() *gin.Engine {
 		// dao
 		dao.NewGORMUserDao,
 		dao.NewArticleGORMDAO,
+		dao.NewGORMRoleDAO, dao.NewGORMMFADAO,
 		// cache
-		cache.NewRedisUserCache, cache.NewRedisCodeCache,
+		ioc.InitUserCache, ioc.InitCodeCache, cache.NewRedisCaptchaCache, cache.NewRedisRoleCache, cache.NewRedisOAuthStateCache,
 		// repository
-		repository.NewCodeRepository, repository.NewCachedUserRepository, repository.NewCachedArticleRepository,
+		repository.NewCodeRepository, repository.NewCaptchaRepository, repository.NewCachedUserRepository, repository.NewCachedArticleRepository,
+		repository.NewCachedRoleRepository, repository.NewMFARepository, repository.NewOAuthStateRepository,
 		// service
-		ioc.InitSmsService,
-		ioc.InitWechatService,
-		service.NewuserService, service.NewcodeService, service.NewArticleService,
+		ioc.InitSmsService, ioc.InitEmailService, ioc.InitVoiceService,
+		ioc.InitOAuth2Providers, ioc.InitOAuthStateService,
+		service.NewuserService, service.NewcodeService, service.NewArticleService, service.NewCaptchaService,
+		service.NewRoleService, service.NewMFAService, service.NewAccountService,
+		ioc.InitStorage, service.NewImageService,
+		service.NewNoopFollowService, service.NewProfileService,
 
 		// handler
-		ijwt.NewRedisJWTHandler,
+		ioc.InitJWTKeyManager,
+		ioc.InitJWTHandler,
 		web.NewUserHandler,
-		web.NewOAuth2WechatHandler,
+		web.NewOAuth2Handler,
 		web.NewArticleHandler,
+		web.NewCaptchaHandler,
+		web.NewJWKSHandler,
+		web.NewAdminHandler,
+		web.NewAccountHandler,
+		web.NewUploadHandler,
+		web.NewProfileHandler,
 
+		authz.NewRegistry,
 		ioc.InitGinMiddleWares,
 		ioc.InitWebServer,
+		ioc.InitJobs,
+
+		wire.Struct(new(App), "*"),
 	)
-	return gin.Default()
+	return new(App)
 }